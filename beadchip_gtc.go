@@ -584,3 +584,17 @@ func (self *GTCHeader) parseBaseCalls(file io.ReadSeeker) (*BaseCalls, error) {
 	}
 	return ret, nil
 }
+
+//ReadGTCInfo sample level info of a gtc file; the file stays open only while it is read
+func ReadGTCInfo(filename string) (*GTCInfo, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	header, err := ParserGTCHeader(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s err:%s", filename, err.Error())
+	}
+	return header.GetGTCInfo()
+}
@@ -25,15 +25,23 @@ type BoxWhiskerStat struct {
 func MeanStat(a *[]float64) (mean float64, stdev float64) {
 	sum, devsum := float64(0.0), float64(0.0)
 	arr := *a
-	num := len(arr)
-	if num == 0 {
-		return
-	}
+	num := 0
 	for _, v := range arr {
+		//NaN is a tile without data
+		if math.IsNaN(v) {
+			continue
+		}
 		sum += v
+		num++
+	}
+	if num == 0 {
+		return
 	}
 	mean = sum / float64(num)
 	for _, v := range arr {
+		if math.IsNaN(v) {
+			continue
+		}
 		b := mean - v
 		devsum += (b * b)
 	}
@@ -0,0 +1,234 @@
+package interop
+
+//bycycle.go per lane, per cycle series of the standard by-cycle plots
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+//CycleSeries lane -> cycle -> value
+type CycleSeries map[uint16]map[uint16]float64
+
+func (self CycleSeries) Lanes() []uint16 {
+	ret := []uint16{}
+	for ln := range self {
+		ret = append(ret, ln)
+	}
+	sortUint16s(ret)
+	return ret
+}
+
+func (self CycleSeries) Cycles(laneNum uint16) []uint16 {
+	ret := []uint16{}
+	for c := range self[laneNum] {
+		ret = append(ret, c)
+	}
+	sortUint16s(ret)
+	return ret
+}
+
+//WriteCSV one row per cycle, one column per lane
+func (self CycleSeries) WriteCSV(w io.Writer) error {
+	lanes := self.Lanes()
+	cycleSet := map[uint16]bool{}
+	header := []string{"Cycle"}
+	for _, ln := range lanes {
+		header = append(header, fmt.Sprintf("Lane %d", ln))
+		for c := range self[ln] {
+			cycleSet[c] = true
+		}
+	}
+	cycles := []uint16{}
+	for c := range cycleSet {
+		cycles = append(cycles, c)
+	}
+	sortUint16s(cycles)
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, c := range cycles {
+		rec := []string{strconv.Itoa(int(c))}
+		for _, ln := range lanes {
+			v, ok := self[ln][c]
+			if !ok {
+				rec = append(rec, "")
+				continue
+			}
+			rec = append(rec, strconv.FormatFloat(v, 'g', 6, 64))
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type cycleAccumulator struct {
+	sum map[uint16]map[uint16]float64
+	cnt map[uint16]map[uint16]float64
+}
+
+func newCycleAccumulator() *cycleAccumulator {
+	return &cycleAccumulator{
+		sum: make(map[uint16]map[uint16]float64),
+		cnt: make(map[uint16]map[uint16]float64),
+	}
+}
+
+func (self *cycleAccumulator) add(laneNum, cycle uint16, value, weight float64) {
+	if laneNum == 0 || cycle == 0 {
+		return
+	}
+	if _, ok := self.sum[laneNum]; !ok {
+		self.sum[laneNum] = make(map[uint16]float64)
+		self.cnt[laneNum] = make(map[uint16]float64)
+	}
+	self.sum[laneNum][cycle] += value
+	self.cnt[laneNum][cycle] += weight
+}
+
+func (self *cycleAccumulator) ratio(scale float64) CycleSeries {
+	ret := make(CycleSeries)
+	for ln, cycles := range self.sum {
+		ret[ln] = make(map[uint16]float64)
+		for c, v := range cycles {
+			if self.cnt[ln][c] == 0 {
+				continue
+			}
+			ret[ln][c] = scale * v / self.cnt[ln][c]
+		}
+	}
+	return ret
+}
+
+//Q30ByCycle percent of clusters >=Q30 per lane and cycle
+func Q30ByCycle(qInfo *QMetricsInfo) CycleSeries {
+	acc := newCycleAccumulator()
+	qInfo.EachRecord(func(laneNum uint16, tileNum uint32, cycle uint16, numClusters *[50]uint32) {
+		above, total := QscoreAbove(numClusters, Q30)
+		acc.add(laneNum, cycle, float64(above), float64(total))
+	})
+	return acc.ratio(100)
+}
+
+//MeanQscoreByCycle cluster weighted mean Q score per lane and cycle
+func MeanQscoreByCycle(qInfo *QMetricsInfo) CycleSeries {
+	acc := newCycleAccumulator()
+	qInfo.EachRecord(func(laneNum uint16, tileNum uint32, cycle uint16, numClusters *[50]uint32) {
+		for qval, n := range numClusters {
+			acc.add(laneNum, cycle, float64(qval+1)*float64(n), float64(n))
+		}
+	})
+	return acc.ratio(1)
+}
+
+//ErrorRateByCycle tile mean error rate per lane and cycle
+func ErrorRateByCycle(errInfo *ErrorInfo) CycleSeries {
+	acc := newCycleAccumulator()
+	errInfo.EachRecord(func(laneNum uint16, tileNum uint32, cycle uint16, errorRate float32) {
+		acc.add(laneNum, cycle, float64(errorRate), 1)
+	})
+	return acc.ratio(1)
+}
+
+//EachIntensity visit channel intensities of every record regardless of file version
+func (self *ExtractionInfo) EachIntensity(fn func(laneNum uint16, tileNum uint32, cycle uint16, intensity []uint16)) {
+	for _, m := range self.Metrics {
+		fn(m.LaneNum, uint32(m.TileNum), m.Cycle, []uint16{m.Intensity_A, m.Intensity_C, m.Intensity_G, m.Intensity_T})
+	}
+	for _, m := range self.Metrics3 {
+		fn(m.LaneNum, m.TileNum, m.Cycle, m.Intensity)
+	}
+}
+
+//IntensityByCycle tile mean intensity per lane and cycle; channel < 0 takes the brightest channel
+func IntensityByCycle(extractionInfo *ExtractionInfo, channel int) CycleSeries {
+	acc := newCycleAccumulator()
+	extractionInfo.EachIntensity(func(laneNum uint16, tileNum uint32, cycle uint16, intensity []uint16) {
+		if channel >= len(intensity) {
+			return
+		}
+		if channel >= 0 {
+			acc.add(laneNum, cycle, float64(intensity[channel]), 1)
+			return
+		}
+		acc.add(laneNum, cycle, float64(maxUint16(intensity)), 1)
+	})
+	return acc.ratio(1)
+}
+
+func maxUint16(arr []uint16) uint16 {
+	ret := uint16(0)
+	for _, v := range arr {
+		if v > ret {
+			ret = v
+		}
+	}
+	return ret
+}
+
+//EachFwhm visit channel FWHM of every record regardless of file version
+func (self *ExtractionInfo) EachFwhm(fn func(laneNum uint16, tileNum uint32, cycle uint16, fwhm []float32)) {
+	for _, m := range self.Metrics {
+		fn(m.LaneNum, uint32(m.TileNum), m.Cycle, []float32{m.Fwhm_A, m.Fwhm_C, m.Fwhm_G, m.Fwhm_T})
+	}
+	for _, m := range self.Metrics3 {
+		fn(m.LaneNum, m.TileNum, m.Cycle, m.Fwhm)
+	}
+}
+
+//FwhmByCycle tile mean FWHM over channels per lane and cycle
+func FwhmByCycle(extractionInfo *ExtractionInfo) CycleSeries {
+	acc := newCycleAccumulator()
+	extractionInfo.EachFwhm(func(laneNum uint16, tileNum uint32, cycle uint16, fwhm []float32) {
+		for _, v := range fwhm {
+			acc.add(laneNum, cycle, float64(v), 1)
+		}
+	})
+	return acc.ratio(1)
+}
+
+var (
+	BYCYCLE_MEAN_QSCORE = "mean_qscore"
+	BYCYCLE_FWHM        = "fwhm"
+
+	//BYCYCLE_METRICS names accepted by Run.ByCycle
+	BYCYCLE_METRICS = []string{SERIES_INTENSITY, SERIES_ERROR_RATE, SERIES_PCT_Q30, BYCYCLE_MEAN_QSCORE, BYCYCLE_FWHM}
+)
+
+//ByCycle one of BYCYCLE_METRICS
+func (self *Run) ByCycle(metric string) (CycleSeries, error) {
+	missing := func(file string) error {
+		return fmt.Errorf("%s needs %s which run %s does not have", metric, file, self.Name())
+	}
+	switch metric {
+	case SERIES_INTENSITY, BYCYCLE_FWHM:
+		if self.Extraction == nil {
+			return nil, missing("ExtractionMetricsOut.bin")
+		}
+		if metric == BYCYCLE_FWHM {
+			return FwhmByCycle(self.Extraction), nil
+		}
+		return IntensityByCycle(self.Extraction, -1), nil
+	case SERIES_ERROR_RATE:
+		if self.Error == nil {
+			return nil, missing("ErrorMetricsOut.bin")
+		}
+		return ErrorRateByCycle(self.Error), nil
+	case SERIES_PCT_Q30, BYCYCLE_MEAN_QSCORE:
+		if self.Q == nil {
+			return nil, missing("QMetricsOut.bin")
+		}
+		if metric == BYCYCLE_MEAN_QSCORE {
+			return MeanQscoreByCycle(self.Q), nil
+		}
+		return Q30ByCycle(self.Q), nil
+	}
+	return nil, fmt.Errorf("unknown by cycle metric %s, expect one of %s", metric, strings.Join(BYCYCLE_METRICS, ","))
+}
@@ -0,0 +1,524 @@
+package main
+
+//interop command line access to a run folder's InterOp metrics
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ws6/interop"
+	"github.com/ws6/interop/multiqc"
+	"github.com/ws6/interop/openmetrics"
+	"github.com/ws6/interop/plot"
+	"github.com/ws6/interop/report"
+)
+
+var (
+	EXIT_OK      = 0
+	EXIT_ERROR   = 1 //run folder or metric could not be read
+	EXIT_USAGE   = 2 //bad command line
+	EXIT_INVALID = 3 //validate found files that do not parse, or manifest -verify files missing or changed
+
+	PLOT_CHARTS = []string{"heatmap", "bycycle", "qhist", "qheatmap", "subtile"}
+)
+
+type command struct {
+	Name  string
+	Args  string
+	Usage string
+	Run   func(fs *flag.FlagSet, args []string) int
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{Name: "summary", Args: "<run folder>", Usage: "read and lane summary", Run: runSummary},
+		{Name: "index-summary", Args: "<run folder>", Usage: "per lane sample representation", Run: runIndexSummary},
+		{Name: "dumptext", Args: "<run folder>", Usage: "every record in every metric file, in Illumina InterOp dumptext layout", Run: runDumpText},
+		{Name: "imaging-table", Args: "<run folder>", Usage: "CSV of every per lane, tile and cycle metric", Run: runImagingTable},
+		{Name: "heatmap", Args: "<run folder> <metric>", Usage: "CSV of per tile values, metric one of " + strings.Join(interop.HEATMAP_METRICS, ","), Run: runHeatmap},
+		{Name: "bycycle", Args: "<run folder> <metric>", Usage: "CSV of per lane, per cycle values, metric one of " + strings.Join(interop.BYCYCLE_METRICS, ","), Run: runByCycle},
+		{Name: "qhist", Args: "<run folder>", Usage: "CSV of Q score histogram", Run: runQHist},
+		{Name: "validate", Args: "<run folder>", Usage: "check every metric file parses", Run: runValidate},
+		{Name: "report", Args: "<run folder>", Usage: "self-contained HTML run report", Run: runReport},
+		{Name: "metrics", Args: "<root>", Usage: "Prometheus gauges of the active runs under root, for node-exporter's textfile collector", Run: runMetrics},
+		{Name: "multiqc", Args: "[run folder...]", Usage: "MultiQC custom content files of run, lane, index and GTC sample QC", Run: runMultiQC},
+		{Name: "plot", Args: "<run folder> <chart>", Usage: "SVG chart, one of " + strings.Join(PLOT_CHARTS, ","), Run: runPlot},
+		{Name: "manifest", Args: "<run folder>", Usage: "JSON manifest with checksums for archiving, or -verify the run folder against one", Run: runManifest},
+	}
+}
+
+var stdout io.Writer = os.Stdout
+var stderr io.Writer = os.Stderr
+
+func usage() {
+	fmt.Fprintln(stderr, "usage: interop <command> [flags] <run folder or archive> [args]")
+	fmt.Fprintln(stderr, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(stderr, "  %-14s %s\n", c.Name, c.Usage)
+	}
+	fmt.Fprintln(stderr, "run interop <command> -h for the flags of a command")
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return EXIT_USAGE
+	}
+	if args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		usage()
+		return EXIT_OK
+	}
+	for _, c := range commands {
+		if c.Name != args[0] {
+			continue
+		}
+		fs := flag.NewFlagSet(c.Name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		fs.Usage = func() {
+			fmt.Fprintf(stderr, "usage: interop %s [flags] %s\n%s\n", c.Name, c.Args, c.Usage)
+			fs.PrintDefaults()
+		}
+		return c.Run(fs, args[1:])
+	}
+	fmt.Fprintf(stderr, "unknown command %s\n", args[0])
+	usage()
+	return EXIT_USAGE
+}
+
+//parse parse flags and check the number of positional arguments; nargs -1 takes any number
+func parse(fs *flag.FlagSet, args []string, nargs int) ([]string, int) {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil, EXIT_OK
+		}
+		return nil, EXIT_USAGE
+	}
+	if nargs >= 0 && fs.NArg() != nargs {
+		fs.Usage()
+		return nil, EXIT_USAGE
+	}
+	return fs.Args(), -1
+}
+
+//openRun runFolder may also be a .zip, .tar, .tar.gz or .tgz archive of a run folder; Close the run once done
+func openRun(runFolder string) (*interop.Run, error) {
+	if fi, err := os.Stat(runFolder); err == nil && !fi.IsDir() {
+		return interop.LoadRunArchive(runFolder)
+	}
+	return interop.LoadRun(runFolder)
+}
+
+func loadRun(runFolder string) (*interop.Run, int) {
+	r, err := openRun(runFolder)
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return nil, EXIT_ERROR
+	}
+	for name, err := range r.ParseErrors {
+		if name == "Flowcell" {
+			continue
+		}
+		fmt.Fprintf(stderr, "warning: %s err:%s\n", name, err.Error())
+	}
+	return r, -1
+}
+
+func fail(err error) int {
+	if err == nil {
+		return EXIT_OK
+	}
+	fmt.Fprintln(stderr, err.Error())
+	return EXIT_ERROR
+}
+
+func writeJson(v interface{}) int {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fail(err)
+	}
+	_, err = stdout.Write(append(b, '\n'))
+	return fail(err)
+}
+
+func runSummary(fs *flag.FlagSet, args []string) int {
+	asJson := fs.Bool("json", false, "JSON instead of a text table")
+	pos, code := parse(fs, args, 1)
+	if pos == nil {
+		return code
+	}
+	r, code := loadRun(pos[0])
+	if r == nil {
+		return code
+	}
+	defer r.Close()
+	summary := r.Summary()
+	if *asJson {
+		return writeJson(summary)
+	}
+	return fail(summary.WriteTable(stdout))
+}
+
+func runIndexSummary(fs *flag.FlagSet, args []string) int {
+	asJson := fs.Bool("json", false, "JSON instead of a text table")
+	pos, code := parse(fs, args, 1)
+	if pos == nil {
+		return code
+	}
+	r, code := loadRun(pos[0])
+	if r == nil {
+		return code
+	}
+	defer r.Close()
+	if r.Index == nil {
+		return fail(fmt.Errorf("run %s has no IndexMetricsOut.bin", r.Name()))
+	}
+	summary := r.IndexSummary()
+	if *asJson {
+		return writeJson(summary)
+	}
+	return fail(summary.WriteTable(stdout))
+}
+
+func runDumpText(fs *flag.FlagSet, args []string) int {
+	pos, code := parse(fs, args, 1)
+	if pos == nil {
+		return code
+	}
+	r, code := loadRun(pos[0])
+	if r == nil {
+		return code
+	}
+	defer r.Close()
+	return fail(r.DumpText(stdout))
+}
+
+func runImagingTable(fs *flag.FlagSet, args []string) int {
+	pos, code := parse(fs, args, 1)
+	if pos == nil {
+		return code
+	}
+	r, code := loadRun(pos[0])
+	if r == nil {
+		return code
+	}
+	defer r.Close()
+	return fail(r.ImagingTable().WriteCSV(stdout))
+}
+
+func runHeatmap(fs *flag.FlagSet, args []string) int {
+	cycle := fs.Int("cycle", 0, "cycle of per cycle metrics, 0 averages every cycle")
+	pos, code := parse(fs, args, 2)
+	if pos == nil {
+		return code
+	}
+	r, code := loadRun(pos[0])
+	if r == nil {
+		return code
+	}
+	defer r.Close()
+	hm, err := r.Heatmap(pos[1], *cycle)
+	if err != nil {
+		return fail(err)
+	}
+	return fail(hm.WriteCSV(stdout))
+}
+
+func runByCycle(fs *flag.FlagSet, args []string) int {
+	pos, code := parse(fs, args, 2)
+	if pos == nil {
+		return code
+	}
+	r, code := loadRun(pos[0])
+	if r == nil {
+		return code
+	}
+	defer r.Close()
+	series, err := r.ByCycle(pos[1])
+	if err != nil {
+		return fail(err)
+	}
+	return fail(series.WriteCSV(stdout))
+}
+
+func runQHist(fs *flag.FlagSet, args []string) int {
+	read := fs.Int("read", 0, "read number, 0 for every read")
+	pos, code := parse(fs, args, 1)
+	if pos == nil {
+		return code
+	}
+	r, code := loadRun(pos[0])
+	if r == nil {
+		return code
+	}
+	defer r.Close()
+	hist, err := r.QHistogram(*read)
+	if err != nil {
+		return fail(err)
+	}
+	return fail(hist.WriteCSV(stdout))
+}
+
+func runValidate(fs *flag.FlagSet, args []string) int {
+	pos, code := parse(fs, args, 1)
+	if pos == nil {
+		return code
+	}
+	r, err := openRun(pos[0])
+	if err != nil {
+		return fail(err)
+	}
+	defer r.Close()
+	statuses, ok := r.Validate()
+	for _, st := range statuses {
+		switch {
+		case st.Err != nil:
+			fmt.Fprintf(stdout, "FAIL\t%s\t%s\n", st.Name, st.Err.Error())
+		case st.Present:
+			fmt.Fprintf(stdout, "OK\t%s\n", st.Name)
+		default:
+			fmt.Fprintf(stdout, "MISSING\t%s\n", st.Name)
+		}
+	}
+	if !ok {
+		return EXIT_INVALID
+	}
+	return EXIT_OK
+}
+
+func runReport(fs *flag.FlagSet, args []string) int {
+	override := fs.String("template", "", "file redefining blocks of the default template, or a whole template with -full")
+	full := fs.Bool("full", false, "-template replaces the whole template")
+	out := fs.String("o", "", "output file instead of stdout")
+	pos, code := parse(fs, args, 1)
+	if pos == nil {
+		return code
+	}
+	r, code := loadRun(pos[0])
+	if r == nil {
+		return code
+	}
+	defer r.Close()
+	g := report.New()
+	if *override != "" {
+		b, err := ioutil.ReadFile(*override)
+		if err != nil {
+			return fail(err)
+		}
+		if *full {
+			err = g.ParseTemplate(string(b))
+		} else {
+			err = g.Override(string(b))
+		}
+		if err != nil {
+			return fail(fmt.Errorf("parse template %s err:%s", *override, err.Error()))
+		}
+	}
+	if *out == "" {
+		return fail(g.Write(stdout, r))
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return fail(err)
+	}
+	if err := g.Write(f, r); err != nil {
+		f.Close()
+		return fail(err)
+	}
+	return fail(f.Close())
+}
+
+func runMultiQC(fs *flag.FlagSet, args []string) int {
+	out := fs.String("o", ".", "folder the *_mqc files are written to")
+	gtc := fs.String("gtc", "", "glob of GTC files for the genotyping sample QC table")
+	tsv := fs.Bool("tsv", false, "write *_mqc.tsv instead of *_mqc.json")
+	pos, code := parse(fs, args, -1)
+	if pos == nil {
+		return code
+	}
+	if len(pos) == 0 && *gtc == "" {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	written := []string{}
+	for _, runFolder := range pos {
+		r, code := loadRun(runFolder)
+		if r == nil {
+			return code
+		}
+		files, err := multiqc.WriteFiles(*out, r.Name(), multiqc.RunSections(r), *tsv)
+		r.Close()
+		if err != nil {
+			return fail(err)
+		}
+		written = append(written, files...)
+	}
+	if *gtc != "" {
+		gtcFiles, err := filepath.Glob(*gtc)
+		if err != nil {
+			return fail(err)
+		}
+		if len(gtcFiles) == 0 {
+			return fail(fmt.Errorf("no GTC file matches %s", *gtc))
+		}
+		infos, err := multiqc.ReadGTCFiles(gtcFiles)
+		if err != nil {
+			return fail(err)
+		}
+		files, err := multiqc.WriteFiles(*out, "", []*multiqc.Section{multiqc.GTCSection(gtcFiles, infos)}, *tsv)
+		if err != nil {
+			return fail(err)
+		}
+		written = append(written, files...)
+	}
+	for _, f := range written {
+		fmt.Fprintln(stdout, f)
+	}
+	return EXIT_OK
+}
+
+func runPlot(fs *flag.FlagSet, args []string) int {
+	metric := fs.String("metric", interop.SERIES_PCT_Q30, "metric of heatmap and bycycle charts")
+	cycle := fs.Int("cycle", 0, "heatmap cycle, 0 averages every cycle")
+	read := fs.Int("read", 0, "qhist read number, 0 for every read")
+	lane := fs.Int("lane", 1, "subtile lane")
+	pos, code := parse(fs, args, 2)
+	if pos == nil {
+		return code
+	}
+	r, code := loadRun(pos[0])
+	if r == nil {
+		return code
+	}
+	defer r.Close()
+	reads := plot.ReadSpans(r.RunInfo)
+	var svg []byte
+	switch pos[1] {
+	case "heatmap":
+		h, err := r.Heatmap(*metric, *cycle)
+		if err != nil {
+			return fail(err)
+		}
+		svg = plot.FlowcellHeatmap(*metric, h)
+	case "bycycle":
+		series, err := r.ByCycle(*metric)
+		if err != nil {
+			return fail(err)
+		}
+		c := &plot.LineChart{Title: *metric + " by cycle", XLabel: "Cycle", YLabel: *metric, Series: plot.CycleSeriesLines(series), Reads: reads}
+		svg = c.SVG()
+	case "qhist":
+		h, err := r.QHistogram(*read)
+		if err != nil {
+			return fail(err)
+		}
+		svg = plot.QHistogram("Q Score Distribution", h)
+	case "qheatmap":
+		h, err := r.QHeatmap()
+		if err != nil {
+			return fail(err)
+		}
+		svg = plot.QHeatmap("Q Score Heatmap", h, reads)
+	case "subtile":
+		subtile, err := r.Subtile()
+		if err != nil {
+			return fail(err)
+		}
+		c := &plot.BoxChart{Title: fmt.Sprintf("Lane %d %% PF by Subtile X Bin", *lane), XLabel: "X Bin", YLabel: "% PF"}
+		for _, l := range subtile.SubtileLaneStat.ToJson().PF.XBinStat {
+			if int(l.LaneNum) != *lane {
+				continue
+			}
+			for _, bin := range l.BinBoxStat {
+				c.Boxes = append(c.Boxes, &plot.Box{Label: fmt.Sprintf("%d", bin.BinValue), Stat: bin.BoxWhiskerStat})
+			}
+		}
+		if len(c.Boxes) == 0 {
+			return fail(fmt.Errorf("run %s has no subtile stats of lane %d", r.Name(), *lane))
+		}
+		svg = c.SVG()
+	default:
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	_, err := stdout.Write(svg)
+	return fail(err)
+}
+
+func runMetrics(fs *flag.FlagSet, args []string) int {
+	out := fs.String("o", "", "textfile to replace atomically instead of writing to stdout")
+	all := fs.Bool("all", false, "include completed runs")
+	pos, code := parse(fs, args, 1)
+	if pos == nil {
+		return code
+	}
+	c := openmetrics.NewCollector(pos[0])
+	c.IncludeComplete = *all
+	if *out != "" {
+		return fail(c.WriteTextfile(*out))
+	}
+	families, err := c.Collect()
+	if err != nil {
+		return fail(err)
+	}
+	return fail(openmetrics.Write(stdout, families, false))
+}
+
+func runManifest(fs *flag.FlagSet, args []string) int {
+	out := fs.String("o", "", "output file instead of stdout")
+	verify := fs.String("verify", "", "manifest to check the run folder against instead of writing one")
+	pos, code := parse(fs, args, 1)
+	if pos == nil {
+		return code
+	}
+	if *verify != "" {
+		f, err := os.Open(*verify)
+		if err != nil {
+			return fail(err)
+		}
+		m, err := interop.ReadManifest(f)
+		f.Close()
+		if err != nil {
+			return fail(err)
+		}
+		problems, err := interop.VerifyManifest(pos[0], m)
+		if err != nil {
+			return fail(err)
+		}
+		for _, p := range problems {
+			fmt.Fprintf(stdout, "FAIL\t%s\n", p.String())
+		}
+		if len(problems) > 0 {
+			return EXIT_INVALID
+		}
+		fmt.Fprintf(stdout, "OK\t%s\t%d files\n", m.RunId, len(m.Files))
+		return EXIT_OK
+	}
+	m, err := interop.BuildManifest(pos[0])
+	if err != nil {
+		return fail(err)
+	}
+	if *out == "" {
+		return fail(m.Write(stdout))
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return fail(err)
+	}
+	if err := m.Write(f); err != nil {
+		f.Close()
+		return fail(err)
+	}
+	return fail(f.Close())
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testRunInfo = `<?xml version="1.0"?>
+<RunInfo>
+  <Run Id="131220_SN1_0001_AH7TESTXX" Number="1">
+    <Flowcell>H7TESTXX</Flowcell>
+    <Instrument>SN1</Instrument>
+    <Date>131220</Date>
+    <Reads>
+      <Read Number="1" NumCycles="60" IsIndexedRead="N" />
+      <Read Number="2" NumCycles="6" IsIndexedRead="Y" />
+      <Read Number="3" NumCycles="60" IsIndexedRead="N" />
+    </Reads>
+    <FlowcellLayout LaneCount="8" SurfaceCount="2" SwathCount="3" TileCount="16" />
+  </Run>
+</RunInfo>`
+
+//makeRunFolder RunInfo.xml, one lane of base calls plus the InterOp files of test_data
+func makeRunFolder(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "interop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "RunInfo.xml"), []byte(testRunInfo), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lane := filepath.Join(dir, "Data", "Intensities", "BaseCalls", "L001")
+	if err := os.MkdirAll(lane, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(lane, "s_1_1101.bcl"), []byte("bcl"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "InterOp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join("..", "..", "test_data", "InterOp")
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		b, err := ioutil.ReadFile(filepath.Join(src, f.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "InterOp", f.Name()), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestCommands(t *testing.T) {
+	dir := makeRunFolder(t)
+	defer os.RemoveAll(dir)
+	manifest := filepath.Join(dir, "manifest.json")
+
+	for _, tc := range []struct {
+		args   []string
+		code   int
+		output string
+	}{
+		{[]string{}, EXIT_USAGE, ""},
+		{[]string{"nope"}, EXIT_USAGE, ""},
+		{[]string{"summary"}, EXIT_USAGE, ""},
+		{[]string{"summary", filepath.Join(dir, "missing")}, EXIT_ERROR, ""},
+		{[]string{"summary", dir}, EXIT_OK, "Non-indexed"},
+		{[]string{"summary", "-json", dir}, EXIT_OK, `"NonIndexTotal"`},
+		{[]string{"index-summary", dir}, EXIT_OK, "% of Identified"},
+		{[]string{"dumptext", dir}, EXIT_OK, "# Tile,2"},
+		{[]string{"imaging-table", dir}, EXIT_OK, "Error Rate"},
+		{[]string{"heatmap", dir, "density"}, EXIT_OK, "Lane,Tile"},
+		{[]string{"heatmap", dir, "bogus"}, EXIT_ERROR, ""},
+		{[]string{"heatmap", dir, "pct_q30"}, EXIT_ERROR, ""},
+		{[]string{"bycycle", dir, "error_rate"}, EXIT_OK, "Cycle,Lane 1"},
+		{[]string{"qhist", dir}, EXIT_ERROR, ""},
+		{[]string{"validate", dir}, EXIT_OK, "OK\tTileMetricsOut.bin"},
+		{[]string{"report", dir}, EXIT_OK, "<svg"},
+		{[]string{"plot", "-metric", "error_rate", dir, "bycycle"}, EXIT_OK, "<polyline"},
+		{[]string{"plot", "-metric", "density", dir, "heatmap"}, EXIT_OK, "<svg"},
+		{[]string{"plot", dir, "qheatmap"}, EXIT_ERROR, ""},
+		{[]string{"plot", dir, "pie"}, EXIT_USAGE, ""},
+		{[]string{"metrics"}, EXIT_USAGE, ""},
+		{[]string{"multiqc"}, EXIT_USAGE, ""},
+		{[]string{"multiqc", "-o", dir, dir}, EXIT_OK, "interop_lane_metrics_mqc.json"},
+		{[]string{"manifest", dir}, EXIT_OK, `"SHA256"`},
+		{[]string{"manifest", "-o", manifest, dir}, EXIT_OK, ""},
+		{[]string{"manifest", "-verify", manifest, dir}, EXIT_OK, "OK\t131220_SN1_0001_AH7TESTXX"},
+	} {
+		var out, errOut bytes.Buffer
+		stdout, stderr = &out, &errOut
+		if code := run(tc.args); code != tc.code {
+			t.Fatalf("%v: expect exit %d, got %d, stderr %s", tc.args, tc.code, code, errOut.String())
+		}
+		if !strings.Contains(out.String(), tc.output) {
+			t.Fatalf("%v: expect %q in output", tc.args, tc.output)
+		}
+	}
+
+	//a broken file fails validate
+	if err := ioutil.WriteFile(filepath.Join(dir, "InterOp", "ErrorMetricsOut.bin"), []byte{3}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	stdout, stderr = &out, &out
+	if code := run([]string{"validate", dir}); code != EXIT_INVALID {
+		t.Fatalf("expect exit %d on a truncated file, got %d: %s", EXIT_INVALID, code, out.String())
+	}
+	out.Reset()
+	if code := run([]string{"manifest", "-verify", manifest, dir}); code != EXIT_INVALID || !strings.Contains(out.String(), "InterOp/ErrorMetricsOut.bin size changed") {
+		t.Fatalf("expect exit %d on a changed file, got %d: %s", EXIT_INVALID, code, out.String())
+	}
+}
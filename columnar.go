@@ -0,0 +1,407 @@
+package interop
+
+//columnar.go struct of arrays copies of the Q, error and tile metrics.
+//A []*Struct holds one heap object per record, millions for Q and error metrics of a large flowcell;
+//the columns hold a handful of slices, so a long running process keeps far fewer objects for the GC to scan.
+//Rows are sorted by lane, tile and cycle (or code) and indexed, so per lane aggregations touch only their rows.
+
+import (
+	"math"
+	"sort"
+
+	"github.com/ws6/interop/fcinfo"
+)
+
+var (
+	//Q_BINS histogram slots per Q row, Q1 through Q50 as in QMetrics.NumClusters
+	Q_BINS = 50
+)
+
+//RowRange rows Start up to, not including, End
+type RowRange struct {
+	Start int
+	End   int
+}
+
+func (self RowRange) Len() int {
+	return self.End - self.Start
+}
+
+//LaneTileColumns lane and tile columns shared by every columnar metric, with the lane and tile index
+type LaneTileColumns struct {
+	LaneNum []uint16
+	TileNum []uint32
+	lanes   map[uint16]RowRange
+	tiles   map[tileKey]RowRange
+}
+
+func (self *LaneTileColumns) Len() int {
+	return len(self.LaneNum)
+}
+
+func (self *LaneTileColumns) growLaneTile(n int) {
+	self.LaneNum = append(make([]uint16, 0, len(self.LaneNum)+n), self.LaneNum...)
+	self.TileNum = append(make([]uint32, 0, len(self.TileNum)+n), self.TileNum...)
+}
+
+func (self *LaneTileColumns) appendLaneTile(laneNum uint16, tileNum uint32) {
+	self.LaneNum = append(self.LaneNum, laneNum)
+	self.TileNum = append(self.TileNum, tileNum)
+}
+
+//compareLaneTile -1, 0 or 1 comparing rows i and j by lane then tile
+func (self *LaneTileColumns) compareLaneTile(i, j int) int {
+	switch {
+	case self.LaneNum[i] != self.LaneNum[j]:
+		if self.LaneNum[i] < self.LaneNum[j] {
+			return -1
+		}
+		return 1
+	case self.TileNum[i] != self.TileNum[j]:
+		if self.TileNum[i] < self.TileNum[j] {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+//sortRows put rows in lane, tile and key order, moving LaneNum and TileNum itself and the caller's other columns by move,
+//where row -1 is a one-row scratch the caller keeps. Rows are permuted in place, one move per misplaced row,
+//so a cycle-major file, as RTA writes, costs no second copy of its columns
+func (self *LaneTileColumns) sortRows(key []uint16, move func(dst, src int)) {
+	less := func(i, j int) bool {
+		if c := self.compareLaneTile(i, j); c != 0 {
+			return c < 0
+		}
+		return key[i] < key[j]
+	}
+	n := self.Len()
+	sorted := true
+	for i := 1; i < n && sorted; i++ {
+		sorted = !less(i, i-1)
+	}
+	if sorted {
+		return
+	}
+	//perm old row of each new row
+	perm := make([]int32, n)
+	for i := range perm {
+		perm[i] = int32(i)
+	}
+	sort.SliceStable(perm, func(a, b int) bool {
+		return less(int(perm[a]), int(perm[b]))
+	})
+	done := make([]bool, n)
+	var lane uint16
+	var tile uint32
+	for i := range perm {
+		if done[i] || int(perm[i]) == i {
+			continue
+		}
+		//walk the cycle through i: park row i, pull each new row's old row into place, put the parked row last
+		lane, tile = self.LaneNum[i], self.TileNum[i]
+		move(-1, i)
+		j := i
+		for int(perm[j]) != i {
+			k := int(perm[j])
+			self.LaneNum[j], self.TileNum[j] = self.LaneNum[k], self.TileNum[k]
+			move(j, k)
+			done[j] = true
+			j = k
+		}
+		self.LaneNum[j], self.TileNum[j] = lane, tile
+		move(j, -1)
+		done[j] = true
+	}
+}
+
+//buildIndex rows must already be sorted by lane then tile
+func (self *LaneTileColumns) buildIndex() {
+	self.lanes = make(map[uint16]RowRange)
+	self.tiles = make(map[tileKey]RowRange)
+	for i := 0; i < len(self.LaneNum); {
+		j := i
+		for j < len(self.LaneNum) && self.LaneNum[j] == self.LaneNum[i] && self.TileNum[j] == self.TileNum[i] {
+			j++
+		}
+		self.tiles[tileKey{self.LaneNum[i], self.TileNum[i]}] = RowRange{i, j}
+		lr, ok := self.lanes[self.LaneNum[i]]
+		if !ok {
+			lr.Start = i
+		}
+		lr.End = j
+		self.lanes[self.LaneNum[i]] = lr
+		i = j
+	}
+}
+
+//Lanes sorted lane numbers
+func (self *LaneTileColumns) Lanes() []uint16 {
+	ret := make([]uint16, 0, len(self.lanes))
+	for ln := range self.lanes {
+		ret = append(ret, ln)
+	}
+	sortUint16s(ret)
+	return ret
+}
+
+//Lane rows of a lane; empty if the lane has none
+func (self *LaneTileColumns) Lane(laneNum uint16) RowRange {
+	return self.lanes[laneNum]
+}
+
+//Tile rows of a tile; empty if the tile has none
+func (self *LaneTileColumns) Tile(laneNum uint16, tileNum uint32) RowRange {
+	return self.tiles[tileKey{laneNum, tileNum}]
+}
+
+//QColumns Q metrics as columns; NumClusters holds Q_BINS values per row
+type QColumns struct {
+	LaneTileColumns
+	Cycle       []uint16
+	NumClusters []uint32
+}
+
+//NewQColumns copy of every record of info, any file version; info can be dropped afterwards
+func NewQColumns(info *QMetricsInfo) *QColumns {
+	ret := new(QColumns)
+	ret.Grow(len(info.Metrics) + len(info.Metrics7))
+	info.EachRecord(func(laneNum uint16, tileNum uint32, cycle uint16, numClusters *[50]uint32) {
+		ret.Append(laneNum, tileNum, cycle, numClusters[:])
+	})
+	ret.Index()
+	return ret
+}
+
+//Grow room for n more rows, for parsers that know the record count from the file size
+func (self *QColumns) Grow(n int) {
+	self.growLaneTile(n)
+	self.Cycle = append(make([]uint16, 0, len(self.Cycle)+n), self.Cycle...)
+	self.NumClusters = append(make([]uint32, 0, len(self.NumClusters)+n*Q_BINS), self.NumClusters...)
+}
+
+//Append add a row; call Index once every row is in
+func (self *QColumns) Append(laneNum uint16, tileNum uint32, cycle uint16, numClusters []uint32) {
+	self.appendLaneTile(laneNum, tileNum)
+	self.Cycle = append(self.Cycle, cycle)
+	row := len(self.NumClusters)
+	for i := 0; i < Q_BINS; i++ {
+		self.NumClusters = append(self.NumClusters, 0)
+	}
+	copy(self.NumClusters[row:], numClusters)
+}
+
+//Index sort rows by lane, tile and cycle and rebuild the lane and tile index
+func (self *QColumns) Index() {
+	var cycle uint16
+	counts := make([]uint32, Q_BINS)
+	self.sortRows(self.Cycle, func(dst, src int) {
+		switch {
+		case src < 0:
+			self.Cycle[dst] = cycle
+			copy(self.Histogram(dst), counts)
+		case dst < 0:
+			cycle = self.Cycle[src]
+			copy(counts, self.Histogram(src))
+		default:
+			self.Cycle[dst] = self.Cycle[src]
+			copy(self.Histogram(dst), self.Histogram(src))
+		}
+	})
+	self.buildIndex()
+}
+
+//Histogram counts of row i, Q1 first; a view, not a copy
+func (self *QColumns) Histogram(i int) []uint32 {
+	return self.NumClusters[i*Q_BINS : (i+1)*Q_BINS]
+}
+
+//GetLaneSum same as QMetricsInfo.GetLaneSum, but version 7 rows count too
+func (self *QColumns) GetLaneSum(cycles fcinfo.CycleSet) map[uint16][]uint64 {
+	ret := make(map[uint16][]uint64)
+	for ln, rr := range self.lanes {
+		sum := make([]uint64, Q_BINS)
+		used := false
+		for i := rr.Start; i < rr.End; i++ {
+			if !cycles.Admits(self.Cycle[i]) {
+				continue
+			}
+			used = true
+			for qval, n := range self.Histogram(i) {
+				sum[qval] += uint64(n)
+			}
+		}
+		if used {
+			ret[ln] = sum
+		}
+	}
+	return ret
+}
+
+//ErrorColumns error metrics as columns
+type ErrorColumns struct {
+	LaneTileColumns
+	Cycle     []uint16
+	ErrorRate []float32
+}
+
+//NewErrorColumns copy of every record of info, any file version
+func NewErrorColumns(info *ErrorInfo) *ErrorColumns {
+	ret := new(ErrorColumns)
+	ret.Grow(len(info.Metrics) + len(info.Metrics4))
+	info.EachRecord(ret.Append)
+	ret.Index()
+	return ret
+}
+
+func (self *ErrorColumns) Grow(n int) {
+	self.growLaneTile(n)
+	self.Cycle = append(make([]uint16, 0, len(self.Cycle)+n), self.Cycle...)
+	self.ErrorRate = append(make([]float32, 0, len(self.ErrorRate)+n), self.ErrorRate...)
+}
+
+//Append add a row; call Index once every row is in
+func (self *ErrorColumns) Append(laneNum uint16, tileNum uint32, cycle uint16, errorRate float32) {
+	self.appendLaneTile(laneNum, tileNum)
+	self.Cycle = append(self.Cycle, cycle)
+	self.ErrorRate = append(self.ErrorRate, errorRate)
+}
+
+//Index sort rows by lane, tile and cycle and rebuild the lane and tile index
+func (self *ErrorColumns) Index() {
+	var cycle uint16
+	var rate float32
+	self.sortRows(self.Cycle, func(dst, src int) {
+		switch {
+		case src < 0:
+			self.Cycle[dst], self.ErrorRate[dst] = cycle, rate
+		case dst < 0:
+			cycle, rate = self.Cycle[src], self.ErrorRate[src]
+		default:
+			self.Cycle[dst], self.ErrorRate[dst] = self.Cycle[src], self.ErrorRate[src]
+		}
+	})
+	self.buildIndex()
+}
+
+//laneRates error rates of a lane within cycles, nil cycles takes every cycle
+func (self *ErrorColumns) laneRates(laneNum uint16, cycles fcinfo.CycleSet, fn func(v float64)) {
+	rr := self.Lane(laneNum)
+	for i := rr.Start; i < rr.End; i++ {
+		if !cycles.Admits(self.Cycle[i]) {
+			continue
+		}
+		fn(float64(self.ErrorRate[i]))
+	}
+}
+
+//GetAvgErrorRateByLane same as ErrorInfo.GetAvgErrorRateByLane
+func (self *ErrorColumns) GetAvgErrorRateByLane(laneNum uint16, cycles fcinfo.CycleSet) float64 {
+	sum, cnt := float64(0), 0
+	self.laneRates(laneNum, cycles, func(v float64) {
+		sum += v
+		cnt++
+	})
+	if cnt == 0 {
+		return float64(0.0)
+	}
+	return sum / float64(cnt)
+}
+
+//GetStatErrorRateByLane same as ErrorInfo.GetStatErrorRateByLane
+func (self *ErrorColumns) GetStatErrorRateByLane(laneNum uint16, cycles fcinfo.CycleSet) (mean float64, stdv float64) {
+	sum, cnt := float64(0), 0
+	self.laneRates(laneNum, cycles, func(v float64) {
+		sum += v
+		cnt++
+	})
+	if cnt == 0 {
+		return
+	}
+	mean = sum / float64(cnt)
+	devsum := float64(0)
+	self.laneRates(laneNum, cycles, func(v float64) {
+		devsum += (mean - v) * (mean - v)
+	})
+	stdv = math.Sqrt(devsum / float64(cnt))
+	return
+}
+
+//TileColumns tile metrics (RTA 1 and 2 layout) as columns
+type TileColumns struct {
+	LaneTileColumns
+	MetricCode  []uint16
+	MetricValue []float32
+}
+
+func NewTileColumns(info *TileInfo) *TileColumns {
+	ret := new(TileColumns)
+	ret.Grow(len(info.Metrics))
+	for _, m := range info.Metrics {
+		ret.Append(m.LaneNum, uint32(m.TileNum), m.MetricCode, m.MetricValue)
+	}
+	ret.Index()
+	return ret
+}
+
+func (self *TileColumns) Grow(n int) {
+	self.growLaneTile(n)
+	self.MetricCode = append(make([]uint16, 0, len(self.MetricCode)+n), self.MetricCode...)
+	self.MetricValue = append(make([]float32, 0, len(self.MetricValue)+n), self.MetricValue...)
+}
+
+//Append add a row; call Index once every row is in
+func (self *TileColumns) Append(laneNum uint16, tileNum uint32, code uint16, value float32) {
+	self.appendLaneTile(laneNum, tileNum)
+	self.MetricCode = append(self.MetricCode, code)
+	self.MetricValue = append(self.MetricValue, value)
+}
+
+//Index sort rows by lane, tile and code and rebuild the lane and tile index
+func (self *TileColumns) Index() {
+	var code uint16
+	var value float32
+	self.sortRows(self.MetricCode, func(dst, src int) {
+		switch {
+		case src < 0:
+			self.MetricCode[dst], self.MetricValue[dst] = code, value
+		case dst < 0:
+			code, value = self.MetricCode[src], self.MetricValue[src]
+		default:
+			self.MetricCode[dst], self.MetricValue[dst] = self.MetricCode[src], self.MetricValue[src]
+		}
+	})
+	self.buildIndex()
+}
+
+//CodeAvgByLane same as TileInfo.CodeAvgByLane
+func (self *TileColumns) CodeAvgByLane(laneNum, code uint16) float64 {
+	mean, _ := self.CodeStatByLane(laneNum, code)
+	return mean
+}
+
+//CodeStatByLane same as TileInfo.CodeStatByLane
+func (self *TileColumns) CodeStatByLane(laneNum, code uint16) (mean float64, stdev float64) {
+	rr := self.Lane(laneNum)
+	sum, count := float64(0), 0
+	for i := rr.Start; i < rr.End; i++ {
+		if self.MetricCode[i] == code {
+			sum += float64(self.MetricValue[i])
+			count++
+		}
+	}
+	if count == 0 {
+		return
+	}
+	mean = sum / float64(count)
+	devsum := float64(0)
+	for i := rr.Start; i < rr.End; i++ {
+		if self.MetricCode[i] == code {
+			b := mean - float64(self.MetricValue[i])
+			devsum += b * b
+		}
+	}
+	stdev = math.Sqrt(devsum / float64(count))
+	return
+}
@@ -0,0 +1,231 @@
+package interop
+
+import (
+	"math"
+	"runtime"
+	"testing"
+
+	"github.com/ws6/interop/fcinfo"
+)
+
+//synthQ lanes x tiles x cycles Q records, cycles outermost like RTA writes them
+func synthQ(lanes, tiles, cycles int) *QMetricsInfo {
+	ret := &QMetricsInfo{Version: 4}
+	for c := 1; c <= cycles; c++ {
+		for ln := 1; ln <= lanes; ln++ {
+			for t := 1; t <= tiles; t++ {
+				m := new(QMetrics)
+				m.LaneNum, m.TileNum, m.Cycle = uint16(ln), uint16(1100+t), uint16(c)
+				for q := range m.NumClusters {
+					m.NumClusters[q] = uint32((c*7 + ln*13 + t*17 + q*3) % 1000)
+				}
+				ret.Metrics = append(ret.Metrics, m)
+			}
+		}
+	}
+	return ret
+}
+
+func mustParseError(tb testing.TB) *ErrorInfo {
+	info := &ErrorInfo{Filename: `test_data/InterOp/ErrorMetricsOut.bin`}
+	if err := info.Parse(); err != nil {
+		tb.Fatal(err)
+	}
+	return info
+}
+
+func mustParseTile(tb testing.TB) *TileInfo {
+	info := &TileInfo{Filename: `test_data/InterOp/TileMetricsOut.bin`}
+	if err := info.Parse(); err != nil {
+		tb.Fatal(err)
+	}
+	return info
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Abs(a))
+}
+
+func TestColumnsMatchRows(t *testing.T) {
+	q := synthQ(2, 3, 10)
+	qc := NewQColumns(q)
+	cycles := fcinfo.NewCycleSet(2, 3, 9)
+	for _, cm := range []fcinfo.CycleSet{nil, cycles} {
+		want, got := q.GetLaneSum(cm), qc.GetLaneSum(cm)
+		if len(want) != len(got) {
+			t.Fatalf("lanes %d, expect %d", len(got), len(want))
+		}
+		for ln, arr := range want {
+			for i := range arr {
+				if got[ln][i] != arr[i] {
+					t.Fatalf("lane %d Q%d %d, expect %d", ln, i+1, got[ln][i], arr[i])
+				}
+			}
+		}
+	}
+	if rr := qc.Tile(2, 1103); rr.Len() != 10 || qc.Cycle[rr.Start] != 1 || qc.Cycle[rr.End-1] != 10 {
+		t.Fatalf("tile 2_1103 rows %+v", rr)
+	}
+
+	e := mustParseError(t)
+	ec := NewErrorColumns(e)
+	if ec.Len() != len(e.Metrics) {
+		t.Fatalf("error rows %d, expect %d", ec.Len(), len(e.Metrics))
+	}
+	for _, ln := range ec.Lanes() {
+		for _, cm := range []fcinfo.CycleSet{nil, cycles} {
+			if got, want := ec.GetAvgErrorRateByLane(ln, cm), e.GetAvgErrorRateByLane(ln, cm); !closeTo(got, want) {
+				t.Fatalf("lane %d error rate %f, expect %f", ln, got, want)
+			}
+			m1, s1 := ec.GetStatErrorRateByLane(ln, cm)
+			m2, s2 := e.GetStatErrorRateByLane(ln, cm)
+			if !closeTo(m1, m2) || !closeTo(s1, s2) {
+				t.Fatalf("lane %d error stat %f %f, expect %f %f", ln, m1, s1, m2, s2)
+			}
+		}
+	}
+
+	tile := mustParseTile(t)
+	tc := NewTileColumns(tile)
+	for _, ln := range tile.GetLanesSorted() {
+		for _, code := range []uint16{CLUSTER_DENSITY, NUMBER_CLUSTER_PF, PHASING_READ1, 999} {
+			m1, s1 := tc.CodeStatByLane(ln, code)
+			m2, s2 := tile.CodeStatByLane(ln, code)
+			if !closeTo(m1, m2) || !closeTo(s1, s2) {
+				t.Fatalf("lane %d code %d stat %f %f, expect %f %f", ln, code, m1, s1, m2, s2)
+			}
+		}
+	}
+}
+
+//8 lanes, 96 tiles, 150 cycles: a small HiSeq flowcell
+const benchLanes, benchTiles, benchCycles = 8, 96, 150
+
+func BenchmarkQRowsBuild(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		synthQ(benchLanes, benchTiles, benchCycles).GetLaneSum(nil)
+	}
+}
+
+//buildQColumns the synthQ records appended straight to columns, cycle-major, then indexed
+func buildQColumns(lanes, tiles, cycles int) *QColumns {
+	counts := make([]uint32, Q_BINS)
+	qc := new(QColumns)
+	qc.Grow(lanes * tiles * cycles)
+	for c := 1; c <= cycles; c++ {
+		for ln := 1; ln <= lanes; ln++ {
+			for t := 1; t <= tiles; t++ {
+				for q := range counts {
+					counts[q] = uint32((c*7 + ln*13 + t*17 + q*3) % 1000)
+				}
+				qc.Append(uint16(ln), uint32(1100+t), uint16(c), counts)
+			}
+		}
+	}
+	qc.Index()
+	return qc
+}
+
+func BenchmarkQColumnsBuild(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buildQColumns(benchLanes, benchTiles, benchCycles).GetLaneSum(nil)
+	}
+}
+
+//heapAfterGC live heap bytes once garbage is collected
+func heapAfterGC() uint64 {
+	var ms runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&ms)
+	return ms.HeapAlloc
+}
+
+//benchRetained reports the heap build leaves live, the memory a loaded run actually holds
+func benchRetained(b *testing.B, build func() interface{}) {
+	var retained uint64
+	for i := 0; i < b.N; i++ {
+		before := heapAfterGC()
+		kept := build()
+		after := heapAfterGC()
+		runtime.KeepAlive(kept)
+		if after > before {
+			retained += after - before
+		}
+	}
+	b.ReportMetric(float64(retained)/float64(b.N), "retained-B")
+}
+
+func BenchmarkQRowsRetained(b *testing.B) {
+	benchRetained(b, func() interface{} {
+		return synthQ(benchLanes, benchTiles, benchCycles)
+	})
+}
+
+func BenchmarkQColumnsRetained(b *testing.B) {
+	benchRetained(b, func() interface{} {
+		return buildQColumns(benchLanes, benchTiles, benchCycles)
+	})
+}
+
+func BenchmarkQRowsLaneSum(b *testing.B) {
+	q := synthQ(benchLanes, benchTiles, benchCycles)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q.GetLaneSum(nil)
+	}
+}
+
+func BenchmarkQColumnsLaneSum(b *testing.B) {
+	qc := NewQColumns(synthQ(benchLanes, benchTiles, benchCycles))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		qc.GetLaneSum(nil)
+	}
+}
+
+func BenchmarkErrorRowsAvgByLane(b *testing.B) {
+	e := mustParseError(b)
+	lanes := NewErrorColumns(e).Lanes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, ln := range lanes {
+			e.GetAvgErrorRateByLane(ln, nil)
+		}
+	}
+}
+
+func BenchmarkErrorColumnsAvgByLane(b *testing.B) {
+	ec := NewErrorColumns(mustParseError(b))
+	lanes := ec.Lanes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, ln := range lanes {
+			ec.GetAvgErrorRateByLane(ln, nil)
+		}
+	}
+}
+
+func BenchmarkTileRowsStatByLane(b *testing.B) {
+	tile := mustParseTile(b)
+	lanes := tile.GetLanesSorted()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, ln := range lanes {
+			tile.CodeStatByLane(ln, CLUSTER_DENSITY)
+		}
+	}
+}
+
+func BenchmarkTileColumnsStatByLane(b *testing.B) {
+	tile := mustParseTile(b)
+	tc := NewTileColumns(tile)
+	lanes := tile.GetLanesSorted()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, ln := range lanes {
+			tc.CodeStatByLane(ln, CLUSTER_DENSITY)
+		}
+	}
+}
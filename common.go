@@ -3,7 +3,10 @@ package interop
 import (
 	"bufio"
 	"encoding/binary"
+	"io"
 	"os"
+
+	"github.com/ws6/interop/fcinfo"
 )
 
 //LaneTile for common filter usage
@@ -18,7 +21,7 @@ type HeaderInfo struct {
 	Buf     *bufio.Reader
 }
 
-func GetHeader(file *os.File) (header *HeaderInfo, err error) {
+func GetHeader(file io.Reader) (header *HeaderInfo, err error) {
 	header = new(HeaderInfo)
 
 	//	defer file.Close()
@@ -49,3 +52,36 @@ func MakeLaneTileMap(tm *[]LaneTile) map[uint16]map[uint16]bool {
 	}
 	return ret
 }
+
+//PeekVersion read the first byte of an InterOp file, which is always the file version
+func PeekVersion(filename string) (uint8, error) {
+	return peekVersion(nil, filename)
+}
+
+func peekVersion(fsys fcinfo.RunFS, filename string) (uint8, error) {
+	file, err := openMetricFile(fsys, filename)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	var version uint8
+	if err := binary.Read(file, binary.LittleEndian, &version); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+//openMetricFile filename of fsys, or of the disk when fsys is nil
+func openMetricFile(fsys fcinfo.RunFS, filename string) (io.ReadCloser, error) {
+	if fsys == nil {
+		return os.Open(filename)
+	}
+	return fsys.Open(filename)
+}
+
+func statMetricFile(fsys fcinfo.RunFS, filename string) (os.FileInfo, error) {
+	if fsys == nil {
+		return os.Stat(filename)
+	}
+	return fsys.Stat(filename)
+}
@@ -0,0 +1,370 @@
+package interop
+
+//compare.go run-to-run regression diff, e.g. a rerun against the original or a new reagent lot against a baseline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"text/tabwriter"
+
+	"github.com/ws6/interop/fcinfo"
+)
+
+var (
+	COMPARE_SCOPE_LANE      = "lane"
+	COMPARE_SCOPE_READ      = "read"
+	COMPARE_SCOPE_NON_INDEX = "non_index_total"
+	COMPARE_SCOPE_TOTAL     = "total"
+
+	SERIES_INTENSITY  = "intensity"
+	SERIES_ERROR_RATE = "error_rate"
+	SERIES_PCT_Q30    = "pct_q30"
+)
+
+type SummaryDelta struct {
+	Scope       string
+	ReadA       int //0 for run totals
+	ReadB       int
+	LaneNum     uint16 //0 unless Scope is lane
+	Metric      string
+	A           float64
+	B           float64
+	Delta       float64  //B - A
+	PctChange   float64  //Delta / A %; zero when A is zero
+	PValue      *float64 `json:",omitempty"` //Welch t-test on tile values; nil when not enough tiles
+	Significant bool
+}
+
+type CycleDelta struct {
+	Series      string
+	LaneNum     uint16
+	ReadA       int
+	ReadB       int
+	CycleInRead int //1-based
+	CycleA      uint16
+	CycleB      uint16
+	A           float64
+	B           float64
+	Delta       float64
+}
+
+type IndexDelta struct {
+	LaneNum    uint16
+	SampleName string
+	IndexName  string
+	A          float64 //% of identified clusters
+	B          float64
+	Delta      float64
+	MissingIn  string `json:",omitempty"` //"A" or "B" when the sample is only in one run
+}
+
+type RunComparison struct {
+	RunA    string
+	RunB    string
+	Notes   []string //structural differences, e.g. read layout
+	Summary []*SummaryDelta
+	ByCycle []*CycleDelta
+	Index   []*IndexDelta
+}
+
+//ReadPair 1-based read numbers of two runs holding the same kind of read
+type ReadPair struct {
+	ReadA int
+	ReadB int
+}
+
+//AlignReads pair reads by kind and order: the n-th index read of A with the n-th index read of B, same for non-index reads
+func AlignReads(a, b *fcinfo.RunInfo) []ReadPair {
+	byKind := func(ri *fcinfo.RunInfo) map[bool][]int {
+		ret := map[bool][]int{}
+		for i, r := range ri.Run.Reads {
+			indexed := r.IsIndexedRead == "Y"
+			ret[indexed] = append(ret[indexed], i+1)
+		}
+		return ret
+	}
+	ka, kb := byKind(a), byKind(b)
+	ret := []ReadPair{}
+	for i, r := range a.Run.Reads {
+		indexed := r.IsIndexedRead == "Y"
+		ordinal := 0
+		for j, n := range ka[indexed] {
+			if n == i+1 {
+				ordinal = j
+			}
+		}
+		if ordinal < len(kb[indexed]) {
+			ret = append(ret, ReadPair{ReadA: i + 1, ReadB: kb[indexed][ordinal]})
+		}
+	}
+	return ret
+}
+
+func newSummaryDelta(scope string, pair ReadPair, laneNum uint16, metric string, a, b float64, tilesA, tilesB []float64) *SummaryDelta {
+	ret := &SummaryDelta{
+		Scope:   scope,
+		ReadA:   pair.ReadA,
+		ReadB:   pair.ReadB,
+		LaneNum: laneNum,
+		Metric:  metric,
+		A:       a,
+		B:       b,
+		Delta:   b - a,
+	}
+	if a != 0 {
+		ret.PctChange = 100. * ret.Delta / a
+	}
+	if tt := WelchTTest(tilesA, tilesB); tt != nil {
+		p := tt.PValue
+		ret.PValue = &p
+		ret.Significant = p < SIGNIFICANCE_ALPHA
+	}
+	return ret
+}
+
+func compareTotals(scope string, a, b *SummaryTotal) []*SummaryDelta {
+	ret := []*SummaryDelta{}
+	if a == nil || b == nil {
+		return ret
+	}
+	bm := map[string]float64{}
+	for _, mv := range b.Metrics() {
+		bm[mv.Name] = mv.Value
+	}
+	for _, mv := range a.Metrics() {
+		if v, ok := bm[mv.Name]; ok {
+			ret = append(ret, newSummaryDelta(scope, ReadPair{}, 0, mv.Name, mv.Value, v, nil, nil))
+		}
+	}
+	return ret
+}
+
+func compareSeries(name string, pairs []ReadPair, riA, riB *fcinfo.RunInfo, sa, sb CycleSeries) []*CycleDelta {
+	ret := []*CycleDelta{}
+	for _, pair := range pairs {
+		flA := riA.GetFirstLastCyclesByRead(pair.ReadA)
+		flB := riB.GetFirstLastCyclesByRead(pair.ReadB)
+		n := int(flA[1]) - int(flA[0]) + 1
+		if m := int(flB[1]) - int(flB[0]) + 1; m < n {
+			n = m
+		}
+		for _, ln := range sa.Lanes() {
+			if _, ok := sb[ln]; !ok {
+				continue
+			}
+			for off := 0; off < n; off++ {
+				ca, cb := flA[0]+uint16(off), flB[0]+uint16(off)
+				va, okA := sa[ln][ca]
+				vb, okB := sb[ln][cb]
+				if !okA || !okB {
+					continue
+				}
+				ret = append(ret, &CycleDelta{
+					Series:      name,
+					LaneNum:     ln,
+					ReadA:       pair.ReadA,
+					ReadB:       pair.ReadB,
+					CycleInRead: off + 1,
+					CycleA:      ca,
+					CycleB:      cb,
+					A:           va,
+					B:           vb,
+					Delta:       vb - va,
+				})
+			}
+		}
+	}
+	return ret
+}
+
+//compareIndex every sample of either run; a lane or sample only one run has leaves the other side zero and says which run misses it
+func compareIndex(a, b *IndexSummary) []*IndexDelta {
+	ret := []*IndexDelta{}
+	//missing deltas of the samples of la the other run lacks; lb nil when it has no such lane
+	missing := func(la, lb *IndexLaneSummary, missingIn string) {
+		for _, s := range la.Samples {
+			if lb != nil && lb.GetSample(s.SampleName, s.IndexName) != nil {
+				continue
+			}
+			d := &IndexDelta{LaneNum: la.LaneNum, SampleName: s.SampleName, IndexName: s.IndexName, MissingIn: missingIn}
+			if missingIn == "A" {
+				d.B = s.PctOfIdentified
+			} else {
+				d.A = s.PctOfIdentified
+			}
+			d.Delta = d.B - d.A
+			ret = append(ret, d)
+		}
+	}
+	for _, la := range a.Lanes {
+		lb := b.GetLane(la.LaneNum)
+		if lb != nil {
+			for _, sa := range la.Samples {
+				if sb := lb.GetSample(sa.SampleName, sa.IndexName); sb != nil {
+					ret = append(ret, &IndexDelta{
+						LaneNum:    la.LaneNum,
+						SampleName: sa.SampleName,
+						IndexName:  sa.IndexName,
+						A:          sa.PctOfIdentified,
+						B:          sb.PctOfIdentified,
+						Delta:      sb.PctOfIdentified - sa.PctOfIdentified,
+					})
+				}
+			}
+		}
+		missing(la, lb, "B")
+		if lb != nil {
+			missing(lb, la, "A")
+		}
+	}
+	for _, lb := range b.Lanes {
+		if a.GetLane(lb.LaneNum) == nil {
+			missing(lb, nil, "A")
+		}
+	}
+	return ret
+}
+
+//Compare align lanes and reads of two runs and report B - A for summary metrics, by-cycle series and index representation
+func Compare(runA, runB *Run) (*RunComparison, error) {
+	for _, run := range []*Run{runA, runB} {
+		if run.RunInfo == nil {
+			return nil, fmt.Errorf("run %s has no RunInfo", run.Name())
+		}
+	}
+	ret := &RunComparison{RunA: runA.Name(), RunB: runB.Name()}
+	if ra, rb := fcinfo.GetRunType(runA.RunInfo), fcinfo.GetRunType(runB.RunInfo); ra != rb {
+		ret.Notes = append(ret.Notes, fmt.Sprintf("read layout differs: %s vs %s", ra, rb))
+	}
+	pairs := AlignReads(runA.RunInfo, runB.RunInfo)
+	if len(pairs) != len(runA.RunInfo.Run.Reads) || len(pairs) != len(runB.RunInfo.Run.Reads) {
+		ret.Notes = append(ret.Notes, fmt.Sprintf("only %d reads could be paired", len(pairs)))
+	}
+
+	sumA, sumB := runA.Summary(), runB.Summary()
+	for _, pair := range pairs {
+		rsA, rsB := sumA.Reads[pair.ReadA-1], sumB.Reads[pair.ReadB-1]
+		for _, lsA := range rsA.Lanes {
+			lsB := rsB.GetLane(lsA.LaneNum)
+			if lsB == nil {
+				ret.Notes = append(ret.Notes, fmt.Sprintf("lane %d of read %d missing in B", lsA.LaneNum, pair.ReadA))
+				continue
+			}
+			for _, mv := range lsA.Metrics() {
+				if len(lsB.TileValues[mv.Name]) == 0 {
+					continue
+				}
+				ret.Summary = append(ret.Summary, newSummaryDelta(COMPARE_SCOPE_LANE, pair, lsA.LaneNum, mv.Name,
+					mv.Value, lsB.Value(mv.Name), lsA.TileValues[mv.Name], lsB.TileValues[mv.Name]))
+			}
+		}
+		for _, d := range compareTotals(COMPARE_SCOPE_READ, &rsA.SummaryTotal, &rsB.SummaryTotal) {
+			d.ReadA, d.ReadB = pair.ReadA, pair.ReadB
+			if tt := WelchTTest(rsA.TileValues(d.Metric), rsB.TileValues(d.Metric)); tt != nil {
+				p := tt.PValue
+				d.PValue = &p
+				d.Significant = p < SIGNIFICANCE_ALPHA
+			}
+			ret.Summary = append(ret.Summary, d)
+		}
+	}
+	ret.Summary = append(ret.Summary, compareTotals(COMPARE_SCOPE_NON_INDEX, sumA.NonIndexTotal, sumB.NonIndexTotal)...)
+	ret.Summary = append(ret.Summary, compareTotals(COMPARE_SCOPE_TOTAL, sumA.Total, sumB.Total)...)
+
+	ret.ByCycle = []*CycleDelta{}
+	if runA.Extraction != nil && runB.Extraction != nil {
+		ret.ByCycle = append(ret.ByCycle, compareSeries(SERIES_INTENSITY, pairs, runA.RunInfo, runB.RunInfo,
+			IntensityByCycle(runA.Extraction, -1), IntensityByCycle(runB.Extraction, -1))...)
+	}
+	if runA.Error != nil && runB.Error != nil {
+		ret.ByCycle = append(ret.ByCycle, compareSeries(SERIES_ERROR_RATE, pairs, runA.RunInfo, runB.RunInfo,
+			ErrorRateByCycle(runA.Error), ErrorRateByCycle(runB.Error))...)
+	}
+	if runA.Q != nil && runB.Q != nil {
+		ret.ByCycle = append(ret.ByCycle, compareSeries(SERIES_PCT_Q30, pairs, runA.RunInfo, runB.RunInfo,
+			Q30ByCycle(runA.Q), Q30ByCycle(runB.Q))...)
+	}
+
+	ret.Index = compareIndex(runA.IndexSummary(), runB.IndexSummary())
+	return ret, nil
+}
+
+func (self *RunComparison) ToJson() ([]byte, error) {
+	return json.MarshalIndent(self, "", "  ")
+}
+
+func formatPValue(p *float64) string {
+	if p == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.3g", *p)
+}
+
+//cycleDeltaDigest mean and largest delta of one series, lane and read
+type cycleDeltaDigest struct {
+	Series  string
+	LaneNum uint16
+	ReadA   int
+	Cycles  int
+	Sum     float64
+	Max     *CycleDelta
+}
+
+//WriteTable aligned text tables; by-cycle series are digested to mean and largest delta per read and lane
+func (self *RunComparison) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "A: %s\nB: %s\n", self.RunA, self.RunB)
+	for _, n := range self.Notes {
+		fmt.Fprintf(tw, "note: %s\n", n)
+	}
+
+	fmt.Fprintln(tw, "\nScope\tRead\tLane\tMetric\tA\tB\tDelta\t%Change\tp\t\t")
+	for _, d := range self.Summary {
+		read, lane, sig := "-", "-", ""
+		if d.ReadA > 0 {
+			read = fmt.Sprintf("%d/%d", d.ReadA, d.ReadB)
+		}
+		if d.LaneNum > 0 {
+			lane = fmt.Sprintf("%d", d.LaneNum)
+		}
+		if d.Significant {
+			sig = "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%.4g\t%.4g\t%+.4g\t%+.2f\t%s\t%s\t\n",
+			d.Scope, read, lane, d.Metric, d.A, d.B, d.Delta, d.PctChange, formatPValue(d.PValue), sig)
+	}
+
+	digests := []*cycleDeltaDigest{}
+	index := map[string]*cycleDeltaDigest{}
+	for _, d := range self.ByCycle {
+		k := fmt.Sprintf("%s/%d/%d", d.Series, d.ReadA, d.LaneNum)
+		dg, ok := index[k]
+		if !ok {
+			dg = &cycleDeltaDigest{Series: d.Series, LaneNum: d.LaneNum, ReadA: d.ReadA}
+			index[k] = dg
+			digests = append(digests, dg)
+		}
+		dg.Cycles++
+		dg.Sum += d.Delta
+		if dg.Max == nil || math.Abs(d.Delta) > math.Abs(dg.Max.Delta) {
+			dg.Max = d
+		}
+	}
+	fmt.Fprintln(tw, "\nSeries\tRead\tLane\tCycles\tMean Delta\tMax Delta\tAt Cycle\t")
+	for _, dg := range digests {
+		fmt.Fprintf(tw, "%s\t%d/%d\t%d\t%d\t%+.4g\t%+.4g\t%d\t\n",
+			dg.Series, dg.Max.ReadA, dg.Max.ReadB, dg.LaneNum, dg.Cycles, dg.Sum/float64(dg.Cycles), dg.Max.Delta, dg.Max.CycleInRead)
+	}
+
+	fmt.Fprintln(tw, "\nLane\tSample\tIndex\tA %\tB %\tDelta\tMissing\t")
+	for _, d := range self.Index {
+		missing := d.MissingIn
+		if missing == "" {
+			missing = "-"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%.4f\t%.4f\t%+.4f\t%s\t\n", d.LaneNum, d.SampleName, d.IndexName, d.A, d.B, d.Delta, missing)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,164 @@
+package interop
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/ws6/interop/fcinfo"
+)
+
+func TestCompare(t *testing.T) {
+	runInfo, err := fcinfo.ParseRunInfoXML(testRunInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tileInfo := &TileInfo{Filename: `test_data/InterOp/TileMetricsOut.bin`}
+	if err := tileInfo.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	indexInfo := &IndexInfo{Filename: `test_data/InterOp/IndexMetricsOut.bin`}
+	if err := indexInfo.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	runA := &Run{RunFolder: "A", RunInfo: runInfo, Tile: tileInfo, Index: indexInfo, Q: makeDecayQMetrics(runInfo)}
+	//B loses 5% Q30 on every cycle
+	qB := makeDecayQMetrics(runInfo)
+	for _, m := range qB.Metrics {
+		moved := m.NumClusters[35] / 20
+		m.NumClusters[35] -= moved
+		m.NumClusters[15] += moved
+	}
+	runB := &Run{RunFolder: "B", RunInfo: runInfo, Tile: tileInfo, Index: indexInfo, Q: qB}
+
+	same, err := Compare(runA, runA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range same.Summary {
+		if d.Delta != 0 {
+			t.Fatalf("self compare %s %s lane %d: delta %f", d.Scope, d.Metric, d.LaneNum, d.Delta)
+		}
+	}
+	for _, d := range same.Index {
+		if d.Delta != 0 || d.MissingIn != "" {
+			t.Fatalf("self compare index %s: %+v", d.SampleName, d)
+		}
+	}
+
+	cmp, err := Compare(runA, runB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmp.ByCycle) != 126 {
+		t.Fatalf("expect 126 q30 cycle deltas, got %d", len(cmp.ByCycle))
+	}
+	for _, d := range cmp.ByCycle {
+		if d.Series != SERIES_PCT_Q30 || d.Delta >= 0 {
+			t.Fatalf("expect Q30 drop at cycle %d, got %+v", d.CycleA, d)
+		}
+	}
+	var buf bytes.Buffer
+	if err := cmp.WriteTable(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cmp.ToJson(); err != nil {
+		t.Fatal(err)
+	}
+
+	//lanes and samples of either run show up, the missing side left empty
+	ia := &IndexSummary{Lanes: []*IndexLaneSummary{
+		{LaneNum: 1, Samples: []*IndexSampleSummary{{SampleName: "s1", PctOfIdentified: 60}, {SampleName: "s2", PctOfIdentified: 40}}},
+		{LaneNum: 2, Samples: []*IndexSampleSummary{{SampleName: "s3", PctOfIdentified: 100}}},
+	}}
+	ib := &IndexSummary{Lanes: []*IndexLaneSummary{
+		{LaneNum: 1, Samples: []*IndexSampleSummary{{SampleName: "s1", PctOfIdentified: 50}, {SampleName: "s4", PctOfIdentified: 50}}},
+		{LaneNum: 3, Samples: []*IndexSampleSummary{{SampleName: "s5", PctOfIdentified: 100}}},
+	}}
+	want := map[string]IndexDelta{
+		"s1": {LaneNum: 1, SampleName: "s1", A: 60, B: 50, Delta: -10},
+		"s2": {LaneNum: 1, SampleName: "s2", A: 40, Delta: -40, MissingIn: "B"},
+		"s4": {LaneNum: 1, SampleName: "s4", B: 50, Delta: 50, MissingIn: "A"},
+		"s3": {LaneNum: 2, SampleName: "s3", A: 100, Delta: -100, MissingIn: "B"},
+		"s5": {LaneNum: 3, SampleName: "s5", B: 100, Delta: 100, MissingIn: "A"},
+	}
+	deltas := compareIndex(ia, ib)
+	if len(deltas) != len(want) {
+		t.Fatalf("%d index deltas, expect %d", len(deltas), len(want))
+	}
+	for _, d := range deltas {
+		if *d != want[d.SampleName] {
+			t.Fatalf("index delta %+v, expect %+v", *d, want[d.SampleName])
+		}
+	}
+}
+
+const testCompareRunInfo = `<?xml version="1.0"?>
+<RunInfo>
+  <Run Id="140101_SN1_0001_AH7CMPXX" Number="1">
+    <Flowcell>H7CMPXX</Flowcell>
+    <Instrument>SN1</Instrument>
+    <Date>140101</Date>
+    <Reads>
+      <Read Number="1" NumCycles="36" IsIndexedRead="N" />
+    </Reads>
+    <FlowcellLayout LaneCount="1" SurfaceCount="1" SwathCount="1" TileCount="2" />
+  </Run>
+</RunInfo>`
+
+//densityTiles two tiles of lane 1 with the given cluster densities in k/mm2
+func densityTiles(d1, d2 float32) *TileInfo {
+	return &TileInfo{Metrics: []*TileMetrics{
+		{LaneNum: 1, TileNum: 1101, MetricCode: CLUSTER_DENSITY, MetricValue: d1 * 1000},
+		{LaneNum: 1, TileNum: 1102, MetricCode: CLUSTER_DENSITY, MetricValue: d2 * 1000},
+	}}
+}
+
+func TestCompareSignificance(t *testing.T) {
+	runInfo, err := fcinfo.ParseRunInfoXML(testCompareRunInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//two tiles a side with equal variance give Welch df 2, whose tail has the closed form P(|T|>=t) = 1 - t/sqrt(t^2+2);
+	//densities 100,102 vs 110,112 give t = 10/sqrt(2)
+	runA := &Run{RunFolder: "A", RunInfo: runInfo, Tile: densityTiles(100, 102)}
+	runB := &Run{RunFolder: "B", RunInfo: runInfo, Tile: densityTiles(110, 112)}
+	cmp, err := Compare(runA, runB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tt := 10 / math.Sqrt(2)
+	want := 1 - tt/math.Sqrt(tt*tt+2)
+	var found *SummaryDelta
+	for _, d := range cmp.Summary {
+		if d.Scope == COMPARE_SCOPE_LANE && d.Metric == SUMMARY_DENSITY {
+			found = d
+		}
+	}
+	if found == nil || found.PValue == nil {
+		t.Fatalf("expect a lane density delta with a p-value, got %+v", found)
+	}
+	if math.Abs(found.Delta-10) > 1e-9 || math.Abs(*found.PValue-want) > 1e-9 || !found.Significant {
+		t.Fatalf("expect delta 10 and p %f significant, got %+v p %f", want, found, *found.PValue)
+	}
+
+	//a difference of 2 against the same spread gives t = sqrt(2), p = 1 - 1/sqrt(2), not significant
+	cmp, err = Compare(runA, &Run{RunFolder: "C", RunInfo: runInfo, Tile: densityTiles(102, 104)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range cmp.Summary {
+		if d.Scope == COMPARE_SCOPE_LANE && d.Metric == SUMMARY_DENSITY {
+			if d.PValue == nil || math.Abs(*d.PValue-(1-1/math.Sqrt(2))) > 1e-9 || d.Significant {
+				t.Fatalf("expect p %f not significant, got %+v", 1-1/math.Sqrt(2), d)
+			}
+		}
+	}
+
+	if _, err := Compare(runA, &Run{RunFolder: "dump"}); err == nil {
+		t.Errorf("expect an error comparing with a run without RunInfo")
+	}
+	if s := (&Run{RunFolder: "dump"}).Summary(); len(s.Reads) != 0 || s.Total == nil {
+		t.Errorf("expect an empty summary without RunInfo, got %+v", s)
+	}
+}
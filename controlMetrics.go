@@ -3,7 +3,8 @@ package interop
 import (
 	"bufio"
 	"encoding/binary"
-	"os"
+
+	"github.com/ws6/interop/fcinfo"
 )
 
 type ControlMetrics struct {
@@ -23,13 +24,14 @@ type ControlInfo struct {
 	SSize    uint8
 	Metrics  []*ControlMetrics
 	err      error
+	fsys     fcinfo.RunFS //nil reads Filename from disk
 }
 
 func (self *ControlInfo) Parse() error {
 	if self.err != nil {
 		return self.err
 	}
-	file, err := os.Open(self.Filename)
+	file, err := openMetricFile(self.fsys, self.Filename)
 	if err != nil {
 		self.err = err
 		return self.err
@@ -2,7 +2,8 @@ package interop
 
 import (
 	"encoding/binary"
-	"os"
+
+	"github.com/ws6/interop/fcinfo"
 )
 
 type CorrectIntMetrics struct {
@@ -32,13 +33,14 @@ type CorrectIntInfo struct {
 	SSize    uint8
 	Metrics  []*CorrectIntMetrics
 	err      error
+	fsys     fcinfo.RunFS //nil reads Filename from disk
 }
 
 func (self *CorrectIntInfo) Parse() error {
 	if self.err != nil {
 		return self.err
 	}
-	file, err := os.Open(self.Filename)
+	file, err := openMetricFile(self.fsys, self.Filename)
 	if err != nil {
 		self.err = err
 		return self.err
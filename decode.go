@@ -0,0 +1,455 @@
+package interop
+
+//decode.go reflection free parsers of the fixed size record files.
+//Files are read a block at a time and records decoded with binary.LittleEndian on byte slices;
+//records are allocated in slabs, so a file costs a few allocations instead of one per record.
+//Results match the binary.Read parsers, except a cut off last record is always io.ErrUnexpectedEOF,
+//where some binary.Read parsers drop it silently.
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/ws6/interop/fcinfo"
+)
+
+var (
+	DECODE_BLOCK_SIZE = 1 << 20 //bytes read at once
+	DECODE_SLAB       = 4096    //records allocated at once
+)
+
+var le = binary.LittleEndian
+
+func f32(b []byte) float32 {
+	return math.Float32frombits(le.Uint32(b))
+}
+
+//recordScanner fixed size records of a reader
+type recordScanner struct {
+	r    io.Reader
+	size int
+	buf  []byte
+	pos  int
+	end  int
+	done bool
+	err  error
+}
+
+func newRecordScanner(r io.Reader, size int) *recordScanner {
+	n := DECODE_BLOCK_SIZE / size
+	if n < 1 {
+		n = 1
+	}
+	return &recordScanner{r: r, size: size, buf: make([]byte, n*size)}
+}
+
+//Next the next record, valid until the following call; nil at the end, then see Err
+func (self *recordScanner) Next() []byte {
+	if self.end-self.pos < self.size {
+		if self.done {
+			return nil
+		}
+		self.fill()
+		if self.end-self.pos < self.size {
+			return nil
+		}
+	}
+	rec := self.buf[self.pos : self.pos+self.size]
+	self.pos += self.size
+	return rec
+}
+
+func (self *recordScanner) fill() {
+	left := copy(self.buf, self.buf[self.pos:self.end])
+	n, err := io.ReadFull(self.r, self.buf[left:])
+	self.pos, self.end = 0, left+n
+	if err == nil {
+		return
+	}
+	self.done = true
+	if err != io.EOF && err != io.ErrUnexpectedEOF {
+		self.err = err
+		return
+	}
+	if self.end%self.size != 0 {
+		self.err = io.ErrUnexpectedEOF
+	}
+}
+
+//Err nil after a clean end of file
+func (self *recordScanner) Err() error {
+	return self.err
+}
+
+//openRecords file positioned after its version and record size bytes; hint is the record count the file size allows
+func openRecords(fsys fcinfo.RunFS, filename string, size int) (io.ReadCloser, *HeaderInfo, int, error) {
+	file, err := openMetricFile(fsys, filename)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	header, err := GetHeader(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, 0, err
+	}
+	hint := 0
+	if fi, err := statMetricFile(fsys, filename); err == nil {
+		hint = int(fi.Size()) / size
+	}
+	return file, header, hint, nil
+}
+
+func slabSize(hint, have int) int {
+	if n := hint - have; n > 0 && n < DECODE_SLAB*16 {
+		return n
+	}
+	return DECODE_SLAB
+}
+
+//ParseFast same as Parse, or ParseRTA3 for version 3 files
+func (self *TileInfo) ParseFast() error {
+	version, err := peekVersion(self.fsys, self.Filename)
+	if err != nil {
+		return err
+	}
+	if version == 3 {
+		return self.parseFast3()
+	}
+	file, header, hint, err := openRecords(self.fsys, self.Filename, 10)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	self.Version = header.Version
+	self.SSize = header.SSize
+	self.Metrics = make([]*TileMetrics, 0, hint)
+	var slab []TileMetrics
+	sc := newRecordScanner(header.Buf, 10)
+	for rec := sc.Next(); rec != nil; rec = sc.Next() {
+		if len(slab) == 0 {
+			slab = make([]TileMetrics, slabSize(hint, len(self.Metrics)))
+		}
+		m := &slab[0]
+		slab = slab[1:]
+		m.LaneNum = le.Uint16(rec)
+		m.TileNum = le.Uint16(rec[2:])
+		m.MetricCode = le.Uint16(rec[4:])
+		m.MetricValue = f32(rec[6:])
+		self.Metrics = append(self.Metrics, m)
+	}
+	return sc.Err()
+}
+
+//parseFast3 RTA3 layout, every record 15 bytes
+func (self *TileInfo) parseFast3() error {
+	file, header, hint, err := openRecords(self.fsys, self.Filename, 15)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	self.Version = header.Version
+	self.SSize = header.SSize
+	if err := binary.Read(header.Buf, le, &self.AreaSize); err != nil {
+		return err
+	}
+	self.Metrics3 = make([]*TileMetrics3, 0, hint)
+	var slab []TileMetrics3
+	sc := newRecordScanner(header.Buf, 15)
+	for rec := sc.Next(); rec != nil; rec = sc.Next() {
+		if len(slab) == 0 {
+			slab = make([]TileMetrics3, slabSize(hint, len(self.Metrics3)))
+		}
+		m := &slab[0]
+		slab = slab[1:]
+		m.LaneNum = le.Uint16(rec)
+		m.TileNum = le.Uint32(rec[2:])
+		m.MetricCode = rec[6]
+		switch m.MetricCode {
+		case 't':
+			m.ClusterCount = f32(rec[7:])
+			m.PFClusterCount = f32(rec[11:])
+		case 'r':
+			m.NumberRead = le.Uint32(rec[7:])
+			m.PctAligned = f32(rec[11:])
+		}
+		self.Metrics3 = append(self.Metrics3, m)
+	}
+	return sc.Err()
+}
+
+//ParseFast same as Parse, versions 3 and 4
+func (self *ErrorInfo) ParseFast() error {
+	version, err := peekVersion(self.fsys, self.Filename)
+	if err != nil {
+		return err
+	}
+	size := 30
+	if version == 4 {
+		size = 12
+	}
+	file, header, hint, err := openRecords(self.fsys, self.Filename, size)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	self.Version = header.Version
+	self.SSize = header.SSize
+	sc := newRecordScanner(header.Buf, size)
+	if version == 4 {
+		self.Metrics4 = make([]*ErrorMetrics4, 0, hint)
+		var slab []ErrorMetrics4
+		for rec := sc.Next(); rec != nil; rec = sc.Next() {
+			if len(slab) == 0 {
+				slab = make([]ErrorMetrics4, slabSize(hint, len(self.Metrics4)))
+			}
+			m := &slab[0]
+			slab = slab[1:]
+			m.LaneNum = le.Uint16(rec)
+			m.TileNum = le.Uint32(rec[2:])
+			m.Cycle = le.Uint16(rec[6:])
+			m.ErrorRate = f32(rec[8:])
+			self.Metrics4 = append(self.Metrics4, m)
+		}
+		return sc.Err()
+	}
+	self.Metrics = make([]*ErrorMetrics, 0, hint)
+	var slab []ErrorMetrics
+	for rec := sc.Next(); rec != nil; rec = sc.Next() {
+		if len(slab) == 0 {
+			slab = make([]ErrorMetrics, slabSize(hint, len(self.Metrics)))
+		}
+		m := &slab[0]
+		slab = slab[1:]
+		m.LaneNum = le.Uint16(rec)
+		m.TileNum = le.Uint16(rec[2:])
+		m.Cycle = le.Uint16(rec[4:])
+		m.ErrorRate = f32(rec[6:])
+		m.NumPerfectReads = le.Uint32(rec[10:])
+		m.Num_1_Error = le.Uint32(rec[14:])
+		m.Num_2_Error = le.Uint32(rec[18:])
+		m.Num_3_Error = le.Uint32(rec[22:])
+		m.Num_4_Error = le.Uint32(rec[26:])
+		self.Metrics = append(self.Metrics, m)
+	}
+	return sc.Err()
+}
+
+//ParseFast same as Parse, or Parse3 for version 3 files
+func (self *ExtractionInfo) ParseFast() error {
+	version, err := peekVersion(self.fsys, self.Filename)
+	if err != nil {
+		return err
+	}
+	if version == 3 {
+		return self.parseFast3()
+	}
+	file, header, hint, err := openRecords(self.fsys, self.Filename, 38)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	self.Version = header.Version
+	self.SSize = header.SSize
+	self.Metrics = make([]*ExtractionMetrics, 0, hint)
+	var slab []ExtractionMetrics
+	sc := newRecordScanner(header.Buf, 38)
+	for rec := sc.Next(); rec != nil; rec = sc.Next() {
+		if len(slab) == 0 {
+			slab = make([]ExtractionMetrics, slabSize(hint, len(self.Metrics)))
+		}
+		m := &slab[0]
+		slab = slab[1:]
+		m.LaneNum = le.Uint16(rec)
+		m.TileNum = le.Uint16(rec[2:])
+		m.Cycle = le.Uint16(rec[4:])
+		m.Fwhm_A = f32(rec[6:])
+		m.Fwhm_C = f32(rec[10:])
+		m.Fwhm_G = f32(rec[14:])
+		m.Fwhm_T = f32(rec[18:])
+		m.Intensity_A = le.Uint16(rec[22:])
+		m.Intensity_C = le.Uint16(rec[24:])
+		m.Intensity_G = le.Uint16(rec[26:])
+		m.Intensity_T = le.Uint16(rec[28:])
+		m.CIF_TIME = WinToUnixTimeStamp(le.Uint64(rec[30:]))
+		self.Metrics = append(self.Metrics, m)
+	}
+	return sc.Err()
+}
+
+//parseFast3 NovaSeq layout, lane, tile and cycle then FWHM and intensity of each channel
+func (self *ExtractionInfo) parseFast3() error {
+	file, header, _, err := openRecords(self.fsys, self.Filename, 1)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	self.Version = header.Version
+	self.SSize = header.SSize
+	if err := binary.Read(header.Buf, le, &self.NumChannels); err != nil {
+		return err
+	}
+	channels := int(self.NumChannels)
+	size := 8 + 6*channels
+	var slab []ExtractionMetricsV3
+	var fwhm []float32
+	var intensity []uint16
+	sc := newRecordScanner(header.Buf, size)
+	for rec := sc.Next(); rec != nil; rec = sc.Next() {
+		if len(slab) == 0 {
+			slab = make([]ExtractionMetricsV3, DECODE_SLAB)
+			fwhm = make([]float32, DECODE_SLAB*channels)
+			intensity = make([]uint16, DECODE_SLAB*channels)
+		}
+		m := &slab[0]
+		slab = slab[1:]
+		m.LaneNum = le.Uint16(rec)
+		m.TileNum = le.Uint32(rec[2:])
+		m.Cycle = le.Uint16(rec[6:])
+		if channels > 0 {
+			m.Fwhm, fwhm = fwhm[:channels:channels], fwhm[channels:]
+			m.Intensity, intensity = intensity[:channels:channels], intensity[channels:]
+			for i := 0; i < channels; i++ {
+				m.Fwhm[i] = f32(rec[8+4*i:])
+				m.Intensity[i] = le.Uint16(rec[8+4*channels+2*i:])
+			}
+		}
+		self.Metrics3 = append(self.Metrics3, m)
+	}
+	return sc.Err()
+}
+
+//ParseFast same as Parse, versions 4 to 7 with or without Q binning
+func (self *QMetricsInfo) ParseFast() error {
+	file, header, _, err := openRecords(self.fsys, self.Filename, 1)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	self.Version = header.Version
+	self.SSize = header.SSize
+	self.EnableQbin = false
+
+	if self.Version >= 5 {
+		var enableQbined uint8
+		if err := binary.Read(header.Buf, le, &enableQbined); err != nil {
+			return err
+		}
+		self.EnableQbin = enableQbined == 1
+	}
+	if !self.EnableQbin {
+		return self.decodeQ(header.Buf, 6, 50, nil)
+	}
+	switch self.Version {
+	case 5:
+		if err := self.ParseQbinConfig(header.Buf); err != nil {
+			return err
+		}
+		return self.decodeQ(header.Buf, 6, 50, nil)
+	case 6:
+		if err := self.ParseQbinConfig(header.Buf); err != nil {
+			return err
+		}
+	default:
+		if err := self.ParseQbinConfig7(header.Buf); err != nil {
+			return err
+		}
+	}
+	if err := self.ValidateQbinConfig(); err != nil {
+		return err
+	}
+	for _, q := range self.QbinConfig.ReMapScores {
+		if int(q) >= Q_BINS {
+			return fmt.Errorf("remap score %d >=%d", q, Q_BINS)
+		}
+	}
+	ltc := 6
+	if self.Version == 7 {
+		ltc = 8
+	}
+	return self.decodeQ(header.Buf, ltc, int(self.NumQscores), self.QbinConfig.ReMapScores)
+}
+
+//decodeQ records of ltc bytes of lane, tile and cycle, then bins counts; remap nil means Q1 to Q50 in order
+func (self *QMetricsInfo) decodeQ(r *bufio.Reader, ltc, bins int, remap []uint8) error {
+	var slab []QMetrics
+	var slab7 []QMetrics7
+	sc := newRecordScanner(r, ltc+4*bins)
+	for rec := sc.Next(); rec != nil; rec = sc.Next() {
+		var counts *[50]uint32
+		if ltc == 8 {
+			if len(slab7) == 0 {
+				slab7 = make([]QMetrics7, DECODE_SLAB)
+			}
+			m := &slab7[0]
+			slab7 = slab7[1:]
+			m.LaneNum = le.Uint16(rec)
+			m.TileNum = le.Uint32(rec[2:])
+			m.Cycle = le.Uint16(rec[6:])
+			counts = &m.NumClusters
+			self.Metrics7 = append(self.Metrics7, m)
+		} else {
+			if len(slab) == 0 {
+				slab = make([]QMetrics, DECODE_SLAB)
+			}
+			m := &slab[0]
+			slab = slab[1:]
+			m.LaneNum = le.Uint16(rec)
+			m.TileNum = le.Uint16(rec[2:])
+			m.Cycle = le.Uint16(rec[4:])
+			counts = &m.NumClusters
+			self.Metrics = append(self.Metrics, m)
+		}
+		for i := 0; i < bins; i++ {
+			q := i
+			if remap != nil {
+				q = int(remap[i])
+			}
+			counts[q] = le.Uint32(rec[ltc+4*i:])
+		}
+	}
+	return sc.Err()
+}
+
+//ParseFast same as Parse
+func (self *PFMetricsInfo) ParseFast() error {
+	file, err := openMetricFile(self.fsys, self.Filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	buffer := bufio.NewReader(file)
+	head := make([]byte, 11)
+	if _, err := io.ReadFull(buffer, head); err != nil {
+		return err
+	}
+	self.Version = head[0]
+	self.SSize = le.Uint16(head[1:])
+	self.NumX = le.Uint16(head[3:])
+	self.NumY = le.Uint16(head[5:])
+	self.BinArea = f32(head[7:])
+	numSubTiles := int(self.NumX * self.NumY)
+	var slab []PFSubTileMetrics
+	sc := newRecordScanner(buffer, 4+8*numSubTiles)
+	for rec := sc.Next(); rec != nil; rec = sc.Next() {
+		if len(slab) == 0 {
+			slab = make([]PFSubTileMetrics, DECODE_SLAB)
+		}
+		m := &slab[0]
+		slab = slab[1:]
+		m.LaneNum = le.Uint16(rec)
+		m.TileNum = le.Uint16(rec[2:])
+		if numSubTiles > 0 {
+			counts := make([]uint32, 2*numSubTiles)
+			for i := range counts {
+				counts[i] = le.Uint32(rec[4+4*i:])
+			}
+			m.RawCluster = counts[:numSubTiles:numSubTiles]
+			m.PFCluster = counts[numSubTiles:]
+		}
+		self.Metrics = append(self.Metrics, m)
+	}
+	return sc.Err()
+}
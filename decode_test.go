@@ -0,0 +1,356 @@
+package interop
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/ws6/interop/fcinfo"
+	"github.com/ws6/interop/synthrun"
+)
+
+//writeBin file of the little endian encoding of values, in order
+func writeBin(t *testing.T, dir, name string, values ...interface{}) string {
+	var b bytes.Buffer
+	for _, v := range values {
+		if err := binary.Write(&b, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	filename := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(filename, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+//qbinRecords lane, tile and cycle of ltc type followed by 3 binned counts, for 2 tiles and 3 cycles
+func qbinRecords(ltc func(tile, cycle int) interface{}) []interface{} {
+	ret := []interface{}{}
+	for c := 1; c <= 3; c++ {
+		for tile := 1101; tile <= 1102; tile++ {
+			ret = append(ret, ltc(tile, c), []uint32{uint32(c), uint32(tile), uint32(c * tile)})
+		}
+	}
+	return ret
+}
+
+func TestParseFastMatchesParse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "interop-decode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tileFile := "test_data/InterOp/TileMetricsOut.bin"
+	errorFile := "test_data/InterOp/ErrorMetricsOut.bin"
+	extractionFile := "test_data/InterOp/ExtractionMetricsOut.bin"
+	qv4 := []interface{}{uint8(4), uint8(206)}
+	for _, m := range synthQ(2, 2, 3).Metrics {
+		qv4 = append(qv4, m)
+	}
+	ltc := func(tile, cycle int) interface{} { return LTC{1, uint16(tile), uint16(cycle)} }
+	ltc3 := func(tile, cycle int) interface{} { return LTC3{1, uint32(tile), uint16(cycle)} }
+	qv6 := append([]interface{}{uint8(6), uint8(18), uint8(1), uint8(3), []uint8{2, 10, 30}, []uint8{9, 29, 45}, []uint8{7, 19, 37}}, qbinRecords(ltc)...)
+	qv7 := append([]interface{}{uint8(7), uint8(20), uint8(1), uint8(3), []uint8{2, 9, 7, 10, 29, 19, 30, 45, 37}}, qbinRecords(ltc3)...)
+	tile3 := []interface{}{uint8(3), uint8(15), float32(2.5),
+		LT{1, 1101}, uint8('t'), Cluster{1000, 800},
+		LT{1, 1101}, uint8('r'), ReadAlignment{1, 0.5},
+		LT{1, 1102}, uint8(0), Padding{}}
+	extraction3 := []interface{}{uint8(3), uint8(0), uint8(2),
+		LTC3{1, 1101, 1}, []float32{2.1, 2.2}, []uint16{300, 400},
+		LTC3{1, 1101, 2}, []float32{2.3, 2.4}, []uint16{500, 600}}
+	error4 := []interface{}{uint8(4), uint8(12), ErrorMetrics4{1, 1101, 1, 0.25}, ErrorMetrics4{2, 2202, 3, 0.5}}
+	pf := []interface{}{uint8(1), uint16(0), uint16(2), uint16(2), float32(0.01),
+		uint16(1), uint16(1101), []uint32{1, 2, 3, 4}, []uint32{1, 1, 2, 3},
+		uint16(1), uint16(1102), []uint32{5, 6, 7, 8}, []uint32{4, 5, 6, 7}}
+
+	//same binary.Read and fast parse results, want and got are the record slices
+	same := func(name string, parseErr, fastErr error, want, got interface{}) {
+		if parseErr != nil && parseErr != io.EOF {
+			t.Fatalf("%s parse err:%s", name, parseErr.Error())
+		}
+		if fastErr != nil {
+			t.Fatalf("%s fast parse err:%s", name, fastErr.Error())
+		}
+		if reflect.ValueOf(got).Len() == 0 {
+			t.Fatalf("%s no record", name)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("%s records differ", name)
+		}
+	}
+
+	{
+		f := tileFile
+		a, b := &TileInfo{Filename: f}, &TileInfo{Filename: f}
+		errA, errB := a.Parse(), b.ParseFast()
+		same("tile", errA, errB, a.Metrics, b.Metrics)
+	}
+
+	{
+		f := writeBin(t, dir, "tile3", tile3...)
+		a, b := &TileInfo{Filename: f}, &TileInfo{Filename: f}
+		errA, errB := a.ParseRTA3(), b.ParseFast()
+		same("tile3", errA, errB, a.Metrics3, b.Metrics3)
+	}
+
+	{
+		f := errorFile
+		a, b := &ErrorInfo{Filename: f}, &ErrorInfo{Filename: f}
+		errA, errB := a.Parse(), b.ParseFast()
+		same("error", errA, errB, a.Metrics, b.Metrics)
+	}
+
+	{
+		f := writeBin(t, dir, "error4", error4...)
+		a, b := &ErrorInfo{Filename: f}, &ErrorInfo{Filename: f}
+		errA, errB := a.Parse(), b.ParseFast()
+		same("error4", errA, errB, a.Metrics4, b.Metrics4)
+	}
+
+	{
+		f := extractionFile
+		a, b := &ExtractionInfo{Filename: f}, &ExtractionInfo{Filename: f}
+		errA, errB := a.Parse(), b.ParseFast()
+		same("extraction", errA, errB, a.Metrics, b.Metrics)
+	}
+
+	{
+		f := writeBin(t, dir, "extraction3", extraction3...)
+		a, b := &ExtractionInfo{Filename: f}, &ExtractionInfo{Filename: f}
+		errA, errB := a.Parse3(f), b.ParseFast()
+		same("extraction3", errA, errB, a.Metrics3, b.Metrics3)
+	}
+
+	{
+		f := writeBin(t, dir, "q4", qv4...)
+		a, b := &QMetricsInfo{Filename: f}, &QMetricsInfo{Filename: f}
+		errA, errB := a.Parse(), b.ParseFast()
+		same("q4", errA, errB, a.Metrics, b.Metrics)
+	}
+
+	{
+		f := writeBin(t, dir, "q6", qv6...)
+		a, b := &QMetricsInfo{Filename: f}, &QMetricsInfo{Filename: f}
+		errA, errB := a.Parse(), b.ParseFast()
+		same("q6", errA, errB, a.Metrics, b.Metrics)
+	}
+
+	{
+		f := writeBin(t, dir, "q7", qv7...)
+		a, b := &QMetricsInfo{Filename: f}, &QMetricsInfo{Filename: f}
+		errA, errB := a.Parse(), b.ParseFast()
+		same("q7", errA, errB, a.Metrics7, b.Metrics7)
+	}
+
+	{
+		f := writeBin(t, dir, "pf", pf...)
+		a, b := &PFMetricsInfo{Filename: f}, &PFMetricsInfo{Filename: f}
+		errA, errB := a.Parse(), b.ParseFast()
+		same("pf", errA, errB, a.Metrics, b.Metrics)
+	}
+
+	//a cut off last record
+	b, err := ioutil.ReadFile("test_data/InterOp/TileMetricsOut.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cut := filepath.Join(dir, "cut")
+	if err := ioutil.WriteFile(cut, b[:len(b)-3], 0644); err != nil {
+		t.Fatal(err)
+	}
+	info := &TileInfo{Filename: cut}
+	if err := info.ParseFast(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("cut file err %v", err)
+	}
+	if len(info.Metrics) != (len(b)-2)/10-1 {
+		t.Fatalf("cut file %d records", len(info.Metrics))
+	}
+}
+
+func BenchmarkTileParse(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		(&TileInfo{Filename: "test_data/InterOp/TileMetricsOut.bin"}).Parse()
+	}
+}
+
+func BenchmarkTileParseFast(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		(&TileInfo{Filename: "test_data/InterOp/TileMetricsOut.bin"}).ParseFast()
+	}
+}
+
+func BenchmarkErrorParse(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		(&ErrorInfo{Filename: "test_data/InterOp/ErrorMetricsOut.bin"}).Parse()
+	}
+}
+
+func BenchmarkErrorParseFast(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		(&ErrorInfo{Filename: "test_data/InterOp/ErrorMetricsOut.bin"}).ParseFast()
+	}
+}
+
+func BenchmarkExtractionParse(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		(&ExtractionInfo{Filename: "test_data/InterOp/ExtractionMetricsOut.bin"}).Parse()
+	}
+}
+
+func BenchmarkExtractionParseFast(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		(&ExtractionInfo{Filename: "test_data/InterOp/ExtractionMetricsOut.bin"}).ParseFast()
+	}
+}
+
+var novaSeqS4 struct {
+	once  sync.Once
+	fsys  fcinfo.RunFS
+	files map[string][]byte
+	err   error
+}
+
+//novaSeqS4InterOp InterOp files of a NovaSeq S4 sized 2x151 run: 4 lanes of 2 surfaces, 6 swaths and 88 tiles, binned Q v7
+func novaSeqS4InterOp(tb testing.TB) fcinfo.RunFS {
+	novaSeqS4.once.Do(func() {
+		spec := synthrun.DefaultSpec(synthrun.NOVASEQ)
+		spec.Lanes, spec.Swaths, spec.TilesPerSwath = 4, 6, 88
+		spec.ReadStructure = "Y151;I8;I8;Y151"
+		novaSeqS4.files, novaSeqS4.err = spec.Files()
+		novaSeqS4.fsys = fcinfo.MapFS(novaSeqS4.files)
+	})
+	if novaSeqS4.err != nil {
+		tb.Fatal(novaSeqS4.err)
+	}
+	return novaSeqS4.fsys
+}
+
+//hiSeqXPFGrid PF grid of a HiSeq X flowcell: 8 lanes of 96 tiles, 16x40 bins
+func hiSeqXPFGrid() fcinfo.RunFS {
+	var b bytes.Buffer
+	numX, numY := 16, 40
+	for _, v := range []interface{}{uint8(1), uint16(4 + 8*numX*numY), uint16(numX), uint16(numY), float32(0.01)} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	counts := make([]uint32, 2*numX*numY)
+	for lane := 1; lane <= 8; lane++ {
+		for _, tile := range []int{1101, 1201, 2101, 2201} {
+			for t := 0; t < 24; t++ {
+				for i := range counts {
+					counts[i] = uint32(lane*tile+t*i) % 5000
+				}
+				binary.Write(&b, binary.LittleEndian, uint16(lane))
+				binary.Write(&b, binary.LittleEndian, uint16(tile+t))
+				binary.Write(&b, binary.LittleEndian, counts)
+			}
+		}
+	}
+	return fcinfo.MapFS(map[string][]byte{"InterOp/PFGridMetricsOut.bin": b.Bytes()})
+}
+
+//TestParseFastMatchesParseFullSize full sized files: binned Q v7 of NovaSeq, binned Q v6 of HiSeq 4000 and a HiSeq X PF grid
+func TestParseFastMatchesParseFullSize(t *testing.T) {
+	if testing.Short() {
+		t.Skip("full sized run")
+	}
+	fsys := novaSeqS4InterOp(t)
+	a := &QMetricsInfo{Filename: "InterOp/QMetricsOut.bin", fsys: fsys}
+	b := &QMetricsInfo{Filename: "InterOp/QMetricsOut.bin", fsys: fsys}
+	if err := a.Parse(); err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if err := b.ParseFast(); err != nil {
+		t.Fatal(err)
+	}
+	if a.Version != 7 || !a.EnableQbin || len(a.Metrics7) < 4*2*6*88 {
+		t.Fatalf("expect binned v7 of every tile, got version %d binned %v %d records", a.Version, a.EnableQbin, len(a.Metrics7))
+	}
+	if !reflect.DeepEqual(a.Metrics7, b.Metrics7) || !reflect.DeepEqual(a.QbinConfig, b.QbinConfig) {
+		t.Fatal("NovaSeq Q v7 differs between Parse and ParseFast")
+	}
+
+	spec := synthrun.DefaultSpec(synthrun.HISEQ_4000)
+	spec.Lanes, spec.TilesPerSwath = 8, 28
+	files, err := spec.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hiseq := fcinfo.MapFS(files)
+	a = &QMetricsInfo{Filename: "InterOp/QMetricsOut.bin", fsys: hiseq}
+	b = &QMetricsInfo{Filename: "InterOp/QMetricsOut.bin", fsys: hiseq}
+	if err := a.Parse(); err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if err := b.ParseFast(); err != nil {
+		t.Fatal(err)
+	}
+	if a.Version != 6 || !a.EnableQbin || len(a.Metrics) < 8*2*2*28 {
+		t.Fatalf("expect binned v6 of every tile, got version %d binned %v %d records", a.Version, a.EnableQbin, len(a.Metrics))
+	}
+	if !reflect.DeepEqual(a.Metrics, b.Metrics) || !reflect.DeepEqual(a.QbinConfig, b.QbinConfig) {
+		t.Fatal("HiSeq 4000 Q v6 differs between Parse and ParseFast")
+	}
+
+	grid := hiSeqXPFGrid()
+	pfA := &PFMetricsInfo{Filename: "InterOp/PFGridMetricsOut.bin", fsys: grid}
+	pfB := &PFMetricsInfo{Filename: "InterOp/PFGridMetricsOut.bin", fsys: grid}
+	if err := pfA.Parse(); err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if err := pfB.ParseFast(); err != nil {
+		t.Fatal(err)
+	}
+	if len(pfA.Metrics) != 8*96 || !reflect.DeepEqual(pfA.Metrics, pfB.Metrics) {
+		t.Fatalf("PF grid of %d tiles differs between Parse and ParseFast", len(pfA.Metrics))
+	}
+}
+
+func BenchmarkQParse(b *testing.B) {
+	fsys := novaSeqS4InterOp(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		(&QMetricsInfo{Filename: "InterOp/QMetricsOut.bin", fsys: fsys}).Parse()
+	}
+}
+
+func BenchmarkQParseFast(b *testing.B) {
+	fsys := novaSeqS4InterOp(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		(&QMetricsInfo{Filename: "InterOp/QMetricsOut.bin", fsys: fsys}).ParseFast()
+	}
+}
+
+func BenchmarkPFGridParse(b *testing.B) {
+	fsys := hiSeqXPFGrid()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		(&PFMetricsInfo{Filename: "InterOp/PFGridMetricsOut.bin", fsys: fsys}).Parse()
+	}
+}
+
+func BenchmarkPFGridParseFast(b *testing.B) {
+	fsys := hiSeqXPFGrid()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		(&PFMetricsInfo{Filename: "InterOp/PFGridMetricsOut.bin", fsys: fsys}).ParseFast()
+	}
+}
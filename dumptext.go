@@ -0,0 +1,880 @@
+package interop
+
+//dumptext.go sectioned CSV in the layout of Illumina InterOp's dumptext, and the reader for it.
+//Every section starts with "# <Name>,<Version>", then "# Key: value" lines, the column header and the rows.
+//Missing values are written as nan.
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	DUMPTEXT_VERSION = "v1.1.4" //InterOp release whose dumptext layout is written, on the "# Version:" line
+	DUMPTEXT_NAN     = "nan"
+
+	DUMPTEXT_TILE              = "Tile"
+	DUMPTEXT_Q                 = "Q"
+	DUMPTEXT_ERROR             = "Error"
+	DUMPTEXT_EXTRACTION        = "Extraction"
+	DUMPTEXT_CORRECTED_INT     = "CorrectedInt"
+	DUMPTEXT_INDEX             = "Index"
+	DUMPTEXT_CONTROL           = "Control"
+	DUMPTEXT_IMAGE             = "Image"
+	DUMPTEXT_EMPIRICAL_PHASING = "EmpiricalPhasing"
+	DUMPTEXT_EXTENDED_TILE     = "ExtendedTile"
+
+	LEGACY_CHANNEL_NAMES   = []string{"A", "C", "G", "T"}
+	TWO_COLOR_CHANNEL_NAME = []string{"Red", "Green"}
+)
+
+//DumpTextChannelNames channel column suffixes for n channels
+func DumpTextChannelNames(n int) []string {
+	switch n {
+	case 4:
+		return LEGACY_CHANNEL_NAMES
+	case 2:
+		return TWO_COLOR_CHANNEL_NAME
+	}
+	ret := []string{}
+	for i := 1; i <= n; i++ {
+		ret = append(ret, strconv.Itoa(i))
+	}
+	return ret
+}
+
+//DumpTextSection one metric set as text
+type DumpTextSection struct {
+	Name    string
+	Version int
+	Meta    map[string]string //"# Key: value" lines besides Column Count
+	Header  []string
+	Rows    [][]string
+}
+
+func (self *DumpTextSection) index() map[string]int {
+	ret := map[string]int{}
+	for i, h := range self.Header {
+		ret[h] = i
+	}
+	return ret
+}
+
+func formatDumpFloat(v float64, bits int) string {
+	if math.IsNaN(v) {
+		return DUMPTEXT_NAN
+	}
+	return strconv.FormatFloat(v, 'g', -1, bits)
+}
+
+func f32s(v float32) string {
+	return formatDumpFloat(float64(v), 32)
+}
+
+func u64s(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
+
+func prefixed(prefix string, names []string) []string {
+	ret := []string{}
+	for _, n := range names {
+		ret = append(ret, prefix+n)
+	}
+	return ret
+}
+
+//tileReadValues per tile values of legacy tile metrics; codes outside the known ones are dropped
+type tileReadValues struct {
+	LaneNum  uint16
+	TileNum  uint32
+	Clusters [4]float64 //raw, PF, density, density PF
+	Reads    map[int]*[3]float64
+}
+
+func newTileReadValues(laneNum uint16, tileNum uint32) *tileReadValues {
+	ret := &tileReadValues{LaneNum: laneNum, TileNum: tileNum, Reads: map[int]*[3]float64{}}
+	for i := range ret.Clusters {
+		ret.Clusters[i] = math.NaN()
+	}
+	return ret
+}
+
+func (self *tileReadValues) read(readNum int) *[3]float64 {
+	if r, ok := self.Reads[readNum]; ok {
+		return r
+	}
+	r := &[3]float64{math.NaN(), math.NaN(), math.NaN()}
+	self.Reads[readNum] = r
+	return r
+}
+
+var dumpTextTileHeader = []string{"Lane", "Tile", "Read", "ClusterCount", "ClusterCountPF", "Density", "DensityPF", "Aligned", "Prephasing", "Phasing"}
+
+func dumpTileSection(info *TileInfo) *DumpTextSection {
+	ret := &DumpTextSection{Name: DUMPTEXT_TILE, Version: int(info.Version), Meta: map[string]string{}, Header: dumpTextTileHeader}
+	tiles := map[tileKey]*tileReadValues{}
+	get := func(laneNum uint16, tileNum uint32) *tileReadValues {
+		k := tileKey{laneNum, tileNum}
+		if t, ok := tiles[k]; ok {
+			return t
+		}
+		tiles[k] = newTileReadValues(laneNum, tileNum)
+		return tiles[k]
+	}
+	for _, m := range info.Metrics {
+		t := get(m.LaneNum, uint32(m.TileNum))
+		v := float64(m.MetricValue)
+		switch code := int(m.MetricCode); {
+		case code == int(CLUSTER_DENSITY):
+			t.Clusters[2] = v
+		case code == int(CLUSTER_DENSITY_PF):
+			t.Clusters[3] = v
+		case code == int(NUMBER_CLUSTER):
+			t.Clusters[0] = v
+		case code == int(NUMBER_CLUSTER_PF):
+			t.Clusters[1] = v
+		case code >= 200 && code < 300:
+			//200 + 2(r-1) phasing, 201 + 2(r-1) prephasing
+			r := t.read((code-200)/2 + 1)
+			r[2-(code-200)%2] = v
+		case code >= 300 && code < 400:
+			t.read(code - 300 + 1)[0] = v
+		}
+	}
+	for _, m := range info.Metrics3 {
+		t := get(m.LaneNum, m.TileNum)
+		switch m.MetricCode {
+		case 't':
+			t.Clusters[0], t.Clusters[1] = float64(m.ClusterCount), float64(m.PFClusterCount)
+			if info.AreaSize > 0 {
+				t.Clusters[2] = float64(m.ClusterCount) / float64(info.AreaSize)
+				t.Clusters[3] = float64(m.PFClusterCount) / float64(info.AreaSize)
+			}
+		case 'r':
+			t.read(int(m.NumberRead))[0] = float64(m.PctAligned)
+		}
+	}
+	if info.Version == 3 {
+		ret.Meta["Area Size"] = f32s(info.AreaSize)
+	}
+	keys := make(tileValues)
+	for k := range tiles {
+		keys[k] = 0
+	}
+	for _, k := range keys.sortedKeys() {
+		t := tiles[k]
+		readNums := []int{}
+		for r := range t.Reads {
+			readNums = append(readNums, r)
+		}
+		sort.Ints(readNums)
+		if len(readNums) == 0 {
+			readNums = []int{0}
+		}
+		for _, r := range readNums {
+			row := []string{strconv.Itoa(int(t.LaneNum)), u64s(uint64(t.TileNum)), strconv.Itoa(r)}
+			for _, v := range t.Clusters {
+				row = append(row, formatDumpFloat(v, 32))
+			}
+			rv := &[3]float64{math.NaN(), math.NaN(), math.NaN()}
+			if r > 0 {
+				rv = t.Reads[r]
+			}
+			for _, v := range rv {
+				row = append(row, formatDumpFloat(v, 32))
+			}
+			ret.Rows = append(ret.Rows, row)
+		}
+	}
+	return ret
+}
+
+func dumpQSection(info *QMetricsInfo) *DumpTextSection {
+	ret := &DumpTextSection{Name: DUMPTEXT_Q, Version: int(info.Version), Meta: map[string]string{}, Header: []string{"Lane", "Tile", "Cycle"}}
+	for q := 1; q <= 50; q++ {
+		ret.Header = append(ret.Header, fmt.Sprintf("Q%d", q))
+	}
+	if info.EnableQbin {
+		ret.Meta["Bin Count"] = strconv.Itoa(int(info.NumQscores))
+		bins := []string{}
+		for i := range info.QbinConfig.ReMapScores {
+			bins = append(bins, fmt.Sprintf("%d-%d:%d", info.QbinConfig.LowerBound[i], info.QbinConfig.UpperBound[i], info.QbinConfig.ReMapScores[i]))
+		}
+		ret.Meta["Bins"] = strings.Join(bins, " ")
+	}
+	info.EachRecord(func(laneNum uint16, tileNum uint32, cycle uint16, numClusters *[50]uint32) {
+		row := []string{strconv.Itoa(int(laneNum)), u64s(uint64(tileNum)), strconv.Itoa(int(cycle))}
+		for _, n := range numClusters {
+			row = append(row, u64s(uint64(n)))
+		}
+		ret.Rows = append(ret.Rows, row)
+	})
+	return ret
+}
+
+func dumpErrorSection(info *ErrorInfo) *DumpTextSection {
+	ret := &DumpTextSection{Name: DUMPTEXT_ERROR, Version: int(info.Version), Header: []string{"Lane", "Tile", "Cycle", "ErrorRate"}}
+	if len(info.Metrics4) > 0 {
+		for _, m := range info.Metrics4 {
+			ret.Rows = append(ret.Rows, []string{strconv.Itoa(int(m.LaneNum)), u64s(uint64(m.TileNum)), strconv.Itoa(int(m.Cycle)), f32s(m.ErrorRate)})
+		}
+		return ret
+	}
+	ret.Header = append(ret.Header, "Errors_0", "Errors_1", "Errors_2", "Errors_3", "Errors_4")
+	for _, m := range info.Metrics {
+		ret.Rows = append(ret.Rows, []string{
+			strconv.Itoa(int(m.LaneNum)), u64s(uint64(m.TileNum)), strconv.Itoa(int(m.Cycle)), f32s(m.ErrorRate),
+			u64s(uint64(m.NumPerfectReads)), u64s(uint64(m.Num_1_Error)), u64s(uint64(m.Num_2_Error)), u64s(uint64(m.Num_3_Error)), u64s(uint64(m.Num_4_Error)),
+		})
+	}
+	return ret
+}
+
+func dumpExtractionSection(info *ExtractionInfo) *DumpTextSection {
+	ret := &DumpTextSection{Name: DUMPTEXT_EXTRACTION, Version: int(info.Version), Meta: map[string]string{}}
+	n := 4
+	if len(info.Metrics3) > 0 {
+		n = len(info.Metrics3[0].Intensity)
+	}
+	names := DumpTextChannelNames(n)
+	ret.Meta["Channel Count"] = strconv.Itoa(n)
+	ret.Header = append([]string{"Lane", "Tile", "Cycle"}, prefixed("MaxIntensity_", names)...)
+	ret.Header = append(ret.Header, prefixed("FocusScore_", names)...)
+	add := func(laneNum uint16, tileNum uint32, cycle uint16, intensity []uint16, fwhm []float32) {
+		row := []string{strconv.Itoa(int(laneNum)), u64s(uint64(tileNum)), strconv.Itoa(int(cycle))}
+		for i := 0; i < n; i++ {
+			if i < len(intensity) {
+				row = append(row, u64s(uint64(intensity[i])))
+			} else {
+				row = append(row, DUMPTEXT_NAN)
+			}
+		}
+		for i := 0; i < n; i++ {
+			if i < len(fwhm) {
+				row = append(row, f32s(fwhm[i]))
+			} else {
+				row = append(row, DUMPTEXT_NAN)
+			}
+		}
+		ret.Rows = append(ret.Rows, row)
+	}
+	for _, m := range info.Metrics {
+		add(m.LaneNum, uint32(m.TileNum), m.Cycle,
+			[]uint16{m.Intensity_A, m.Intensity_C, m.Intensity_G, m.Intensity_T},
+			[]float32{m.Fwhm_A, m.Fwhm_C, m.Fwhm_G, m.Fwhm_T})
+	}
+	for _, m := range info.Metrics3 {
+		add(m.LaneNum, m.TileNum, m.Cycle, m.Intensity, m.Fwhm)
+	}
+	return ret
+}
+
+func dumpCorrectedIntSection(info *CorrectIntInfo) *DumpTextSection {
+	ret := &DumpTextSection{Name: DUMPTEXT_CORRECTED_INT, Version: int(info.Version)}
+	ret.Header = append([]string{"Lane", "Tile", "Cycle", "AverageCycleIntensity"}, prefixed("AverageCorrectedIntensity_", LEGACY_CHANNEL_NAMES)...)
+	ret.Header = append(ret.Header, prefixed("AverageCalledIntensity_", LEGACY_CHANNEL_NAMES)...)
+	ret.Header = append(ret.Header, "CalledCount_NC")
+	ret.Header = append(ret.Header, prefixed("CalledCount_", LEGACY_CHANNEL_NAMES)...)
+	ret.Header = append(ret.Header, "SignalToNoise")
+	for _, m := range info.Metrics {
+		row := []string{strconv.Itoa(int(m.LaneNum)), u64s(uint64(m.TileNum)), strconv.Itoa(int(m.Cycle)), u64s(uint64(m.AvgIntensity))}
+		for _, v := range []uint16{m.Avg_Int_A, m.Avg_Int_C, m.Avg_Int_G, m.Avg_Int_T, m.Avg_Called_A, m.Avg_Called_C, m.Avg_Called_G, m.Avg_Called_T} {
+			row = append(row, u64s(uint64(v)))
+		}
+		for _, v := range []float32{m.BaseCall_NoCall, m.BaseCall_A, m.BaseCall_C, m.BaseCall_G, m.BaseCall_T, m.NoiseRatio} {
+			row = append(row, f32s(v))
+		}
+		ret.Rows = append(ret.Rows, row)
+	}
+	return ret
+}
+
+func dumpIndexSection(info *IndexInfo) *DumpTextSection {
+	ret := &DumpTextSection{Name: DUMPTEXT_INDEX, Version: int(info.Version), Header: []string{"Lane", "Tile", "Read", "Sequence", "Sample", "Project", "ClusterCount"}}
+	for _, m := range info.Metrics {
+		ret.Rows = append(ret.Rows, []string{strconv.Itoa(int(m.LaneNum)), u64s(uint64(m.TileNum)), strconv.Itoa(int(m.Read)), m.IndexName, m.SampleName, m.ProjectName, u64s(uint64(m.Clusters_PF))})
+	}
+	return ret
+}
+
+func dumpControlSection(info *ControlInfo) *DumpTextSection {
+	ret := &DumpTextSection{Name: DUMPTEXT_CONTROL, Version: int(info.Version), Header: []string{"Lane", "Tile", "Read", "Control", "Index", "ClusterCount"}}
+	for _, m := range info.Metrics {
+		ret.Rows = append(ret.Rows, []string{strconv.Itoa(int(m.LaneNum)), u64s(uint64(m.TileNum)), strconv.Itoa(int(m.Read)), m.ControlName, m.IndexName, u64s(uint64(m.NumClusters))})
+	}
+	return ret
+}
+
+func dumpImageSection(info *ImageInfo) *DumpTextSection {
+	ret := &DumpTextSection{Name: DUMPTEXT_IMAGE, Version: int(info.Version), Meta: map[string]string{}}
+	type ltc struct {
+		LaneNum uint16
+		TileNum uint16
+		Cycle   uint16
+	}
+	//version 1 has a record per channel, join them on a row like version 2
+	rows := map[ltc][2][]uint16{}
+	order := []ltc{}
+	n := int(info.NumOfChannels)
+	for _, m := range info.Metrics {
+		k := ltc{m.LaneNum, m.TileNum, m.Cycle}
+		mm, ok := rows[k]
+		if !ok {
+			order = append(order, k)
+		}
+		if len(m.MinContrasts) > 0 {
+			mm = [2][]uint16{m.MinContrasts, m.MaxContrasts}
+		} else {
+			for int(m.ChannelId) >= len(mm[0]) {
+				mm[0], mm[1] = append(mm[0], 0), append(mm[1], 0)
+			}
+			mm[0][m.ChannelId], mm[1][m.ChannelId] = m.MinContrast, m.MaxContrast
+		}
+		if len(mm[0]) > n {
+			n = len(mm[0])
+		}
+		rows[k] = mm
+	}
+	names := DumpTextChannelNames(n)
+	ret.Meta["Channel Count"] = strconv.Itoa(n)
+	ret.Header = append([]string{"Lane", "Tile", "Cycle"}, prefixed("MinContrast_", names)...)
+	ret.Header = append(ret.Header, prefixed("MaxContrast_", names)...)
+	for _, k := range order {
+		row := []string{strconv.Itoa(int(k.LaneNum)), u64s(uint64(k.TileNum)), strconv.Itoa(int(k.Cycle))}
+		for side := 0; side < 2; side++ {
+			for i := 0; i < n; i++ {
+				if i < len(rows[k][side]) {
+					row = append(row, u64s(uint64(rows[k][side][i])))
+				} else {
+					row = append(row, DUMPTEXT_NAN)
+				}
+			}
+		}
+		ret.Rows = append(ret.Rows, row)
+	}
+	return ret
+}
+
+func dumpEmpiricalPhasingSection(info *EmpericalPhasingInfo) *DumpTextSection {
+	ret := &DumpTextSection{Name: DUMPTEXT_EMPIRICAL_PHASING, Version: int(info.Version), Header: []string{"Lane", "Tile", "Cycle", "Phasing", "Prephasing"}}
+	for _, m := range info.Metrics {
+		ret.Rows = append(ret.Rows, []string{strconv.Itoa(int(m.LaneNum)), u64s(uint64(m.TileNum)), strconv.Itoa(int(m.Cycle)), f32s(m.Phasing), f32s(m.PrePhasing)})
+	}
+	return ret
+}
+
+func dumpExtendedTileSection(info *ExtendMetricsInfo) *DumpTextSection {
+	ret := &DumpTextSection{Name: DUMPTEXT_EXTENDED_TILE, Version: int(info.Version), Header: []string{"Lane", "Tile", "Code", "Value"}}
+	for _, m := range info.Metrics {
+		ret.Rows = append(ret.Rows, []string{strconv.Itoa(int(m.LaneNum)), u64s(uint64(m.TileNum)), strconv.Itoa(int(m.Code)), f32s(m.Value)})
+	}
+	return ret
+}
+
+//DumpTextSections every loaded metric set of the run, in dumptext order
+func (self *Run) DumpTextSections() []*DumpTextSection {
+	ret := []*DumpTextSection{}
+	if self.Tile != nil {
+		ret = append(ret, dumpTileSection(self.Tile))
+	}
+	if self.Q != nil {
+		ret = append(ret, dumpQSection(self.Q))
+	}
+	if self.Error != nil {
+		ret = append(ret, dumpErrorSection(self.Error))
+	}
+	if self.EmpiricalPhasing != nil {
+		ret = append(ret, dumpEmpiricalPhasingSection(self.EmpiricalPhasing))
+	}
+	if self.CorrectedInt != nil {
+		ret = append(ret, dumpCorrectedIntSection(self.CorrectedInt))
+	}
+	if self.Extraction != nil {
+		ret = append(ret, dumpExtractionSection(self.Extraction))
+	}
+	if self.Image != nil {
+		ret = append(ret, dumpImageSection(self.Image))
+	}
+	if self.Index != nil {
+		ret = append(ret, dumpIndexSection(self.Index))
+	}
+	if self.Control != nil {
+		ret = append(ret, dumpControlSection(self.Control))
+	}
+	if self.Extended != nil {
+		ret = append(ret, dumpExtendedTileSection(self.Extended))
+	}
+	return ret
+}
+
+//WriteDumpText write sections; meta keys are sorted so output is stable
+func WriteDumpText(w io.Writer, runId string, sections []*DumpTextSection) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# Version: %s\n", DUMPTEXT_VERSION)
+	if runId != "" {
+		fmt.Fprintf(bw, "# Run Folder: %s\n", runId)
+	}
+	cw := csv.NewWriter(bw)
+	for _, sec := range sections {
+		cw.Flush()
+		fmt.Fprintf(bw, "# %s,%d\n", sec.Name, sec.Version)
+		keys := []string{}
+		for k := range sec.Meta {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(bw, "# %s: %s\n", k, sec.Meta[k])
+		}
+		fmt.Fprintf(bw, "# Column Count: %d\n", len(sec.Header))
+		if err := cw.Write(sec.Header); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(sec.Rows); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+//DumpText every loaded metric set in dumptext layout
+func (self *Run) DumpText(w io.Writer) error {
+	return WriteDumpText(w, self.Name(), self.DumpTextSections())
+}
+
+//ReadDumpTextSections split dumptext into sections; lines before the first section are ignored
+func ReadDumpTextSections(r io.Reader) ([]*DumpTextSection, error) {
+	ret := []*DumpTextSection{}
+	var cur *DumpTextSection
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			body := strings.TrimSpace(strings.TrimPrefix(line, "#"))
+			if i := strings.Index(body, ":"); i > 0 {
+				if cur != nil {
+					cur.Meta[strings.TrimSpace(body[:i])] = strings.TrimSpace(body[i+1:])
+				}
+				continue
+			}
+			sp := strings.Split(body, ",")
+			if len(sp) != 2 {
+				return nil, fmt.Errorf("line %d: bad section header %s", lineNum, line)
+			}
+			version, err := strconv.Atoi(strings.TrimSpace(sp[1]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: bad section version %s", lineNum, line)
+			}
+			cur = &DumpTextSection{Name: strings.TrimSpace(sp[0]), Version: version, Meta: map[string]string{}}
+			ret = append(ret, cur)
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		fields, err := csv.NewReader(strings.NewReader(line)).Read()
+		if err != nil {
+			return nil, fmt.Errorf("line %d err:%s", lineNum, err.Error())
+		}
+		if cur.Header == nil {
+			cur.Header = fields
+			continue
+		}
+		if len(fields) != len(cur.Header) {
+			return nil, fmt.Errorf("line %d: %d columns, %s header has %d", lineNum, len(fields), cur.Name, len(cur.Header))
+		}
+		cur.Rows = append(cur.Rows, fields)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+//dumpRow typed access to one row, the first parse error sticks
+type dumpRow struct {
+	sec *DumpTextSection
+	idx map[string]int
+	row []string
+	err error
+}
+
+func (self *dumpRow) str(col string) string {
+	i, ok := self.idx[col]
+	if !ok {
+		if self.err == nil {
+			self.err = fmt.Errorf("%s section has no %s column", self.sec.Name, col)
+		}
+		return ""
+	}
+	return self.row[i]
+}
+
+func (self *dumpRow) has(col string) bool {
+	_, ok := self.idx[col]
+	return ok
+}
+
+func (self *dumpRow) uint(col string, bits int) uint64 {
+	s := self.str(col)
+	v, err := strconv.ParseUint(s, 10, bits)
+	if err != nil && self.err == nil {
+		self.err = fmt.Errorf("%s %s err:%s", self.sec.Name, col, err.Error())
+	}
+	return v
+}
+
+//float NaN for nan, and for -nan as glibc prints it
+func (self *dumpRow) float(col string) float64 {
+	s := self.str(col)
+	if s == DUMPTEXT_NAN || s == "-"+DUMPTEXT_NAN || s == "" {
+		return math.NaN()
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil && self.err == nil {
+		self.err = fmt.Errorf("%s %s err:%s", self.sec.Name, col, err.Error())
+	}
+	return v
+}
+
+func (self *dumpRow) f32(col string) float32 {
+	return float32(self.float(col))
+}
+
+//each call fn on every row, stop on the first error
+func (self *DumpTextSection) each(fn func(r *dumpRow)) error {
+	idx := self.index()
+	for i, row := range self.Rows {
+		r := &dumpRow{sec: self, idx: idx, row: row}
+		fn(r)
+		if r.err != nil {
+			return fmt.Errorf("row %d: %s", i+1, r.err.Error())
+		}
+	}
+	return nil
+}
+
+//channelCount number of columns with prefix
+func (self *DumpTextSection) channelCount(prefix string) int {
+	n := 0
+	for _, h := range self.Header {
+		if strings.HasPrefix(h, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func loadTileSection(sec *DumpTextSection, run *Run) error {
+	info := &TileInfo{Version: uint8(sec.Version)}
+	if v, ok := sec.Meta["Area Size"]; ok {
+		a, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			return fmt.Errorf("Tile Area Size err:%s", err.Error())
+		}
+		info.AreaSize = float32(a)
+	}
+	seenTile := map[tileKey]bool{}
+	err := sec.each(func(r *dumpRow) {
+		laneNum, tileNum, readNum := uint16(r.uint("Lane", 16)), uint32(r.uint("Tile", 32)), int(r.uint("Read", 16))
+		k := tileKey{laneNum, tileNum}
+		first := !seenTile[k]
+		seenTile[k] = true
+		if sec.Version == 3 {
+			if first {
+				m := &TileMetrics3{MetricCode: 't'}
+				m.LaneNum, m.TileNum = laneNum, tileNum
+				m.ClusterCount, m.PFClusterCount = r.f32("ClusterCount"), r.f32("ClusterCountPF")
+				info.Metrics3 = append(info.Metrics3, m)
+			}
+			if aligned := r.float("Aligned"); readNum > 0 && !math.IsNaN(aligned) {
+				m := &TileMetrics3{MetricCode: 'r'}
+				m.LaneNum, m.TileNum = laneNum, tileNum
+				m.NumberRead, m.PctAligned = uint32(readNum), float32(aligned)
+				info.Metrics3 = append(info.Metrics3, m)
+			}
+			return
+		}
+		add := func(code int, v float64) {
+			if !math.IsNaN(v) {
+				info.Metrics = append(info.Metrics, &TileMetrics{LaneNum: laneNum, TileNum: uint16(tileNum), MetricCode: uint16(code), MetricValue: float32(v)})
+			}
+		}
+		if first {
+			add(int(CLUSTER_DENSITY), r.float("Density"))
+			add(int(CLUSTER_DENSITY_PF), r.float("DensityPF"))
+			add(int(NUMBER_CLUSTER), r.float("ClusterCount"))
+			add(int(NUMBER_CLUSTER_PF), r.float("ClusterCountPF"))
+		}
+		if readNum > 0 {
+			add(200+2*(readNum-1), r.float("Phasing"))
+			add(201+2*(readNum-1), r.float("Prephasing"))
+			add(300+readNum-1, r.float("Aligned"))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	run.Tile = info
+	return nil
+}
+
+func loadQSection(sec *DumpTextSection, run *Run) error {
+	info := &QMetricsInfo{Version: uint8(sec.Version)}
+	if v, ok := sec.Meta["Bins"]; ok && v != "" {
+		info.EnableQbin = true
+		for _, b := range strings.Fields(v) {
+			var lower, upper, remap uint8
+			if _, err := fmt.Sscanf(b, "%d-%d:%d", &lower, &upper, &remap); err != nil {
+				return fmt.Errorf("Q Bins %s err:%s", b, err.Error())
+			}
+			info.QbinConfig.LowerBound = append(info.QbinConfig.LowerBound, lower)
+			info.QbinConfig.UpperBound = append(info.QbinConfig.UpperBound, upper)
+			info.QbinConfig.ReMapScores = append(info.QbinConfig.ReMapScores, remap)
+		}
+		info.NumQscores = uint8(len(info.QbinConfig.ReMapScores))
+	}
+	err := sec.each(func(r *dumpRow) {
+		var counts [50]uint32
+		for q := 1; q <= 50; q++ {
+			counts[q-1] = uint32(r.uint(fmt.Sprintf("Q%d", q), 32))
+		}
+		laneNum, tileNum, cycle := uint16(r.uint("Lane", 16)), r.uint("Tile", 32), uint16(r.uint("Cycle", 16))
+		if sec.Version >= 7 {
+			m := &QMetrics7{NumClusters: counts}
+			m.LaneNum, m.TileNum, m.Cycle = laneNum, uint32(tileNum), cycle
+			info.Metrics7 = append(info.Metrics7, m)
+			return
+		}
+		m := &QMetrics{NumClusters: counts}
+		m.LaneNum, m.TileNum, m.Cycle = laneNum, uint16(tileNum), cycle
+		info.Metrics = append(info.Metrics, m)
+	})
+	if err != nil {
+		return err
+	}
+	run.Q = info
+	return nil
+}
+
+func loadErrorSection(sec *DumpTextSection, run *Run) error {
+	info := &ErrorInfo{Version: uint8(sec.Version)}
+	err := sec.each(func(r *dumpRow) {
+		if sec.Version >= 4 {
+			info.Metrics4 = append(info.Metrics4, &ErrorMetrics4{
+				LaneNum: uint16(r.uint("Lane", 16)), TileNum: uint32(r.uint("Tile", 32)), Cycle: uint16(r.uint("Cycle", 16)), ErrorRate: r.f32("ErrorRate"),
+			})
+			return
+		}
+		info.Metrics = append(info.Metrics, &ErrorMetrics{
+			LaneNum:         uint16(r.uint("Lane", 16)),
+			TileNum:         uint16(r.uint("Tile", 16)),
+			Cycle:           uint16(r.uint("Cycle", 16)),
+			ErrorRate:       r.f32("ErrorRate"),
+			NumPerfectReads: uint32(r.uint("Errors_0", 32)),
+			Num_1_Error:     uint32(r.uint("Errors_1", 32)),
+			Num_2_Error:     uint32(r.uint("Errors_2", 32)),
+			Num_3_Error:     uint32(r.uint("Errors_3", 32)),
+			Num_4_Error:     uint32(r.uint("Errors_4", 32)),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	run.Error = info
+	return nil
+}
+
+func loadExtractionSection(sec *DumpTextSection, run *Run) error {
+	n := sec.channelCount("MaxIntensity_")
+	names := DumpTextChannelNames(n)
+	info := &ExtractionInfo{Version: uint8(sec.Version), NumChannels: uint8(n)}
+	err := sec.each(func(r *dumpRow) {
+		intensity, fwhm := []uint16{}, []float32{}
+		for _, name := range names {
+			intensity = append(intensity, uint16(r.uint("MaxIntensity_"+name, 16)))
+			fwhm = append(fwhm, r.f32("FocusScore_"+name))
+		}
+		if sec.Version >= 3 {
+			m := &ExtractionMetricsV3{Intensity: intensity, Fwhm: fwhm}
+			m.LaneNum, m.TileNum, m.Cycle = uint16(r.uint("Lane", 16)), uint32(r.uint("Tile", 32)), uint16(r.uint("Cycle", 16))
+			info.Metrics3 = append(info.Metrics3, m)
+			return
+		}
+		if n != 4 {
+			r.err = fmt.Errorf("Extraction version %d needs 4 channels, got %d", sec.Version, n)
+			return
+		}
+		info.Metrics = append(info.Metrics, &ExtractionMetrics{
+			LaneNum: uint16(r.uint("Lane", 16)), TileNum: uint16(r.uint("Tile", 16)), Cycle: uint16(r.uint("Cycle", 16)),
+			Fwhm_A: fwhm[0], Fwhm_C: fwhm[1], Fwhm_G: fwhm[2], Fwhm_T: fwhm[3],
+			Intensity_A: intensity[0], Intensity_C: intensity[1], Intensity_G: intensity[2], Intensity_T: intensity[3],
+		})
+	})
+	if err != nil {
+		return err
+	}
+	run.Extraction = info
+	return nil
+}
+
+func loadCorrectedIntSection(sec *DumpTextSection, run *Run) error {
+	info := &CorrectIntInfo{Version: uint8(sec.Version)}
+	err := sec.each(func(r *dumpRow) {
+		u := func(col string) uint16 { return uint16(r.uint(col, 16)) }
+		info.Metrics = append(info.Metrics, &CorrectIntMetrics{
+			LaneNum: u("Lane"), TileNum: u("Tile"), Cycle: u("Cycle"), AvgIntensity: u("AverageCycleIntensity"),
+			Avg_Int_A: u("AverageCorrectedIntensity_A"), Avg_Int_C: u("AverageCorrectedIntensity_C"),
+			Avg_Int_G: u("AverageCorrectedIntensity_G"), Avg_Int_T: u("AverageCorrectedIntensity_T"),
+			Avg_Called_A: u("AverageCalledIntensity_A"), Avg_Called_C: u("AverageCalledIntensity_C"),
+			Avg_Called_G: u("AverageCalledIntensity_G"), Avg_Called_T: u("AverageCalledIntensity_T"),
+			BaseCall_NoCall: r.f32("CalledCount_NC"),
+			BaseCall_A:      r.f32("CalledCount_A"), BaseCall_C: r.f32("CalledCount_C"),
+			BaseCall_G: r.f32("CalledCount_G"), BaseCall_T: r.f32("CalledCount_T"),
+			NoiseRatio: r.f32("SignalToNoise"),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	run.CorrectedInt = info
+	return nil
+}
+
+func loadIndexSection(sec *DumpTextSection, run *Run) error {
+	info := &IndexInfo{Version: uint8(sec.Version)}
+	err := sec.each(func(r *dumpRow) {
+		m := &IndexMetrics{
+			LaneNum: uint16(r.uint("Lane", 16)), TileNum: uint16(r.uint("Tile", 16)), Read: uint16(r.uint("Read", 16)),
+			IndexName: r.str("Sequence"), SampleName: r.str("Sample"), ProjectName: r.str("Project"),
+			Clusters_PF: uint32(r.uint("ClusterCount", 32)),
+		}
+		m.Sz_IndexName, m.Sz_SampleName, m.Sz_ProjectName = uint16(len(m.IndexName)), uint16(len(m.SampleName)), uint16(len(m.ProjectName))
+		info.Metrics = append(info.Metrics, m)
+	})
+	if err != nil {
+		return err
+	}
+	run.Index = info
+	return nil
+}
+
+func loadControlSection(sec *DumpTextSection, run *Run) error {
+	info := &ControlInfo{Version: uint8(sec.Version)}
+	err := sec.each(func(r *dumpRow) {
+		m := &ControlMetrics{
+			LaneNum: uint16(r.uint("Lane", 16)), TileNum: uint16(r.uint("Tile", 16)), Read: uint16(r.uint("Read", 16)),
+			ControlName: r.str("Control"), IndexName: r.str("Index"), NumClusters: uint32(r.uint("ClusterCount", 32)),
+		}
+		m.Sz_ControlName, m.Sz_IndexName = uint16(len(m.ControlName)), uint16(len(m.IndexName))
+		info.Metrics = append(info.Metrics, m)
+	})
+	if err != nil {
+		return err
+	}
+	run.Control = info
+	return nil
+}
+
+func loadImageSection(sec *DumpTextSection, run *Run) error {
+	n := sec.channelCount("MinContrast_")
+	names := DumpTextChannelNames(n)
+	info := &ImageInfo{Version: uint8(sec.Version), NumOfChannels: uint8(n)}
+	err := sec.each(func(r *dumpRow) {
+		lo, hi := []uint16{}, []uint16{}
+		for _, name := range names {
+			lo = append(lo, uint16(r.uint("MinContrast_"+name, 16)))
+			hi = append(hi, uint16(r.uint("MaxContrast_"+name, 16)))
+		}
+		ltc := LTC{LaneNum: uint16(r.uint("Lane", 16)), TileNum: uint16(r.uint("Tile", 16)), Cycle: uint16(r.uint("Cycle", 16))}
+		if sec.Version >= 2 {
+			info.Metrics = append(info.Metrics, &ImageMetrics{LTC: ltc, MinContrasts: lo, MaxContrasts: hi})
+			return
+		}
+		for i := range lo {
+			info.Metrics = append(info.Metrics, &ImageMetrics{LTC: ltc, ChannelId: uint16(i), MinContrast: lo[i], MaxContrast: hi[i]})
+		}
+	})
+	if err != nil {
+		return err
+	}
+	run.Image = info
+	return nil
+}
+
+func loadEmpiricalPhasingSection(sec *DumpTextSection, run *Run) error {
+	info := &EmpericalPhasingInfo{Version: uint8(sec.Version)}
+	err := sec.each(func(r *dumpRow) {
+		m := &PhasingMetrics{Phasing: r.f32("Phasing"), PrePhasing: r.f32("Prephasing")}
+		m.LaneNum, m.TileNum, m.Cycle = uint16(r.uint("Lane", 16)), uint16(r.uint("Tile", 16)), uint16(r.uint("Cycle", 16))
+		info.Metrics = append(info.Metrics, m)
+	})
+	if err != nil {
+		return err
+	}
+	run.EmpiricalPhasing = info
+	return nil
+}
+
+func loadExtendedTileSection(sec *DumpTextSection, run *Run) error {
+	info := &ExtendMetricsInfo{Version: uint8(sec.Version)}
+	err := sec.each(func(r *dumpRow) {
+		info.Metrics = append(info.Metrics, &ExtendMetrics{
+			LaneNum: uint16(r.uint("Lane", 16)), TileNum: uint16(r.uint("Tile", 16)), Code: uint16(r.uint("Code", 16)), Value: r.f32("Value"),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	run.Extended = info
+	return nil
+}
+
+//DumpTextLoaders section name -> loader; unknown sections are skipped by ReadDumpText
+var DumpTextLoaders = map[string]func(sec *DumpTextSection, run *Run) error{
+	DUMPTEXT_TILE:              loadTileSection,
+	DUMPTEXT_Q:                 loadQSection,
+	DUMPTEXT_ERROR:             loadErrorSection,
+	DUMPTEXT_EXTRACTION:        loadExtractionSection,
+	DUMPTEXT_CORRECTED_INT:     loadCorrectedIntSection,
+	DUMPTEXT_INDEX:             loadIndexSection,
+	DUMPTEXT_CONTROL:           loadControlSection,
+	DUMPTEXT_IMAGE:             loadImageSection,
+	DUMPTEXT_EMPIRICAL_PHASING: loadEmpiricalPhasingSection,
+	DUMPTEXT_EXTENDED_TILE:     loadExtendedTileSection,
+}
+
+//ReadDumpText metric sets of a dumptext file; RunInfo and RunFolder are left empty
+func ReadDumpText(r io.Reader) (*Run, error) {
+	sections, err := ReadDumpTextSections(r)
+	if err != nil {
+		return nil, err
+	}
+	ret := &Run{ParseErrors: make(map[string]error)}
+	for _, sec := range sections {
+		load, ok := DumpTextLoaders[sec.Name]
+		if !ok {
+			continue
+		}
+		if err := load(sec, ret); err != nil {
+			return nil, fmt.Errorf("%s section err:%s", sec.Name, err.Error())
+		}
+	}
+	return ret, nil
+}
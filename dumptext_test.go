@@ -0,0 +1,210 @@
+package interop
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+//dumpTextQMetrics a few tiles and cycles spread over two Q scores
+func dumpTextQMetrics() *QMetricsInfo {
+	ret := &QMetricsInfo{Version: 4}
+	for _, tileNum := range []uint16{1101, 1102} {
+		for cycle := uint16(1); cycle <= 3; cycle++ {
+			m := new(QMetrics)
+			m.LaneNum, m.TileNum, m.Cycle = 1, tileNum, cycle
+			m.NumClusters[35] = 90000 - 1000*uint32(cycle)
+			m.NumClusters[15] = 10000 + 1000*uint32(cycle) + uint32(tileNum%2)
+			ret.Metrics = append(ret.Metrics, m)
+		}
+	}
+	return ret
+}
+
+func TestDumpTextRoundTrip(t *testing.T) {
+	run := &Run{RunFolder: "test_data", ParseErrors: map[string]error{}}
+	for _, f := range InterOpFiles {
+		if err := f.Load(run, "test_data/InterOp/"+f.Name); err != nil && !strings.Contains(err.Error(), "no such file") {
+			t.Fatalf("%s: %s", f.Name, err.Error())
+		}
+	}
+	run.Q = dumpTextQMetrics()
+
+	var first bytes.Buffer
+	if err := run.DumpText(&first); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"# Tile,2", "# Q,4", "# Error,3", "# Extraction,2", "# Index,1", "# Control,1"} {
+		if !strings.Contains(first.String(), name+"\n") {
+			t.Fatalf("expect section %s", name)
+		}
+	}
+	back, err := ReadDumpText(bytes.NewReader(first.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	back.RunFolder = "test_data"
+	if len(back.Error.Metrics) != len(run.Error.Metrics) || len(back.Index.Metrics) != len(run.Index.Metrics) {
+		t.Fatalf("record count differs after import")
+	}
+	var second bytes.Buffer
+	if err := back.DumpText(&second); err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != second.String() {
+		a, b := strings.Split(first.String(), "\n"), strings.Split(second.String(), "\n")
+		for i := range a {
+			if i >= len(b) || a[i] != b[i] {
+				t.Fatalf("line %d differs after round trip:\n%s", i+1, a[i])
+			}
+		}
+		t.Fatalf("round trip adds %d lines", len(b)-len(a))
+	}
+}
+
+//TestReadDumpTextInterOp hand-written in the layout of InterOp's C++ dumptext, float formatting and -nan included;
+//it checks the importer against our reading of the format, TestDumpTextReference against the C++ tool itself
+
+func TestReadDumpTextInterOp(t *testing.T) {
+	file, err := os.Open("test_data/dumptext/dumptext_v1.1.4.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	run, err := ReadDumpText(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Error == nil || len(run.Error.Metrics) != 4 || run.Error.Metrics[0].ErrorRate != float32(0.271154) {
+		t.Fatalf("unexpected error metrics %+v", run.Error)
+	}
+	if run.Extraction == nil || len(run.Extraction.Metrics) != 2 {
+		t.Fatalf("unexpected extraction metrics %+v", run.Extraction)
+	}
+	if m := run.Extraction.Metrics[1]; m.TileNum != 1102 || m.Intensity_A != 4498 || m.Fwhm_T != float32(2.72563) {
+		t.Fatalf("unexpected extraction record %+v", m)
+	}
+	if run.Q == nil || len(run.Q.Metrics) != 2 || run.Q.Metrics[0].NumClusters[34] != 1006380 {
+		t.Fatalf("unexpected Q metrics %+v", run.Q)
+	}
+	raw, pf := run.Tile.ClustersByTile()
+	if raw[1][1101] != float64(float32(1.23457e+06)) || pf[1][1102] != float64(float32(1.03571e+06)) {
+		t.Fatalf("unexpected cluster counts %v %v", raw, pf)
+	}
+	aligned := map[uint16]float32{}
+	for _, m := range run.Tile.Metrics {
+		if m.MetricCode >= 300 && m.MetricCode < 400 {
+			aligned[m.TileNum] = m.MetricValue
+		}
+		if math.IsNaN(float64(m.MetricValue)) {
+			t.Fatalf("-nan shall not make a record: %+v", m)
+		}
+	}
+	if _, ok := aligned[1102]; ok || aligned[1101] != float32(97.8806) {
+		t.Fatalf("expect read 2 aligned of tile 1101 only, got %v", aligned)
+	}
+}
+
+//DUMPTEXT_REFERENCE output of InterOp's C++ dumptext for test_data, made with
+//
+//	dumptext test_data > test_data/dumptext/test_data.txt
+var DUMPTEXT_REFERENCE = "test_data/dumptext/test_data.txt"
+
+//dumpRowKeys columns that tell rows of a section apart
+var dumpRowKeys = []string{"Lane", "Tile", "Cycle", "Read", "Sequence", "Sample", "Control", "Index", "Code"}
+
+//dumpTextRows rows of sec by their key columns
+func dumpTextRows(sec *DumpTextSection) map[string]map[string]string {
+	idx := sec.index()
+	ret := map[string]map[string]string{}
+	for _, row := range sec.Rows {
+		key := []string{}
+		for _, k := range dumpRowKeys {
+			if i, ok := idx[k]; ok {
+				key = append(key, row[i])
+			}
+		}
+		cells := map[string]string{}
+		for i, h := range sec.Header {
+			cells[h] = row[i]
+		}
+		ret[strings.Join(key, ",")] = cells
+	}
+	return ret
+}
+
+//sameDumpCell equal strings, or numbers within the 6 significant digits the C++ tool prints
+func sameDumpCell(a, b string) bool {
+	if a == b {
+		return true
+	}
+	x, errA := strconv.ParseFloat(strings.TrimPrefix(a, "-"), 64)
+	y, errB := strconv.ParseFloat(strings.TrimPrefix(b, "-"), 64)
+	if errA != nil || errB != nil {
+		return false
+	}
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return math.IsNaN(x) && math.IsNaN(y)
+	}
+	return math.Abs(x-y) <= 1e-5*math.Max(math.Abs(x), math.Abs(y)) && strings.HasPrefix(a, "-") == strings.HasPrefix(b, "-")
+}
+
+//TestDumpTextReference records the C++ dumptext prints for test_data equal what Parse reads from the binaries
+func TestDumpTextReference(t *testing.T) {
+	file, err := os.Open(DUMPTEXT_REFERENCE)
+	if os.IsNotExist(err) {
+		t.Skipf("no C++ dumptext reference at %s", DUMPTEXT_REFERENCE)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reference, err := ReadDumpText(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := &Run{RunFolder: "test_data", ParseErrors: map[string]error{}}
+	for _, f := range InterOpFiles {
+		if err := f.Load(parsed, "test_data/InterOp/"+f.Name); err != nil && !strings.Contains(err.Error(), "no such file") {
+			t.Fatalf("%s: %s", f.Name, err.Error())
+		}
+	}
+
+	want := map[string]*DumpTextSection{}
+	for _, sec := range parsed.DumpTextSections() {
+		want[sec.Name] = sec
+	}
+	got := reference.DumpTextSections()
+	if len(got) == 0 {
+		t.Fatal("reference has no section ReadDumpText knows")
+	}
+	for _, sec := range got {
+		w, ok := want[sec.Name]
+		if !ok {
+			t.Errorf("%s section in the reference, test_data has no such file", sec.Name)
+			continue
+		}
+		if sec.Version != w.Version {
+			t.Errorf("%s version %d, Parse read %d", sec.Name, sec.Version, w.Version)
+		}
+		gotRows, wantRows := dumpTextRows(sec), dumpTextRows(w)
+		if len(gotRows) != len(wantRows) {
+			t.Errorf("%s has %d records in the reference, Parse read %d", sec.Name, len(gotRows), len(wantRows))
+		}
+		for key, cells := range wantRows {
+			ref, ok := gotRows[key]
+			if !ok {
+				t.Errorf("%s record %s missing from the reference", sec.Name, key)
+				continue
+			}
+			for col, v := range cells {
+				if !sameDumpCell(ref[col], v) {
+					t.Errorf("%s record %s %s: reference %s, Parse %s", sec.Name, key, col, ref[col], v)
+				}
+			}
+		}
+	}
+}
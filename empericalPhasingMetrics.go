@@ -6,7 +6,8 @@ import (
 	"fmt"
 	//	"math"
 	"io"
-	"os"
+
+	"github.com/ws6/interop/fcinfo"
 )
 
 type PhasingMetrics struct {
@@ -20,10 +21,11 @@ type EmpericalPhasingInfo struct {
 	Version  uint8
 	SSize    uint8
 	Metrics  []*PhasingMetrics
+	fsys     fcinfo.RunFS //nil reads Filename from disk
 }
 
 func (self *EmpericalPhasingInfo) Parse() error {
-	file, err := os.Open(self.Filename)
+	file, err := openMetricFile(self.fsys, self.Filename)
 	if err != nil {
 		return err
 	}
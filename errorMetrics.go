@@ -6,8 +6,9 @@ import (
 	"fmt"
 	"io"
 	"math"
-	"os"
 	"strconv"
+
+	"github.com/ws6/interop/fcinfo"
 )
 
 var (
@@ -76,6 +77,7 @@ type ErrorInfo struct {
 	Metrics  []*ErrorMetrics
 	Metrics4 []*ErrorMetrics4
 	err      error
+	fsys     fcinfo.RunFS //nil reads Filename from disk
 }
 
 func (self *ErrorInfo) Parse4(buf *bufio.Reader) error {
@@ -99,7 +101,7 @@ func (self *ErrorInfo) Parse() error {
 	if self.err != nil {
 		return self.err
 	}
-	file, err := os.Open(self.Filename)
+	file, err := openMetricFile(self.fsys, self.Filename)
 	if err != nil {
 		self.err = err
 		return self.err
@@ -156,19 +158,16 @@ func (self *ErrorInfo) FilterByTileMap(tm *[]LaneTile) *ErrorInfo {
 	return nil
 }
 
-//GetAvgErrorRateByLane if cycleMap is nil, not to use
-func (self *ErrorInfo) GetAvgErrorRateByLane(laneNum uint16, cycleMap *map[uint16]bool) float64 {
+//GetAvgErrorRateByLane if cycles is nil, not to use
+func (self *ErrorInfo) GetAvgErrorRateByLane(laneNum uint16, cycles fcinfo.CycleSet) float64 {
 	sum := float64(0)
 	cnt := 0
 	for _, m := range self.Metrics {
 		if m.LaneNum != laneNum {
 			continue
 		}
-		if cycleMap != nil {
-			dref := *cycleMap
-			if _, ok := dref[m.Cycle]; !ok {
-				continue
-			}
+		if !cycles.Admits(m.Cycle) {
+			continue
 		}
 
 		cnt++
@@ -181,18 +180,15 @@ func (self *ErrorInfo) GetAvgErrorRateByLane(laneNum uint16, cycleMap *map[uint1
 
 }
 
-func (self *ErrorInfo) GetStatErrorRateByLane(laneNum uint16, cycleMap *map[uint16]bool) (mean float64, stdv float64) {
+func (self *ErrorInfo) GetStatErrorRateByLane(laneNum uint16, cycles fcinfo.CycleSet) (mean float64, stdv float64) {
 	sum, devsum := float64(0), float64(0)
 	cnt := 0
 	for _, m := range self.Metrics {
 		if m.LaneNum != laneNum {
 			continue
 		}
-		if cycleMap != nil {
-			dref := *cycleMap
-			if _, ok := dref[m.Cycle]; !ok {
-				continue
-			}
+		if !cycles.Admits(m.Cycle) {
+			continue
 		}
 
 		cnt++
@@ -207,11 +203,8 @@ func (self *ErrorInfo) GetStatErrorRateByLane(laneNum uint16, cycleMap *map[uint
 		if m.LaneNum != laneNum {
 			continue
 		}
-		if cycleMap != nil {
-			dref := *cycleMap
-			if _, ok := dref[m.Cycle]; !ok {
-				continue
-			}
+		if !cycles.Admits(m.Cycle) {
+			continue
 		}
 
 		v := float64(m.ErrorRate)
@@ -678,3 +671,35 @@ func (self *FlowcellErrorRate) GetBubbleSum(_dim *TileDimension) BubbleSum {
 
 	return ret
 }
+
+//EachRecord visit every record regardless of file version
+func (self *ErrorInfo) EachRecord(fn func(laneNum uint16, tileNum uint32, cycle uint16, errorRate float32)) {
+	for _, m := range self.Metrics {
+		fn(m.LaneNum, uint32(m.TileNum), m.Cycle, m.ErrorRate)
+	}
+	for _, m := range self.Metrics4 {
+		fn(m.LaneNum, m.TileNum, m.Cycle, m.ErrorRate)
+	}
+}
+
+//TruncateAtCycle return a copy only keeps records up to maxCycle(inclusive)
+func (self *ErrorInfo) TruncateAtCycle(maxCycle uint16) *ErrorInfo {
+	ret := &ErrorInfo{
+		Filename: self.Filename,
+		Version:  self.Version,
+		SSize:    self.SSize,
+	}
+	for _, m := range self.Metrics {
+		if m.Cycle > maxCycle {
+			continue
+		}
+		ret.Metrics = append(ret.Metrics, m)
+	}
+	for _, m := range self.Metrics4 {
+		if m.Cycle > maxCycle {
+			continue
+		}
+		ret.Metrics4 = append(ret.Metrics4, m)
+	}
+	return ret
+}
@@ -3,7 +3,8 @@ package interop
 import (
 	"bufio"
 	"encoding/binary"
-	"os"
+
+	"github.com/ws6/interop/fcinfo"
 )
 
 //byte 0: file version number (1)
@@ -33,13 +34,14 @@ type ExtendMetricsInfo struct {
 	SSize    uint8
 	Metrics  []*ExtendMetrics
 	err      error
+	fsys     fcinfo.RunFS //nil reads Filename from disk
 }
 
 func (self *ExtendMetricsInfo) Parse() error {
 	if self.err != nil {
 		return self.err
 	}
-	file, err := os.Open(self.Filename)
+	file, err := openMetricFile(self.fsys, self.Filename)
 	if err != nil {
 		self.err = err
 		return self.err
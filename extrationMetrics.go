@@ -6,8 +6,9 @@ import (
 
 	"io"
 
-	"os"
 	"time"
+
+	"github.com/ws6/interop/fcinfo"
 )
 
 var (
@@ -62,6 +63,7 @@ type ExtractionInfo struct {
 	Metrics3    []*ExtractionMetricsV3
 	MaxCycle    uint64
 	err         error
+	fsys        fcinfo.RunFS //nil reads Filename from disk
 }
 
 //WinToUnixTimeStamp RTA windows timestamp to linux timestamp
@@ -79,7 +81,7 @@ func (self *ExtractionInfo) Parse3(filename string) error {
 	if self.err != nil {
 		return self.err
 	}
-	file, err := os.Open(filename)
+	file, err := openMetricFile(self.fsys, filename)
 	if err != nil {
 		self.err = err
 		return self.err
@@ -153,7 +155,7 @@ func (self *ExtractionInfo) Parse() error {
 	if self.err != nil {
 		return self.err
 	}
-	file, err := os.Open(self.Filename)
+	file, err := openMetricFile(self.fsys, self.Filename)
 	if err != nil {
 		self.err = err
 		return self.err
@@ -0,0 +1,144 @@
+package fcinfo
+
+//completion.go RunCompletionStatus.xml and RTAComplete.txt, how and when a run ended
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//CompletionStatus normalized RunCompletionStatus.xml CompletionStatus
+type CompletionStatus string
+
+var (
+	COMPLETION_UNKNOWN    CompletionStatus = "unknown" //no RunCompletionStatus.xml, or a status not recognized
+	COMPLETION_COMPLETED  CompletionStatus = "completed"
+	COMPLETION_USER_ENDED CompletionStatus = "user_ended"
+	COMPLETION_FAILED     CompletionStatus = "failed"
+
+	//RTAComplete.txt time layouts: RTA 1 writes "11/7/2013,10:48:45.123,Illumina RTA 1.18.54",
+	//RTA 2 "RTA 2.4.11 completed on 6/3/2019 5:20:34 AM"
+	RTA_COMPLETE_LAYOUTS = []string{"1/2/2006,15:04:05.000", "1/2/2006,15:04:05", "1/2/2006 3:04:05 PM", time.RFC3339}
+
+	rtaVersionRe = regexp.MustCompile(`RTA\s+v?(\d+(\.\d+)+)`)
+	rtaTimeRe    = regexp.MustCompile(`(\d{1,2}/\d{1,2}/\d{4}(,| )\d{1,2}:\d{2}:\d{2}(\.\d+)?( [AP]M)?)|(\d{4}-\d{2}-\d{2}T[0-9:.]+(Z|[+-]\d{2}:\d{2}))`)
+)
+
+//RunCompletionStatus RunCompletionStatus.xml
+type RunCompletionStatus struct {
+	XMLName            xml.Name `xml:"RunCompletionStatus"`
+	CompletionStatus   string   `xml:"CompletionStatus"`
+	RunId              string   `xml:"RunId"`
+	ErrorDescription   string   `xml:"ErrorDescription"`
+	NumCyclesExpected  int      `xml:"NumCyclesExpected"`
+	NumCyclesCompleted int      `xml:"NumCyclesCompleted"`
+}
+
+func ParseRunCompletionStatusXML(s string) (*RunCompletionStatus, error) {
+	ret := new(RunCompletionStatus)
+	if err := xml.Unmarshal([]byte(s), ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+//Status CompletedAsPlanned and the like complete, UserEndedEarly is ended by the user, errors and exceptions fail
+func (self *RunCompletionStatus) Status() CompletionStatus {
+	s := strings.ToLower(self.CompletionStatus)
+	switch {
+	case s == "":
+		return COMPLETION_UNKNOWN
+	case strings.Contains(s, "user") || strings.Contains(s, "abort") || strings.Contains(s, "stopped"):
+		return COMPLETION_USER_ENDED
+	case strings.Contains(s, "exception") || strings.Contains(s, "error") || strings.Contains(s, "fail"):
+		return COMPLETION_FAILED
+	case strings.HasPrefix(s, "completed") || strings.Contains(s, "success"):
+		return COMPLETION_COMPLETED
+	}
+	return COMPLETION_UNKNOWN
+}
+
+//Message error description, with cycles done when the run stopped short
+func (self *RunCompletionStatus) Message() string {
+	msg := strings.TrimSpace(self.ErrorDescription)
+	if strings.EqualFold(msg, "none") {
+		msg = ""
+	}
+	if self.NumCyclesExpected > 0 && self.NumCyclesCompleted < self.NumCyclesExpected {
+		cycles := fmt.Sprintf("%d of %d cycles", self.NumCyclesCompleted, self.NumCyclesExpected)
+		if msg == "" {
+			return cycles
+		}
+		return msg + "; " + cycles
+	}
+	return msg
+}
+
+//RTAComplete RTAComplete.txt; Time is zero and RtaVersion empty when the file does not say, RTA 3 writes an empty file.
+//Times without a zone, as RTA 1 and 2 write them, are taken as UTC whatever the zone of the machine reading them
+type RTAComplete struct {
+	Time       time.Time
+	RtaVersion string
+}
+
+func ParseRTAComplete(s string) *RTAComplete {
+	ret := new(RTAComplete)
+	if m := rtaVersionRe.FindStringSubmatch(s); m != nil {
+		ret.RtaVersion = m[1]
+	}
+	if m := rtaTimeRe.FindString(s); m != "" {
+		for _, layout := range RTA_COMPLETE_LAYOUTS {
+			if t, err := time.Parse(layout, m); err == nil {
+				ret.Time = t
+				break
+			}
+		}
+	}
+	return ret
+}
+
+//RunCompletion how a run folder ended as far as its files tell
+type RunCompletion struct {
+	Status          CompletionStatus
+	Message         string
+	CyclesCompleted int          //0 when not told
+	RTAComplete     *RTAComplete //nil without RTAComplete.txt
+}
+
+//ReadRunCompletion RunCompletionStatus.xml and RTAComplete.txt of runFolder; both are optional
+func ReadRunCompletion(runFolder string) *RunCompletion {
+	return ReadRunCompletionFS(DirFS(runFolder))
+}
+
+func ReadRunCompletionFS(fsys RunFS) *RunCompletion {
+	ret := &RunCompletion{Status: COMPLETION_UNKNOWN}
+	if b, err := ReadFileFS(fsys, RUN_COMPLETION_FILE); err == nil {
+		if status, err := ParseRunCompletionStatusXML(string(b)); err == nil {
+			ret.Status, ret.Message, ret.CyclesCompleted = status.Status(), status.Message(), status.NumCyclesCompleted
+		}
+	}
+	if b, err := ReadFileFS(fsys, RTA_COMPLETE_FILE); err == nil {
+		ret.RTAComplete = ParseRTAComplete(string(b))
+	}
+	return ret
+}
+
+//setCompletion fill the completion fields of self from the run folder
+func (self *Flowcell) setCompletion(fsys RunFS) {
+	c := ReadRunCompletionFS(fsys)
+	self.Completion, self.CompletionMessage = c.Status, c.Message
+	if c.RTAComplete == nil {
+		return
+	}
+	if !c.RTAComplete.Time.IsZero() {
+		self.RTACompleteTime = c.RTAComplete.Time.Format(time.RFC3339)
+	}
+	if self.RtaVersion == "" || self.RtaVersion == `undefined` {
+		if c.RTAComplete.RtaVersion != "" {
+			self.RtaVersion = c.RTAComplete.RtaVersion
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package fcinfo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunCompletion(t *testing.T) {
+	for status, want := range map[string]CompletionStatus{
+		"CompletedAsPlanned":  COMPLETION_COMPLETED,
+		"UserEndedEarly":      COMPLETION_USER_ENDED,
+		"ExceptionEndedEarly": COMPLETION_FAILED,
+		"SomethingNew":        COMPLETION_UNKNOWN,
+	} {
+		if got := (&RunCompletionStatus{CompletionStatus: status}).Status(); got != want {
+			t.Fatalf("%s is %s, expect %s", status, got, want)
+		}
+	}
+
+	for s, want := range map[string]RTAComplete{
+		"11/7/2013,10:48:45.123,Illumina RTA 1.18.54":  {time.Date(2013, 11, 7, 10, 48, 45, 123e6, time.UTC), "1.18.54"},
+		"RTA 2.4.11 completed on 6/3/2019 5:20:34 AM":  {time.Date(2019, 6, 3, 5, 20, 34, 0, time.UTC), "2.4.11"},
+		"RTA 3.9.25 completed on 2023-01-02T03:04:05Z": {time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), "3.9.25"},
+		"": {},
+	} {
+		got := ParseRTAComplete(s)
+		if !got.Time.Equal(want.Time) || got.RtaVersion != want.RtaVersion {
+			t.Fatalf("%q parsed %+v, expect %+v", s, got, want)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "fcinfo-completion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"RunInfo.xml":       testRunInfoNovaSeq,
+		"RunParameters.xml": `<RunParameters><Side>A</Side><Application>NovaSeqXPlus Control Software</Application></RunParameters>`,
+		RTA_COMPLETE_FILE:   "RTA 4.6.7 completed on 6/3/2024 5:20:34 AM",
+		RUN_COMPLETION_FILE: `<RunCompletionStatus><CompletionStatus>ExceptionEndedEarly</CompletionStatus><ErrorDescription>Fluidics error</ErrorDescription>
+			<NumCyclesExpected>318</NumCyclesExpected><NumCyclesCompleted>120</NumCyclesCompleted></RunCompletionStatus>`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, sub := range []string{"Data", "InterOp"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fc, err := ParseFlowcellRunFolder(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fc.Completion != COMPLETION_FAILED || fc.CompletionMessage != "Fluidics error; 120 of 318 cycles" || fc.RtaVersion != "4.6.7" || fc.RTACompleteTime == "" {
+		t.Fatalf("flowcell %+v", fc)
+	}
+}
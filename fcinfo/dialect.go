@@ -0,0 +1,296 @@
+package fcinfo
+
+//dialect.go RunParameters.xml flavours. Each instrument family writes its own layout; a dialect tells its
+//layout apart from the fields in RunParamsSniff and parses it into RunParams.
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	NOVASEQ_X   = "NovaSeqX"
+	MISEQ_I100  = "MiSeqi100"
+	NEXTSEQ_2K  = "Vega" //NextSeq 1000/2000
+	ISEQ        = FIRE_FLY
+	DIALECT_SEP = ", "
+)
+
+//RunParamsSniff fields dialects are told apart by, decoded once per document
+type RunParamsSniff struct {
+	XMLName              xml.Name
+	SetupApplicationName string `xml:"Setup>ApplicationName"`
+	ApplicationName      string `xml:"ApplicationName"`
+	Application          string `xml:"Application"`
+	InstrumentType       string `xml:"InstrumentType"`
+}
+
+//AppName Setup>ApplicationName, else ApplicationName
+func (self *RunParamsSniff) AppName() string {
+	if self.SetupApplicationName != "" {
+		return self.SetupApplicationName
+	}
+	return self.ApplicationName
+}
+
+//Any true when re matches the application name, Application or InstrumentType
+func (self *RunParamsSniff) Any(re *regexp.Regexp) bool {
+	return re.MatchString(self.AppName()) || re.MatchString(self.Application) || re.MatchString(self.InstrumentType)
+}
+
+type RunParamsDialect struct {
+	Name  string
+	Sniff func(sniff *RunParamsSniff) bool
+	Parse func(runParamString string) (*RunParams, error)
+}
+
+var (
+	dialectsMu sync.RWMutex
+	dialects   = builtinDialects()
+)
+
+//RegisterRunParamsDialect adds d ahead of the dialects already known, so it can take over a layout they sniff
+func RegisterRunParamsDialect(d *RunParamsDialect) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	dialects = append([]*RunParamsDialect{d}, dialects...)
+}
+
+//RunParamsDialects in the order they are tried
+func RunParamsDialects() []*RunParamsDialect {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	return append([]*RunParamsDialect{}, dialects...)
+}
+
+//ParseRunParamsXML parse with the first dialect sniffing runParamString
+func ParseRunParamsXML(runParamString string) (*RunParams, error) {
+	sniff := new(RunParamsSniff)
+	if err := xml.Unmarshal([]byte(runParamString), sniff); err != nil {
+		return nil, err
+	}
+	tried := []string{}
+	for _, d := range RunParamsDialects() {
+		if d.Sniff(sniff) {
+			return d.Parse(runParamString)
+		}
+		tried = append(tried, d.Name)
+	}
+	app := sniff.AppName()
+	if app == "" {
+		app = sniff.Application
+	}
+	return nil, fmt.Errorf("Can not find a properate parser for Application %s, tried dialects: %s", app, strings.Join(tried, DIALECT_SEP))
+}
+
+//preDialect dialect of the common RunParamsPre layout, sniffed on the application name
+func preDialect(name, pattern string, parse func(*RunParamsPre) (*RunParams, error)) *RunParamsDialect {
+	re := regexp.MustCompile(pattern)
+	return &RunParamsDialect{
+		Name: name,
+		Sniff: func(sniff *RunParamsSniff) bool {
+			return re.MatchString(sniff.AppName())
+		},
+		Parse: func(runParamString string) (*RunParams, error) {
+			pre, err := ParseRunParamsXMLPre(runParamString)
+			if err != nil {
+				return nil, err
+			}
+			return parse(pre)
+		},
+	}
+}
+
+func builtinDialects() []*RunParamsDialect {
+	novaSeqX := regexp.MustCompile(`(?i)NovaSeq ?X`)
+	miSeqI100 := regexp.MustCompile(`(?i)MiSeq ?i100`)
+	nextSeq2k := regexp.MustCompile(`(?i)NextSeq 1000/2000|Vega`)
+	voyager := regexp.MustCompile(`(?i)Voyager|NovaSeq`)
+	iSeq := regexp.MustCompile(`(?i)\biSeq`)
+	return []*RunParamsDialect{
+		//the newer layouts first, their names contain the older ones
+		{
+			Name:  "NovaSeq X",
+			Sniff: func(sniff *RunParamsSniff) bool { return sniff.Any(novaSeqX) },
+			Parse: func(s string) (*RunParams, error) { return parseRunParamsSeries(s, NOVASEQ_X) },
+		},
+		{
+			Name:  "MiSeq i100",
+			Sniff: func(sniff *RunParamsSniff) bool { return sniff.Any(miSeqI100) },
+			Parse: func(s string) (*RunParams, error) { return parseRunParamsSeries(s, MISEQ_I100) },
+		},
+		{
+			Name:  "NextSeq 1000/2000",
+			Sniff: func(sniff *RunParamsSniff) bool { return nextSeq2k.MatchString(sniff.AppName()) },
+			Parse: parseRunParamsNextSeq2k,
+		},
+		preDialect("HiSeq X", `(?i)HiSeq X`, parseRunParamHiSeqX),
+		preDialect("HiSeq", `(?i)HiSeq`, parseRunParamHiSeq),
+		preDialect("Merlion", `(?i)Merlion`, parseRunParamMerlion),
+		preDialect("MiniSeq", `(?i)MiniSeq`, parseRunParamMiniSeq),
+		preDialect("Avatar", `(?i)Avatar`, parseRunParamAvatar),
+		preDialect("MiSeq", `(?i)MiSeq`, parseRunParamMiSeq),
+		preDialect("Firefly", `(?i)Firefly`, parseRunParamFirefly),
+		preDialect("NextSeq", `(?i)NextSeq`, parseRunParamNextSeq),
+		preDialect("Project 11", `(?i)Project 11`, parseRunParamP11),
+		{
+			Name:  "Voyager",
+			Sniff: func(sniff *RunParamsSniff) bool { return voyager.MatchString(sniff.Application) },
+			Parse: func(s string) (*RunParams, error) {
+				pre, err := ParseRunParamsXMLVoyager(s)
+				if err != nil {
+					return nil, err
+				}
+				return parseRunParamVoyager(pre)
+			},
+		},
+		{
+			Name:  "iSeq 100",
+			Sniff: func(sniff *RunParamsSniff) bool { return iSeq.MatchString(sniff.ApplicationName) },
+			Parse: parseRunParamsISeq,
+		},
+	}
+}
+
+//RunParamsPlannedRead <Read ReadName="Read1" Cycles="151" />
+type RunParamsPlannedRead struct {
+	ReadName string `xml:"ReadName,attr"`
+	Cycles   int    `xml:"Cycles,attr"`
+}
+
+type RunParamsConsumable struct {
+	Type         string `xml:"Type"`
+	Mode         string `xml:"Mode"`
+	SerialNumber string `xml:"SerialNumber"`
+	Version      string `xml:"Version"`
+}
+
+//RunParamsSeries layout of the series control software, NovaSeq X and MiSeq i100
+type RunParamsSeries struct {
+	XMLName            xml.Name               `xml:"RunParameters"`
+	Side               string                 `xml:"Side"`
+	Application        string                 `xml:"Application"`
+	ApplicationVersion string                 `xml:"ApplicationVersion"`
+	SystemSuiteVersion string                 `xml:"SystemSuiteVersion"`
+	InstrumentType     string                 `xml:"InstrumentType"`
+	OutputFolder       string                 `xml:"OutputFolder"`
+	RecipeName         string                 `xml:"RecipeName"`
+	PlannedReads       []RunParamsPlannedRead `xml:"PlannedReads>Read"`
+	Consumables        []RunParamsConsumable  `xml:"ConsumableInfo>ConsumableInfo"`
+}
+
+//setPlanned fill the planned cycles of the read named Read1, Read2, Index1 or Index2; 0 cycles are left unset
+func (self *RunParams) setPlanned(name string, cycles int) {
+	if cycles <= 0 {
+		return
+	}
+	s := strconv.Itoa(cycles)
+	switch strings.ToLower(name) {
+	case "read1":
+		self.PlannedRead1Cycles = s
+	case "read2":
+		self.PlannedRead2Cycles = s
+	case "index1":
+		self.PlannedIndex1ReadCycles = s
+	case "index2":
+		self.PlannedIndex2ReadCycles = s
+	}
+}
+
+func parseRunParamsSeries(runParamString, instrumentType string) (*RunParams, error) {
+	pre := new(RunParamsSeries)
+	if err := xml.Unmarshal([]byte(runParamString), pre); err != nil {
+		return nil, err
+	}
+	runParam := new(RunParams)
+	runParam.ApplicationName = pre.Application
+	runParam.ApplicationVersion = pre.ApplicationVersion
+	if runParam.ApplicationVersion == "" {
+		runParam.ApplicationVersion = pre.SystemSuiteVersion
+	}
+	runParam.InstrumentType = instrumentType
+	runParam.FPGVersion = `undefined`
+	runParam.RTAVersion = `undefined`
+	runParam.RecipeFragmentVersion = `undefined`
+	runParam.RunParamOutPutFolder = pre.OutputFolder
+	runParam.RecipePath = pre.RecipeName
+	runParam.FCPosition = pre.Side
+	for _, c := range pre.Consumables {
+		switch {
+		case strings.EqualFold(c.Type, "FlowCell"):
+			runParam.FlowcellMode = c.Mode
+		case strings.EqualFold(c.Type, "Reagent"):
+			runParam.Chemistry = c.Version
+		}
+	}
+	for _, r := range pre.PlannedReads {
+		runParam.setPlanned(r.ReadName, r.Cycles)
+	}
+	return runParam, nil
+}
+
+//RunParamsNextSeq2k planned cycles of NextSeq 1000/2000, the rest is read through RunParamsPre
+type RunParamsNextSeq2k struct {
+	XMLName      xml.Name `xml:"RunParameters"`
+	FlowCellType string   `xml:"FlowCellType"`
+	Read1        int      `xml:"PlannedCycles>Read1"`
+	Read2        int      `xml:"PlannedCycles>Read2"`
+	Index1       int      `xml:"PlannedCycles>Index1"`
+	Index2       int      `xml:"PlannedCycles>Index2"`
+}
+
+func parseRunParamsNextSeq2k(runParamString string) (*RunParams, error) {
+	pre, err := ParseRunParamsXMLPre(runParamString)
+	if err != nil {
+		return nil, err
+	}
+	runParam, err := parseRunParamMiSeq(pre)
+	if err != nil {
+		return nil, err
+	}
+	runParam.InstrumentType = NEXTSEQ_2K
+	planned := new(RunParamsNextSeq2k)
+	if err := xml.Unmarshal([]byte(runParamString), planned); err != nil {
+		return nil, err
+	}
+	runParam.FlowcellMode = planned.FlowCellType
+	runParam.setPlanned("Read1", planned.Read1)
+	runParam.setPlanned("Read2", planned.Read2)
+	runParam.setPlanned("Index1", planned.Index1)
+	runParam.setPlanned("Index2", planned.Index2)
+	return runParam, nil
+}
+
+//RunParamsISeq planned cycles of iSeq 100, the rest is read through RunParamsFireflyProto2
+type RunParamsISeq struct {
+	XMLName xml.Name `xml:"RunParameters"`
+	Read1   int      `xml:"PlannedRead1Cycles"`
+	Read2   int      `xml:"PlannedRead2Cycles"`
+	Index1  int      `xml:"PlannedIndex1ReadCycles"`
+	Index2  int      `xml:"PlannedIndex2ReadCycles"`
+}
+
+func parseRunParamsISeq(runParamString string) (*RunParams, error) {
+	pre, err := ParseRunParamsXMLFireflyProto2(runParamString)
+	if err != nil {
+		return nil, err
+	}
+	runParam, err := parseRunParamFireflyProto2(pre)
+	if err != nil {
+		return nil, err
+	}
+	runParam.InstrumentType = ISEQ
+	planned := new(RunParamsISeq)
+	if err := xml.Unmarshal([]byte(runParamString), planned); err != nil {
+		return nil, err
+	}
+	runParam.setPlanned("Read1", planned.Read1)
+	runParam.setPlanned("Read2", planned.Read2)
+	runParam.setPlanned("Index1", planned.Index1)
+	runParam.setPlanned("Index2", planned.Index2)
+	return runParam, nil
+}
@@ -0,0 +1,57 @@
+package fcinfo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRunParamsXMLDialects(t *testing.T) {
+	cases := []struct {
+		xml            string
+		instrumentType string
+		read1, index2  string
+	}{
+		{`<RunParameters><Setup><ApplicationName>HiSeq X Control Software</ApplicationName><FCPosition>A</FCPosition></Setup></RunParameters>`, "HiSeqX", "", ""},
+		{`<RunParameters><Setup><ApplicationName>MiSeq Control Software</ApplicationName></Setup><Chemistry>Amplicon</Chemistry></RunParameters>`, "MiSeq", "", ""},
+		{`<RunParameters><Application>NovaSeq Control Software</Application><RunId>200101_A00123_0001_AHXXXXXX</RunId></RunParameters>`, VOYAGER, "", ""},
+		{`<RunParameters><Side>B</Side><Application>NovaSeqXPlus Control Software</Application><InstrumentType>NovaSeqXPlus</InstrumentType>
+			<PlannedReads><Read ReadName="Read1" Cycles="151" /><Read ReadName="Index1" Cycles="10" /><Read ReadName="Index2" Cycles="10" /><Read ReadName="Read2" Cycles="151" /></PlannedReads>
+			<ConsumableInfo><ConsumableInfo><Type>FlowCell</Type><Mode>10B</Mode></ConsumableInfo></ConsumableInfo></RunParameters>`, NOVASEQ_X, "151", "10"},
+		{`<RunParameters><Application>MiSeq i100 Series Control Software</Application><PlannedReads><Read ReadName="Read1" Cycles="301" /></PlannedReads></RunParameters>`, MISEQ_I100, "301", ""},
+		{`<RunParameters><ApplicationName>NextSeq 1000/2000 Control Software</ApplicationName><FlowCellType>P2</FlowCellType>
+			<PlannedCycles><Read1>101</Read1><Index1>8</Index1><Index2>8</Index2><Read2>101</Read2></PlannedCycles></RunParameters>`, NEXTSEQ_2K, "101", "8"},
+		{`<RunParameters><ApplicationName>NextSeq Control Software</ApplicationName></RunParameters>`, "NextSeq", "", ""},
+		{`<RunParameters><ApplicationName>iSeq Control Software</ApplicationName><OutputFolderPath>D:\Runs</OutputFolderPath><PlannedRead1Cycles>151</PlannedRead1Cycles></RunParameters>`, ISEQ, "151", ""},
+	}
+	for _, c := range cases {
+		rp, err := ParseRunParamsXML(c.xml)
+		if err != nil {
+			t.Fatalf("%s err:%s", c.instrumentType, err.Error())
+		}
+		if rp.InstrumentType != c.instrumentType || rp.PlannedRead1Cycles != c.read1 || rp.PlannedIndex2ReadCycles != c.index2 {
+			t.Fatalf("expect %s %s %s got %+v", c.instrumentType, c.read1, c.index2, rp)
+		}
+	}
+
+	_, err := ParseRunParamsXML(`<RunParameters><ApplicationName>Sequel</ApplicationName></RunParameters>`)
+	if err == nil || !strings.Contains(err.Error(), "NovaSeq X, MiSeq i100") || !strings.Contains(err.Error(), "iSeq 100") {
+		t.Fatalf("unknown dialect err %v", err)
+	}
+
+	//the registry is global, put it back for other tests
+	saved := RunParamsDialects()
+	defer func() {
+		dialectsMu.Lock()
+		dialects = saved
+		dialectsMu.Unlock()
+	}()
+	RegisterRunParamsDialect(&RunParamsDialect{
+		Name:  "Sequel",
+		Sniff: func(sniff *RunParamsSniff) bool { return sniff.AppName() == "Sequel" },
+		Parse: func(string) (*RunParams, error) { return &RunParams{InstrumentType: "Sequel"}, nil },
+	})
+	rp, err := ParseRunParamsXML(`<RunParameters><ApplicationName>Sequel</ApplicationName></RunParameters>`)
+	if err != nil || rp.InstrumentType != "Sequel" {
+		t.Fatalf("registered dialect %+v %v", rp, err)
+	}
+}
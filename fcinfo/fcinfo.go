@@ -2,13 +2,11 @@ package fcinfo
 
 import (
 	"encoding/xml"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -47,6 +45,9 @@ type Flowcell struct {
 	Chemistry            string
 	Cycles               int
 	RecipePath           string
+	Completion           CompletionStatus //RunCompletionStatus.xml
+	CompletionMessage    string
+	RTACompleteTime      string //RFC3339 time in RTAComplete.txt
 }
 
 type RunFolder struct {
@@ -83,6 +84,7 @@ type RunParams struct {
 	RecipeFragmentVersion string
 	FCPosition            string
 	RunStartDate          string
+	FlowcellMode          string //S4, P2, 10B, as the instrument names it
 
 	PlannedRead1Cycles      string
 	PlannedRead2Cycles      string
@@ -126,6 +128,7 @@ type RunParamsVoyager struct {
 	IsRehyb              string   `xml:"IsRehyb"`
 	RecipeFilePath       string   `xml:"RecipeFilePath"`
 	RunId                string   `xml:"RunId"`
+	FlowCellMode         string   `xml:"RfidsInfo>FlowCellMode"`
 	//	RunStartDate         string   `xml:"RunStartDate"`
 	//	FPGAVersion            string   `xml:"FPGAVersion"`
 	//	RTAVersion             string `xml:"RTAVersion"`
@@ -146,36 +149,46 @@ type RunParamsFireflyProto2 struct {
 //self close only use attributes of each <Read />
 type RunInfoReads struct {
 	// <Read Number="1" NumCycles="126" IsIndexedRead="N" />
-	Number        int    `xml:"Number,attr"`
-	NumCycles     int    `xml:"NumCycles,attr"`
-	IsIndexedRead string `xml:"IsIndexedRead,attr"` //"N" or "Y"
-	FirstCycle    int    `xml:"FirstCycle,attr"`
-	LastCycle     int    `xml:"LastCycle,attr"`
+	Number              int    `xml:"Number,attr"`
+	NumCycles           int    `xml:"NumCycles,attr,omitempty"`
+	IsIndexedRead       string `xml:"IsIndexedRead,attr"`                 //"N" or "Y"
+	IsReverseComplement string `xml:"IsReverseComplement,attr,omitempty"` //"Y" on i5 read on the reverse strand
+	FirstCycle          int    `xml:"FirstCycle,attr,omitempty"`
+	LastCycle           int    `xml:"LastCycle,attr,omitempty"`
 	//	 FirstCycle="1" LastCycle="101"
 }
 
 //<FlowcellLayout LaneCount="1" SurfaceCount="2" SwathCount="1" TileCount="14" />
 type RunInfoFlowcellLayout struct {
-	LaneCount    int `xml:"LaneCount,attr"`
-	SurfaceCount int `xml:"SurfaceCount,attr"`
-	SwathCount   int `xml:"SwathCount,attr"`
-	TileCount    int `xml:"TileCount,attr"`
+	LaneCount      int             `xml:"LaneCount,attr"`
+	SurfaceCount   int             `xml:"SurfaceCount,attr"`
+	SwathCount     int             `xml:"SwathCount,attr"`
+	TileCount      int             `xml:"TileCount,attr"`
+	SectionPerLane int             `xml:"SectionPerLane,attr,omitempty"`
+	LanePerSection int             `xml:"LanePerSection,attr,omitempty"`
+	TileSet        *RunInfoTileSet `xml:"TileSet,omitempty"`
 }
 
 //<Run Id="140203_M00805_0281_000000000-A7K65" Number="280">
 type RunInfoRun struct {
-	RunId           string                `xml:"Id,attr"`
-	RunNumber       string                `xml:"Number,attr"`
-	Date            string                `xml:"Date"`
-	Instrument      string                `xml:"Instrument"`
-	FlowcellBarcode string                `xml:"Flowcell"`
-	FlowcellLayout  RunInfoFlowcellLayout `xml:"FlowcellLayout"`
-	Reads           []RunInfoReads        `xml:"Reads>Read"`
+	RunId           string                  `xml:"Id,attr"`
+	RunNumber       string                  `xml:"Number,attr"`
+	FlowcellBarcode string                  `xml:"Flowcell"`
+	Instrument      string                  `xml:"Instrument"`
+	Date            string                  `xml:"Date"`
+	Reads           []RunInfoReads          `xml:"Reads>Read"`
+	FlowcellLayout  RunInfoFlowcellLayout   `xml:"FlowcellLayout"`
+	AlignToPhiX     []int                   `xml:"AlignToPhiX>Lane,omitempty"`
+	ImageDimensions *RunInfoImageDimensions `xml:"ImageDimensions,omitempty"`
+	ImageChannels   []string                `xml:"ImageChannels>Name,omitempty"`
+	Extra           []RunInfoElement        `xml:",any"` //elements not modelled above, kept for round trips
 }
 
 type RunInfo struct {
-	XMLName xml.Name `xml:"RunInfo"`
-	Run     RunInfoRun
+	XMLName xml.Name         `xml:"RunInfo"`
+	Version string           `xml:"Version,attr,omitempty"`
+	Run     RunInfoRun       `xml:"Run"`
+	Extra   []RunInfoElement `xml:",any"`
 }
 
 func ParseRunInfoXML(runInfoString string) (runInfo *RunInfo, err error) {
@@ -216,6 +229,7 @@ func parseRunParamVoyager(runParamsPre *RunParamsVoyager) (runParam *RunParams,
 	runParam.RunParamOutPutFolder = runParamsPre.RunParamOutPutFolder
 	runParam.RecipeFragmentVersion = `undefined`
 	runParam.RunStartDate = runParamsPre.RunStartDate // overwrite from runInfo.xml's
+	runParam.FlowcellMode = runParamsPre.FlowCellMode
 	//guestimate the position
 	sp := strings.Split(runParamsPre.RunId, "_")
 	if len(sp) > 0 {
@@ -347,11 +361,6 @@ func parseRunParamNextSeq(runParamsPre *RunParamsPre) (runParam *RunParams, err
 		return
 	}
 	runParam.InstrumentType = "NextSeq"
-	if strings.Contains(runParam.ApplicationName, "NextSeq 1000/2000") ||
-		strings.Contains(runParam.ApplicationName, "Vega") {
-		runParam.InstrumentType = `Vega`
-
-	}
 	return
 }
 
@@ -364,136 +373,19 @@ func parseRunParamP11(runParamsPre *RunParamsPre) (runParam *RunParams, err erro
 	return
 }
 
-func ParseRunParamsXML(runParamString string) (*RunParams, error) {
-	runParamPre, err := ParseRunParamsXMLPre(runParamString)
-
-	if err != nil {
-		return nil, err
-	}
-
-	//	ApplicationName := runParamPre.SetUp.ApplicationName
-	ApplicationName := runParamPre.Setup.ApplicationName
-
-	if ApplicationName == "" {
-		ApplicationName = runParamPre.ApplicationName
-	}
-
-	matched, _ := regexp.MatchString("(?i)HiSeq X", ApplicationName)
-	if matched {
-		return parseRunParamHiSeqX(runParamPre)
-	}
-	matched, _ = regexp.MatchString("(?i)HiSeq", ApplicationName)
-	if matched {
-		return parseRunParamHiSeq(runParamPre)
-	}
-
-	matched, _ = regexp.MatchString("(?i)Merlion", ApplicationName)
-	if matched {
-		return parseRunParamMerlion(runParamPre)
-	}
-	matched, _ = regexp.MatchString("(?i)MiniSeq", ApplicationName)
-	if matched {
-		return parseRunParamMiniSeq(runParamPre)
-	}
-	//parseRunParamAvatar
-	matched, _ = regexp.MatchString("(?i)Avatar", ApplicationName)
-	if matched {
-		return parseRunParamAvatar(runParamPre)
-	}
-	matched, _ = regexp.MatchString("(?i)MiSeq", ApplicationName)
-	if matched {
-		return parseRunParamMiSeq(runParamPre)
-	}
-
-	matched, _ = regexp.MatchString("(?i)Firefly", ApplicationName)
-	if matched {
-		firefly, _ := parseRunParamFirefly(runParamPre)
-
-		return firefly, nil
-	}
-
-	matched, _ = regexp.MatchString("(?i)NextSeq", ApplicationName)
-	if matched {
-		return parseRunParamNextSeq(runParamPre)
-	}
-
-	matched, _ = regexp.MatchString("(?i)Project 11", ApplicationName)
-	if matched {
-		return parseRunParamP11(runParamPre)
-	}
-
-	//	matched, _ = regexp.MatchString("(?i)Voyager", ApplicationName)
-	//	if matched {
-	//		return parseRunParamVoyager(runParamPre)
-	//	}
-	//	fmt.Printf("%+v\n", runParamPre)
-	//TODO parse out Voyager
-	runParamPreVoyager, err := ParseRunParamsXMLVoyager(runParamString)
-	if err == nil {
-		matched, _ = regexp.MatchString("(?i)Voyager", runParamPreVoyager.Application)
-		if matched {
-			return parseRunParamVoyager(runParamPreVoyager)
-		}
-		//NovaSeq
-		matched, _ = regexp.MatchString("(?i)NovaSeq", runParamPreVoyager.Application)
-		if matched {
-			return parseRunParamVoyager(runParamPreVoyager)
-		}
-		//		fmt.Printf("%+v\n", runParamPreVoyager)
-	}
-	//TODO parse firefly proto2
-	//ParseRunParamsXMLFireflyProto2
-	runParamPreFireflyProto2, err := ParseRunParamsXMLFireflyProto2(runParamString)
-	if err == nil {
-		matched, _ = regexp.MatchString("(?i)Firefly", runParamPreFireflyProto2.ApplicationName)
-		if matched {
-			return parseRunParamFireflyProto2(runParamPreFireflyProto2)
-		}
-		matchedISeq, _ := regexp.MatchString("(?i)iSeq", runParamPreFireflyProto2.ApplicationName)
-		if matchedISeq {
-			return parseRunParamFireflyProto2(runParamPreFireflyProto2)
-		}
-
-	}
-
-	//iSeq Control Software
-
-	return nil, errors.New("Can not find a properate parser for Application " + ApplicationName)
-}
-
 func calcCycles(runInfo *RunInfo) int {
-	ret := 0
-	ga2Cycle := 0
-	for _, r := range runInfo.Run.Reads {
-
-		ret += r.NumCycles
-		ga2Cycle = r.LastCycle
-	}
-	if ret != 0 {
-		return ret
-	}
-	return ga2Cycle
+	_, end := runInfo.readSpan(len(runInfo.Run.Reads))
+	return end
 }
 
 func GetCycles(runInfo *RunInfo) int {
 	return calcCycles(runInfo)
 }
 func (runInfo *RunInfo) GetNumNonIndexReads() int {
-	total := 0
-	for _, r := range runInfo.Run.Reads {
-		if r.IsIndexedRead != "Y" {
-			total++
-		}
-	}
-	return total
+	return len(runInfo.ReadsOfType(false))
 }
 func isIndexed(runInfo *RunInfo) bool {
-	for _, r := range runInfo.Run.Reads {
-		if r.IsIndexedRead == "Y" {
-			return true
-		}
-	}
-	return false
+	return len(runInfo.ReadsOfType(true)) > 0
 }
 func isIndexedString(runInfo *RunInfo) string {
 	if isIndexed(runInfo) {
@@ -507,131 +399,39 @@ func (runInfo *RunInfo) GetNumReads() int {
 }
 
 func (runInfo *RunInfo) GetNumCycles() int {
-	ret := 0
-	for _, r := range runInfo.Run.Reads {
-		ret += int(r.NumCycles)
-	}
 	//for compatable with GAIIX RunInfo.xml
-	if ret == 0 {
-		for _, r := range runInfo.Run.Reads {
-			ret = r.LastCycle
-		}
-	}
-	return ret
+	return calcCycles(runInfo)
 }
 
-func (runInfo *RunInfo) GetCyclesMapByRead(readNum int) map[uint16]bool {
-	ret := make(map[uint16]bool)
-	start, end := 0, 0
-	for i := 0; i < readNum; i++ {
-		if i >= len(runInfo.Run.Reads) {
-			break
-		}
-		end += runInfo.Run.Reads[i].NumCycles
-		start = end - runInfo.Run.Reads[i].NumCycles + 1
-		//for GAIIX
-		if runInfo.Run.Reads[i].NumCycles == 0 {
-			end = runInfo.Run.Reads[i].LastCycle
-			start = runInfo.Run.Reads[i].FirstCycle
-		}
-	}
-	//Remove first and last cycle
-	for i := start; i <= end; i++ {
-		ret[uint16(i)] = true
-	}
-	return ret
+func (runInfo *RunInfo) GetCyclesMapByRead(readNum int) CycleSet {
+	start, end := runInfo.readSpan(readNum)
+	return CycleSpan(start, end)
 }
 
 //todo get first cycle map R1 && R2
-func (runInfo *RunInfo) GetFirstCyclesMapByRead(readNum int) map[uint16]bool {
-	ret := make(map[uint16]bool)
-	start, end := 0, 0
-	for i := 0; i < readNum; i++ {
-		if i >= len(runInfo.Run.Reads) {
-			break
-		}
-		end += runInfo.Run.Reads[i].NumCycles
-		start = end - runInfo.Run.Reads[i].NumCycles + 1
-		//for GAIIX
-		if runInfo.Run.Reads[i].NumCycles == 0 {
-			end = runInfo.Run.Reads[i].LastCycle
-			start = runInfo.Run.Reads[i].FirstCycle
-		}
-
-	}
-	ret[uint16(start)] = true
-	return ret
+func (runInfo *RunInfo) GetFirstCyclesMapByRead(readNum int) CycleSet {
+	start, _ := runInfo.readSpan(readNum)
+	return NewCycleSet(uint16(start))
 }
 
-func (runInfo *RunInfo) GetLastCyclesMapByRead(readNum int) map[uint16]bool {
-	ret := make(map[uint16]bool)
-	start, end := 0, 0
-	_ = start
-	for i := 0; i < readNum; i++ {
-		if i >= len(runInfo.Run.Reads) {
-			break
-		}
-		end += runInfo.Run.Reads[i].NumCycles
-		start = end - runInfo.Run.Reads[i].NumCycles + 1
-		//for GAIIX
-		if runInfo.Run.Reads[i].NumCycles == 0 {
-			end = runInfo.Run.Reads[i].LastCycle
-			start = runInfo.Run.Reads[i].FirstCycle
-		}
-
-	}
-	ret[uint16(end)] = true
-	return ret
+func (runInfo *RunInfo) GetLastCyclesMapByRead(readNum int) CycleSet {
+	_, end := runInfo.readSpan(readNum)
+	return NewCycleSet(uint16(end))
 }
 
-//Todo get last N cycle map R1 && R2
-func (runInfo *RunInfo) GetLastNCyclesMapByRead(readNum, n int) map[uint16]bool {
-	ret := make(map[uint16]bool)
-	start, end := 0, 0
-	for i := 0; i < readNum; i++ {
-		if i >= len(runInfo.Run.Reads) {
-			break
-		}
-		end += runInfo.Run.Reads[i].NumCycles
-		start = end - runInfo.Run.Reads[i].NumCycles + 1
-		//for GAIIX
-		if runInfo.Run.Reads[i].NumCycles == 0 {
-			end = runInfo.Run.Reads[i].LastCycle
-			start = runInfo.Run.Reads[i].FirstCycle
-		}
-
-	}
-
-	cycleStart := start
-	if n > (end - start + 1) {
-		cycleStart = start
-	}
-
-	for i := cycleStart; i <= end; i++ {
-		ret[uint16(i)] = true
+//GetLastNCyclesMapByRead last n cycles of read readNum, the whole read when it is n cycles or shorter.
+//It used to return the whole read whatever n was.
+func (runInfo *RunInfo) GetLastNCyclesMapByRead(readNum, n int) CycleSet {
+	start, end := runInfo.readSpan(readNum)
+	if n < end-start+1 {
+		start = end - n + 1
 	}
-	return ret
+	return CycleSpan(start, end)
 }
 
 func (runInfo *RunInfo) GetFirstLastCyclesByRead(readNum int) []uint16 {
-	ret := make([]uint16, 2)
-	start, end := 0, 0
-	for i := 0; i < readNum; i++ {
-		if i >= len(runInfo.Run.Reads) {
-			break
-		}
-		end += runInfo.Run.Reads[i].NumCycles
-		start = end - runInfo.Run.Reads[i].NumCycles + 1
-		//for GAIIX
-		if runInfo.Run.Reads[i].NumCycles == 0 {
-			end = runInfo.Run.Reads[i].LastCycle
-			start = runInfo.Run.Reads[i].FirstCycle
-		}
-
-	}
-	ret[0] = uint16(start)
-	ret[1] = uint16(end)
-	return ret
+	start, end := runInfo.readSpan(readNum)
+	return []uint16{uint16(start), uint16(end)}
 }
 
 func calcReadLengthString(runInfo *RunInfo) string {
@@ -780,38 +580,42 @@ func _CheckFlowcellRunFolder(dirIn string) (*RunFolder, error) {
 }
 
 func CheckFlowcellRunFolder(dirIn string) (*RunFolder, error) {
-	ret := new(RunFolder)
-
 	dir, err := filepath.Abs(dirIn)
 
 	if err != nil {
 		return nil, fmt.Errorf("filepath.Abs(dirIn) error : %s", dirIn)
 	}
+	ret, err := CheckFlowcellRunFolderFS(DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+	ret.RunFolder = dir
+	ret.RunInfoFileName = filepath.Join(dir, ret.RunInfoFileName)
+	if ret.RunParameterFileName != "" {
+		ret.RunParameterFileName = filepath.Join(dir, ret.RunParameterFileName)
+	}
+	return ret, nil
+}
 
-	//fast error return
-	dataFolderJoin := filepath.Join(dir, "Data")
-	interOpFolderJoin := filepath.Join(dir, "InterOp")
+//CheckFlowcellRunFolderFS same as CheckFlowcellRunFolder, file names are relative to fsys and RunFolder is left empty
+func CheckFlowcellRunFolderFS(fsys RunFS) (*RunFolder, error) {
+	ret := new(RunFolder)
 
-	if _, err := os.Stat(dataFolderJoin); err != nil {
+	//fast error return
+	if _, err := fsys.Stat("Data"); err != nil {
 		return nil, fmt.Errorf("read Data/ folder err:%s", err.Error())
 	}
 
-	if _, err := os.Stat(interOpFolderJoin); err != nil {
+	if _, err := fsys.Stat("InterOp"); err != nil {
 		return nil, fmt.Errorf("read InterOp/ folder err:%s", err.Error())
 	}
 
-	relFiles, err := ioutil.ReadDir(dir)
+	relFiles, err := fsys.ReadDir(".")
 	if err != nil {
 		return nil, fmt.Errorf("read dir error:%s", err.Error())
 	}
 
-	files := []string{}
-
-	for _, f := range relFiles {
-		files = append(files, filepath.Join(dir, f.Name()))
-	}
-
-	ret.RunFolder = dir
+	files := entryNames(relFiles)
 
 	runInfo, err := ExistsOnePattern(files, "runInfo.xml")
 	if err != nil {
@@ -958,10 +762,59 @@ func ParseFlowcellRunFolder(runFolder string, bearNoRunParam bool) (ret *Flowcel
 		if ret.Location == "" {
 			ret.Location = runFolder
 		}
+		ret.setCompletion(DirFS(runFolder))
 	}
 	return ret, err
 }
 
+//ParseFlowcellFS Flowcell of the run folder in fsys, location names it. Unlike ParseFlowcellRunFolder Data/ is not required,
+//archived runs often leave it out.
+func ParseFlowcellFS(fsys RunFS, location string, notSkipMissingRunParam bool) (*Flowcell, error) {
+	entries, err := fsys.ReadDir(".")
+	if err != nil {
+		return nil, fmt.Errorf("read dir error:%s", err.Error())
+	}
+	files := entryNames(entries)
+	runInfo, _ := ExistsOnePattern(files, "runInfo.xml")
+	if runInfo == nil {
+		return nil, fmt.Errorf("RunInfo.xml missing")
+	}
+	runInfoByte, err := ReadFileFS(fsys, *runInfo)
+	if err != nil {
+		return nil, err
+	}
+	var ret *Flowcell
+	if runParameters, _ := ExistsOnePattern(files, "runParameters.xml"); runParameters != nil {
+		runParamsByte, err := ReadFileFS(fsys, *runParameters)
+		if err != nil {
+			return nil, err
+		}
+		ret, err = ParseFlowcell(string(runInfoByte), string(runParamsByte))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if notSkipMissingRunParam {
+			return nil, NORUNPARAM
+		}
+		ret, err = ParseGAFlowcell(string(runInfoByte))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(ret.RunStartDate) > 10 {
+		ret.RunStartDate = ret.RunStartDate[0:10]
+	}
+	if ret.FlowcellBarcode == "" {
+		ret.FlowcellBarcode = ret.RunId
+	}
+	if ret.Location == "" {
+		ret.Location = location
+	}
+	ret.setCompletion(fsys)
+	return ret, nil
+}
+
 func ParseFlowcellRunInfo(runfoldInfo *RunFolder) (ret *Flowcell, err error) {
 	ret, err = _ParseFlowcellRunInfo(runfoldInfo)
 	if ret != nil {
@@ -971,23 +824,31 @@ func ParseFlowcellRunInfo(runfoldInfo *RunFolder) (ret *Flowcell, err error) {
 		if ret.FlowcellBarcode == "" {
 			ret.FlowcellBarcode = ret.RunId
 		}
+		if runfoldInfo.RunFolder != "" {
+			ret.setCompletion(DirFS(runfoldInfo.RunFolder))
+		}
 	}
 	return ret, err
 }
 
 func (self *RunInfo) CycleInRead(cycle int) *RunInfoReads {
-	readStart := 0
-	readEnd := 0
-	for _, r := range self.Run.Reads {
-		readEnd += r.NumCycles
-		if cycle > readStart && cycle <= readEnd {
-			return &r
-		}
-		readStart = readEnd
+	if readNum := self.ReadNumOfCycle(cycle); readNum != 0 {
+		return &self.Run.Reads[readNum-1]
 	}
 	return nil
 }
 
+//ReadNumOfCycle 1 based position of the read cycle is in, as the ByRead helpers take it; 0 when no read has cycle
+func (self *RunInfo) ReadNumOfCycle(cycle int) int {
+	for i := range self.Run.Reads {
+		start, end := self.readSpan(i + 1)
+		if cycle >= start && cycle <= end {
+			return i + 1
+		}
+	}
+	return 0
+}
+
 func IsNotExpired(runfolder string, expiredHours int) (bool, error) {
 	notExpired := false
 	err := filepath.Walk(runfolder, func(path string, info os.FileInfo, err error) error {
@@ -1004,7 +865,6 @@ func IsNotExpired(runfolder string, expiredHours int) (bool, error) {
 
 		if time.Since(info.ModTime()).Hours() < float64(expiredHours) {
 			notExpired = true
-			fmt.Println(info.ModTime(), (time.Since(info.ModTime()).Hours() - float64(expiredHours)))
 			return filepath.SkipDir
 		}
 		return nil
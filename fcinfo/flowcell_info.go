@@ -0,0 +1,294 @@
+package fcinfo
+
+//flowcell_info.go typed flowcell metadata beside the all string Flowcell: parsed dates, platform, chemistry,
+//flowcell mode and cycles per read
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Platform string
+
+var (
+	PLATFORM_UNKNOWN    Platform = "unknown"
+	PLATFORM_GA         Platform = "GA"
+	PLATFORM_HISEQ      Platform = "HiSeq"
+	PLATFORM_HISEQ_X    Platform = "HiSeqX"
+	PLATFORM_MISEQ      Platform = "MiSeq"
+	PLATFORM_MISEQ_I100 Platform = "MiSeqi100"
+	PLATFORM_MINISEQ    Platform = "MiniSeq"
+	PLATFORM_ISEQ       Platform = "iSeq"
+	PLATFORM_NEXTSEQ    Platform = "NextSeq"
+	PLATFORM_NEXTSEQ_2K Platform = "NextSeq2000"
+	PLATFORM_NOVASEQ    Platform = "NovaSeq"
+	PLATFORM_NOVASEQ_X  Platform = "NovaSeqX"
+
+	//INSTRUMENT_PREFIXES letters an instrument id starts with on each platform
+	INSTRUMENT_PREFIXES = []struct {
+		Prefix   string
+		Platform Platform
+	}{
+		{"HWI", PLATFORM_GA}, {"MN", PLATFORM_MINISEQ}, {"NB", PLATFORM_NEXTSEQ}, {"NS", PLATFORM_NEXTSEQ},
+		{"VH", PLATFORM_NEXTSEQ_2K}, {"VL", PLATFORM_NEXTSEQ_2K}, {"LH", PLATFORM_NOVASEQ_X}, {"FS", PLATFORM_ISEQ},
+		{"SH", PLATFORM_MISEQ_I100}, {"SN", PLATFORM_HISEQ}, {"ST", PLATFORM_HISEQ_X},
+		{"A", PLATFORM_NOVASEQ}, {"D", PLATFORM_HISEQ}, {"E", PLATFORM_HISEQ_X}, {"J", PLATFORM_HISEQ}, {"K", PLATFORM_HISEQ},
+		{"M", PLATFORM_MISEQ},
+	}
+
+	//INSTRUMENT_TYPE_PLATFORMS RunParams.InstrumentType of each dialect
+	INSTRUMENT_TYPE_PLATFORMS = map[string]Platform{
+		"HiSeq": PLATFORM_HISEQ, "HiSeqX": PLATFORM_HISEQ_X, "MiSeq": PLATFORM_MISEQ, "MiniSeq": PLATFORM_MINISEQ,
+		"NextSeq": PLATFORM_NEXTSEQ, NEXTSEQ_2K: PLATFORM_NEXTSEQ_2K, VOYAGER: PLATFORM_NOVASEQ,
+		NOVASEQ_X: PLATFORM_NOVASEQ_X, MISEQ_I100: PLATFORM_MISEQ_I100, FIRE_FLY: PLATFORM_ISEQ,
+	}
+
+	//RUN_DATE_LAYOUTS RunInfo.xml Date and RunParameters.xml RunStartDate layouts, tried in order
+	RUN_DATE_LAYOUTS = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "1/2/2006 3:04:05 PM", "2006-01-02", "20060102", "060102"}
+
+	chemistryVersionRe = regexp.MustCompile(`(?i)v(?:ersion)?\s*(\d+(\.\d+)?)`)
+	instrumentPrefixRe = regexp.MustCompile(`^[A-Za-z]+`)
+)
+
+type Channels string
+
+var (
+	CHANNELS_UNKNOWN Channels = "unknown"
+	CHANNELS_FOUR    Channels = "4-color"
+	CHANNELS_TWO     Channels = "2-color"
+	CHANNELS_ONE     Channels = "1-color"
+)
+
+//Chemistry Raw is what RunParameters.xml says, Version the number following v or Version in it
+type Chemistry struct {
+	Raw      string   `json:",omitempty"`
+	Version  string   `json:",omitempty"`
+	Channels Channels //from the platform
+}
+
+//FlowcellMode normalized flowcell type: SP, S1, S2, S4, P1 to P4, 1.5B, 10B, 25B, Mid Output, High Output
+type FlowcellMode string
+
+var (
+	FLOWCELL_MODE_HIGH_OUTPUT FlowcellMode = "High Output"
+	FLOWCELL_MODE_MID_OUTPUT  FlowcellMode = "Mid Output"
+
+	flowcellModeRe = regexp.MustCompile(`(?i)^(SP|S[124]|P[1-4]|1\.5B|10B|25B)$`)
+)
+
+//ReadCycles Planned is what RunParameters.xml asked for, else what RunInfo.xml lays out; Actual is RunInfo.xml's,
+//cut to the cycles done when RunCompletionStatus.xml tells a run ended early
+type ReadCycles struct {
+	Name    string //Read1, Index1, Index2, Read2
+	IsIndex bool
+	Planned int
+	Actual  int
+}
+
+type FlowcellInfo struct {
+	RunId              string
+	RunNumber          int
+	FlowcellBarcode    string
+	InstrumentId       string
+	RunStart           *time.Time `json:",omitempty"`
+	Platform           Platform
+	Chemistry          Chemistry
+	FlowcellMode       FlowcellMode `json:",omitempty"`
+	FCPosition         string       `json:",omitempty"`
+	ApplicationName    string       `json:",omitempty"`
+	ApplicationVersion string       `json:",omitempty"`
+	RtaVersion         string       `json:",omitempty"`
+	Reads              []*ReadCycles
+	Completion         CompletionStatus
+	CompletionMessage  string     `json:",omitempty"`
+	RTAComplete        *time.Time `json:",omitempty"`
+}
+
+//ParseRunDate the RunInfo.xml Date forms: ISO 8601, "2/3/2014 10:11:12 AM", "20140203" and "140203".
+//Dates without a zone are the instrument's clock, which the run folder does not tell; they are read as UTC so
+//a date parses to the same instant wherever it is parsed.
+func ParseRunDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range RUN_DATE_LAYOUTS {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown run date format %q", s)
+}
+
+//PlatformOfInstrument platform by instrument id prefix, M00805 is a MiSeq
+func PlatformOfInstrument(instrumentId string) Platform {
+	prefix := strings.ToUpper(instrumentPrefixRe.FindString(instrumentId))
+	for _, p := range INSTRUMENT_PREFIXES {
+		if prefix == p.Prefix {
+			return p.Platform
+		}
+	}
+	return PLATFORM_UNKNOWN
+}
+
+//Channels dye channels the platform images
+func (self Platform) Channels() Channels {
+	switch self {
+	case PLATFORM_GA, PLATFORM_HISEQ, PLATFORM_HISEQ_X, PLATFORM_MISEQ:
+		return CHANNELS_FOUR
+	case PLATFORM_MINISEQ, PLATFORM_NEXTSEQ, PLATFORM_NEXTSEQ_2K, PLATFORM_NOVASEQ, PLATFORM_NOVASEQ_X, PLATFORM_MISEQ_I100:
+		return CHANNELS_TWO
+	case PLATFORM_ISEQ:
+		return CHANNELS_ONE
+	}
+	return CHANNELS_UNKNOWN
+}
+
+//NormalizeFlowcellMode "s4" is S4, "NextSeq High" is High Output; anything else is kept as it is
+func NormalizeFlowcellMode(raw string) FlowcellMode {
+	raw = strings.TrimSpace(raw)
+	if flowcellModeRe.MatchString(raw) {
+		return FlowcellMode(strings.ToUpper(raw))
+	}
+	lower := strings.ToLower(raw)
+	switch {
+	case strings.Contains(lower, "high"):
+		return FLOWCELL_MODE_HIGH_OUTPUT
+	case strings.Contains(lower, "mid"):
+		return FLOWCELL_MODE_MID_OUTPUT
+	}
+	return FlowcellMode(raw)
+}
+
+func parseChemistry(raw string, platform Platform) Chemistry {
+	ret := Chemistry{Raw: raw, Channels: platform.Channels()}
+	if m := chemistryVersionRe.FindStringSubmatch(raw); m != nil {
+		ret.Version = m[1]
+	}
+	return ret
+}
+
+//NewFlowcellInfo typed model of RunInfo.xml and RunParameters.xml; runParams may be nil
+func NewFlowcellInfo(runInfo *RunInfo, runParams *RunParams) *FlowcellInfo {
+	run := runInfo.Run
+	ret := &FlowcellInfo{RunId: run.RunId, FlowcellBarcode: run.FlowcellBarcode, InstrumentId: run.Instrument,
+		Platform: PLATFORM_UNKNOWN, Completion: COMPLETION_UNKNOWN}
+	ret.RunNumber, _ = strconv.Atoi(run.RunNumber)
+	sp := strings.Split(run.RunId, "_")
+	if ret.InstrumentId == "" && len(sp) >= 2 {
+		ret.InstrumentId = sp[1]
+	}
+
+	dates := []string{run.Date}
+	if runParams != nil {
+		dates = append([]string{runParams.RunStartDate}, dates...)
+	}
+	if len(sp) > 0 {
+		dates = append(dates, sp[0])
+	}
+	for _, d := range dates {
+		if t, err := ParseRunDate(d); err == nil {
+			ret.RunStart = &t
+			break
+		}
+	}
+
+	planned := map[string]string{}
+	if runParams != nil {
+		if p, ok := INSTRUMENT_TYPE_PLATFORMS[runParams.InstrumentType]; ok {
+			ret.Platform = p
+		}
+		ret.FCPosition = runParams.FCPosition
+		ret.ApplicationName, ret.ApplicationVersion = runParams.ApplicationName, runParams.ApplicationVersion
+		if runParams.RTAVersion != `undefined` {
+			ret.RtaVersion = runParams.RTAVersion
+		}
+		ret.FlowcellMode = NormalizeFlowcellMode(runParams.FlowcellMode)
+		if ret.FlowcellMode == "" && ret.Platform == PLATFORM_NEXTSEQ {
+			ret.FlowcellMode = NormalizeFlowcellMode(runParams.Chemistry)
+		}
+		planned = map[string]string{"Read1": runParams.PlannedRead1Cycles, "Read2": runParams.PlannedRead2Cycles,
+			"Index1": runParams.PlannedIndex1ReadCycles, "Index2": runParams.PlannedIndex2ReadCycles}
+	}
+	if ret.Platform == PLATFORM_UNKNOWN {
+		ret.Platform = PlatformOfInstrument(ret.InstrumentId)
+	}
+	raw := ""
+	if runParams != nil {
+		raw = runParams.Chemistry
+	}
+	ret.Chemistry = parseChemistry(raw, ret.Platform)
+
+	reads, indexes := 0, 0
+	for i := range run.Reads {
+		r := &run.Reads[i]
+		rc := &ReadCycles{IsIndex: r.IsIndex()}
+		if rc.IsIndex {
+			indexes++
+			rc.Name = fmt.Sprintf("Index%d", indexes)
+		} else {
+			reads++
+			rc.Name = fmt.Sprintf("Read%d", reads)
+		}
+		start, end := runInfo.readSpan(i + 1)
+		rc.Actual = end - start + 1
+		rc.Planned, _ = strconv.Atoi(planned[rc.Name])
+		if rc.Planned == 0 {
+			rc.Planned = rc.Actual
+		}
+		ret.Reads = append(ret.Reads, rc)
+	}
+	return ret
+}
+
+//SetCompletion completion status, and the cycles each read got to when the run ended early
+func (self *FlowcellInfo) SetCompletion(c *RunCompletion) {
+	self.Completion, self.CompletionMessage = c.Status, c.Message
+	if c.RTAComplete != nil {
+		if !c.RTAComplete.Time.IsZero() {
+			t := c.RTAComplete.Time
+			self.RTAComplete = &t
+		}
+		if self.RtaVersion == "" {
+			self.RtaVersion = c.RTAComplete.RtaVersion
+		}
+	}
+	cyclesDone := c.CyclesCompleted
+	if cyclesDone <= 0 || (c.Status != COMPLETION_FAILED && c.Status != COMPLETION_USER_ENDED) {
+		return
+	}
+	for _, r := range self.Reads {
+		if r.Actual > cyclesDone {
+			r.Actual = cyclesDone
+		}
+		cyclesDone -= r.Actual
+	}
+}
+
+//ReadFlowcellInfo typed model of runFolder; RunParameters.xml, RunCompletionStatus.xml and RTAComplete.txt are optional
+func ReadFlowcellInfo(runFolder string) (*FlowcellInfo, error) {
+	return ReadFlowcellInfoFS(DirFS(runFolder))
+}
+
+func ReadFlowcellInfoFS(fsys RunFS) (*FlowcellInfo, error) {
+	b, err := ReadFileFS(fsys, "RunInfo.xml")
+	if err != nil {
+		return nil, err
+	}
+	runInfo, err := ParseRunInfoXML(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("parse RunInfo.xml err:%s", err.Error())
+	}
+	var runParams *RunParams
+	for _, name := range []string{"RunParameters.xml", "runParameters.xml"} {
+		if b, err := ReadFileFS(fsys, name); err == nil {
+			if runParams, err = ParseRunParamsXML(string(b)); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	ret := NewFlowcellInfo(runInfo, runParams)
+	ret.SetCompletion(ReadRunCompletionFS(fsys))
+	return ret, nil
+}
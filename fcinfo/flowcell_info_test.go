@@ -0,0 +1,77 @@
+package fcinfo
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFlowcellInfo(t *testing.T) {
+	for s, want := range map[string]time.Time{
+		"140203":                 time.Date(2014, 2, 3, 0, 0, 0, 0, time.UTC),
+		"20140203":               time.Date(2014, 2, 3, 0, 0, 0, 0, time.UTC),
+		"2/3/2014 10:11:12 AM":   time.Date(2014, 2, 3, 10, 11, 12, 0, time.UTC),
+		"2014-02-03T10:11:12Z":   time.Date(2014, 2, 3, 10, 11, 12, 0, time.UTC),
+		"2014-02-03T10:11:12":    time.Date(2014, 2, 3, 10, 11, 12, 0, time.UTC),
+		"2014-02-03T10:11:12.5Z": time.Date(2014, 2, 3, 10, 11, 12, 5e8, time.UTC),
+	} {
+		if got, err := ParseRunDate(s); err != nil || !got.Equal(want) {
+			t.Fatalf("%q parsed %v %v", s, got, err)
+		}
+	}
+	if PlatformOfInstrument("M00805") != PLATFORM_MISEQ || PlatformOfInstrument("MN01234") != PLATFORM_MINISEQ || PlatformOfInstrument("LH00211") != PLATFORM_NOVASEQ_X {
+		t.Fatal("platform by instrument id")
+	}
+
+	dir, err := ioutil.TempDir("", "fcinfo-typed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"RunInfo.xml": testRunInfoNovaSeq,
+		"RunParameters.xml": `<RunParameters><Side>B</Side><Application>NovaSeqXPlus Control Software</Application>
+			<PlannedReads><Read ReadName="Read1" Cycles="151" /><Read ReadName="Index1" Cycles="10" /><Read ReadName="Index2" Cycles="10" /><Read ReadName="Read2" Cycles="151" /></PlannedReads>
+			<ConsumableInfo><ConsumableInfo><Type>FlowCell</Type><Mode>10b</Mode></ConsumableInfo><ConsumableInfo><Type>Reagent</Type><Version>Version 1.0</Version></ConsumableInfo></ConsumableInfo></RunParameters>`,
+		RUN_COMPLETION_FILE: `<RunCompletionStatus><CompletionStatus>UserEndedEarly</CompletionStatus><NumCyclesExpected>318</NumCyclesExpected><NumCyclesCompleted>155</NumCyclesCompleted></RunCompletionStatus>`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info, err := ReadFlowcellInfo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Platform != PLATFORM_NOVASEQ_X || info.FlowcellMode != "10B" || info.Chemistry.Version != "1.0" || info.Chemistry.Channels != CHANNELS_TWO ||
+		info.RunStart == nil || info.RunStart.Year() != 2020 || info.FCPosition != "B" || info.Completion != COMPLETION_USER_ENDED {
+		t.Fatalf("info %+v", info)
+	}
+	want := []ReadCycles{{"Read1", false, 151, 151}, {"Index1", true, 10, 4}, {"Index2", true, 10, 0}, {"Read2", false, 151, 0}}
+	for i, r := range info.Reads {
+		if *r != want[i] {
+			t.Fatalf("read %d %+v, expect %+v", i, r, want[i])
+		}
+	}
+
+	b, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := new(FlowcellInfo)
+	if err := json.Unmarshal(b, back); err != nil {
+		t.Fatal(err)
+	}
+	if !back.RunStart.Equal(*info.RunStart) {
+		t.Fatalf("run start %v, expect %v", back.RunStart, info.RunStart)
+	}
+	back.RunStart = info.RunStart
+	if !reflect.DeepEqual(back, info) {
+		t.Fatalf("json round trip\n%s", b)
+	}
+}
@@ -0,0 +1,273 @@
+package fcinfo
+
+//read_structure.go what each cycle of a run is used for, as BCL Convert OverrideCycles writes it: "U7N1Y93;I8;I8;U7N1Y93"
+//is four reads, the first taking a 7 cycle UMI, skipping one cycle and keeping 93 as template.
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	SEGMENT_TEMPLATE byte = 'Y'
+	SEGMENT_INDEX    byte = 'I'
+	SEGMENT_UMI      byte = 'U'
+	SEGMENT_SKIP     byte = 'N'
+
+	OVERRIDE_CYCLES_READ_SEP = ";"
+)
+
+//CycleSet 1 based cycle numbers, a cycle is in when true. Where a cycle filter is taken a nil set filters nothing.
+type CycleSet map[uint16]bool
+
+func NewCycleSet(cycles ...uint16) CycleSet {
+	ret := CycleSet{}
+	for _, c := range cycles {
+		ret[c] = true
+	}
+	return ret
+}
+
+//CycleSpan first to last, inclusive
+func CycleSpan(first, last int) CycleSet {
+	ret := CycleSet{}
+	for c := first; c <= last; c++ {
+		ret[uint16(c)] = true
+	}
+	return ret
+}
+
+func (self CycleSet) Has(cycle uint16) bool {
+	return self[cycle]
+}
+
+//Admits as a cycle filter: a nil set admits every cycle
+func (self CycleSet) Admits(cycle uint16) bool {
+	return self == nil || self[cycle]
+}
+
+//Len cycles in the set
+func (self CycleSet) Len() int {
+	n := 0
+	for _, in := range self {
+		if in {
+			n++
+		}
+	}
+	return n
+}
+
+func (self CycleSet) Sorted() []uint16 {
+	ret := []uint16{}
+	for c, in := range self {
+		if in {
+			ret = append(ret, c)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
+
+func (self CycleSet) Union(other CycleSet) CycleSet {
+	ret := CycleSet{}
+	for _, s := range []CycleSet{self, other} {
+		for c, in := range s {
+			if in {
+				ret[c] = true
+			}
+		}
+	}
+	return ret
+}
+
+func (self CycleSet) Intersect(other CycleSet) CycleSet {
+	ret := CycleSet{}
+	for c, in := range self {
+		if in && other[c] {
+			ret[c] = true
+		}
+	}
+	return ret
+}
+
+//Exclude cycles of self not in other
+func (self CycleSet) Exclude(other CycleSet) CycleSet {
+	ret := CycleSet{}
+	for c, in := range self {
+		if in && !other[c] {
+			ret[c] = true
+		}
+	}
+	return ret
+}
+
+//ReadSegment Cycles cycles used as Type: template, index, UMI or skipped
+type ReadSegment struct {
+	Type   byte
+	Cycles int
+}
+
+//ReadSpec one sequencing read, its segments in cycle order
+type ReadSpec struct {
+	Segments []ReadSegment
+}
+
+func (self *ReadSpec) NumCycles() int {
+	n := 0
+	for _, s := range self.Segments {
+		n += s.Cycles
+	}
+	return n
+}
+
+//IsIndex true when the read holds index cycles
+func (self *ReadSpec) IsIndex() bool {
+	for _, s := range self.Segments {
+		if s.Type == SEGMENT_INDEX {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *ReadSpec) String() string {
+	var b strings.Builder
+	for _, s := range self.Segments {
+		b.WriteByte(s.Type)
+		b.WriteString(strconv.Itoa(s.Cycles))
+	}
+	return b.String()
+}
+
+type ReadStructure struct {
+	Reads []*ReadSpec
+}
+
+//ParseOverrideCycles parse an OverrideCycles value, segment letters in either case
+func ParseOverrideCycles(s string) (*ReadStructure, error) {
+	ret := new(ReadStructure)
+	for i, part := range strings.Split(strings.TrimSpace(s), OVERRIDE_CYCLES_READ_SEP) {
+		read, err := parseReadSpec(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("OverrideCycles %q read %d err:%s", s, i+1, err.Error())
+		}
+		ret.Reads = append(ret.Reads, read)
+	}
+	return ret, nil
+}
+
+func parseReadSpec(s string) (*ReadSpec, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty read")
+	}
+	ret := new(ReadSpec)
+	for i := 0; i < len(s); {
+		t := strings.ToUpper(s[i : i+1])[0]
+		if t != SEGMENT_TEMPLATE && t != SEGMENT_INDEX && t != SEGMENT_UMI && t != SEGMENT_SKIP {
+			return nil, fmt.Errorf("unknown segment %q", s[i])
+		}
+		j := i + 1
+		for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			j++
+		}
+		n, err := strconv.Atoi(s[i+1 : j])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("segment %c needs a cycle count", t)
+		}
+		ret.Segments = append(ret.Segments, ReadSegment{Type: t, Cycles: n})
+		i = j
+	}
+	return ret, nil
+}
+
+//String the OverrideCycles value
+func (self *ReadStructure) String() string {
+	parts := make([]string, len(self.Reads))
+	for i, r := range self.Reads {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, OVERRIDE_CYCLES_READ_SEP)
+}
+
+func (self *ReadStructure) NumCycles() int {
+	n := 0
+	for _, r := range self.Reads {
+		n += r.NumCycles()
+	}
+	return n
+}
+
+//ReadStructureOfRunInfo template cycles for each non index read and index cycles for each index read
+func ReadStructureOfRunInfo(runInfo *RunInfo) *ReadStructure {
+	ret := new(ReadStructure)
+	for i := range runInfo.Run.Reads {
+		start, end := runInfo.readSpan(i + 1)
+		t := SEGMENT_TEMPLATE
+		if runInfo.Run.Reads[i].IsIndex() {
+			t = SEGMENT_INDEX
+		}
+		ret.Reads = append(ret.Reads, &ReadSpec{Segments: []ReadSegment{{Type: t, Cycles: end - start + 1}}})
+	}
+	return ret
+}
+
+//RunInfoReads reads as RunInfo.xml lists them
+func (self *ReadStructure) RunInfoReads() []RunInfoReads {
+	ret := []RunInfoReads{}
+	for i, r := range self.Reads {
+		indexed := "N"
+		if r.IsIndex() {
+			indexed = "Y"
+		}
+		ret = append(ret, RunInfoReads{Number: i + 1, NumCycles: r.NumCycles(), IsIndexedRead: indexed})
+	}
+	return ret
+}
+
+//Validate the reads and their cycles match runInfo's
+func (self *ReadStructure) Validate(runInfo *RunInfo) error {
+	if len(self.Reads) != len(runInfo.Run.Reads) {
+		return fmt.Errorf("%s has %d reads, RunInfo.xml %d", self.String(), len(self.Reads), len(runInfo.Run.Reads))
+	}
+	for i, r := range self.Reads {
+		start, end := runInfo.readSpan(i + 1)
+		if r.NumCycles() != end-start+1 {
+			return fmt.Errorf("%s read %d has %d cycles, RunInfo.xml %d", self.String(), i+1, r.NumCycles(), end-start+1)
+		}
+	}
+	if self.NumCycles() != calcCycles(runInfo) {
+		return fmt.Errorf("%s has %d cycles, RunInfo.xml %d", self.String(), self.NumCycles(), calcCycles(runInfo))
+	}
+	return nil
+}
+
+//Cycles run cycles of segments of type t
+func (self *ReadStructure) Cycles(t byte) CycleSet {
+	ret := CycleSet{}
+	cycle := 1
+	for _, r := range self.Reads {
+		for _, s := range r.Segments {
+			if s.Type == t {
+				for c := cycle; c < cycle+s.Cycles; c++ {
+					ret[uint16(c)] = true
+				}
+			}
+			cycle += s.Cycles
+		}
+	}
+	return ret
+}
+
+//ReadCycles run cycles of read readNum, 1 based
+func (self *ReadStructure) ReadCycles(readNum int) CycleSet {
+	first := 1
+	for i, r := range self.Reads {
+		if i+1 == readNum {
+			return CycleSpan(first, first+r.NumCycles()-1)
+		}
+		first += r.NumCycles()
+	}
+	return CycleSet{}
+}
@@ -0,0 +1,62 @@
+package fcinfo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadStructure(t *testing.T) {
+	rs, err := ParseOverrideCycles("u7n1Y143; I8 ;I8;U7N1Y143")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.String() != "U7N1Y143;I8;I8;U7N1Y143" || rs.NumCycles() != 318 {
+		t.Fatalf("parsed %s, %d cycles", rs.String(), rs.NumCycles())
+	}
+	for _, bad := range []string{"", "Y151;", "Y", "X10", "Y0", "151"} {
+		if _, err := ParseOverrideCycles(bad); err == nil {
+			t.Fatalf("%q parsed", bad)
+		}
+	}
+
+	ri, err := ParseRunInfoXML(testRunInfoNovaSeq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.Validate(ri); err != nil {
+		t.Fatal(err)
+	}
+	if short, _ := ParseOverrideCycles("Y150;I8;I8;Y151"); short.Validate(ri) == nil {
+		t.Fatal("Y150 validated against a 151 cycle read")
+	}
+	plain := ReadStructureOfRunInfo(ri)
+	if plain.String() != "Y151;I8;I8;Y151" {
+		t.Fatalf("from RunInfo %s", plain.String())
+	}
+	for i, r := range plain.RunInfoReads() {
+		want := ri.Run.Reads[i]
+		if r.Number != want.Number || r.NumCycles != want.NumCycles || r.IsIndexedRead != want.IsIndexedRead {
+			t.Fatalf("read %+v, expect %+v", r, want)
+		}
+	}
+
+	umi, index := rs.Cycles(SEGMENT_UMI), rs.Cycles(SEGMENT_INDEX)
+	if !reflect.DeepEqual(umi.Sorted(), []uint16{1, 2, 3, 4, 5, 6, 7, 168, 169, 170, 171, 172, 173, 174}) || index.Len() != 16 || !index.Has(152) {
+		t.Fatalf("umi %v index %v", umi.Sorted(), index.Sorted())
+	}
+	read1 := rs.ReadCycles(1)
+	if !reflect.DeepEqual(read1, ri.GetCyclesMapByRead(1)) {
+		t.Fatal("read 1 cycles differ from RunInfo")
+	}
+	template := read1.Exclude(umi).Exclude(rs.Cycles(SEGMENT_SKIP))
+	if template.Len() != 143 || template.Has(8) || !template.Has(9) {
+		t.Fatalf("read 1 template %d cycles", template.Len())
+	}
+	if got := read1.Intersect(umi).Union(NewCycleSet(300)).Sorted(); !reflect.DeepEqual(got, []uint16{1, 2, 3, 4, 5, 6, 7, 300}) {
+		t.Fatalf("intersect union %v", got)
+	}
+	var all CycleSet
+	if !all.Admits(1) || template.Admits(8) {
+		t.Fatal("nil set admits every cycle")
+	}
+}
@@ -0,0 +1,317 @@
+package fcinfo
+
+//runfs.go run folders that are not plain directories: zip and tar archives or files held in memory.
+//RunFS is the subset of io/fs run parsing needs, kept to what go 1.14 has.
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//RunFS read only run folder. Names are slash separated and relative to the run folder, "." being the folder itself.
+type RunFS interface {
+	Open(name string) (io.ReadCloser, error)
+	Stat(name string) (os.FileInfo, error)
+	ReadDir(name string) ([]os.FileInfo, error) //sorted by name
+}
+
+type dirFS string
+
+//DirFS run folder on disk
+func DirFS(dir string) RunFS {
+	return dirFS(dir)
+}
+
+func (self dirFS) join(name string) string {
+	return filepath.Join(string(self), filepath.FromSlash(name))
+}
+
+func (self dirFS) Open(name string) (io.ReadCloser, error) {
+	return os.Open(self.join(name))
+}
+
+func (self dirFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(self.join(name))
+}
+
+func (self dirFS) ReadDir(name string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(self.join(name))
+}
+
+//ReadFileFS whole content of name
+func ReadFileFS(fsys RunFS, name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+type memInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (self *memInfo) Name() string       { return self.name }
+func (self *memInfo) Size() int64        { return self.size }
+func (self *memInfo) ModTime() time.Time { return self.modTime }
+func (self *memInfo) IsDir() bool        { return self.dir }
+func (self *memInfo) Sys() interface{}   { return nil }
+
+func (self *memInfo) Mode() os.FileMode {
+	if self.dir {
+		return os.ModeDir | 0555
+	}
+	return 0444
+}
+
+type memFile struct {
+	info *memInfo
+	open func() (io.ReadCloser, error)
+}
+
+//memFS files by clean slash name; directories are implied by the files under them
+type memFS struct {
+	files map[string]*memFile
+}
+
+func newMemFS() *memFS {
+	return &memFS{files: map[string]*memFile{}}
+}
+
+func (self *memFS) add(name string, size int64, modTime time.Time, open func() (io.ReadCloser, error)) {
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	self.files[name] = &memFile{info: &memInfo{name: path.Base(name), size: size, modTime: modTime}, open: open}
+}
+
+func notExist(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
+
+func (self *memFS) Open(name string) (io.ReadCloser, error) {
+	f, ok := self.files[path.Clean(name)]
+	if !ok {
+		return nil, notExist("open", name)
+	}
+	return f.open()
+}
+
+func (self *memFS) Stat(name string) (os.FileInfo, error) {
+	name = path.Clean(name)
+	if f, ok := self.files[name]; ok {
+		return f.info, nil
+	}
+	entries, err := self.ReadDir(name)
+	if err != nil {
+		return nil, notExist("stat", name)
+	}
+	ret := &memInfo{name: path.Base(name), dir: true}
+	for _, e := range entries {
+		if e.ModTime().After(ret.modTime) {
+			ret.modTime = e.ModTime()
+		}
+	}
+	return ret, nil
+}
+
+func (self *memFS) ReadDir(name string) ([]os.FileInfo, error) {
+	name = path.Clean(name)
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	found := map[string]os.FileInfo{}
+	for n, f := range self.files {
+		if !strings.HasPrefix(n, prefix) || n == name {
+			continue
+		}
+		rest := n[len(prefix):]
+		if i := strings.Index(rest, "/"); i >= 0 {
+			dir := rest[:i]
+			d, ok := found[dir].(*memInfo)
+			if !ok {
+				d = &memInfo{name: dir, dir: true}
+				found[dir] = d
+			}
+			if f.info.modTime.After(d.modTime) {
+				d.modTime = f.info.modTime
+			}
+			continue
+		}
+		found[rest] = f.info
+	}
+	if len(found) == 0 {
+		if _, ok := self.files[name]; ok {
+			return nil, fmt.Errorf("readdir %s: not a directory", name)
+		}
+		if name != "." {
+			return nil, notExist("readdir", name)
+		}
+	}
+	ret := make([]os.FileInfo, 0, len(found))
+	for _, fi := range found {
+		ret = append(ret, fi)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name() < ret[j].Name() })
+	return ret, nil
+}
+
+//MapFS in memory run folder, file content by slash separated name
+func MapFS(files map[string][]byte) RunFS {
+	ret := newMemFS()
+	now := time.Now()
+	for name, b := range files {
+		b := b
+		ret.add(name, int64(len(b)), now, func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		})
+	}
+	return ret
+}
+
+//ZipFS run folder in a zip archive; entries are read from r as they are opened
+func ZipFS(r io.ReaderAt, size int64) (RunFS, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("read zip err:%s", err.Error())
+	}
+	return zipFiles(zr.File), nil
+}
+
+func zipFiles(files []*zip.File) *memFS {
+	ret := newMemFS()
+	for _, f := range files {
+		if !f.FileInfo().IsDir() {
+			ret.add(f.Name, int64(f.UncompressedSize64), f.Modified, f.Open)
+		}
+	}
+	return ret
+}
+
+//TarFS run folder in a tar or tar.gz archive, gzip is told by its magic bytes. Tar can not seek, so file content is kept in memory.
+func TarFS(r io.Reader) (RunFS, error) {
+	br := bufio.NewReader(r)
+	var in io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("read gzip err:%s", err.Error())
+		}
+		defer gz.Close()
+		in = gz
+	}
+	tr := tar.NewReader(in)
+	ret := newMemFS()
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read tar err:%s", err.Error())
+		}
+		if !h.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read tar %s err:%s", h.Name, err.Error())
+		}
+		ret.add(h.Name, int64(len(b)), h.ModTime, func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		})
+	}
+	return ret, nil
+}
+
+type subFS struct {
+	fsys RunFS
+	dir  string
+}
+
+//SubFS dir of fsys as a run folder of its own
+func SubFS(fsys RunFS, dir string) RunFS {
+	dir = path.Clean(dir)
+	if dir == "." {
+		return fsys
+	}
+	return &subFS{fsys: fsys, dir: dir}
+}
+
+func (self *subFS) Open(name string) (io.ReadCloser, error) {
+	return self.fsys.Open(path.Join(self.dir, name))
+}
+
+func (self *subFS) Stat(name string) (os.FileInfo, error) {
+	return self.fsys.Stat(path.Join(self.dir, name))
+}
+
+func (self *subFS) ReadDir(name string) ([]os.FileInfo, error) {
+	return self.fsys.ReadDir(path.Join(self.dir, name))
+}
+
+//RunRootFS archives usually hold the run folder itself rather than its content;
+//without RunInfo.xml at the top, a single top folder is taken as the run folder
+func RunRootFS(fsys RunFS) RunFS {
+	entries, err := fsys.ReadDir(".")
+	if err != nil {
+		return fsys
+	}
+	if found, _ := ExistsOnePattern(entryNames(entries), "RunInfo.xml"); found == nil && len(entries) == 1 && entries[0].IsDir() {
+		return SubFS(fsys, entries[0].Name())
+	}
+	return fsys
+}
+
+func entryNames(entries []os.FileInfo) []string {
+	ret := make([]string, len(entries))
+	for i, e := range entries {
+		ret[i] = e.Name()
+	}
+	return ret
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+//OpenArchive run folder in a .zip, .tar, .tar.gz or .tgz file, see RunRootFS.
+//Close the returned closer once done reading from the RunFS.
+func OpenArchive(filename string) (RunFS, io.Closer, error) {
+	lower := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		zr, err := zip.OpenReader(filename)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open %s err:%s", filename, err.Error())
+		}
+		return RunRootFS(zipFiles(zr.File)), zr, nil
+	case strings.HasSuffix(lower, ".tar"), strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer f.Close()
+		ret, err := TarFS(f)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s err:%s", filename, err.Error())
+		}
+		return RunRootFS(ret), nopCloser{}, nil
+	}
+	return nil, nil, fmt.Errorf("%s is not a .zip, .tar, .tar.gz or .tgz archive", filename)
+}
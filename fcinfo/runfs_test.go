@@ -0,0 +1,119 @@
+package fcinfo
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRunFS(t *testing.T) {
+	files := map[string][]byte{
+		"run1/RunInfo.xml":                 []byte(testRunInfoNovaSeq),
+		"run1/InterOp/TileMetricsOut.bin":  {2, 10},
+		"run1/InterOp/ErrorMetricsOut.bin": {3, 30},
+		"run1/" + RUN_COMPLETION_FILE:      []byte(`<RunCompletionStatus><CompletionStatus>CompletedAsPlanned</CompletionStatus></RunCompletionStatus>`),
+	}
+
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	var tarBuf bytes.Buffer
+	gz := gzip.NewWriter(&tarBuf)
+	tw := tar.NewWriter(gz)
+	for name, b := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(b)
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(b)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write(b)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+	gz.Close()
+
+	zipFS, err := ZipFS(bytes.NewReader(zipBuf.Bytes()), int64(zipBuf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarFS, err := TarFS(bytes.NewReader(tarBuf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, fsys := range map[string]RunFS{"map": MapFS(files), "zip": zipFS, "tar.gz": tarFS} {
+		run := RunRootFS(fsys)
+		entries, err := run.ReadDir(".")
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if got := entryNames(entries); !reflect.DeepEqual(got, []string{"InterOp", RUN_COMPLETION_FILE, "RunInfo.xml"}) || !entries[0].IsDir() {
+			t.Fatalf("%s: root %v", name, got)
+		}
+		if fi, err := run.Stat("InterOp/ErrorMetricsOut.bin"); err != nil || fi.Size() != 2 || fi.IsDir() {
+			t.Fatalf("%s: stat %v %v", name, fi, err)
+		}
+		if _, err := run.Stat("Data"); !os.IsNotExist(err) {
+			t.Fatalf("%s: stat of a missing folder %v", name, err)
+		}
+		if b, err := ReadFileFS(run, "InterOp/TileMetricsOut.bin"); err != nil || !bytes.Equal(b, []byte{2, 10}) {
+			t.Fatalf("%s: read %v %v", name, b, err)
+		}
+		//archives leave Data/ out, which CheckFlowcellRunFolderFS requires but ParseFlowcellFS does not
+		if _, err := CheckFlowcellRunFolderFS(run); err == nil {
+			t.Fatalf("%s: run folder without Data/ passed the check", name)
+		}
+		fc, err := ParseFlowcellFS(run, name, false)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if fc.FlowcellBarcode != "AHXXXXXX" || fc.Location != name || fc.Completion != COMPLETION_COMPLETED {
+			t.Fatalf("%s: flowcell %+v", name, fc)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "fcinfo-runfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	archive := filepath.Join(dir, "run1.tgz")
+	if err := ioutil.WriteFile(archive, tarBuf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fsys, closer, err := OpenArchive(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+	info, err := ReadFlowcellInfoFS(fsys)
+	if err != nil || info.RunId != "200101_A00123_0001_AHXXXXXX" {
+		t.Fatalf("info %+v %v", info, err)
+	}
+	if _, _, err := OpenArchive(filepath.Join(dir, "run1.rar")); err == nil {
+		t.Fatal("opened a .rar")
+	}
+
+	//a plain folder checks the same through DirFS
+	for _, d := range []string{"Data", "InterOp"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "RunInfo.xml"), []byte(testRunInfoNovaSeq), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rf, err := CheckFlowcellRunFolder(dir)
+	if err != nil || rf.RunInfoFileName != filepath.Join(dir, "RunInfo.xml") || rf.Err != NORUNPARAM {
+		t.Fatalf("run folder %+v %v", rf, err)
+	}
+}
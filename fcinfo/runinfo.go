@@ -0,0 +1,164 @@
+package fcinfo
+
+//runinfo.go RunInfo.xml parts beyond the read list, and cycle helpers aware of index and reverse complemented reads
+
+import (
+	"encoding/xml"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	TILE_NAMING_FOUR_DIGIT = "FourDigit"
+	TILE_NAMING_FIVE_DIGIT = "FiveDigit"
+)
+
+//<TileSet TileNamingConvention="FourDigit"><Tiles><Tile>1_1101</Tile>...</Tiles></TileSet>
+type RunInfoTileSet struct {
+	TileNamingConvention string   `xml:"TileNamingConvention,attr"`
+	Tiles                []string `xml:"Tiles>Tile"`
+}
+
+//<ImageDimensions Width="3200" Height="3000" />
+type RunInfoImageDimensions struct {
+	Width  int `xml:"Width,attr"`
+	Height int `xml:"Height,attr"`
+}
+
+//RunInfoElement element kept verbatim
+type RunInfoElement struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Inner   string     `xml:",innerxml"`
+}
+
+//MarshalRunInfoXML RunInfo.xml of runInfo, unknown elements included
+func MarshalRunInfoXML(runInfo *RunInfo) (string, error) {
+	b, err := xml.MarshalIndent(runInfo, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(b), nil
+}
+
+func (self *RunInfoReads) IsIndex() bool {
+	return strings.EqualFold(self.IsIndexedRead, "Y")
+}
+
+func (self *RunInfoReads) IsReverseComplemented() bool {
+	return strings.EqualFold(self.IsReverseComplement, "Y")
+}
+
+//readSpan first and last cycle of read readNum, 1 based; past the last read it is the last read.
+//GA RunInfo.xml gives FirstCycle and LastCycle instead of NumCycles.
+func (self *RunInfo) readSpan(readNum int) (start, end int) {
+	for i := 0; i < readNum && i < len(self.Run.Reads); i++ {
+		r := &self.Run.Reads[i]
+		if r.NumCycles == 0 {
+			start, end = r.FirstCycle, r.LastCycle
+			continue
+		}
+		start, end = end+1, end+r.NumCycles
+	}
+	return
+}
+
+//ReadsOfType read numbers, 1 based, of index or of non index reads
+func (self *RunInfo) ReadsOfType(index bool) []int {
+	ret := []int{}
+	for i := range self.Run.Reads {
+		if self.Run.Reads[i].IsIndex() == index {
+			ret = append(ret, i+1)
+		}
+	}
+	return ret
+}
+
+//GetCyclesMapByReadType cycles of the nth, 1 based, index or non index read; nil when there is no such read
+func (self *RunInfo) GetCyclesMapByReadType(nth int, index bool) CycleSet {
+	reads := self.ReadsOfType(index)
+	if nth < 1 || nth > len(reads) {
+		return nil
+	}
+	return self.GetCyclesMapByRead(reads[nth-1])
+}
+
+//GetCyclesMapByType cycles of every index or every non index read
+func (self *RunInfo) GetCyclesMapByType(index bool) CycleSet {
+	ret := CycleSet{}
+	for _, readNum := range self.ReadsOfType(index) {
+		ret = ret.Union(self.GetCyclesMapByRead(readNum))
+	}
+	return ret
+}
+
+//GetNumCyclesByType cycles of the index or non index reads
+func (self *RunInfo) GetNumCyclesByType(index bool) int {
+	total := 0
+	for _, readNum := range self.ReadsOfType(index) {
+		start, end := self.readSpan(readNum)
+		total += end - start + 1
+	}
+	return total
+}
+
+//I5ReverseComplement true when the second index read, i5, is flagged IsReverseComplement
+func (self *RunInfo) I5ReverseComplement() bool {
+	reads := self.ReadsOfType(true)
+	if len(reads) < 2 {
+		return false
+	}
+	return self.Run.Reads[reads[1]-1].IsReverseComplemented()
+}
+
+//TileNames TileSet tiles as lane_tile names, empty when RunInfo.xml has no TileSet
+func (self *RunInfo) TileNames() []string {
+	if self.Run.FlowcellLayout.TileSet == nil {
+		return []string{}
+	}
+	return self.Run.FlowcellLayout.TileSet.Tiles
+}
+
+//ExpectedTiles tile numbers per lane the run should image: the TileSet when given, else enumerated from the
+//FlowcellLayout as surface, swath and tile digits, FiveDigit once TileCount needs three. ok is false when neither
+//tells, as for layouts split in sections without a TileSet.
+func (self *RunInfo) ExpectedTiles() (tiles map[int][]int, ok bool) {
+	layout := self.Run.FlowcellLayout
+	tiles = map[int][]int{}
+	if layout.TileSet != nil && len(layout.TileSet.Tiles) > 0 {
+		for _, name := range layout.TileSet.Tiles {
+			sp := strings.SplitN(name, "_", 2)
+			if len(sp) != 2 {
+				continue
+			}
+			lane, err1 := strconv.Atoi(sp[0])
+			tile, err2 := strconv.Atoi(sp[1])
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			tiles[lane] = append(tiles[lane], tile)
+		}
+		for _, t := range tiles {
+			sort.Ints(t)
+		}
+		return tiles, len(tiles) > 0
+	}
+	if layout.LaneCount == 0 || layout.SurfaceCount == 0 || layout.SwathCount == 0 || layout.TileCount == 0 || layout.SectionPerLane > 1 {
+		return tiles, false
+	}
+	swathBase, surfaceBase := 100, 1000
+	if layout.TileCount >= 100 || (layout.TileSet != nil && layout.TileSet.TileNamingConvention == TILE_NAMING_FIVE_DIGIT) {
+		swathBase, surfaceBase = 1000, 10000
+	}
+	for lane := 1; lane <= layout.LaneCount; lane++ {
+		for surface := 1; surface <= layout.SurfaceCount; surface++ {
+			for swath := 1; swath <= layout.SwathCount; swath++ {
+				for t := 1; t <= layout.TileCount; t++ {
+					tiles[lane] = append(tiles[lane], surface*surfaceBase+swath*swathBase+t)
+				}
+			}
+		}
+	}
+	return tiles, true
+}
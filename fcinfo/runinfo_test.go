@@ -0,0 +1,86 @@
+package fcinfo
+
+import (
+	"strings"
+	"testing"
+)
+
+const testRunInfoNovaSeq = `<?xml version="1.0"?>
+<RunInfo Version="5">
+  <Run Id="200101_A00123_0001_AHXXXXXX" Number="1">
+    <Flowcell>HXXXXXX</Flowcell>
+    <Instrument>A00123</Instrument>
+    <Date>1/1/2020 10:00:00 AM</Date>
+    <Reads>
+      <Read Number="1" NumCycles="151" IsIndexedRead="N" IsReverseComplement="N" />
+      <Read Number="2" NumCycles="8" IsIndexedRead="Y" IsReverseComplement="N" />
+      <Read Number="3" NumCycles="8" IsIndexedRead="Y" IsReverseComplement="Y" />
+      <Read Number="4" NumCycles="151" IsIndexedRead="N" IsReverseComplement="N" />
+    </Reads>
+    <FlowcellLayout LaneCount="2" SurfaceCount="2" SwathCount="4" TileCount="78" SectionPerLane="1" LanePerSection="1">
+      <TileSet TileNamingConvention="FourDigit">
+        <Tiles><Tile>1_1101</Tile><Tile>1_1102</Tile><Tile>2_1101</Tile></Tiles>
+      </TileSet>
+    </FlowcellLayout>
+    <AlignToPhiX><Lane>1</Lane><Lane>2</Lane></AlignToPhiX>
+    <ImageDimensions Width="3200" Height="3000" />
+    <ImageChannels><Name>Red</Name><Name>Green</Name></ImageChannels>
+    <SomethingNew Kind="x"><Part>kept</Part></SomethingNew>
+  </Run>
+</RunInfo>`
+
+func TestRunInfoModel(t *testing.T) {
+	ri, err := ParseRunInfoXML(testRunInfoNovaSeq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layout := ri.Run.FlowcellLayout
+	if layout.SectionPerLane != 1 || layout.TileSet == nil || layout.TileSet.TileNamingConvention != TILE_NAMING_FOUR_DIGIT || len(ri.TileNames()) != 3 {
+		t.Fatalf("layout %+v", layout)
+	}
+	if len(ri.Run.AlignToPhiX) != 2 || ri.Run.ImageDimensions.Width != 3200 || strings.Join(ri.Run.ImageChannels, ",") != "Red,Green" {
+		t.Fatalf("run %+v", ri.Run)
+	}
+	if len(ri.Run.Extra) != 1 || ri.Run.Extra[0].XMLName.Local != "SomethingNew" {
+		t.Fatalf("extra %+v", ri.Run.Extra)
+	}
+
+	//unknown elements survive a round trip
+	s, err := MarshalRunInfoXML(ri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ParseRunInfoXML(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Run.Extra) != 1 || !strings.Contains(s, `<Part>kept</Part>`) || !strings.Contains(s, `Kind="x"`) || again.Version != "5" {
+		t.Fatalf("round trip lost elements:\n%s", s)
+	}
+
+	if calcCycles(ri) != 318 || ri.GetNumCyclesByType(true) != 16 || ri.GetNumCyclesByType(false) != 302 {
+		t.Fatalf("cycles %d index %d", calcCycles(ri), ri.GetNumCyclesByType(true))
+	}
+	r2 := ri.GetCyclesMapByReadType(2, false)
+	if len(r2) != 151 || !r2[168] || !r2[318] || r2[167] {
+		t.Fatalf("second non index read %d cycles", len(r2))
+	}
+	if r := ri.CycleInRead(160); r == nil || !r.IsIndex() || !r.IsReverseComplemented() {
+		t.Fatalf("cycle 160 in %+v", r)
+	}
+	if !ri.I5ReverseComplement() {
+		t.Fatal("i5 is reverse complemented")
+	}
+	if last := ri.GetLastNCyclesMapByRead(1, 5); len(last) != 5 || !last[147] || !last[151] {
+		t.Fatalf("last 5 cycles of read 1 %v", last)
+	}
+	if last := ri.GetLastNCyclesMapByRead(3, 50); len(last) != 8 {
+		t.Fatalf("read 3 shorter than n gives %d cycles", len(last))
+	}
+
+	//GA layout gives first and last cycles
+	ga := &RunInfo{Run: RunInfoRun{Reads: []RunInfoReads{{Number: 1, FirstCycle: 1, LastCycle: 76}, {Number: 2, FirstCycle: 77, LastCycle: 152}}}}
+	if calcCycles(ga) != 152 || ga.CycleInRead(100).Number != 2 || ga.ReadNumOfCycle(100) != 2 || ga.ReadNumOfCycle(153) != 0 {
+		t.Fatalf("GA cycles %d", calcCycles(ga))
+	}
+}
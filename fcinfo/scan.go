@@ -0,0 +1,215 @@
+package fcinfo
+
+//scan.go run folders under a sequencer output root, their state, and what changed since the previous scan
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type RunState string
+
+var (
+	RUN_STATE_SEQUENCING    RunState = "sequencing"
+	RUN_STATE_RTA_COMPLETE  RunState = "rta_complete"
+	RUN_STATE_COPY_COMPLETE RunState = "copy_complete"
+	RUN_STATE_FAILED        RunState = "failed"
+	RUN_STATE_STALE         RunState = "stale"
+	RUN_STATE_REMOVED       RunState = "removed"
+
+	RTA_COMPLETE_FILE   = "RTAComplete.txt"
+	COPY_COMPLETE_FILE  = "CopyComplete.txt"
+	RUN_COMPLETION_FILE = "RunCompletionStatus.xml"
+	SCAN_MAX_DEPTH      = 2
+	SCAN_STALE_AFTER    = INTEROP_EXPIRED_HOURS * time.Hour
+)
+
+//RunFolderState one run folder as of a scan
+type RunFolderState struct {
+	RunFolder    string
+	RunId        string //folder name
+	State        RunState
+	LastModified time.Time //newest of the folder's top entries and InterOp files
+}
+
+//RunStateChange From is empty for a run first seen
+type RunStateChange struct {
+	RunFolder string
+	From      RunState
+	To        RunState
+}
+
+//RunScanner Last is the state of each run folder at the previous scan; persist it to report changes across restarts
+type RunScanner struct {
+	Root       string
+	MaxDepth   int           //levels under Root searched for run folders
+	StaleAfter time.Duration //a sequencing run untouched that long is stale
+	Last       map[string]RunState
+
+	mu sync.Mutex
+}
+
+func NewRunScanner(root string) *RunScanner {
+	return &RunScanner{Root: root, MaxDepth: SCAN_MAX_DEPTH, StaleAfter: SCAN_STALE_AFTER, Last: map[string]RunState{}}
+}
+
+//Scan states of the run folders under Root, and the changes since the previous Scan
+func (self *RunScanner) Scan() ([]*RunFolderState, []*RunStateChange, error) {
+	folders, err := FindRunFolders(self.Root, self.MaxDepth)
+	if err != nil {
+		return nil, nil, err
+	}
+	ret := []*RunFolderState{}
+	now := time.Now()
+	for _, dir := range folders {
+		st, err := ReadRunFolderState(dir, self.StaleAfter, now)
+		if err != nil {
+			continue //removed while scanning
+		}
+		ret = append(ret, st)
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.Last == nil {
+		self.Last = map[string]RunState{}
+	}
+	changes := []*RunStateChange{}
+	seen := map[string]bool{}
+	for _, st := range ret {
+		seen[st.RunFolder] = true
+		if from, ok := self.Last[st.RunFolder]; !ok || from != st.State {
+			changes = append(changes, &RunStateChange{RunFolder: st.RunFolder, From: from, To: st.State})
+			self.Last[st.RunFolder] = st.State
+		}
+	}
+	gone := []string{}
+	for dir := range self.Last {
+		if !seen[dir] {
+			gone = append(gone, dir)
+		}
+	}
+	sort.Strings(gone)
+	for _, dir := range gone {
+		changes = append(changes, &RunStateChange{RunFolder: dir, From: self.Last[dir], To: RUN_STATE_REMOVED})
+		delete(self.Last, dir)
+	}
+	return ret, changes, nil
+}
+
+//IsRunFolder true when dir holds a RunInfo.xml
+func IsRunFolder(dir string) bool {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, f := range files {
+		if !f.IsDir() && strings.EqualFold(f.Name(), "RunInfo.xml") {
+			return true
+		}
+	}
+	return false
+}
+
+//FindRunFolders run folders under root down to maxDepth levels, sorted; run folders are not searched further
+func FindRunFolders(root string, maxDepth int) ([]string, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(root); err != nil {
+		return nil, err
+	}
+	ret := []string{}
+	var walk func(dir string, depth int)
+	walk = func(dir string, depth int) {
+		if IsRunFolder(dir) {
+			ret = append(ret, dir)
+			return
+		}
+		if depth >= maxDepth {
+			return
+		}
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		for _, f := range files {
+			if f.IsDir() && !strings.HasPrefix(f.Name(), ".") {
+				walk(filepath.Join(dir, f.Name()), depth+1)
+			}
+		}
+	}
+	walk(root, 0)
+	sort.Strings(ret)
+	return ret, nil
+}
+
+//ReadRunFolderState classify dir: failed over copy complete over RTA complete; otherwise sequencing, or stale once
+//nothing changed for staleAfter. Data/ is not walked, a sequencing run keeps its InterOp files fresh.
+func ReadRunFolderState(dir string, staleAfter time.Duration, now time.Time) (*RunFolderState, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ret := &RunFolderState{RunFolder: dir, RunId: filepath.Base(dir)}
+	names := map[string]string{}
+	for _, f := range files {
+		names[strings.ToLower(f.Name())] = f.Name()
+		if f.ModTime().After(ret.LastModified) {
+			ret.LastModified = f.ModTime()
+		}
+	}
+	if interop, ok := names["interop"]; ok {
+		if inner, err := ioutil.ReadDir(filepath.Join(dir, interop)); err == nil {
+			for _, f := range inner {
+				if f.ModTime().After(ret.LastModified) {
+					ret.LastModified = f.ModTime()
+				}
+			}
+		}
+	}
+	has := func(name string) (string, bool) {
+		n, ok := names[strings.ToLower(name)]
+		return filepath.Join(dir, n), ok
+	}
+
+	failed := false
+	if filename, ok := has(RUN_COMPLETION_FILE); ok {
+		failed = runFailed(filename)
+	}
+	_, copied := has(COPY_COMPLETE_FILE)
+	_, rtaDone := has(RTA_COMPLETE_FILE)
+	switch {
+	case failed:
+		ret.State = RUN_STATE_FAILED
+	case copied:
+		ret.State = RUN_STATE_COPY_COMPLETE
+	case rtaDone:
+		ret.State = RUN_STATE_RTA_COMPLETE
+	case staleAfter > 0 && now.Sub(ret.LastModified) > staleAfter:
+		ret.State = RUN_STATE_STALE
+	default:
+		ret.State = RUN_STATE_SEQUENCING
+	}
+	return ret, nil
+}
+
+//runFailed true when RunCompletionStatus.xml reports a run ended early by an error or by the user
+func runFailed(filename string) bool {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return false
+	}
+	status, err := ParseRunCompletionStatusXML(string(b))
+	if err != nil {
+		return false
+	}
+	st := status.Status()
+	return st == COMPLETION_FAILED || st == COMPLETION_USER_ENDED
+}
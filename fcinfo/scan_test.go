@@ -0,0 +1,73 @@
+package fcinfo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunScanner(t *testing.T) {
+	root, err := ioutil.TempDir("", "fcinfo-scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	touch := func(name, content string) {
+		filename := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, run := range []string{"A/run_seq", "A/run_rta", "B/run_copied", "B/run_failed", "run_stale"} {
+		touch(filepath.Join(run, "RunInfo.xml"), "<RunInfo/>")
+		touch(filepath.Join(run, "InterOp", "TileMetricsOut.bin"), "")
+	}
+	touch("A/run_rta/RTAComplete.txt", "")
+	touch("B/run_copied/RTAComplete.txt", "")
+	touch("B/run_copied/CopyComplete.txt", "")
+	touch("B/run_failed/RunCompletionStatus.xml", "<RunCompletionStatus><CompletionStatus>ExceptionEndedEarly</CompletionStatus></RunCompletionStatus>")
+	touch("not_a_run/notes.txt", "")
+	old := time.Now().Add(-48 * time.Hour)
+	for _, name := range []string{"run_stale", "run_stale/RunInfo.xml", "run_stale/InterOp", "run_stale/InterOp/TileMetricsOut.bin"} {
+		if err := os.Chtimes(filepath.Join(root, name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scanner := NewRunScanner(root)
+	states, changes, err := scanner.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]RunState{"run_seq": RUN_STATE_SEQUENCING, "run_rta": RUN_STATE_RTA_COMPLETE, "run_copied": RUN_STATE_COPY_COMPLETE,
+		"run_failed": RUN_STATE_FAILED, "run_stale": RUN_STATE_STALE}
+	if len(states) != len(want) || len(changes) != len(want) {
+		t.Fatalf("%d runs %d changes", len(states), len(changes))
+	}
+	for _, st := range states {
+		if want[st.RunId] != st.State {
+			t.Fatalf("%s is %s, expect %s", st.RunId, st.State, want[st.RunId])
+		}
+	}
+
+	//only what moved is reported, once
+	touch("A/run_rta/CopyComplete.txt", "")
+	if err := os.RemoveAll(filepath.Join(root, "run_stale")); err != nil {
+		t.Fatal(err)
+	}
+	_, changes, err = scanner.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].From != RUN_STATE_RTA_COMPLETE || changes[0].To != RUN_STATE_COPY_COMPLETE || changes[1].To != RUN_STATE_REMOVED {
+		t.Fatalf("changes %+v %+v", changes[0], changes[1])
+	}
+	if _, changes, _ = scanner.Scan(); len(changes) != 0 {
+		t.Fatalf("%d changes on an unchanged root", len(changes))
+	}
+}
@@ -7,7 +7,8 @@ import (
 	"io"
 	//	"fmt"
 	//	"math"
-	"os"
+
+	"github.com/ws6/interop/fcinfo"
 )
 
 type FwhmChannel struct {
@@ -30,6 +31,7 @@ type FwhmMetricsInfo struct {
 
 	Metrics []*FwhmSubTileMetrics
 	err     error
+	fsys    fcinfo.RunFS //nil reads Filename from disk
 }
 
 //for to get header only, this will prevent 1Giga bytes size memory being used
@@ -37,7 +39,7 @@ func (self *FwhmMetricsInfo) ParseHeaderOnly() error {
 	if self.err != nil {
 		return self.err
 	}
-	file, err := os.Open(self.Filename)
+	file, err := openMetricFile(self.fsys, self.Filename)
 	if err != nil {
 		self.err = err
 		return self.err
@@ -76,7 +78,7 @@ func (self *FwhmMetricsInfo) Parse() error {
 	if self.err != nil {
 		return self.err
 	}
-	file, err := os.Open(self.Filename)
+	file, err := openMetricFile(self.fsys, self.Filename)
 	if err != nil {
 		self.err = err
 		return self.err
@@ -0,0 +1,217 @@
+package interop
+
+//heatmap.go per tile values laid out by surface, swath and tile, SAV's flowcell chart
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	HEATMAP_INTENSITY   = SERIES_INTENSITY
+	HEATMAP_FWHM        = BYCYCLE_FWHM
+	HEATMAP_ERROR_RATE  = SERIES_ERROR_RATE
+	HEATMAP_PCT_Q30     = SERIES_PCT_Q30
+	HEATMAP_MEAN_QSCORE = BYCYCLE_MEAN_QSCORE
+
+	//HEATMAP_METRICS names accepted by Run.Heatmap; the first five do not depend on cycle
+	HEATMAP_METRICS = []string{
+		SUMMARY_DENSITY,
+		SUMMARY_DENSITY_PF,
+		SUMMARY_CLUSTERS,
+		SUMMARY_CLUSTERS_PF,
+		SUMMARY_PCT_PF,
+		HEATMAP_INTENSITY,
+		HEATMAP_FWHM,
+		HEATMAP_ERROR_RATE,
+		HEATMAP_PCT_Q30,
+		HEATMAP_MEAN_QSCORE,
+	}
+)
+
+type HeatmapTile struct {
+	TileNum     uint32
+	Surface     uint32
+	Swath       uint32
+	TileInSwath uint32
+	Value       float64
+}
+
+type HeatmapLane struct {
+	LaneNum uint16
+	Tiles   []*HeatmapTile
+}
+
+type Heatmap struct {
+	Metric        string
+	Cycle         int //0 when averaged over every cycle or the metric has no cycle
+	Surfaces      int
+	Swaths        int
+	TilesPerSwath int
+	Min           float64
+	Max           float64
+	Lanes         []*HeatmapLane
+}
+
+func (self *Heatmap) GetLane(laneNum uint16) *HeatmapLane {
+	for _, l := range self.Lanes {
+		if l.LaneNum == laneNum {
+			return l
+		}
+	}
+	return nil
+}
+
+//Heatmap per tile value of metric at cycle; cycle 0 averages every cycle
+func (self *Run) Heatmap(metric string, cycle int) (*Heatmap, error) {
+	values, err := self.heatmapValues(metric, cycle)
+	if err != nil {
+		return nil, err
+	}
+	ret := &Heatmap{Metric: metric, Cycle: cycle, Min: math.Inf(1), Max: math.Inf(-1)}
+	if self.RunInfo != nil {
+		layout := self.RunInfo.Run.FlowcellLayout
+		ret.Surfaces, ret.Swaths, ret.TilesPerSwath = layout.SurfaceCount, layout.SwathCount, layout.TileCount
+	}
+	lanes := map[uint16]*HeatmapLane{}
+	laneNums := []uint16{}
+	for _, k := range values.sortedKeys() {
+		if k.LaneNum == 0 {
+			continue
+		}
+		l, ok := lanes[k.LaneNum]
+		if !ok {
+			l = &HeatmapLane{LaneNum: k.LaneNum}
+			lanes[k.LaneNum] = l
+			laneNums = append(laneNums, k.LaneNum)
+		}
+		dim := GetTileDim(k.TileNum)
+		v := values[k]
+		l.Tiles = append(l.Tiles, &HeatmapTile{TileNum: k.TileNum, Surface: dim.Surface, Swath: dim.Swath, TileInSwath: dim.TilesInSwath, Value: v})
+		ret.Min = math.Min(ret.Min, v)
+		ret.Max = math.Max(ret.Max, v)
+		//RunInfo of older runs have no layout, take it from tile names
+		ret.Surfaces = maxInt(ret.Surfaces, int(dim.Surface))
+		ret.Swaths = maxInt(ret.Swaths, int(dim.Swath))
+		ret.TilesPerSwath = maxInt(ret.TilesPerSwath, int(dim.TilesInSwath))
+	}
+	if len(laneNums) == 0 {
+		return nil, fmt.Errorf("run %s has no %s tile values", self.Name(), metric)
+	}
+	sortUint16s(laneNums)
+	for _, ln := range laneNums {
+		ret.Lanes = append(ret.Lanes, lanes[ln])
+	}
+	return ret, nil
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (self *Run) heatmapValues(metric string, cycle int) (tileValues, error) {
+	inCycle := func(c uint16) bool {
+		return cycle == 0 || int(c) == cycle
+	}
+	missing := func(file string) error {
+		return fmt.Errorf("%s needs %s which run %s does not have", metric, file, self.Name())
+	}
+	counter := newTileCounter()
+	switch metric {
+	case SUMMARY_DENSITY, SUMMARY_DENSITY_PF, SUMMARY_CLUSTERS, SUMMARY_CLUSTERS_PF, SUMMARY_PCT_PF:
+		if self.Tile == nil {
+			return nil, missing("TileMetricsOut.bin")
+		}
+		return self.clusterTileValues()[metric], nil
+	case HEATMAP_INTENSITY:
+		if self.Extraction == nil {
+			return nil, missing("ExtractionMetricsOut.bin")
+		}
+		self.Extraction.EachIntensity(func(laneNum uint16, tileNum uint32, c uint16, intensity []uint16) {
+			if inCycle(c) {
+				counter.add(laneNum, tileNum, float64(maxUint16(intensity)), 1)
+			}
+		})
+		return counter.values(1), nil
+	case HEATMAP_FWHM:
+		if self.Extraction == nil {
+			return nil, missing("ExtractionMetricsOut.bin")
+		}
+		self.Extraction.EachFwhm(func(laneNum uint16, tileNum uint32, c uint16, fwhm []float32) {
+			if !inCycle(c) {
+				return
+			}
+			for _, v := range fwhm {
+				counter.add(laneNum, tileNum, float64(v), 1)
+			}
+		})
+		return counter.values(1), nil
+	case HEATMAP_ERROR_RATE:
+		if self.Error == nil {
+			return nil, missing("ErrorMetricsOut.bin")
+		}
+		self.Error.EachRecord(func(laneNum uint16, tileNum uint32, c uint16, errorRate float32) {
+			if inCycle(c) {
+				counter.add(laneNum, tileNum, float64(errorRate), 1)
+			}
+		})
+		return counter.values(1), nil
+	case HEATMAP_PCT_Q30, HEATMAP_MEAN_QSCORE:
+		if self.Q == nil {
+			return nil, missing("QMetricsOut.bin")
+		}
+		self.Q.EachRecord(func(laneNum uint16, tileNum uint32, c uint16, numClusters *[50]uint32) {
+			if !inCycle(c) {
+				return
+			}
+			if metric == HEATMAP_PCT_Q30 {
+				above, total := QscoreAbove(numClusters, Q30)
+				counter.add(laneNum, tileNum, float64(above), float64(total))
+				return
+			}
+			for qval, n := range numClusters {
+				counter.add(laneNum, tileNum, float64(qval+1)*float64(n), float64(n))
+			}
+		})
+		if metric == HEATMAP_PCT_Q30 {
+			return counter.values(100), nil
+		}
+		return counter.values(1), nil
+	}
+	sorted := append([]string{}, HEATMAP_METRICS...)
+	sort.Strings(sorted)
+	return nil, fmt.Errorf("unknown heatmap metric %s, expect one of %s", metric, strings.Join(sorted, ","))
+}
+
+//WriteCSV one row per lane and tile
+func (self *Heatmap) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"Lane", "Tile", "Surface", "Swath", "Tile Number", self.Metric}); err != nil {
+		return err
+	}
+	for _, l := range self.Lanes {
+		for _, t := range l.Tiles {
+			rec := []string{
+				strconv.Itoa(int(l.LaneNum)),
+				strconv.Itoa(int(t.TileNum)),
+				strconv.Itoa(int(t.Surface)),
+				strconv.Itoa(int(t.Swath)),
+				strconv.Itoa(int(t.TileInSwath)),
+				strconv.FormatFloat(t.Value, 'g', 6, 64),
+			}
+			if err := cw.Write(rec); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -4,7 +4,8 @@ import (
 	"bufio"
 	"encoding/binary"
 	"fmt"
-	"os"
+
+	"github.com/ws6/interop/fcinfo"
 )
 
 type ImageMetrics struct {
@@ -26,6 +27,7 @@ type ImageInfo struct {
 	NumOfChannels uint8
 	Metrics       []*ImageMetrics
 	err           error
+	fsys          fcinfo.RunFS //nil reads Filename from disk
 }
 
 func NewImageMetrics(NumOfChannels uint8) *ImageMetrics {
@@ -39,7 +41,7 @@ func (self *ImageInfo) Parse() error {
 	if self.err != nil {
 		return self.err
 	}
-	file, err := os.Open(self.Filename)
+	file, err := openMetricFile(self.fsys, self.Filename)
 	if err != nil {
 		self.err = err
 		return self.err
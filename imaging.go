@@ -0,0 +1,180 @@
+package interop
+
+//imaging.go one row per lane, tile and cycle joining every per cycle metric, SAV's Imaging tab
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+var (
+	IMAGING_FIXED_COLUMNS = []string{"Lane", "Tile", "Cycle", "Read", "Cycle Within Read", "Surface", "Swath", "Tile Number"}
+)
+
+type ImagingRow struct {
+	LaneNum         uint16
+	TileNum         uint32
+	Cycle           uint16
+	ReadNum         int
+	CycleWithinRead int
+	Values          []float64 //one per ImagingTable.Columns, NaN when missing
+}
+
+type ImagingTable struct {
+	Columns []string //value columns, after IMAGING_FIXED_COLUMNS
+	Rows    []*ImagingRow
+}
+
+type imagingKey struct {
+	LaneNum uint16
+	TileNum uint32
+	Cycle   uint16
+}
+
+type imagingBuilder struct {
+	columns []string
+	index   map[string]int
+	rows    map[imagingKey]map[int]float64
+}
+
+func (self *imagingBuilder) column(name string) int {
+	if i, ok := self.index[name]; ok {
+		return i
+	}
+	self.index[name] = len(self.columns)
+	self.columns = append(self.columns, name)
+	return self.index[name]
+}
+
+func (self *imagingBuilder) set(laneNum uint16, tileNum uint32, cycle uint16, name string, v float64) {
+	if laneNum == 0 || cycle == 0 {
+		return
+	}
+	k := imagingKey{laneNum, tileNum, cycle}
+	if _, ok := self.rows[k]; !ok {
+		self.rows[k] = map[int]float64{}
+	}
+	self.rows[k][self.column(name)] = v
+}
+
+//ImagingTable every per cycle value of the run; tile level cluster metrics are repeated on each cycle
+func (self *Run) ImagingTable() *ImagingTable {
+	b := &imagingBuilder{index: map[string]int{}, rows: map[imagingKey]map[int]float64{}}
+	if self.Extraction != nil {
+		self.Extraction.EachIntensity(func(laneNum uint16, tileNum uint32, cycle uint16, intensity []uint16) {
+			for i, v := range intensity {
+				b.set(laneNum, tileNum, cycle, fmt.Sprintf("Intensity %d", i+1), float64(v))
+			}
+		})
+		self.Extraction.EachFwhm(func(laneNum uint16, tileNum uint32, cycle uint16, fwhm []float32) {
+			for i, v := range fwhm {
+				b.set(laneNum, tileNum, cycle, fmt.Sprintf("FWHM %d", i+1), float64(v))
+			}
+		})
+	}
+	if self.Error != nil {
+		self.Error.EachRecord(func(laneNum uint16, tileNum uint32, cycle uint16, errorRate float32) {
+			b.set(laneNum, tileNum, cycle, "Error Rate", float64(errorRate))
+		})
+	}
+	if self.Q != nil {
+		self.Q.EachRecord(func(laneNum uint16, tileNum uint32, cycle uint16, numClusters *[50]uint32) {
+			above, total := QscoreAbove(numClusters, Q30)
+			if total > 0 {
+				b.set(laneNum, tileNum, cycle, "%>=Q30", 100.*float64(above)/float64(total))
+			}
+		})
+	}
+	if self.CorrectedInt != nil {
+		for _, m := range self.CorrectedInt.Metrics {
+			b.set(m.LaneNum, uint32(m.TileNum), m.Cycle, "Corrected Int All", float64(m.AvgIntensity))
+		}
+	}
+
+	//cluster metrics go on every cycle already seen for the tile
+	tileCycles := map[tileKey][]uint16{}
+	for k := range b.rows {
+		tk := tileKey{k.LaneNum, k.TileNum}
+		tileCycles[tk] = append(tileCycles[tk], k.Cycle)
+	}
+	common := self.clusterTileValues()
+	for _, spec := range []struct{ metric, column string }{
+		{SUMMARY_DENSITY, "Density(k/mm2)"},
+		{SUMMARY_CLUSTERS, "Cluster Count"},
+		{SUMMARY_CLUSTERS_PF, "Cluster Count PF"},
+		{SUMMARY_PCT_PF, "% PF"},
+	} {
+		values := common[spec.metric]
+		for _, tk := range values.sortedKeys() {
+			for _, c := range tileCycles[tk] {
+				b.set(tk.LaneNum, tk.TileNum, c, spec.column, values[tk])
+			}
+		}
+	}
+
+	ret := &ImagingTable{Columns: b.columns}
+	for k, cells := range b.rows {
+		row := &ImagingRow{LaneNum: k.LaneNum, TileNum: k.TileNum, Cycle: k.Cycle, Values: make([]float64, len(b.columns))}
+		for i := range row.Values {
+			row.Values[i] = math.NaN()
+		}
+		for i, v := range cells {
+			row.Values[i] = v
+		}
+		if self.RunInfo != nil {
+			if readNum := self.RunInfo.ReadNumOfCycle(int(k.Cycle)); readNum != 0 {
+				row.ReadNum = readNum
+				row.CycleWithinRead = int(k.Cycle) - int(self.RunInfo.GetFirstLastCyclesByRead(readNum)[0]) + 1
+			}
+		}
+		ret.Rows = append(ret.Rows, row)
+	}
+	sort.Slice(ret.Rows, func(i, j int) bool {
+		a, b := ret.Rows[i], ret.Rows[j]
+		if a.LaneNum != b.LaneNum {
+			return a.LaneNum < b.LaneNum
+		}
+		if a.TileNum != b.TileNum {
+			return a.TileNum < b.TileNum
+		}
+		return a.Cycle < b.Cycle
+	})
+	return ret
+}
+
+//WriteCSV IMAGING_FIXED_COLUMNS then Columns; missing values are empty
+func (self *ImagingTable) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append(append([]string{}, IMAGING_FIXED_COLUMNS...), self.Columns...)); err != nil {
+		return err
+	}
+	for _, r := range self.Rows {
+		dim := GetTileDim(r.TileNum)
+		rec := []string{
+			strconv.Itoa(int(r.LaneNum)),
+			strconv.Itoa(int(r.TileNum)),
+			strconv.Itoa(int(r.Cycle)),
+			strconv.Itoa(r.ReadNum),
+			strconv.Itoa(r.CycleWithinRead),
+			strconv.Itoa(int(dim.Surface)),
+			strconv.Itoa(int(dim.Swath)),
+			strconv.Itoa(int(dim.TilesInSwath)),
+		}
+		for _, v := range r.Values {
+			if math.IsNaN(v) {
+				rec = append(rec, "")
+				continue
+			}
+			rec = append(rec, strconv.FormatFloat(v, 'g', 6, 64))
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,162 @@
+package interop
+
+//index_summary.go per lane and per sample index representation, SAV's Indexing tab
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+)
+
+type IndexSampleSummary struct {
+	IndexName       string //i7-i5 as written by RTA
+	SampleName      string
+	ProjectName     string
+	Clusters        uint64  //PF clusters assigned to the sample
+	PctOfLanePF     float64 //% of all PF clusters of the lane
+	PctOfIdentified float64 //% of the identified clusters of the lane
+}
+
+type IndexLaneSummary struct {
+	LaneNum            uint16
+	TotalPFClusters    float64 //from TileMetrics; zero if not available
+	IdentifiedClusters uint64
+	PctIdentified      float64 //identified / PF %
+	CV                 float64 //coefficient of variation of sample PctOfIdentified
+	Min                float64 //lowest sample PctOfIdentified
+	Max                float64 //highest sample PctOfIdentified
+	Samples            []*IndexSampleSummary
+}
+
+type IndexSummary struct {
+	Lanes []*IndexLaneSummary
+}
+
+func (self *IndexSummary) GetLane(laneNum uint16) *IndexLaneSummary {
+	for _, l := range self.Lanes {
+		if l.LaneNum == laneNum {
+			return l
+		}
+	}
+	return nil
+}
+
+func (self *IndexLaneSummary) GetSample(sampleName, indexName string) *IndexSampleSummary {
+	for _, s := range self.Samples {
+		if s.SampleName == sampleName && s.IndexName == indexName {
+			return s
+		}
+	}
+	return nil
+}
+
+//BuildIndexSummary sum index metrics per lane and sample. tileInfo is optional.
+//Only the last index read of a lane is counted, so files with a record per index read do not count twice.
+func BuildIndexSummary(indexInfo *IndexInfo, tileInfo *TileInfo) *IndexSummary {
+	ret := new(IndexSummary)
+	if indexInfo == nil {
+		return ret
+	}
+	lastRead := map[uint16]uint16{}
+	for _, m := range indexInfo.Metrics {
+		if m.Read > lastRead[m.LaneNum] {
+			lastRead[m.LaneNum] = m.Read
+		}
+	}
+	type sampleKey struct {
+		SampleName string
+		IndexName  string
+	}
+	lanes := map[uint16]map[sampleKey]*IndexSampleSummary{}
+	for _, m := range indexInfo.Metrics {
+		if m.LaneNum == 0 || m.Read != lastRead[m.LaneNum] {
+			continue
+		}
+		if _, ok := lanes[m.LaneNum]; !ok {
+			lanes[m.LaneNum] = map[sampleKey]*IndexSampleSummary{}
+		}
+		k := sampleKey{m.SampleName, m.IndexName}
+		s, ok := lanes[m.LaneNum][k]
+		if !ok {
+			s = &IndexSampleSummary{IndexName: m.IndexName, SampleName: m.SampleName, ProjectName: m.ProjectName}
+			lanes[m.LaneNum][k] = s
+		}
+		s.Clusters += uint64(m.Clusters_PF)
+	}
+
+	pfByLane := map[uint16]float64{}
+	if tileInfo != nil {
+		_, pf := tileInfo.ClustersByTile()
+		for ln, tiles := range pf {
+			for _, v := range tiles {
+				pfByLane[ln] += v
+			}
+		}
+	}
+
+	laneNums := []uint16{}
+	for ln := range lanes {
+		laneNums = append(laneNums, ln)
+	}
+	sortUint16s(laneNums)
+	for _, ln := range laneNums {
+		ls := &IndexLaneSummary{LaneNum: ln, TotalPFClusters: pfByLane[ln]}
+		for _, s := range lanes[ln] {
+			ls.IdentifiedClusters += s.Clusters
+			ls.Samples = append(ls.Samples, s)
+		}
+		sort.Slice(ls.Samples, func(i, j int) bool {
+			if ls.Samples[i].SampleName != ls.Samples[j].SampleName {
+				return ls.Samples[i].SampleName < ls.Samples[j].SampleName
+			}
+			return ls.Samples[i].IndexName < ls.Samples[j].IndexName
+		})
+		pcts := []float64{}
+		for _, s := range ls.Samples {
+			if ls.IdentifiedClusters > 0 {
+				s.PctOfIdentified = 100. * float64(s.Clusters) / float64(ls.IdentifiedClusters)
+			}
+			if ls.TotalPFClusters > 0 {
+				s.PctOfLanePF = 100. * float64(s.Clusters) / ls.TotalPFClusters
+			}
+			pcts = append(pcts, s.PctOfIdentified)
+		}
+		if ls.TotalPFClusters > 0 {
+			ls.PctIdentified = 100. * float64(ls.IdentifiedClusters) / ls.TotalPFClusters
+		}
+		if len(pcts) > 0 {
+			mean, stdev := MeanStat(&pcts)
+			if mean > 0 {
+				ls.CV = stdev / mean
+			}
+			ls.Min, ls.Max = math.Inf(1), math.Inf(-1)
+			for _, p := range pcts {
+				ls.Min = math.Min(ls.Min, p)
+				ls.Max = math.Max(ls.Max, p)
+			}
+		}
+		ret.Lanes = append(ret.Lanes, ls)
+	}
+	return ret
+}
+
+func (self *Run) IndexSummary() *IndexSummary {
+	return BuildIndexSummary(self.Index, self.Tile)
+}
+
+//WriteTable SAV like text, one block of samples per lane
+func (self *IndexSummary) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	for _, ls := range self.Lanes {
+		fmt.Fprintf(tw, "Lane %d\tPF Clusters %.0f\tIdentified %d (%.2f%%)\tCV %.4f\tMin %.4f\tMax %.4f\t\n",
+			ls.LaneNum, ls.TotalPFClusters, ls.IdentifiedClusters, ls.PctIdentified, ls.CV, ls.Min, ls.Max)
+		fmt.Fprintln(tw, "Sample\tProject\tIndex\tClusters\t% of Lane PF\t% of Identified\t")
+		for _, s := range ls.Samples {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%.4f\t%.4f\t\n", s.SampleName, s.ProjectName, s.IndexName, s.Clusters, s.PctOfLanePF, s.PctOfIdentified)
+		}
+		fmt.Fprintln(tw, "\t")
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,328 @@
+package interop
+
+//manifest.go what a run folder held when it was archived: run metadata, the InterOp files with their versions and
+//record counts, Data/ bytes per lane and SHA-256 checksums of key files. VerifyManifest tells what went missing or changed since.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ws6/interop/fcinfo"
+)
+
+var (
+	MANIFEST_VERSION = 1
+
+	//MANIFEST_KEY_FILES globs relative to the run folder checksummed besides RunInfo.xml and RunParameters.xml
+	MANIFEST_KEY_FILES = []string{
+		fcinfo.RUN_COMPLETION_FILE,
+		fcinfo.RTA_COMPLETE_FILE,
+		fcinfo.COPY_COMPLETE_FILE,
+		"SampleSheet*.csv",
+		filepath.Join(INTEROP_DIR, "*.bin"),
+	}
+
+	MANIFEST_LANE_FOLDER = regexp.MustCompile(`^L(\d{3})$`)
+
+	PROBLEM_MISSING  = "missing"
+	PROBLEM_SIZE     = "size changed"
+	PROBLEM_CHECKSUM = "checksum changed"
+	PROBLEM_DATA     = "Data/ size changed"
+)
+
+//ManifestInterOpFile Parsed false for files LoadRun does not pick up, or that failed to parse
+type ManifestInterOpFile struct {
+	Name    string
+	Size    int64
+	Version uint8
+	Records int
+	Parsed  bool
+	Err     string `json:",omitempty"`
+}
+
+//LaneData files and bytes under Data/ in L00n folders; LaneNum 0 sums what is outside any lane folder
+type LaneData struct {
+	LaneNum int
+	Files   int
+	Bytes   int64
+}
+
+//ManifestFile Path relative to the run folder, slash separated
+type ManifestFile struct {
+	Path   string
+	Size   int64
+	SHA256 string
+}
+
+type Manifest struct {
+	ManifestVersion int
+	Created         time.Time
+	RunId           string
+	Flowcell        *fcinfo.FlowcellInfo
+	InterOp         []*ManifestInterOpFile
+	Data            []*LaneData
+	Files           []*ManifestFile
+}
+
+//ManifestProblem Expected and Found are sizes or checksums, empty for missing files
+type ManifestProblem struct {
+	Path     string
+	Problem  string
+	Expected string `json:",omitempty"`
+	Found    string `json:",omitempty"`
+}
+
+func (self *ManifestProblem) String() string {
+	if self.Problem == PROBLEM_MISSING {
+		return fmt.Sprintf("%s %s", self.Path, self.Problem)
+	}
+	return fmt.Sprintf("%s %s: %s, found %s", self.Path, self.Problem, self.Expected, self.Found)
+}
+
+//BuildManifest a run folder CheckFlowcellRunFolder accepts, that is with RunInfo.xml, Data/ and InterOp/
+func BuildManifest(runFolder string) (*Manifest, error) {
+	folder, err := fcinfo.CheckFlowcellRunFolder(runFolder)
+	if err != nil {
+		return nil, err
+	}
+	dir := folder.RunFolder
+	ret := &Manifest{ManifestVersion: MANIFEST_VERSION, Created: time.Now()}
+	if ret.Flowcell, err = fcinfo.ReadFlowcellInfo(dir); err != nil {
+		return nil, fmt.Errorf("read run metadata err:%s", err.Error())
+	}
+	ret.RunId = ret.Flowcell.RunId
+
+	run, err := LoadRun(dir)
+	if err != nil {
+		return nil, err
+	}
+	if ret.InterOp, err = manifestInterOp(run); err != nil {
+		return nil, err
+	}
+	if ret.Data, err = laneData(filepath.Join(dir, "Data")); err != nil {
+		return nil, err
+	}
+
+	keyFiles := []string{folder.RunInfoFileName}
+	if folder.RunParameterFileName != "" {
+		keyFiles = append(keyFiles, folder.RunParameterFileName)
+	}
+	for _, pat := range MANIFEST_KEY_FILES {
+		found, err := filepath.Glob(filepath.Join(dir, pat))
+		if err != nil {
+			return nil, fmt.Errorf("bad key file pattern %s err:%s", pat, err.Error())
+		}
+		sort.Strings(found)
+		keyFiles = append(keyFiles, found...)
+	}
+	seen := map[string]bool{}
+	for _, filename := range keyFiles {
+		if seen[filename] {
+			continue
+		}
+		seen[filename] = true
+		rel, err := filepath.Rel(dir, filename)
+		if err != nil {
+			return nil, err
+		}
+		f, err := checksumFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		f.Path = filepath.ToSlash(rel)
+		ret.Files = append(ret.Files, f)
+	}
+	return ret, nil
+}
+
+//manifestInterOp every *.bin under InterOp/, with record counts of those run parsed
+func manifestInterOp(run *Run) ([]*ManifestInterOpFile, error) {
+	interOpDir := filepath.Join(run.RunFolder, INTEROP_DIR)
+	found, err := filepath.Glob(filepath.Join(interOpDir, "*.bin"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(found)
+	known := map[string]*InterOpFile{}
+	for _, f := range InterOpFiles {
+		known[f.Name] = f
+	}
+	ret := []*ManifestInterOpFile{}
+	for _, filename := range found {
+		fi, err := os.Stat(filename)
+		if err != nil {
+			return nil, err
+		}
+		m := &ManifestInterOpFile{Name: fi.Name(), Size: fi.Size()}
+		if v, err := PeekVersion(filename); err == nil {
+			m.Version = v
+		}
+		if err := run.ParseErrors[m.Name]; err != nil {
+			m.Err = err.Error()
+		} else if f, ok := known[m.Name]; ok {
+			m.Records, m.Parsed = countRecords(reflect.ValueOf(run).Elem().FieldByName(f.Field))
+		}
+		ret = append(ret, m)
+	}
+	return ret, nil
+}
+
+//countRecords sum of the Metrics* slices of a parsed metrics struct
+func countRecords(field reflect.Value) (int, bool) {
+	if !field.IsValid() || field.Kind() != reflect.Ptr || field.IsNil() {
+		return 0, false
+	}
+	v := field.Elem()
+	n := 0
+	for i := 0; i < v.NumField(); i++ {
+		if strings.HasPrefix(v.Type().Field(i).Name, "Metrics") && v.Field(i).Kind() == reflect.Slice {
+			n += v.Field(i).Len()
+		}
+	}
+	return n, true
+}
+
+//laneData sizes under dataDir by the L00n folder they are in
+func laneData(dataDir string) ([]*LaneData, error) {
+	lanes := map[int]*LaneData{}
+	err := filepath.Walk(dataDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		laneNum := 0
+		rel, _ := filepath.Rel(dataDir, path)
+		for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+			if m := MANIFEST_LANE_FOLDER.FindStringSubmatch(part); m != nil {
+				laneNum, _ = strconv.Atoi(m[1])
+				break
+			}
+		}
+		l, ok := lanes[laneNum]
+		if !ok {
+			l = &LaneData{LaneNum: laneNum}
+			lanes[laneNum] = l
+		}
+		l.Files++
+		l.Bytes += fi.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk Data/ err:%s", err.Error())
+	}
+	ret := []*LaneData{}
+	for _, l := range lanes {
+		ret = append(ret, l)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].LaneNum < ret[j].LaneNum })
+	return ret, nil
+}
+
+func checksumFile(filename string) (*ManifestFile, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return nil, fmt.Errorf("checksum %s err:%s", filename, err.Error())
+	}
+	return &ManifestFile{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+//Write indented JSON
+func (self *Manifest) Write(w io.Writer) error {
+	b, err := json.MarshalIndent(self, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	ret := new(Manifest)
+	if err := json.NewDecoder(r).Decode(ret); err != nil {
+		return nil, fmt.Errorf("read manifest err:%s", err.Error())
+	}
+	if ret.ManifestVersion > MANIFEST_VERSION {
+		return nil, fmt.Errorf("manifest version %d is newer than %d", ret.ManifestVersion, MANIFEST_VERSION)
+	}
+	return ret, nil
+}
+
+//VerifyManifest checksummed files missing or changed in runFolder, and lanes whose Data/ size changed; none when intact
+func VerifyManifest(runFolder string, manifest *Manifest) ([]*ManifestProblem, error) {
+	dir, err := filepath.Abs(runFolder)
+	if err != nil {
+		return nil, err
+	}
+	ret := []*ManifestProblem{}
+	for _, want := range manifest.Files {
+		got, err := checksumFile(filepath.Join(dir, filepath.FromSlash(want.Path)))
+		switch {
+		case os.IsNotExist(err):
+			ret = append(ret, &ManifestProblem{Path: want.Path, Problem: PROBLEM_MISSING})
+		case err != nil:
+			return nil, err
+		case got.Size != want.Size:
+			ret = append(ret, &ManifestProblem{Path: want.Path, Problem: PROBLEM_SIZE, Expected: strconv.FormatInt(want.Size, 10), Found: strconv.FormatInt(got.Size, 10)})
+		case got.SHA256 != want.SHA256:
+			ret = append(ret, &ManifestProblem{Path: want.Path, Problem: PROBLEM_CHECKSUM, Expected: want.SHA256, Found: got.SHA256})
+		}
+	}
+
+	dataDir := filepath.Join(dir, "Data")
+	if _, err := os.Stat(dataDir); err != nil {
+		if len(manifest.Data) > 0 {
+			ret = append(ret, &ManifestProblem{Path: "Data", Problem: PROBLEM_MISSING})
+		}
+		return ret, nil
+	}
+	lanes, err := laneData(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	found := map[int]*LaneData{}
+	for _, l := range lanes {
+		found[l.LaneNum] = l
+	}
+	for _, want := range manifest.Data {
+		got, ok := found[want.LaneNum]
+		if !ok {
+			got = &LaneData{LaneNum: want.LaneNum}
+		}
+		if got.Files != want.Files || got.Bytes != want.Bytes {
+			ret = append(ret, &ManifestProblem{
+				Path:     laneDataPath(want.LaneNum),
+				Problem:  PROBLEM_DATA,
+				Expected: fmt.Sprintf("%d files %d bytes", want.Files, want.Bytes),
+				Found:    fmt.Sprintf("%d files %d bytes", got.Files, got.Bytes),
+			})
+		}
+	}
+	return ret, nil
+}
+
+//laneDataPath lane folders sit at several depths under Data/, so name the lane rather than a path
+func laneDataPath(laneNum int) string {
+	if laneNum == 0 {
+		return "Data outside lane folders"
+	}
+	return fmt.Sprintf("Data L%03d", laneNum)
+}
@@ -0,0 +1,103 @@
+package interop
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	runFolder, err := ioutil.TempDir("", "interop-manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(runFolder)
+	files := map[string]string{
+		"RunInfo.xml":                             testRunInfo,
+		"SampleSheet.csv":                         "[Data]\nSample_ID\ns1\n",
+		"Data/Intensities/BaseCalls/L001/a.bcl":   "1234",
+		"Data/Intensities/BaseCalls/L001/b.bcl":   "12",
+		"Data/Intensities/BaseCalls/L002/a.bcl":   "123",
+		"Data/Intensities/BaseCalls/config.xml":   "<x/>",
+		"InterOp/FWHMGridMetricsOut.bin":          "\x01",
+		"InterOp/ErrorMetricsOut.bin":             "",
+		"InterOp/IndexMetricsOut.bin":             "",
+		"Thumbnail_Images/L001/C1.1/s_1_1101.jpg": "jpg",
+	}
+	for name, content := range files {
+		filename := filepath.Join(runFolder, filepath.FromSlash(name))
+		if content == "" {
+			b, err := ioutil.ReadFile(filepath.Join("test_data", INTEROP_DIR, filepath.Base(name)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			content = string(b)
+		}
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := BuildManifest(runFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.RunId != "131220_SN1_0001_AH7TESTXX" || m.Flowcell == nil || m.Flowcell.FlowcellBarcode != "H7TESTXX" {
+		t.Fatalf("run %s flowcell %+v", m.RunId, m.Flowcell)
+	}
+	if len(m.InterOp) != 3 || m.InterOp[0].Name != "ErrorMetricsOut.bin" || !m.InterOp[0].Parsed || m.InterOp[0].Records == 0 || m.InterOp[0].Version != 3 ||
+		m.InterOp[1].Name != "FWHMGridMetricsOut.bin" || m.InterOp[1].Parsed || m.InterOp[1].Version != 1 {
+		t.Fatalf("InterOp %+v %+v", m.InterOp[0], m.InterOp[1])
+	}
+	if want := []*LaneData{{0, 1, 4}, {1, 2, 6}, {2, 1, 3}}; !reflect.DeepEqual(m.Data, want) {
+		t.Fatalf("Data %+v", m.Data)
+	}
+	paths := []string{}
+	for _, f := range m.Files {
+		paths = append(paths, f.Path)
+	}
+	if want := []string{"RunInfo.xml", "SampleSheet.csv", "InterOp/ErrorMetricsOut.bin", "InterOp/FWHMGridMetricsOut.bin", "InterOp/IndexMetricsOut.bin"}; !reflect.DeepEqual(paths, want) {
+		t.Fatalf("checksummed %v", paths)
+	}
+
+	var buf bytes.Buffer
+	if err := m.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	back, err := ReadManifest(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems, err := VerifyManifest(runFolder, back); err != nil || len(problems) != 0 {
+		t.Fatalf("intact run folder: %v %v", problems, err)
+	}
+
+	if err := os.Remove(filepath.Join(runFolder, "SampleSheet.csv")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(runFolder, "RunInfo.xml"), []byte(strings.Replace(testRunInfo, "SN1", "SN2", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(runFolder, "Data", "Intensities", "BaseCalls", "L002", "a.bcl")); err != nil {
+		t.Fatal(err)
+	}
+	problems, err := VerifyManifest(runFolder, back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, p := range problems {
+		got = append(got, p.Path+" "+p.Problem)
+	}
+	want := []string{"RunInfo.xml " + PROBLEM_CHECKSUM, "SampleSheet.csv " + PROBLEM_MISSING, "Data L002 " + PROBLEM_DATA}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("problems %v, expect %v", got, want)
+	}
+}
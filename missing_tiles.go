@@ -0,0 +1,244 @@
+package interop
+
+//missing_tiles.go tiles RunInfo.xml lays out against the tiles each metric file reports, cycle by cycle.
+//Lane averages hide a tile that was never imaged or stopped being imaged half way; these reports do not.
+
+import (
+	"sort"
+)
+
+var (
+	TILE_SOURCE_TILESET  = "TileSet"        //RunInfo.xml lists every tile
+	TILE_SOURCE_LAYOUT   = "FlowcellLayout" //enumerated from lane, surface, swath and tile counts
+	TILE_SOURCE_OBSERVED = "observed"       //RunInfo.xml does not tell, every tile seen in any metric file
+
+	METRIC_TILE       = "Tile"
+	METRIC_ERROR      = "Error"
+	METRIC_EXTRACTION = "Extraction"
+)
+
+//MissingTile tile expected but absent from Metrics altogether
+type MissingTile struct {
+	LaneNum uint16
+	TileNum uint32
+	Metrics []string
+}
+
+//TileDropout tile reported up to LastCycle while its lane went on to MaxCycle
+type TileDropout struct {
+	LaneNum   uint16
+	TileNum   uint32
+	Metric    string
+	LastCycle uint16
+	MaxCycle  uint16
+}
+
+//LaneImaging lane of Metric with cycles reporting fewer tiles than Expected
+type LaneImaging struct {
+	LaneNum              uint16
+	Metric               string
+	Expected             int
+	IncompleteCycles     int
+	FirstIncompleteCycle uint16
+	MinTiles             int
+	MinTilesCycle        uint16
+}
+
+type TileCoverage struct {
+	Source          string
+	Expected        int
+	Missing         []*MissingTile
+	Dropouts        []*TileDropout
+	IncompleteLanes []*LaneImaging
+}
+
+//tilePresence tiles a metric file reports at each cycle; per tile files report at cycle 0
+type tilePresence map[uint16]tileSet
+
+func (self tilePresence) add(laneNum uint16, tileNum uint32, cycle uint16) {
+	m, ok := self[cycle]
+	if !ok {
+		m = tileSet{}
+		self[cycle] = m
+	}
+	m[tileKey{laneNum, tileNum}] = true
+}
+
+//presence of the Tile, Error and Extraction files loaded, by metric name
+func (self *Run) presence() (map[string]tilePresence, []string) {
+	ret := map[string]tilePresence{}
+	if self.Tile != nil {
+		p := tilePresence{}
+		for _, m := range self.Tile.Metrics {
+			p.add(m.LaneNum, uint32(m.TileNum), 0)
+		}
+		for _, m := range self.Tile.Metrics3 {
+			p.add(m.LaneNum, m.TileNum, 0)
+		}
+		ret[METRIC_TILE] = p
+	}
+	if self.Error != nil {
+		p := tilePresence{}
+		self.Error.EachRecord(func(laneNum uint16, tileNum uint32, cycle uint16, _ float32) {
+			p.add(laneNum, tileNum, cycle)
+		})
+		ret[METRIC_ERROR] = p
+	}
+	if self.Extraction != nil {
+		p := tilePresence{}
+		for _, m := range self.Extraction.Metrics {
+			p.add(m.LaneNum, uint32(m.TileNum), m.Cycle)
+		}
+		for _, m := range self.Extraction.Metrics3 {
+			p.add(m.LaneNum, m.TileNum, m.Cycle)
+		}
+		ret[METRIC_EXTRACTION] = p
+	}
+	names := []string{}
+	for _, name := range []string{METRIC_TILE, METRIC_ERROR, METRIC_EXTRACTION} {
+		if _, ok := ret[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return ret, names
+}
+
+//TileCoverage missing tiles, tiles dropping out before the end of their lane, and lanes imaged incompletely
+func (self *Run) TileCoverage() *TileCoverage {
+	ret := &TileCoverage{Source: TILE_SOURCE_OBSERVED}
+	presence, names := self.presence()
+
+	expected := tileSet{}
+	if self.RunInfo != nil {
+		if tiles, ok := self.RunInfo.ExpectedTiles(); ok {
+			ret.Source = TILE_SOURCE_LAYOUT
+			if layout := self.RunInfo.Run.FlowcellLayout; layout.TileSet != nil && len(layout.TileSet.Tiles) > 0 {
+				ret.Source = TILE_SOURCE_TILESET
+			}
+			for lane, arr := range tiles {
+				for _, t := range arr {
+					expected[tileKey{uint16(lane), uint32(t)}] = true
+				}
+			}
+		}
+	}
+	if ret.Source == TILE_SOURCE_OBSERVED {
+		for _, p := range presence {
+			for _, tiles := range p {
+				for k := range tiles {
+					expected[k] = true
+				}
+			}
+		}
+	}
+	ret.Expected = len(expected)
+	perLane := map[uint16]int{}
+	for k := range expected {
+		perLane[k.LaneNum]++
+	}
+
+	//ever seen, per metric
+	seen := map[string]tileSet{}
+	for _, name := range names {
+		all := tileSet{}
+		for _, tiles := range presence[name] {
+			for k := range tiles {
+				all[k] = true
+			}
+		}
+		seen[name] = all
+	}
+	for _, k := range expected.sortedKeys() {
+		m := &MissingTile{LaneNum: k.LaneNum, TileNum: k.TileNum}
+		for _, name := range names {
+			if !seen[name][k] {
+				m.Metrics = append(m.Metrics, name)
+			}
+		}
+		if len(m.Metrics) > 0 {
+			ret.Missing = append(ret.Missing, m)
+		}
+	}
+
+	for _, name := range names {
+		p := presence[name]
+		cycles := []uint16{}
+		for c := range p {
+			if c > 0 {
+				cycles = append(cycles, c)
+			}
+		}
+		if len(cycles) == 0 {
+			continue
+		}
+		sortUint16s(cycles)
+
+		lastSeen := map[tileKey]uint16{}
+		laneMin, laneMax := map[uint16]uint16{}, map[uint16]uint16{}
+		for _, c := range cycles {
+			for k := range p[c] {
+				lastSeen[k] = c
+				laneMax[k.LaneNum] = c
+				if _, ok := laneMin[k.LaneNum]; !ok {
+					laneMin[k.LaneNum] = c
+				}
+			}
+		}
+		//a lane silent at a cycle other lanes report counts as imaging no tile
+		lanes := map[uint16]*LaneImaging{}
+		for _, c := range cycles {
+			count := map[uint16]int{}
+			for k := range p[c] {
+				if expected[k] {
+					count[k.LaneNum]++
+				}
+			}
+			for ln, want := range perLane {
+				n := count[ln]
+				if n >= want || c < laneMin[ln] || c > laneMax[ln] {
+					continue
+				}
+				l, ok := lanes[ln]
+				if !ok {
+					l = &LaneImaging{LaneNum: ln, Metric: name, Expected: want, FirstIncompleteCycle: c, MinTiles: n, MinTilesCycle: c}
+					lanes[ln] = l
+				}
+				l.IncompleteCycles++
+				if n < l.MinTiles {
+					l.MinTiles, l.MinTilesCycle = n, c
+				}
+			}
+		}
+		for _, k := range seen[name].sortedKeys() {
+			if last, ok := lastSeen[k]; ok && last < laneMax[k.LaneNum] {
+				ret.Dropouts = append(ret.Dropouts, &TileDropout{LaneNum: k.LaneNum, TileNum: k.TileNum, Metric: name, LastCycle: last, MaxCycle: laneMax[k.LaneNum]})
+			}
+		}
+		laneNums := []uint16{}
+		for ln := range lanes {
+			laneNums = append(laneNums, ln)
+		}
+		sortUint16s(laneNums)
+		for _, ln := range laneNums {
+			ret.IncompleteLanes = append(ret.IncompleteLanes, lanes[ln])
+		}
+	}
+	return ret
+}
+
+type tileSet map[tileKey]bool
+
+//sortedKeys by lane then tile
+func (self tileSet) sortedKeys() []tileKey {
+	ret := make([]tileKey, 0, len(self))
+	for k := range self {
+		ret = append(ret, k)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].LaneNum != ret[j].LaneNum {
+			return ret[i].LaneNum < ret[j].LaneNum
+		}
+		return ret[i].TileNum < ret[j].TileNum
+	})
+	return ret
+}
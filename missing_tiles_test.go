@@ -0,0 +1,57 @@
+package interop
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ws6/interop/fcinfo"
+)
+
+func TestTileCoverage(t *testing.T) {
+	runInfo, err := fcinfo.ParseRunInfoXML(`<RunInfo><Run Id="r" Number="1"><Reads><Read Number="1" NumCycles="10" IsIndexedRead="N" /></Reads>
+		<FlowcellLayout LaneCount="2" SurfaceCount="1" SwathCount="1" TileCount="3" /></Run></RunInfo>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := &Run{RunInfo: runInfo, Tile: &TileInfo{}, Error: &ErrorInfo{}}
+	for ln := uint16(1); ln <= 2; ln++ {
+		for tile := uint16(1101); tile <= 1103; tile++ {
+			run.Tile.Metrics = append(run.Tile.Metrics, &TileMetrics{LaneNum: ln, TileNum: tile, MetricCode: CLUSTER_DENSITY})
+		}
+	}
+	for c := uint16(1); c <= 10; c++ {
+		for _, tile := range []uint16{1101, 1102, 1103} {
+			//1_1103 drops out after cycle 6
+			if tile == 1103 && c > 6 {
+				continue
+			}
+			run.Error.Metrics = append(run.Error.Metrics, &ErrorMetrics{LaneNum: 1, TileNum: tile, Cycle: c})
+		}
+		//lane 2 never reports 1103 and skips cycle 4
+		for _, tile := range []uint16{1101, 1102} {
+			if c != 4 {
+				run.Error.Metrics = append(run.Error.Metrics, &ErrorMetrics{LaneNum: 2, TileNum: tile, Cycle: c})
+			}
+		}
+	}
+
+	cov := run.TileCoverage()
+	if cov.Source != TILE_SOURCE_LAYOUT || cov.Expected != 6 {
+		t.Fatalf("source %s expected %d", cov.Source, cov.Expected)
+	}
+	if len(cov.Missing) != 1 || !reflect.DeepEqual(*cov.Missing[0], MissingTile{2, 1103, []string{METRIC_ERROR}}) {
+		t.Fatalf("missing %+v", cov.Missing)
+	}
+	if len(cov.Dropouts) != 1 || *cov.Dropouts[0] != (TileDropout{1, 1103, METRIC_ERROR, 6, 10}) {
+		t.Fatalf("dropouts %+v", cov.Dropouts)
+	}
+	want := []LaneImaging{{1, METRIC_ERROR, 3, 4, 7, 2, 7}, {2, METRIC_ERROR, 3, 10, 1, 0, 4}}
+	if len(cov.IncompleteLanes) != len(want) {
+		t.Fatalf("%d incomplete lanes", len(cov.IncompleteLanes))
+	}
+	for i, l := range cov.IncompleteLanes {
+		if *l != want[i] {
+			t.Fatalf("lane %+v, expect %+v", l, want[i])
+		}
+	}
+}
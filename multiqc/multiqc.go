@@ -0,0 +1,241 @@
+package multiqc
+
+//multiqc.go MultiQC custom content sections, written as *_mqc.json or *_mqc.tsv so MultiQC picks them up with no module.
+//Section ids are fixed, so files of several runs found by one MultiQC call merge into one table; row names carry the run.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	PLOT_TABLE    = "table"
+	PLOT_BARGRAPH = "bargraph"
+
+	FILE_SUFFIX_JSON = "_mqc.json"
+	FILE_SUFFIX_TSV  = "_mqc.tsv"
+)
+
+//Header column config of a table; Placement keeps the column order, JSON objects have none
+type Header struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Suffix      string   `json:"suffix,omitempty"`
+	Format      string   `json:"format,omitempty"`
+	Min         *float64 `json:"min,omitempty"`
+	Max         *float64 `json:"max,omitempty"`
+	Scale       string   `json:"scale,omitempty"`
+	Placement   int      `json:"placement"`
+}
+
+type Category struct {
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+}
+
+type Section struct {
+	Id          string                            `json:"id"`
+	SectionName string                            `json:"section_name"`
+	Description string                            `json:"description"`
+	PlotType    string                            `json:"plot_type"`
+	Pconfig     map[string]interface{}            `json:"pconfig"`
+	Headers     map[string]*Header                `json:"headers,omitempty"`
+	Categories  map[string]*Category              `json:"categories,omitempty"`
+	Data        map[string]map[string]interface{} `json:"data"`
+
+	columns []string //header or category keys in output order
+	rows    []string //data keys in insertion order
+}
+
+func newSection(id, name, description, plotType string) *Section {
+	return &Section{
+		Id:          id,
+		SectionName: name,
+		Description: description,
+		PlotType:    plotType,
+		Pconfig:     map[string]interface{}{"id": id + "_plot", "title": "InterOp: " + name},
+		Data:        make(map[string]map[string]interface{}),
+	}
+}
+
+func bound(v float64) *float64 {
+	return &v
+}
+
+//Column add a table column; columns show in the order they are added
+func (self *Section) Column(key string, h *Header) {
+	if self.Headers == nil {
+		self.Headers = make(map[string]*Header)
+	}
+	if _, ok := self.Headers[key]; !ok {
+		self.columns = append(self.columns, key)
+	}
+	h.Placement = 1000 + 10*len(self.columns)
+	self.Headers[key] = h
+}
+
+//Category add a bar graph category; bars stack in the order they are added
+func (self *Section) Category(key, name, color string) {
+	if self.Categories == nil {
+		self.Categories = make(map[string]*Category)
+	}
+	if _, ok := self.Categories[key]; !ok {
+		self.columns = append(self.columns, key)
+	}
+	self.Categories[key] = &Category{Name: name, Color: color}
+}
+
+func (self *Section) Set(row, key string, value interface{}) {
+	if _, ok := self.Data[row]; !ok {
+		self.Data[row] = make(map[string]interface{})
+		self.rows = append(self.rows, row)
+	}
+	self.Data[row][key] = value
+}
+
+//Rows data keys in insertion order, or sorted when the section was decoded
+func (self *Section) Rows() []string {
+	if len(self.rows) == len(self.Data) {
+		return self.rows
+	}
+	ret := []string{}
+	for k := range self.Data {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (self *Section) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(self, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+//yamlValue scalar as YAML; strings are single quoted
+func yamlValue(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return "'" + strings.Replace(x, "'", "''", -1) + "'"
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	case *float64:
+		return strconv.FormatFloat(*x, 'g', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+//WriteTSV section config as the commented YAML header MultiQC reads, then one tab separated row per data key
+func (self *Section) WriteTSV(w io.Writer) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# id: %s\n", yamlValue(self.Id))
+	fmt.Fprintf(&b, "# section_name: %s\n", yamlValue(self.SectionName))
+	fmt.Fprintf(&b, "# description: %s\n", yamlValue(self.Description))
+	fmt.Fprintf(&b, "# plot_type: %s\n", yamlValue(self.PlotType))
+	b.WriteString("# pconfig:\n")
+	keys := []string{}
+	for k := range self.Pconfig {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "#     %s: %s\n", k, yamlValue(self.Pconfig[k]))
+	}
+	if len(self.Headers) > 0 {
+		b.WriteString("# headers:\n")
+		for _, key := range self.columns {
+			h := self.Headers[key]
+			fmt.Fprintf(&b, "#     %s:\n", key)
+			fmt.Fprintf(&b, "#         title: %s\n", yamlValue(h.Title))
+			for _, kv := range []struct {
+				name  string
+				value interface{}
+				set   bool
+			}{
+				{"description", h.Description, h.Description != ""},
+				{"suffix", h.Suffix, h.Suffix != ""},
+				{"format", h.Format, h.Format != ""},
+				{"min", h.Min, h.Min != nil},
+				{"max", h.Max, h.Max != nil},
+				{"scale", h.Scale, h.Scale != ""},
+			} {
+				if kv.set {
+					fmt.Fprintf(&b, "#         %s: %s\n", kv.name, yamlValue(kv.value))
+				}
+			}
+			fmt.Fprintf(&b, "#         placement: %d\n", h.Placement)
+		}
+	}
+	if len(self.Categories) > 0 {
+		b.WriteString("# categories:\n")
+		for _, key := range self.columns {
+			c := self.Categories[key]
+			fmt.Fprintf(&b, "#     %s:\n#         name: %s\n", key, yamlValue(c.Name))
+			if c.Color != "" {
+				fmt.Fprintf(&b, "#         color: %s\n", yamlValue(c.Color))
+			}
+		}
+	}
+	b.WriteString("Sample\t" + strings.Join(self.columns, "\t") + "\n")
+	for _, row := range self.Rows() {
+		rec := []string{row}
+		for _, key := range self.columns {
+			v, ok := self.Data[row][key]
+			switch {
+			case !ok:
+				rec = append(rec, "")
+			case isString(v):
+				rec = append(rec, v.(string))
+			default:
+				rec = append(rec, yamlValue(v))
+			}
+		}
+		b.WriteString(strings.Join(rec, "\t") + "\n")
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func isString(v interface{}) bool {
+	_, ok := v.(string)
+	return ok
+}
+
+//WriteFiles one <prefix>_<section id>_mqc.json, or .tsv, per section under dir; returns the file names
+func WriteFiles(dir, prefix string, sections []*Section, tsv bool) ([]string, error) {
+	ret := []string{}
+	for _, s := range sections {
+		var b bytes.Buffer
+		suffix := FILE_SUFFIX_JSON
+		err := error(nil)
+		if tsv {
+			suffix = FILE_SUFFIX_TSV
+			err = s.WriteTSV(&b)
+		} else {
+			err = s.WriteJSON(&b)
+		}
+		if err != nil {
+			return ret, err
+		}
+		name := s.Id + suffix
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+		filename := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(filename, b.Bytes(), 0644); err != nil {
+			return ret, err
+		}
+		ret = append(ret, filename)
+	}
+	return ret, nil
+}
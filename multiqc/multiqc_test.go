@@ -0,0 +1,135 @@
+package multiqc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ws6/interop"
+)
+
+const testRunInfo = `<?xml version="1.0"?>
+<RunInfo>
+  <Run Id="131220_SN1_0001_AH7TESTXX" Number="1">
+    <Flowcell>H7TESTXX</Flowcell>
+    <Instrument>SN1</Instrument>
+    <Date>131220</Date>
+    <Reads>
+      <Read Number="1" NumCycles="60" IsIndexedRead="N" />
+      <Read Number="2" NumCycles="6" IsIndexedRead="Y" />
+      <Read Number="3" NumCycles="60" IsIndexedRead="N" />
+    </Reads>
+    <FlowcellLayout LaneCount="8" SurfaceCount="2" SwathCount="3" TileCount="16" />
+  </Run>
+</RunInfo>`
+
+//makeRunFolder run folder of test_data's InterOp files and a RunInfo.xml
+func makeRunFolder(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "interop-multiqc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "InterOp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "RunInfo.xml"), []byte(testRunInfo), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join("..", "test_data", "InterOp")
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		b, err := ioutil.ReadFile(filepath.Join(src, f.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "InterOp", f.Name()), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRunSections(t *testing.T) {
+	dir := makeRunFolder(t)
+	defer os.RemoveAll(dir)
+	run, err := interop.LoadRun(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sections := RunSections(run)
+	ids := []string{}
+	for _, s := range sections {
+		ids = append(ids, s.Id)
+	}
+	if strings.Join(ids, ",") != strings.Join([]string{ID_RUN_SUMMARY, ID_LANE_METRICS, ID_INDEX_LANES, ID_INDEX_SAMPLES}, ",") {
+		t.Fatalf("sections %v", ids)
+	}
+	if _, ok := sections[0].Data["131220_SN1_0001_AH7TESTXX Total"]; !ok {
+		t.Fatal("run summary has no total row")
+	}
+	if _, ok := sections[1].Data["131220_SN1_0001_AH7TESTXX L1 R1"]; !ok {
+		t.Fatal("lane metrics have no L1 R1 row")
+	}
+	if len(sections[3].Categories) == 0 || len(sections[3].Data) == 0 {
+		t.Fatal("index representation is empty")
+	}
+
+	out, err := ioutil.TempDir("", "interop-multiqc-out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(out)
+	files, err := WriteFiles(out, run.Name(), sections, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if !strings.HasSuffix(f, FILE_SUFFIX_JSON) {
+			t.Fatalf("%s is not picked up by MultiQC", f)
+		}
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := map[string]interface{}{}
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatalf("%s err:%s", f, err.Error())
+		}
+		for _, k := range []string{"id", "section_name", "plot_type", "pconfig", "data"} {
+			if _, ok := m[k]; !ok {
+				t.Fatalf("%s has no %s", f, k)
+			}
+		}
+	}
+}
+
+func TestGTCSectionTSV(t *testing.T) {
+	infos := []*interop.GTCInfo{
+		{SampleName: "NA12878", SentrixID: "200000001_R01C01", CallRate: 0.9925, LogRDev: 0.12, EstimatedGender: "F", NumSNPs: 700000},
+		{SentrixID: "200000001_R02C01", CallRate: 0.95, LogRDev: 0.3, EstimatedGender: "M", NumSNPs: 700000},
+	}
+	s := GTCSection([]string{"a/NA12878.gtc", "a/200000001_R02C01.gtc"}, infos)
+	var b strings.Builder
+	if err := s.WriteTSV(&b); err != nil {
+		t.Fatal(err)
+	}
+	text := b.String()
+	for _, want := range []string{
+		"# id: 'interop_gtc_sample_qc'\n",
+		"# plot_type: 'table'\n",
+		"Sample\tcall_rate\tlogr_dev\tgender\tsentrix_id\tnum_snps\n",
+		"NA12878\t99.25",
+		"200000001_R02C01\t95\t",
+		"\tMale\t",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("missing %q in\n%s", want, text)
+		}
+	}
+}
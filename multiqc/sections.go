@@ -0,0 +1,201 @@
+package multiqc
+
+//sections.go run, lane, index and GTC sample QC sections
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ws6/interop"
+)
+
+var (
+	ID_RUN_SUMMARY   = "interop_run_summary"
+	ID_LANE_METRICS  = "interop_lane_metrics"
+	ID_INDEX_LANES   = "interop_index_lanes"
+	ID_INDEX_SAMPLES = "interop_index_representation"
+	ID_GTC_SAMPLES   = "interop_gtc_sample_qc"
+
+	//PALETTE lane colors of the index bar graph, same order as the plot package
+	PALETTE = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+)
+
+func pct(title, description string) *Header {
+	return &Header{Title: title, Description: description, Suffix: "%", Format: "{:,.2f}", Min: bound(0), Max: bound(100), Scale: "RdYlGn"}
+}
+
+func number(title, description, format string) *Header {
+	return &Header{Title: title, Description: description, Format: format, Scale: "Blues"}
+}
+
+//RunSummarySection one row per read plus the non-indexed and total rows, like the top table of SAV's Summary tab
+func RunSummarySection(runName string, summary *interop.RunSummary) *Section {
+	s := newSection(ID_RUN_SUMMARY, "Run Summary", "Yield and quality per read, from the run's InterOp files.", PLOT_TABLE)
+	s.Pconfig["namespace"] = "InterOp"
+	s.Column("yield", number("Yield", "Gb, sum over tiles", "{:,.2f}"))
+	s.Column("pct_q30", pct("% >= Q30", "bases at or above Q30"))
+	s.Column("pct_aligned", pct("% Aligned", "PhiX aligned"))
+	s.Column("error_rate", &Header{Title: "Error Rate", Description: "PhiX error rate", Suffix: "%", Format: "{:,.2f}", Min: bound(0), Scale: "OrRd"})
+	s.Column("intensity_c1", number("Intensity C1", "first cycle intensity", "{:,.0f}"))
+	add := func(row string, t *interop.SummaryTotal) {
+		s.Set(row, "yield", t.Yield)
+		s.Set(row, "pct_q30", t.PctQ30)
+		s.Set(row, "pct_aligned", t.PctAligned)
+		s.Set(row, "error_rate", t.ErrorRate)
+		s.Set(row, "intensity_c1", t.IntensityC1)
+	}
+	for _, r := range summary.Reads {
+		name := fmt.Sprintf("%s Read %d", runName, r.ReadNum)
+		if r.IsIndexedRead {
+			name += " (I)"
+		}
+		add(name, &r.SummaryTotal)
+	}
+	if summary.NonIndexTotal != nil {
+		add(runName+" Non-indexed", summary.NonIndexTotal)
+	}
+	if summary.Total != nil {
+		add(runName+" Total", summary.Total)
+	}
+	return s
+}
+
+//LaneSection one row per lane and read, the per read tables of SAV's Summary tab
+func LaneSection(runName string, summary *interop.RunSummary) *Section {
+	s := newSection(ID_LANE_METRICS, "Lane Metrics", "Per lane and read metrics; density in k/mm2, reads in millions.", PLOT_TABLE)
+	s.Pconfig["namespace"] = "InterOp"
+	s.Column("tiles", number("Tiles", "tiles reported", "{:,.0f}"))
+	s.Column("density", number("Density", "clusters k/mm2", "{:,.0f}"))
+	s.Column("density_pf", number("Density PF", "PF clusters k/mm2", "{:,.0f}"))
+	s.Column("pct_pf", pct("% PF", "clusters passing filter"))
+	s.Column("phasing", number("Phasing", "% per cycle", "{:,.3f}"))
+	s.Column("prephasing", number("Prephasing", "% per cycle", "{:,.3f}"))
+	s.Column("reads", number("Reads", "millions", "{:,.2f}"))
+	s.Column("reads_pf", number("Reads PF", "millions", "{:,.2f}"))
+	s.Column("pct_q30", pct("% >= Q30", "bases at or above Q30"))
+	s.Column("yield", number("Yield", "Gb", "{:,.2f}"))
+	s.Column("pct_aligned", pct("% Aligned", "PhiX aligned"))
+	s.Column("error_rate", &Header{Title: "Error Rate", Description: "PhiX error rate", Suffix: "%", Format: "{:,.2f}", Min: bound(0), Scale: "OrRd"})
+	for _, r := range summary.Reads {
+		for _, l := range r.Lanes {
+			row := fmt.Sprintf("%s L%d R%d", runName, l.LaneNum, r.ReadNum)
+			s.Set(row, "tiles", float64(l.TileCount))
+			s.Set(row, "density", l.Density)
+			s.Set(row, "density_pf", l.DensityPF)
+			s.Set(row, "pct_pf", l.PctPF)
+			s.Set(row, "phasing", l.Phasing)
+			s.Set(row, "prephasing", l.Prephasing)
+			s.Set(row, "reads", l.Clusters/1e6)
+			s.Set(row, "reads_pf", l.ClustersPF/1e6)
+			s.Set(row, "pct_q30", l.PctQ30)
+			s.Set(row, "yield", l.Yield)
+			s.Set(row, "pct_aligned", l.PctAligned)
+			s.Set(row, "error_rate", l.ErrorRate)
+		}
+	}
+	return s
+}
+
+//IndexSections lane level identification table and a bar graph of clusters per sample, stacked by lane.
+//Bar graph rows are plain sample names so they line up with other MultiQC modules.
+func IndexSections(runName string, summary *interop.IndexSummary) []*Section {
+	lanes := newSection(ID_INDEX_LANES, "Index Lanes", "Identified reads per lane and spread of the sample representation.", PLOT_TABLE)
+	lanes.Pconfig["namespace"] = "InterOp"
+	lanes.Column("reads_pf", number("Reads PF", "millions", "{:,.2f}"))
+	lanes.Column("pct_identified", pct("% Identified", "PF reads assigned to a sample"))
+	lanes.Column("cv", number("CV", "coefficient of variation of sample representation", "{:,.4f}"))
+	lanes.Column("min", pct("Min", "lowest sample % of identified"))
+	lanes.Column("max", pct("Max", "highest sample % of identified"))
+
+	samples := newSection(ID_INDEX_SAMPLES, "Index Representation", "PF clusters assigned to each sample, per lane.", PLOT_BARGRAPH)
+	samples.Pconfig["ylab"] = "PF Clusters"
+	samples.Pconfig["cpswitch_counts_label"] = "Clusters"
+	for i, l := range summary.Lanes {
+		row := fmt.Sprintf("%s L%d", runName, l.LaneNum)
+		lanes.Set(row, "reads_pf", l.TotalPFClusters/1e6)
+		lanes.Set(row, "pct_identified", l.PctIdentified)
+		lanes.Set(row, "cv", l.CV)
+		lanes.Set(row, "min", l.Min)
+		lanes.Set(row, "max", l.Max)
+		key := fmt.Sprintf("lane_%d", l.LaneNum)
+		samples.Category(key, fmt.Sprintf("Lane %d", l.LaneNum), PALETTE[i%len(PALETTE)])
+		for _, smp := range l.Samples {
+			name := smp.SampleName
+			if name == "" {
+				name = smp.IndexName
+			}
+			prev, _ := samples.Data[name][key].(float64)
+			samples.Set(name, key, prev+float64(smp.Clusters))
+		}
+	}
+	return []*Section{lanes, samples}
+}
+
+//gender GTC stores M, F or U
+func gender(c string) string {
+	switch c {
+	case "M":
+		return "Male"
+	case "F":
+		return "Female"
+	}
+	return "Unknown"
+}
+
+//f32 float64 of the shortest decimal of v, so 0.12 in a GTC stays 0.12 and not 0.11999999731779099
+func f32(v float32) float64 {
+	ret, _ := strconv.ParseFloat(strconv.FormatFloat(float64(v), 'g', -1, 32), 64)
+	return ret
+}
+
+//GTCSection sample QC of genotyping calls; rows are sample names, or the file name when a GTC has none
+func GTCSection(files []string, infos []*interop.GTCInfo) *Section {
+	s := newSection(ID_GTC_SAMPLES, "Genotyping Sample QC", "Call rate, LogR deviation and estimated gender from GTC files.", PLOT_TABLE)
+	s.Pconfig["namespace"] = "GTC"
+	s.Column("call_rate", &Header{Title: "Call Rate", Description: "called SNPs", Suffix: "%", Format: "{:,.3f}", Min: bound(0), Max: bound(100), Scale: "RdYlGn"})
+	s.Column("logr_dev", &Header{Title: "LogR Dev", Description: "standard deviation of LogR", Format: "{:,.4f}", Min: bound(0), Scale: "OrRd"})
+	s.Column("gender", &Header{Title: "Gender", Description: "estimated from X and Y intensities"})
+	s.Column("sentrix_id", &Header{Title: "Sentrix ID", Description: "BeadChip barcode and position"})
+	s.Column("num_snps", number("SNPs", "loci on the manifest", "{:,.0f}"))
+	for i, info := range infos {
+		row := info.SampleName
+		if row == "" && i < len(files) {
+			row = strings.TrimSuffix(filepath.Base(files[i]), filepath.Ext(files[i]))
+		}
+		s.Set(row, "call_rate", f32(info.CallRate*100))
+		s.Set(row, "logr_dev", f32(info.LogRDev))
+		s.Set(row, "gender", gender(info.EstimatedGender))
+		s.Set(row, "sentrix_id", info.SentrixID)
+		s.Set(row, "num_snps", float64(info.NumSNPs))
+	}
+	return s
+}
+
+//RunSections every section the run has data for
+func RunSections(run *interop.Run) []*Section {
+	name := run.Name()
+	ret := []*Section{}
+	if run.RunInfo != nil && run.Tile != nil {
+		summary := run.Summary()
+		ret = append(ret, RunSummarySection(name, summary), LaneSection(name, summary))
+	}
+	if run.Index != nil {
+		ret = append(ret, IndexSections(name, run.IndexSummary())...)
+	}
+	return ret
+}
+
+//ReadGTCFiles GTC infos of files, in order; the first unreadable file fails the lot
+func ReadGTCFiles(files []string) ([]*interop.GTCInfo, error) {
+	ret := []*interop.GTCInfo{}
+	for _, f := range files {
+		info, err := interop.ReadGTCInfo(f)
+		if err != nil {
+			return nil, fmt.Errorf("read gtc %s err:%s", f, err.Error())
+		}
+		ret = append(ret, info)
+	}
+	return ret, nil
+}
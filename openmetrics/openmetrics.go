@@ -0,0 +1,367 @@
+package openmetrics
+
+//openmetrics.go gauges of the runs in progress under a root, for Prometheus scrapes or node-exporter's textfile collector
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ws6/interop"
+	"github.com/ws6/interop/fcinfo"
+)
+
+var (
+	PREFIX = "interop_"
+
+	//COMPLETE_MARKERS a run folder holding any of these is done and no longer exported, unless IncludeComplete
+	COMPLETE_MARKERS = []string{"RTAComplete.txt", "CopyComplete.txt"}
+
+	CONTENT_TYPE_OPENMETRICS = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	CONTENT_TYPE_TEXT        = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+type Sample struct {
+	Labels [][2]string //name, value in output order
+	Value  float64
+}
+
+type Family struct {
+	Name    string //without PREFIX
+	Help    string
+	Samples []*Sample
+}
+
+//Collector reads every active run folder directly under Root at each Collect
+type Collector struct {
+	Root            string
+	IncludeComplete bool
+	runs            *interop.RunCache
+	mu              sync.Mutex
+	computed        map[*interop.Run]map[string][]*Sample //gauges of each cached run, from the last Collect
+}
+
+func NewCollector(root string) *Collector {
+	return &Collector{Root: root, runs: interop.NewRunCache(), computed: make(map[*interop.Run]map[string][]*Sample)}
+}
+
+//ActiveRunFolders folders under Root with RunInfo.xml and InterOp/, and no completion marker
+func (self *Collector) ActiveRunFolders() ([]string, error) {
+	files, err := ioutil.ReadDir(self.Root)
+	if err != nil {
+		return nil, err
+	}
+	ret := []string{}
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+		dir := filepath.Join(self.Root, f.Name())
+		if !exists(filepath.Join(dir, "RunInfo.xml")) || !exists(filepath.Join(dir, interop.INTEROP_DIR)) {
+			continue
+		}
+		if !self.IncludeComplete && isComplete(dir) {
+			continue
+		}
+		ret = append(ret, dir)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+func exists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
+
+func isComplete(dir string) bool {
+	for _, m := range COMPLETE_MARKERS {
+		if exists(filepath.Join(dir, m)) {
+			return true
+		}
+	}
+	return false
+}
+
+//runLabels instrument, run_id and flowcell from the flowcell info, falling back on RunInfo.xml
+func runLabels(run *interop.Run) [][2]string {
+	instrument, runId, flowcell := "", run.Name(), ""
+	if run.RunInfo != nil {
+		instrument, flowcell = run.RunInfo.Run.Instrument, run.RunInfo.Run.FlowcellBarcode
+	}
+	if fc := run.Flowcell; fc != nil {
+		if fc.MachineName != "" {
+			instrument = fc.MachineName
+		}
+		if fc.RunId != "" {
+			runId = fc.RunId
+		}
+		if fc.FlowcellBarcode != "" {
+			flowcell = fc.FlowcellBarcode
+		}
+	}
+	return [][2]string{{"instrument", instrument}, {"run_id", runId}, {"flowcell", flowcell}}
+}
+
+func withLane(labels [][2]string, laneNum uint16) [][2]string {
+	return append(append([][2]string{}, labels...), [2]string{"lane", strconv.Itoa(int(laneNum))})
+}
+
+//nonIndexCycles cycles of every read that is not an index read; nil when RunInfo has no reads
+func nonIndexCycles(run *interop.Run) fcinfo.CycleSet {
+	if run.RunInfo == nil || len(run.RunInfo.Run.Reads) == 0 {
+		return nil
+	}
+	return run.RunInfo.GetCyclesMapByType(false)
+}
+
+type laneValues map[uint16]float64
+
+//runFamilies gauges of one run keyed by family name
+func runFamilies(run *interop.Run) map[string][]*Sample {
+	ret := map[string][]*Sample{}
+	labels := runLabels(run)
+	add := func(name string, labels [][2]string, v float64) {
+		ret[name] = append(ret[name], &Sample{Labels: labels, Value: v})
+	}
+	addLanes := func(name string, values laneValues) {
+		lanes := []int{}
+		for ln := range values {
+			lanes = append(lanes, int(ln))
+		}
+		sort.Ints(lanes)
+		for _, ln := range lanes {
+			add(name, withLane(labels, uint16(ln)), values[uint16(ln)])
+		}
+	}
+
+	add("run_current_cycle", labels, float64(run.CurrentCycle()))
+	if run.RunInfo != nil {
+		planned := 0
+		for i := range run.RunInfo.Run.Reads {
+			span := run.RunInfo.GetFirstLastCyclesByRead(i + 1)
+			planned += int(span[1]) - int(span[0]) + 1
+		}
+		add("run_planned_cycles", labels, float64(planned))
+	}
+	parseErrors := 0
+	for name := range run.ParseErrors {
+		if name != "Flowcell" {
+			parseErrors++
+		}
+	}
+	add("run_parse_errors", labels, float64(parseErrors))
+
+	cycles := nonIndexCycles(run)
+	if run.Q != nil {
+		above, total := map[uint16]uint64{}, map[uint16]uint64{}
+		run.Q.EachRecord(func(laneNum uint16, tileNum uint32, cycle uint16, numClusters *[50]uint32) {
+			if laneNum == 0 || !cycles.Admits(cycle) {
+				return
+			}
+			a, t := interop.QscoreAbove(numClusters, interop.Q30)
+			above[laneNum] += a
+			total[laneNum] += t
+		})
+		values := laneValues{}
+		for ln, t := range total {
+			if t > 0 {
+				values[ln] = 100. * float64(above[ln]) / float64(t)
+			}
+		}
+		addLanes("lane_pct_q30", values)
+	}
+	if run.Error != nil {
+		sum, n := laneValues{}, laneValues{}
+		run.Error.EachRecord(func(laneNum uint16, tileNum uint32, cycle uint16, errorRate float32) {
+			if laneNum == 0 || !cycles.Admits(cycle) {
+				return
+			}
+			sum[laneNum] += float64(errorRate)
+			n[laneNum]++
+		})
+		for ln := range sum {
+			sum[ln] /= n[ln]
+		}
+		addLanes("lane_error_rate", sum)
+
+		bubbles := laneValues{}
+		counted := run.Error.BubbleCounter(nil)
+		for _, lr := range counted.Lanes {
+			bubbles[lr.LaneNum] = 0
+			for _, swaths := range lr.Surfaces {
+				for _, tiles := range swaths {
+					for _, t := range tiles {
+						//BubbleCounter leaves the bubble count of a tile in MeanErrorRate
+						if t.TileNum != 0 && t.MeanErrorRate > 0 {
+							bubbles[lr.LaneNum]++
+						}
+					}
+				}
+			}
+		}
+		addLanes("lane_bubble_tiles", bubbles)
+	}
+	if run.Tile != nil {
+		density := laneValues{}
+		for _, rs := range run.Summary().Reads {
+			for _, ls := range rs.Lanes {
+				if _, ok := density[ls.LaneNum]; !ok && len(ls.TileValues[interop.SUMMARY_DENSITY_PF]) > 0 {
+					density[ls.LaneNum] = ls.DensityPF
+				}
+			}
+		}
+		addLanes("lane_density_pf_k_per_mm2", density)
+	}
+	return ret
+}
+
+//FAMILIES output order and help text
+var FAMILIES = []*Family{
+	{Name: "run_current_cycle", Help: "Highest cycle with metrics so far."},
+	{Name: "run_planned_cycles", Help: "Cycles of every read in RunInfo.xml."},
+	{Name: "run_parse_errors", Help: "InterOp files present that failed to parse."},
+	{Name: "lane_pct_q30", Help: "Percent of non index base calls at or above Q30 so far."},
+	{Name: "lane_error_rate", Help: "Mean PhiX error rate of non index cycles so far, percent."},
+	{Name: "lane_density_pf_k_per_mm2", Help: "Mean PF cluster density over tiles, thousands per mm2."},
+	{Name: "lane_bubble_tiles", Help: "Tiles with at least one error rate spike, a sign of bubbles."},
+}
+
+//Collect gauges of every active run; a run that fails to load is skipped and counted in interop_scrape_errors
+func (self *Collector) Collect() ([]*Family, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	dirs, err := self.ActiveRunFolders()
+	if err != nil {
+		return nil, err
+	}
+	previous := self.computed
+	self.computed = make(map[*interop.Run]map[string][]*Sample)
+	samples := map[string][]*Sample{}
+	failed := 0
+	keep := map[string]bool{}
+	for _, dir := range dirs {
+		if abs, err := filepath.Abs(dir); err == nil {
+			keep[abs] = true
+		}
+		run, err := self.runs.Load(dir)
+		if err != nil {
+			failed++
+			continue
+		}
+		families, ok := previous[run]
+		if !ok {
+			families = runFamilies(run)
+		}
+		self.computed[run] = families
+		for name, s := range families {
+			samples[name] = append(samples[name], s...)
+		}
+	}
+	self.runs.Forget(keep)
+
+	ret := []*Family{}
+	for _, f := range FAMILIES {
+		ret = append(ret, &Family{Name: f.Name, Help: f.Help, Samples: samples[f.Name]})
+	}
+	ret = append(ret,
+		&Family{Name: "active_runs", Help: "Run folders exported.", Samples: []*Sample{{Value: float64(len(dirs))}}},
+		&Family{Name: "scrape_errors", Help: "Run folders that could not be loaded.", Samples: []*Sample{{Value: float64(failed)}}},
+	)
+	return ret, nil
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+//Write gauges in text exposition format; openMetrics adds the # EOF terminator OpenMetrics requires
+func Write(w io.Writer, families []*Family, openMetrics bool) error {
+	var b bytes.Buffer
+	for _, f := range families {
+		name := PREFIX + f.Name
+		fmt.Fprintf(&b, "# HELP %s %s\n", name, f.Help)
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", name)
+		for _, s := range f.Samples {
+			b.WriteString(name)
+			if len(s.Labels) > 0 {
+				b.WriteByte('{')
+				for i, l := range s.Labels {
+					if i > 0 {
+						b.WriteByte(',')
+					}
+					fmt.Fprintf(&b, `%s="%s"`, l[0], labelEscaper.Replace(l[1]))
+				}
+				b.WriteByte('}')
+			}
+			b.WriteString(" " + formatValue(s.Value) + "\n")
+		}
+	}
+	if openMetrics {
+		b.WriteString("# EOF\n")
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+//ServeHTTP OpenMetrics when the scraper asks for it, Prometheus text format otherwise
+func (self *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	families, err := self.Collect()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	if openMetrics {
+		w.Header().Set("Content-Type", CONTENT_TYPE_OPENMETRICS)
+	} else {
+		w.Header().Set("Content-Type", CONTENT_TYPE_TEXT)
+	}
+	Write(w, families, openMetrics)
+}
+
+//WriteTextfile collect and write filename for node-exporter's textfile collector.
+//The file is written next to its final name and renamed, so the collector never reads half a file.
+func (self *Collector) WriteTextfile(filename string) error {
+	families, err := self.Collect()
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename))
+	if err != nil {
+		return err
+	}
+	if err := Write(tmp, families, false); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
@@ -0,0 +1,134 @@
+package openmetrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ws6/interop"
+	"github.com/ws6/interop/fcinfo"
+)
+
+const testRunInfo = `<?xml version="1.0"?>
+<RunInfo>
+  <Run Id="131220_SN1_0001_AH7TESTXX" Number="1">
+    <Flowcell>H7TESTXX</Flowcell>
+    <Instrument>SN1</Instrument>
+    <Date>131220</Date>
+    <Reads>
+      <Read Number="1" NumCycles="60" IsIndexedRead="N" />
+      <Read Number="2" NumCycles="6" IsIndexedRead="Y" />
+      <Read Number="3" NumCycles="60" IsIndexedRead="N" />
+    </Reads>
+    <FlowcellLayout LaneCount="8" SurfaceCount="2" SwathCount="3" TileCount="16" />
+  </Run>
+</RunInfo>`
+
+func makeRunFolder(t *testing.T, dir string) {
+	if err := os.MkdirAll(filepath.Join(dir, "InterOp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "RunInfo.xml"), []byte(testRunInfo), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join("..", "test_data", "InterOp")
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		b, err := ioutil.ReadFile(filepath.Join(src, f.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "InterOp", f.Name()), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCollector(t *testing.T) {
+	root, err := ioutil.TempDir("", "interop-openmetrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	makeRunFolder(t, filepath.Join(root, "active"))
+	makeRunFolder(t, filepath.Join(root, "done"))
+	if err := ioutil.WriteFile(filepath.Join(root, "done", "RTAComplete.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCollector(root)
+	dirs, err := c.ActiveRunFolders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 1 || filepath.Base(dirs[0]) != "active" {
+		t.Fatalf("expect only the active run, got %v", dirs)
+	}
+
+	ts := httptest.NewServer(c)
+	defer ts.Close()
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	out := string(body)
+	if resp.Header.Get("Content-Type") != CONTENT_TYPE_OPENMETRICS {
+		t.Errorf("unexpected content type %s", resp.Header.Get("Content-Type"))
+	}
+	labels := `instrument="SN1",run_id="131220_SN1_0001_AH7TESTXX",flowcell="H7TESTXX"`
+	for _, expect := range []string{
+		"# TYPE interop_run_current_cycle gauge\n",
+		"interop_run_planned_cycles{" + labels + "} 126\n",
+		"interop_lane_error_rate{" + labels + `,lane="1"} `,
+		"interop_lane_density_pf_k_per_mm2{" + labels + `,lane="8"} `,
+		"interop_lane_bubble_tiles{" + labels + `,lane="1"} `,
+		"interop_active_runs 1\n",
+		"interop_scrape_errors 0\n",
+	} {
+		if !strings.Contains(out, expect) {
+			t.Errorf("expect %q in\n%s", expect, out)
+		}
+	}
+	if !strings.HasSuffix(out, "# EOF\n") {
+		t.Error("OpenMetrics output must end with # EOF")
+	}
+
+	filename := filepath.Join(root, "interop.prom")
+	if err := c.WriteTextfile(filename); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "# EOF") || !strings.Contains(string(b), "interop_active_runs 1\n") {
+		t.Errorf("unexpected textfile\n%s", b)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(root, ".interop.prom*")); len(matches) != 0 {
+		t.Errorf("temporary files left behind %v", matches)
+	}
+
+	//planned cycles count reads by position, whatever Number RunInfo.xml gives
+	runInfo, err := fcinfo.ParseRunInfoXML(testRunInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range runInfo.Run.Reads {
+		runInfo.Run.Reads[i].Number = 0
+	}
+	planned := runFamilies(&interop.Run{RunInfo: runInfo})["run_planned_cycles"]
+	if len(planned) != 1 || planned[0].Value != 126 {
+		t.Errorf("planned cycles without read numbers %+v", planned)
+	}
+}
@@ -0,0 +1,260 @@
+package interop
+
+//parse_cache.go parsed InterOp files gob encoded in a cache folder, so runs opened again are not parsed again.
+//An entry is keyed by the file's absolute path, size, mtime and PARSE_CACHE_VERSION; a changed file simply misses.
+//Hits touch the entry, and when the folder outgrows MaxBytes the least recently used entries go first.
+//Files whose metrics type has a ParseFast decoder are not cached: decoding them is quicker than decoding gob.
+//LoadRunSummary caches what is made of them instead, the RunSummary, keyed by RunInfo.xml and every InterOp file.
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	//PARSE_CACHE_VERSION bump when a parser or a metrics struct changes, entries of other versions are never read
+	PARSE_CACHE_VERSION = 1
+
+	PARSE_CACHE_SUFFIX = ".gob"
+)
+
+type ParseCache struct {
+	Dir      string
+	MaxBytes int64 //0 no limit
+
+	mu     sync.Mutex
+	hits   int
+	misses int
+}
+
+//NewParseCache creates dir when missing
+func NewParseCache(dir string, maxBytes int64) (*ParseCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create parse cache %s err:%s", dir, err.Error())
+	}
+	return &ParseCache{Dir: dir, MaxBytes: maxBytes}, nil
+}
+
+//LoadRun same as LoadRun, InterOp files come from the cache when unchanged
+func (self *ParseCache) LoadRun(runFolder string) (*Run, error) {
+	return loadRun(runFolder, self)
+}
+
+//LoadRunSummary Summary of the run in runFolder, from the cache while RunInfo.xml and its InterOp files are unchanged,
+//without loading the run at all
+func (self *ParseCache) LoadRunSummary(runFolder string) (*RunSummary, error) {
+	entry := self.summaryEntry(runFolder)
+	ret := new(RunSummary)
+	if self.count(self.getEntry(entry, ret)) {
+		return ret, nil
+	}
+	run, err := self.LoadRun(runFolder)
+	if err != nil {
+		return nil, err
+	}
+	ret = run.Summary()
+	if entry != "" && self.putEntry(entry, ret) == nil {
+		self.Evict()
+	}
+	return ret, nil
+}
+
+//Stats hits and misses since the cache was created
+func (self *ParseCache) Stats() (hits, misses int) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.hits, self.misses
+}
+
+//entry cache file name of filename as it is now; empty when filename can not be stat
+func (self *ParseCache) entry(filename string) string {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return ""
+	}
+	fi, err := os.Stat(abs)
+	if err != nil {
+		return ""
+	}
+	key := fmt.Sprintf("%s\x00%d\x00%d\x00%d", abs, fi.Size(), fi.ModTime().UnixNano(), PARSE_CACHE_VERSION)
+	return self.entryOf(key)
+}
+
+func (self *ParseCache) entryOf(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(self.Dir, hex.EncodeToString(sum[:16])+PARSE_CACHE_SUFFIX)
+}
+
+//summaryEntry cache file name of the summary of runFolder as its files are now; empty when RunInfo.xml can not be stat
+func (self *ParseCache) summaryEntry(runFolder string) string {
+	abs, err := filepath.Abs(runFolder)
+	if err != nil {
+		return ""
+	}
+	names := []string{"RunInfo.xml"}
+	for _, f := range InterOpFiles {
+		names = append(names, filepath.Join(INTEROP_DIR, f.Name))
+	}
+	key := fmt.Sprintf("summary\x00%s\x00%d", abs, PARSE_CACHE_VERSION)
+	for i, name := range names {
+		fi, err := os.Stat(filepath.Join(abs, name))
+		if err != nil {
+			if i == 0 {
+				return ""
+			}
+			continue
+		}
+		key += fmt.Sprintf("\x00%s\x00%d\x00%d", name, fi.Size(), fi.ModTime().UnixNano())
+	}
+	return self.entryOf(key)
+}
+
+//Get decode the cached parse of filename into v, a pointer; false on a miss or an unreadable entry
+func (self *ParseCache) Get(filename string, v interface{}) bool {
+	return self.count(self.getEntry(self.entry(filename), v))
+}
+
+//count a hit or a miss, returns hit
+func (self *ParseCache) count(hit bool) bool {
+	self.mu.Lock()
+	if hit {
+		self.hits++
+	} else {
+		self.misses++
+	}
+	self.mu.Unlock()
+	return hit
+}
+
+func (self *ParseCache) getEntry(entry string, v interface{}) bool {
+	if entry == "" {
+		return false
+	}
+	file, err := os.Open(entry)
+	if err != nil {
+		return false
+	}
+	err = gob.NewDecoder(file).Decode(v)
+	file.Close()
+	if err != nil {
+		os.Remove(entry)
+		return false
+	}
+	now := time.Now()
+	os.Chtimes(entry, now, now)
+	return true
+}
+
+//Put cache v as the parse of filename, then evict down to MaxBytes
+func (self *ParseCache) Put(filename string, v interface{}) error {
+	entry := self.entry(filename)
+	if entry == "" {
+		return fmt.Errorf("parse cache can not stat %s", filename)
+	}
+	if err := self.putEntry(entry, v); err != nil {
+		return fmt.Errorf("encode %s err:%s", filename, err.Error())
+	}
+	return self.Evict()
+}
+
+func (self *ParseCache) putEntry(entry string, v interface{}) error {
+	tmp, err := ioutil.TempFile(self.Dir, ".put-")
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(tmp).Encode(v); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), entry); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return self.Evict()
+}
+
+//Evict remove least recently used entries until the folder holds at most MaxBytes
+func (self *ParseCache) Evict() error {
+	if self.MaxBytes <= 0 {
+		return nil
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	files, err := ioutil.ReadDir(self.Dir)
+	if err != nil {
+		return err
+	}
+	entries := []os.FileInfo{}
+	total := int64(0)
+	for _, fi := range files {
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), PARSE_CACHE_SUFFIX) {
+			continue
+		}
+		entries = append(entries, fi)
+		total += fi.Size()
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().Before(entries[j].ModTime())
+	})
+	for _, fi := range entries {
+		if total <= self.MaxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(self.Dir, fi.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= fi.Size()
+	}
+	return nil
+}
+
+type fastParser interface {
+	ParseFast() error
+}
+
+//cacheable Run field of f, invalid when f is not worth caching
+func cacheable(f *InterOpFile, run *Run) reflect.Value {
+	field := reflect.ValueOf(run).Elem().FieldByName(f.Field)
+	if !field.IsValid() || field.Kind() != reflect.Ptr || field.Type().Implements(reflect.TypeOf((*fastParser)(nil)).Elem()) {
+		return reflect.Value{}
+	}
+	return field
+}
+
+//restore set f's Run field from the cache
+func (self *ParseCache) restore(f *InterOpFile, run *Run, filename string) bool {
+	field := cacheable(f, run)
+	if !field.IsValid() {
+		return false
+	}
+	v := reflect.New(field.Type().Elem())
+	if !self.Get(filename, v.Interface()) {
+		return false
+	}
+	field.Set(v)
+	return true
+}
+
+//store cache f's Run field; a failed write only costs a parse next time
+func (self *ParseCache) store(f *InterOpFile, run *Run, filename string) {
+	field := cacheable(f, run)
+	if !field.IsValid() || field.IsNil() {
+		return
+	}
+	self.Put(filename, field.Interface())
+}
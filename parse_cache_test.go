@@ -0,0 +1,243 @@
+package interop
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCacheLoadRun(t *testing.T) {
+	root, err := ioutil.TempDir("", "interop-parse-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	runFolder := writeTestRun(t, root, "TileMetricsOut.bin", "ErrorMetricsOut.bin", "IndexMetricsOut.bin", "ControlMetricsOut.bin")
+
+	cache, err := NewParseCache(filepath.Join(root, "cache"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := LoadRun(runFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.LoadRun(runFolder); err != nil {
+		t.Fatal(err)
+	}
+	//tile and error metrics have fast decoders and skip the cache
+	if hits, misses := cache.Stats(); hits != 0 || misses != 2 {
+		t.Fatalf("first load hits %d misses %d", hits, misses)
+	}
+	got, err := cache.LoadRun(runFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits, _ := cache.Stats(); hits != 2 {
+		t.Fatalf("second load hits %d", hits)
+	}
+	if !reflect.DeepEqual(want.Tile.Metrics, got.Tile.Metrics) || !reflect.DeepEqual(want.Error.Metrics, got.Error.Metrics) ||
+		!reflect.DeepEqual(want.Index.Metrics, got.Index.Metrics) || !reflect.DeepEqual(want.Control.Metrics, got.Control.Metrics) {
+		t.Fatal("cached metrics differ from parsed ones")
+	}
+	if !reflect.DeepEqual(want.Summary(), got.Summary()) {
+		t.Fatal("summary of cached run differs")
+	}
+
+	//a touched file misses
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(runFolder, INTEROP_DIR, "IndexMetricsOut.bin"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	cache.LoadRun(runFolder)
+	if hits, misses := cache.Stats(); hits != 3 || misses != 3 {
+		t.Fatalf("after touch hits %d misses %d", hits, misses)
+	}
+
+	//eviction keeps the folder under the limit
+	cache.MaxBytes = 1
+	if err := cache.Evict(); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(cache.Dir, "*"+PARSE_CACHE_SUFFIX))
+	if len(files) != 0 {
+		t.Fatalf("%d entries left over the limit", len(files))
+	}
+}
+
+//writeTestRun run folder under root with testRunInfo and the named test_data InterOp files
+func writeTestRun(tb testing.TB, root string, names ...string) string {
+	runFolder := filepath.Join(root, "run")
+	if err := os.MkdirAll(filepath.Join(runFolder, INTEROP_DIR), 0755); err != nil {
+		tb.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(runFolder, "RunInfo.xml"), []byte(testRunInfo), 0644); err != nil {
+		tb.Fatal(err)
+	}
+	for _, name := range names {
+		b, err := ioutil.ReadFile(filepath.Join("test_data", INTEROP_DIR, name))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(runFolder, INTEROP_DIR, name), b, 0644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return runFolder
+}
+
+func TestParseCacheLoadRunSummary(t *testing.T) {
+	root, err := ioutil.TempDir("", "interop-parse-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	runFolder := writeTestRun(t, root, "TileMetricsOut.bin", "ErrorMetricsOut.bin", "ExtractionMetricsOut.bin")
+	cache, err := NewParseCache(filepath.Join(root, "cache"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run, err := LoadRun(runFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := run.Summary()
+	for i := 0; i < 2; i++ {
+		got, err := cache.LoadRunSummary(runFolder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("load %d summary differs", i+1)
+		}
+	}
+	//the second load is one hit, the summary, and parses nothing
+	if hits, misses := cache.Stats(); hits != 1 || misses != 1 {
+		t.Fatalf("hits %d misses %d", hits, misses)
+	}
+
+	//a touched InterOp file misses
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(runFolder, INTEROP_DIR, "TileMetricsOut.bin"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	cache.LoadRunSummary(runFolder)
+	if hits, misses := cache.Stats(); hits != 1 || misses != 2 {
+		t.Fatalf("after touch hits %d misses %d", hits, misses)
+	}
+}
+
+func TestParseCacheEvictLRU(t *testing.T) {
+	root, err := ioutil.TempDir("", "interop-parse-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	cache, err := NewParseCache(filepath.Join(root, "cache"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//a oldest, then b, then c; reading a makes b the least recently used
+	names := []string{"a", "b", "c"}
+	total := int64(0)
+	for i, name := range names {
+		filename := filepath.Join(root, name)
+		if err := ioutil.WriteFile(filename, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := cache.Put(filename, name); err != nil {
+			t.Fatal(err)
+		}
+		entry := cache.entry(filename)
+		old := time.Now().Add(time.Duration(i-len(names)) * time.Hour)
+		if err := os.Chtimes(entry, old, old); err != nil {
+			t.Fatal(err)
+		}
+		fi, err := os.Stat(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += fi.Size()
+	}
+	var v string
+	if !cache.Get(filepath.Join(root, "a"), &v) || v != "a" {
+		t.Fatalf("expect a hit on a, got %q", v)
+	}
+
+	//one byte short of everything: only the least recently used entry goes
+	cache.MaxBytes = total - 1
+	if err := cache.Evict(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		_, err := os.Stat(cache.entry(filepath.Join(root, name)))
+		if kept := err == nil; kept != (name != "b") {
+			t.Fatalf("%s kept %v after eviction", name, kept)
+		}
+	}
+}
+
+//a run of test_data's InterOp files, loaded, and loaded then summarized as the server and the report do
+func BenchmarkLoadRun(b *testing.B) {
+	benchmarkLoadRun(b, func(runFolder string, cache *ParseCache) error {
+		_, err := LoadRun(runFolder)
+		return err
+	})
+}
+
+func BenchmarkParseCacheLoadRun(b *testing.B) {
+	benchmarkLoadRun(b, func(runFolder string, cache *ParseCache) error {
+		_, err := cache.LoadRun(runFolder)
+		return err
+	})
+}
+
+func BenchmarkLoadRunSummary(b *testing.B) {
+	benchmarkLoadRun(b, func(runFolder string, cache *ParseCache) error {
+		run, err := LoadRun(runFolder)
+		if err == nil {
+			run.Summary()
+		}
+		return err
+	})
+}
+
+func BenchmarkParseCacheLoadRunSummary(b *testing.B) {
+	benchmarkLoadRun(b, func(runFolder string, cache *ParseCache) error {
+		_, err := cache.LoadRunSummary(runFolder)
+		return err
+	})
+}
+
+//benchmarkLoadRun every test_data InterOp file in a run folder; the cache is warmed before timing
+func benchmarkLoadRun(b *testing.B, load func(runFolder string, cache *ParseCache) error) {
+	root, err := ioutil.TempDir("", "interop-parse-cache")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	names := []string{}
+	for _, f := range InterOpFiles {
+		if _, err := os.Stat(filepath.Join("test_data", INTEROP_DIR, f.Name)); err == nil {
+			names = append(names, f.Name)
+		}
+	}
+	runFolder := writeTestRun(b, root, names...)
+	cache, err := NewParseCache(filepath.Join(root, "cache"), 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := load(runFolder, cache); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := load(runFolder, cache); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
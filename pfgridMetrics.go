@@ -6,7 +6,8 @@ import (
 	"encoding/binary"
 	//	"fmt"
 	//	"math"
-	"os"
+
+	"github.com/ws6/interop/fcinfo"
 )
 
 type PFSubTileMetrics struct {
@@ -24,13 +25,14 @@ type PFMetricsInfo struct {
 	BinArea  float32
 	Metrics  []*PFSubTileMetrics
 	err      error
+	fsys     fcinfo.RunFS //nil reads Filename from disk
 }
 
 func (self *PFMetricsInfo) Parse() error {
 	if self.err != nil {
 		return self.err
 	}
-	file, err := os.Open(self.Filename)
+	file, err := openMetricFile(self.fsys, self.Filename)
 	if err != nil {
 		self.err = err
 		return self.err
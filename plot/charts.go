@@ -0,0 +1,252 @@
+package plot
+
+//charts.go the standard InterOp views: by cycle lines, Q histogram, flowcell heatmap and box whiskers
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ws6/interop"
+	"github.com/ws6/interop/fcinfo"
+)
+
+type Series struct {
+	Name string
+	X    []float64
+	Y    []float64
+}
+
+type LineChart struct {
+	Title  string
+	XLabel string
+	YLabel string
+	Series []*Series
+	Reads  []*ReadSpan //optional, marks read boundaries when x is cycle
+}
+
+//ReadSpan cycles of one read
+type ReadSpan struct {
+	Label string
+	First int
+	Last  int
+}
+
+//ReadSpans R1, I1, I2, R2 style spans of every read in RunInfo
+func ReadSpans(runInfo *fcinfo.RunInfo) []*ReadSpan {
+	ret := []*ReadSpan{}
+	if runInfo == nil {
+		return ret
+	}
+	index, nonIndex := 0, 0
+	for i, r := range runInfo.Run.Reads {
+		span := runInfo.GetFirstLastCyclesByRead(i + 1)
+		label := ""
+		if r.IsIndexedRead == "Y" {
+			index++
+			label = fmt.Sprintf("I%d", index)
+		} else {
+			nonIndex++
+			label = fmt.Sprintf("R%d", nonIndex)
+		}
+		ret = append(ret, &ReadSpan{Label: label, First: int(span[0]), Last: int(span[1])})
+	}
+	return ret
+}
+
+//readBoundaries dashed line before every read but the first, label centered over each read
+func (self *Frame) readBoundaries(reads []*ReadSpan) {
+	for i, r := range reads {
+		if i > 0 {
+			x := self.X.Map(float64(r.First) - 0.5)
+			self.Line(x, self.Y.From, x, self.Y.To, "#555555", true)
+		}
+		self.Text(self.X.Map(float64(r.First+r.Last)/2), self.Y.To+12, "middle", 10, r.Label)
+	}
+}
+
+//CycleSeriesLines one series per lane
+func CycleSeriesLines(s interop.CycleSeries) []*Series {
+	ret := []*Series{}
+	for _, ln := range s.Lanes() {
+		line := &Series{Name: fmt.Sprintf("Lane %d", ln)}
+		for _, c := range s.Cycles(ln) {
+			line.X = append(line.X, float64(c))
+			line.Y = append(line.Y, s[ln][c])
+		}
+		ret = append(ret, line)
+	}
+	return ret
+}
+
+func (self *LineChart) extent() (xMin, xMax, yMin, yMax float64) {
+	xMin, yMin = math.Inf(1), math.Inf(1)
+	xMax, yMax = math.Inf(-1), math.Inf(-1)
+	for _, s := range self.Series {
+		for i, x := range s.X {
+			if math.IsNaN(s.Y[i]) {
+				continue
+			}
+			xMin, xMax = math.Min(xMin, x), math.Max(xMax, x)
+			yMin, yMax = math.Min(yMin, s.Y[i]), math.Max(yMax, s.Y[i])
+		}
+	}
+	xMin, xMax = Extent(xMin, xMax)
+	yMin, yMax = Extent(yMin, yMax)
+	return
+}
+
+//SVG lines of every series with a legend; y starts at zero unless values go negative
+func (self *LineChart) SVG() []byte {
+	xMin, xMax, yMin, yMax := self.extent()
+	f := NewFrame(WIDTH, HEIGHT, xMin, xMax, math.Min(0, yMin), yMax)
+	f.Axes(self.Title, self.XLabel, self.YLabel, nil)
+	f.readBoundaries(self.Reads)
+	names, colors := []string{}, []string{}
+	for i, s := range self.Series {
+		color := PALETTE[i%len(PALETTE)]
+		xs, ys := make([]float64, len(s.X)), make([]float64, len(s.Y))
+		for k := range s.X {
+			xs[k], ys[k] = f.X.Map(s.X[k]), f.Y.Map(s.Y[k])
+		}
+		f.Polyline(xs, ys, color)
+		names, colors = append(names, s.Name), append(colors, color)
+	}
+	f.Legend(names, colors)
+	return f.Bytes()
+}
+
+//QHistogram bars per Q score, green at or above Q30
+func QHistogram(title string, h *interop.QHistogram) []byte {
+	yMax := 0.
+	for _, b := range h.Bins {
+		yMax = math.Max(yMax, float64(b.Clusters)/1e6)
+	}
+	_, yMax = Extent(0, yMax)
+	f := NewFrame(WIDTH, HEIGHT, 0, 51, 0, yMax)
+	f.Axes(title, "Q Score", "Clusters (M)", []float64{0, 10, 20, 30, 40, 50})
+	width := f.X.Map(1) - f.X.Map(0)
+	for _, b := range h.Bins {
+		color := "#7f7f7f"
+		if b.Q >= interop.Q30 {
+			color = "#2ca02c"
+		}
+		y := f.Y.Map(float64(b.Clusters) / 1e6)
+		f.Rect(f.X.Map(float64(b.Q))-width*0.4, y, width*0.8, f.Y.From-y, color, fmt.Sprintf("Q%d: %d", b.Q, b.Clusters))
+	}
+	q30 := f.X.Map(float64(interop.Q30) - 0.5)
+	f.Line(q30, f.Y.From, q30, f.Y.To, "black", true)
+	f.Legend([]string{"< Q30", fmt.Sprintf(">= Q30 %.2f%%", h.PctQ30)}, []string{"#7f7f7f", "#2ca02c"})
+	return f.Bytes()
+}
+
+//QHeatmap cycles across, Q scores up, colored by % of the cycle's calls
+func QHeatmap(title string, h *interop.QHeatmap, reads []*ReadSpan) []byte {
+	xMin, xMax := 0.5, 1.5
+	if len(h.Cycles) > 0 {
+		xMin, xMax = float64(h.Cycles[0])-0.5, float64(h.Cycles[len(h.Cycles)-1])+0.5
+	}
+	f := NewFrame(WIDTH, HEIGHT, xMin, xMax, 0.5, float64(maxInt(h.MaxQ, 1))+0.5)
+	_, max := Extent(0, h.Max)
+	for i, c := range h.Cycles {
+		x0, x1 := f.X.Map(float64(c)-0.5), f.X.Map(float64(c)+0.5)
+		for qval, v := range h.Values[i] {
+			if v == 0 {
+				continue
+			}
+			y0, y1 := f.Y.Map(float64(qval+1)+0.5), f.Y.Map(float64(qval+1)-0.5)
+			f.Rect(x0, y0, x1-x0, y1-y0, Color(v/max), fmt.Sprintf("cycle %d Q%d: %.2f%%", c, qval+1, v))
+		}
+	}
+	f.Axes(title, "Cycle", "Q Score", nil)
+	f.readBoundaries(reads)
+	f.ColorBar(f.X.To+12, f.Y.To, 12, f.Y.From-f.Y.To, 0, max)
+	f.Text(f.X.To+12, f.Y.To-6, "start", 10, "% calls")
+	return f.Bytes()
+}
+
+var (
+	HEATMAP_CELL = 8. //tile cell size in pixels
+	HEATMAP_GAP  = 12.
+)
+
+//FlowcellHeatmap one panel per lane; columns are surfaces by swaths, rows are tiles within a swath
+func FlowcellHeatmap(title string, h *interop.Heatmap) []byte {
+	columns := maxInt(h.Surfaces, 1) * maxInt(h.Swaths, 1)
+	rows := maxInt(h.TilesPerSwath, 1)
+	panelW := float64(columns) * HEATMAP_CELL
+	panelH := float64(rows) * HEATMAP_CELL
+	width := MARGIN_LEFT + float64(len(h.Lanes))*(panelW+HEATMAP_GAP) + 70
+	height := MARGIN_TOP + 20 + panelH + 20
+	c := NewCanvas(int(math.Ceil(width)), int(math.Ceil(height)))
+	c.Text(width/2, MARGIN_TOP/2+5, "middle", 14, title)
+	min, max := Extent(h.Min, h.Max)
+	top := MARGIN_TOP + 20
+	for i, l := range h.Lanes {
+		left := MARGIN_LEFT + float64(i)*(panelW+HEATMAP_GAP)
+		c.Text(left+panelW/2, top-6, "middle", 10, fmt.Sprintf("Lane %d", l.LaneNum))
+		for _, t := range l.Tiles {
+			col := int(t.Surface-1)*maxInt(h.Swaths, 1) + int(t.Swath) - 1
+			row := int(t.TileInSwath) - 1
+			if col < 0 || row < 0 {
+				continue
+			}
+			c.Rect(left+float64(col)*HEATMAP_CELL, top+float64(row)*HEATMAP_CELL, HEATMAP_CELL, HEATMAP_CELL,
+				Color((t.Value-min)/(max-min)), fmt.Sprintf("Lane %d tile %d: %.4g", l.LaneNum, t.TileNum, t.Value))
+		}
+		c.StrokeRect(left, top, panelW, panelH, "black")
+	}
+	c.ColorBar(width-60, top, 12, panelH, min, max)
+	return c.Bytes()
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+type Box struct {
+	Label string
+	Stat  *interop.BoxWhiskerStat
+}
+
+type BoxChart struct {
+	Title  string
+	XLabel string
+	YLabel string
+	Boxes  []*Box
+}
+
+//SVG box from Q1 to Q3 with the median, whiskers at Whisker_low and Whisker_high
+func (self *BoxChart) SVG() []byte {
+	yMin, yMax := math.Inf(1), math.Inf(-1)
+	for _, b := range self.Boxes {
+		if b.Stat == nil {
+			continue
+		}
+		yMin, yMax = math.Min(yMin, b.Stat.Whisker_low), math.Max(yMax, b.Stat.Whisker_high)
+	}
+	yMin, yMax = Extent(yMin, yMax)
+	f := NewFrame(WIDTH, HEIGHT, -0.5, float64(len(self.Boxes))-0.5, yMin, yMax)
+	f.Axes(self.Title, self.XLabel, self.YLabel, []float64{})
+	width := (f.X.To - f.X.From) / float64(maxInt(len(self.Boxes), 1))
+	for i, b := range self.Boxes {
+		x := f.X.Map(float64(i))
+		f.Text(x, f.Y.From+16, "middle", 10, b.Label)
+		if b.Stat == nil {
+			continue
+		}
+		s := b.Stat
+		f.Line(x, f.Y.Map(s.Whisker_low), x, f.Y.Map(s.Q1), "black", false)
+		f.Line(x, f.Y.Map(s.Q3), x, f.Y.Map(s.Whisker_high), "black", false)
+		f.Line(x-width*0.2, f.Y.Map(s.Whisker_low), x+width*0.2, f.Y.Map(s.Whisker_low), "black", false)
+		f.Line(x-width*0.2, f.Y.Map(s.Whisker_high), x+width*0.2, f.Y.Map(s.Whisker_high), "black", false)
+		top := f.Y.Map(s.Q3)
+		f.Rect(x-width*0.35, top, width*0.7, f.Y.Map(s.Q1)-top, "#9ecae1",
+			fmt.Sprintf("%s: median %.4g, Q1 %.4g, Q3 %.4g", b.Label, s.Q2, s.Q1, s.Q3))
+		f.StrokeRect(x-width*0.35, top, width*0.7, f.Y.Map(s.Q1)-top, "black")
+		f.Line(x-width*0.35, f.Y.Map(s.Q2), x+width*0.35, f.Y.Map(s.Q2), "#d62728", false)
+	}
+	return f.Bytes()
+}
@@ -0,0 +1,128 @@
+package plot
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/ws6/interop"
+	"github.com/ws6/interop/fcinfo"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files under test_data")
+
+const testRunInfo = `<?xml version="1.0"?>
+<RunInfo>
+  <Run Id="131220_SN1_0001_AH7TESTXX" Number="1">
+    <Reads>
+      <Read Number="1" NumCycles="10" IsIndexedRead="N" />
+      <Read Number="2" NumCycles="4" IsIndexedRead="Y" />
+      <Read Number="3" NumCycles="10" IsIndexedRead="N" />
+    </Reads>
+  </Run>
+</RunInfo>`
+
+//checkGolden compare with test_data/name, or rewrite it with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	//every chart must at least be well formed xml
+	d := xml.NewDecoder(bytes.NewReader(got))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%s is not well formed: %s", name, err.Error())
+		}
+	}
+	filename := filepath.Join("test_data", name)
+	if *update {
+		if err := ioutil.WriteFile(filename, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expect, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(expect, got) {
+		t.Errorf("%s differs from the golden file, run go test -update after checking the new output", name)
+	}
+}
+
+func TestCharts(t *testing.T) {
+	runInfo, err := fcinfo.ParseRunInfoXML(testRunInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reads := ReadSpans(runInfo)
+	if len(reads) != 3 || reads[1].Label != "I1" || reads[2].Label != "R2" || reads[2].First != 15 {
+		t.Fatalf("unexpected read spans %+v %+v %+v", reads[0], reads[1], reads[2])
+	}
+	//spans go by read position, whatever Number RunInfo.xml gives
+	for i := range runInfo.Run.Reads {
+		runInfo.Run.Reads[i].Number = 0
+	}
+	if renumbered := ReadSpans(runInfo); renumbered[2].First != 15 || renumbered[2].Last != reads[2].Last {
+		t.Fatalf("read spans without read numbers %+v", renumbered[2])
+	}
+
+	series := interop.CycleSeries{}
+	for ln := uint16(1); ln <= 2; ln++ {
+		series[ln] = map[uint16]float64{}
+		for c := uint16(1); c <= 24; c++ {
+			series[ln][c] = 95 - float64(c)*0.5 - float64(ln)
+		}
+	}
+	lines := &LineChart{Title: "% >= Q30 by Cycle", XLabel: "Cycle", YLabel: "% >= Q30", Series: CycleSeriesLines(series), Reads: reads}
+	checkGolden(t, "lines.svg", lines.SVG())
+
+	hist := &interop.QHistogram{PctQ30: 87.5, Bins: []*interop.QHistogramBin{
+		{Q: 12, Clusters: 1e6},
+		{Q: 25, Clusters: 2e6},
+		{Q: 32, Clusters: 8e6},
+		{Q: 38, Clusters: 13e6},
+	}}
+	checkGolden(t, "qhist.svg", QHistogram("Q Score Distribution", hist))
+
+	heatmap := &interop.Heatmap{Metric: interop.SUMMARY_DENSITY_PF, Surfaces: 2, Swaths: 2, TilesPerSwath: 3, Min: 100, Max: 223}
+	for ln := uint16(1); ln <= 2; ln++ {
+		l := &interop.HeatmapLane{LaneNum: ln}
+		for surface := uint32(1); surface <= 2; surface++ {
+			for swath := uint32(1); swath <= 2; swath++ {
+				for tile := uint32(1); tile <= 3; tile++ {
+					v := 100 + float64(ln-1)*60 + float64(surface)*10 + float64(swath)*5 + float64(tile)
+					l.Tiles = append(l.Tiles, &interop.HeatmapTile{TileNum: surface*1000 + swath*100 + tile, Surface: surface, Swath: swath, TileInSwath: tile, Value: v})
+				}
+			}
+		}
+		heatmap.Lanes = append(heatmap.Lanes, l)
+	}
+	checkGolden(t, "heatmap.svg", FlowcellHeatmap("Density PF (k/mm2)", heatmap))
+
+	qheatmap := &interop.QHeatmap{MaxQ: 40, Max: 60}
+	for c := uint16(1); c <= 24; c++ {
+		qheatmap.Cycles = append(qheatmap.Cycles, c)
+		row := make([]float64, 40)
+		row[37], row[29], row[19] = 60-float64(c), 30+float64(c)/2, 10+float64(c)/2
+		qheatmap.Values = append(qheatmap.Values, row)
+	}
+	checkGolden(t, "qheatmap.svg", QHeatmap("Q Score Heatmap", qheatmap, reads))
+
+	box := &BoxChart{Title: "Lane 1 % PF by Subtile X Bin", XLabel: "X Bin", YLabel: "% PF"}
+	for bin := 0; bin < 4; bin++ {
+		values := []float64{}
+		for i := 0; i < 20; i++ {
+			values = append(values, 60+float64(bin)*2+float64(i%7))
+		}
+		stat := new(interop.BoxWhiskerStat)
+		stat.GetFloat64(&values)
+		box.Boxes = append(box.Boxes, &Box{Label: string(rune('0' + bin)), Stat: stat})
+	}
+	checkGolden(t, "boxwhisker.svg", box.SVG())
+}
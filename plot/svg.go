@@ -0,0 +1,249 @@
+package plot
+
+//svg.go minimal SVG writer, scales and axes shared by every chart
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+)
+
+var (
+	WIDTH  = 640
+	HEIGHT = 360
+
+	MARGIN_LEFT   = 60.
+	MARGIN_RIGHT  = 110. //room for the legend
+	MARGIN_TOP    = 30.
+	MARGIN_BOTTOM = 45.
+
+	FONT = "sans-serif"
+
+	//PALETTE series colors, lanes take them in order
+	PALETTE = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+)
+
+//Canvas SVG elements in drawing order
+type Canvas struct {
+	Width  int
+	Height int
+	buf    bytes.Buffer
+}
+
+func NewCanvas(width, height int) *Canvas {
+	return &Canvas{Width: width, Height: height}
+}
+
+//num fixed precision keeps output stable across platforms
+func num(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func (self *Canvas) Rect(x, y, w, h float64, fill, title string) {
+	fmt.Fprintf(&self.buf, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"`, num(x), num(y), num(w), num(h), fill)
+	if title == "" {
+		self.buf.WriteString("/>\n")
+		return
+	}
+	fmt.Fprintf(&self.buf, "><title>%s</title></rect>\n", html.EscapeString(title))
+}
+
+func (self *Canvas) StrokeRect(x, y, w, h float64, stroke string) {
+	fmt.Fprintf(&self.buf, `<rect x="%s" y="%s" width="%s" height="%s" fill="none" stroke="%s"/>`+"\n", num(x), num(y), num(w), num(h), stroke)
+}
+
+func (self *Canvas) Line(x1, y1, x2, y2 float64, stroke string, dashed bool) {
+	dash := ""
+	if dashed {
+		dash = ` stroke-dasharray="4,3"`
+	}
+	fmt.Fprintf(&self.buf, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s"%s/>`+"\n", num(x1), num(y1), num(x2), num(y2), stroke, dash)
+}
+
+//Polyline xs and ys are canvas coordinates; NaN points break the line
+func (self *Canvas) Polyline(xs, ys []float64, stroke string) {
+	points := []string{}
+	flush := func() {
+		if len(points) > 0 {
+			self.buf.WriteString(`<polyline fill="none" stroke="` + stroke + `" stroke-width="1.5" points="`)
+			for i, p := range points {
+				if i > 0 {
+					self.buf.WriteByte(' ')
+				}
+				self.buf.WriteString(p)
+			}
+			self.buf.WriteString("\"/>\n")
+		}
+		points = points[:0]
+	}
+	for i := range xs {
+		if math.IsNaN(ys[i]) {
+			flush()
+			continue
+		}
+		points = append(points, num(xs[i])+","+num(ys[i]))
+	}
+	flush()
+}
+
+//Text anchor is start, middle or end
+func (self *Canvas) Text(x, y float64, anchor string, size int, s string) {
+	fmt.Fprintf(&self.buf, `<text x="%s" y="%s" text-anchor="%s" font-size="%d">%s</text>`+"\n", num(x), num(y), anchor, size, html.EscapeString(s))
+}
+
+//VText text rotated to read bottom to top, for y axis labels
+func (self *Canvas) VText(x, y float64, size int, s string) {
+	fmt.Fprintf(&self.buf, `<text x="%s" y="%s" text-anchor="middle" font-size="%d" transform="rotate(-90 %s %s)">%s</text>`+"\n", num(x), num(y), size, num(x), num(y), html.EscapeString(s))
+}
+
+//Bytes the complete svg document
+func (self *Canvas) Bytes() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="%s">`+"\n", self.Width, self.Height, self.Width, self.Height, FONT)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="white"/>`+"\n", self.Width, self.Height)
+	b.Write(self.buf.Bytes())
+	b.WriteString("</svg>\n")
+	return b.Bytes()
+}
+
+//Linear maps the domain Min..Max onto From..To
+type Linear struct {
+	Min  float64
+	Max  float64
+	From float64
+	To   float64
+}
+
+func (self Linear) Map(v float64) float64 {
+	if self.Max == self.Min {
+		return (self.From + self.To) / 2
+	}
+	return self.From + (v-self.Min)/(self.Max-self.Min)*(self.To-self.From)
+}
+
+//Ticks round numbered ticks covering min..max, about n of them
+func Ticks(min, max float64, n int) []float64 {
+	if max <= min || n < 1 {
+		return []float64{min}
+	}
+	raw := (max - min) / float64(n)
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := mag
+	for _, m := range []float64{1, 2, 2.5, 5, 10} {
+		step = m * mag
+		if step >= raw {
+			break
+		}
+	}
+	ret := []float64{}
+	for v := math.Ceil(min/step) * step; v <= max+step*1e-9; v += step {
+		ret = append(ret, math.Round(v/step)*step)
+	}
+	return ret
+}
+
+//Extent pads an empty or flat range so scales never divide by zero
+func Extent(min, max float64) (float64, float64) {
+	if math.IsInf(min, 0) || math.IsInf(max, 0) || math.IsNaN(min) || math.IsNaN(max) {
+		return 0, 1
+	}
+	if min == max {
+		if min == 0 {
+			return 0, 1
+		}
+		return min - math.Abs(min)*0.1, max + math.Abs(max)*0.1
+	}
+	return min, max
+}
+
+func tickLabel(v float64) string {
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
+
+//Frame plot area of a chart with x and y scales
+type Frame struct {
+	*Canvas
+	X Linear
+	Y Linear
+}
+
+//NewFrame canvas with the default margins; y grows upwards
+func NewFrame(width, height int, xMin, xMax, yMin, yMax float64) *Frame {
+	c := NewCanvas(width, height)
+	return &Frame{
+		Canvas: c,
+		X:      Linear{Min: xMin, Max: xMax, From: MARGIN_LEFT, To: float64(width) - MARGIN_RIGHT},
+		Y:      Linear{Min: yMin, Max: yMax, From: float64(height) - MARGIN_BOTTOM, To: MARGIN_TOP},
+	}
+}
+
+//Axes title, axis lines, ticks and labels; xTicks nil picks them from the scale
+func (self *Frame) Axes(title, xLabel, yLabel string, xTicks []float64) {
+	left, right := self.X.From, self.X.To
+	bottom, top := self.Y.From, self.Y.To
+	self.Text(float64(self.Width)/2, MARGIN_TOP/2+5, "middle", 14, title)
+	if xTicks == nil {
+		xTicks = Ticks(self.X.Min, self.X.Max, 8)
+	}
+	for _, v := range xTicks {
+		x := self.X.Map(v)
+		self.Line(x, bottom, x, bottom+4, "black", false)
+		self.Text(x, bottom+16, "middle", 10, tickLabel(v))
+	}
+	for _, v := range Ticks(self.Y.Min, self.Y.Max, 5) {
+		y := self.Y.Map(v)
+		self.Line(left-4, y, left, y, "black", false)
+		self.Line(left, y, right, y, "#e0e0e0", false)
+		self.Text(left-6, y+3, "end", 10, tickLabel(v))
+	}
+	self.Line(left, bottom, right, bottom, "black", false)
+	self.Line(left, bottom, left, top, "black", false)
+	self.Text((left+right)/2, float64(self.Height)-8, "middle", 12, xLabel)
+	self.VText(14, (top+bottom)/2, 12, yLabel)
+}
+
+//Legend one colored swatch per name, right of the plot area
+func (self *Frame) Legend(names, colors []string) {
+	x := self.X.To + 12
+	for i, name := range names {
+		y := self.Y.To + float64(i)*16
+		self.Rect(x, y, 10, 10, colors[i], "")
+		self.Text(x+14, y+9, "start", 10, name)
+	}
+}
+
+//Color of t in 0..1 on a blue, green, yellow, red ramp
+func Color(t float64) string {
+	if math.IsNaN(t) {
+		return "#cccccc"
+	}
+	t = math.Max(0, math.Min(1, t))
+	stops := [][3]float64{{49, 54, 149}, {69, 183, 95}, {254, 224, 80}, {215, 48, 39}}
+	pos := t * float64(len(stops)-1)
+	i := int(pos)
+	if i >= len(stops)-1 {
+		i = len(stops) - 2
+	}
+	f := pos - float64(i)
+	rgb := [3]int{}
+	for k := range rgb {
+		rgb[k] = int(math.Round(stops[i][k] + f*(stops[i+1][k]-stops[i][k])))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+}
+
+//ColorBar vertical color scale from min at the bottom to max at the top
+func (self *Canvas) ColorBar(x, y, w, h, min, max float64) {
+	steps := 20
+	for i := 0; i < steps; i++ {
+		t := (float64(i) + 0.5) / float64(steps)
+		self.Rect(x, y+h-float64(i+1)*h/float64(steps), w, h/float64(steps)+0.5, Color(t), "")
+	}
+	self.StrokeRect(x, y, w, h, "black")
+	scale := Linear{Min: min, Max: max, From: y + h, To: y}
+	for _, v := range Ticks(min, max, 4) {
+		self.Text(x+w+4, scale.Map(v)+3, "start", 10, tickLabel(v))
+	}
+}
@@ -0,0 +1,272 @@
+package interop
+
+//pooling.go library pool balance from index metrics, with relative volumes for the next pooling
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ws6/interop/samplesheetio"
+)
+
+var (
+	POOLING_TARGET_COLUMN = "Target_Fraction" //optional sample sheet column; fraction or percent, normalized per lane; blank takes the mean of the given ones
+	POOLING_TOLERANCE     = 0.2               //observed/expected outside 1 +/- tolerance is over or under represented
+	POOLING_MAX_VOLUME    = 4.0               //volume factors are capped to [1/max, max]; dropouts do not come back with volume alone
+
+	POOLING_OK         = "ok"
+	POOLING_OVER       = "over"
+	POOLING_UNDER      = "under"
+	POOLING_MISSING    = "missing"    //expected but no clusters
+	POOLING_UNEXPECTED = "unexpected" //clusters but not in the targets
+
+	POOLING_CSV_HEADER = []string{
+		"Lane", "Sample_ID", "Index", "Clusters_PF",
+		"Observed_Fraction", "Expected_Fraction", "Ratio", "Status", "Volume_Factor",
+	}
+)
+
+//PoolTarget expected share of a sample; LaneNum 0 applies to every lane
+type PoolTarget struct {
+	LaneNum  uint16
+	SampleId string
+	Fraction float64 //0 for blank: the mean of the lane's given targets, an equal share when none is given
+}
+
+//NewPoolingReader sample sheet reader picking Lane, Sample_ID and the target column
+func NewPoolingReader(targetColumn string) *samplesheetio.Reader {
+	return samplesheetio.NewReader([]*samplesheetio.ColumnDef{
+		{Name: `Sample_ID`, Accepts: []string{`Sample_ID`, `SampleID`}, StopWhenEmtpy: true, ErrorOnMissingFromHeader: true},
+		{Name: `Lane`},
+		{Name: targetColumn},
+	})
+}
+
+//PoolTargetsFromSampleSheet read targets from a sheet read by NewPoolingReader
+func PoolTargetsFromSampleSheet(ss *samplesheetio.SampleSheet, targetColumn string) ([]*PoolTarget, error) {
+	ret := []*PoolTarget{}
+	for i, row := range ss.Data {
+		t := &PoolTarget{SampleId: row.GetCellByName(`Sample_ID`).Value}
+		if c := row.GetCellByName(`Lane`); c != nil && c.Value != "" {
+			ln, err := strconv.ParseUint(c.Value, 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("data row %d bad Lane %s err:%s", i+1, c.Value, err.Error())
+			}
+			t.LaneNum = uint16(ln)
+		}
+		if c := row.GetCellByName(targetColumn); c != nil && c.Value != "" {
+			v, err := strconv.ParseFloat(strings.TrimSuffix(c.Value, "%"), 64)
+			if err != nil || v < 0 {
+				return nil, fmt.Errorf("data row %d bad %s %s", i+1, targetColumn, c.Value)
+			}
+			t.Fraction = v
+		}
+		ret = append(ret, t)
+	}
+	return ret, nil
+}
+
+type PoolingSample struct {
+	LaneNum      uint16
+	SampleId     string
+	IndexName    string
+	Clusters     uint64
+	Observed     float64 //fraction of the clusters of the lane's expected samples; of all identified clusters when unexpected
+	Expected     float64 //normalized target fraction
+	Ratio        float64 //observed / expected
+	Status       string
+	VolumeFactor float64 //multiply the sample's pooling volume by this; 0 when it can not be computed
+}
+
+type PoolingLane struct {
+	LaneNum            uint16
+	IdentifiedClusters uint64
+	ExpectedClusters   uint64  //identified clusters of expected samples, what Observed is a fraction of
+	UnexpectedClusters uint64  //identified clusters of samples not in the targets
+	UnexpectedFraction float64 //of identified clusters
+	CV                 float64 //of observed / expected over expected samples
+	Over               int
+	Under              int
+	Missing            int
+	Samples            []*PoolingSample
+}
+
+type PoolingReport struct {
+	Lanes []*PoolingLane
+}
+
+//expectedFractions targets of one lane; blank targets get the mean of the given ones, then everything sums to 1
+func expectedFractions(laneNum uint16, targets []*PoolTarget) map[string]float64 {
+	ret := map[string]float64{}
+	given, sum := 0, 0.
+	for _, t := range targets {
+		if t.LaneNum != 0 && t.LaneNum != laneNum {
+			continue
+		}
+		ret[t.SampleId] = t.Fraction
+		if t.Fraction > 0 {
+			given++
+			sum += t.Fraction
+		}
+	}
+	fill := 1.
+	if given > 0 {
+		fill = sum / float64(given)
+	}
+	total := 0.
+	for id, v := range ret {
+		if v == 0 {
+			ret[id] = fill
+		}
+		total += ret[id]
+	}
+	for id := range ret {
+		ret[id] /= total
+	}
+	return ret
+}
+
+//AnalyzePooling compare observed sample fractions with targets; nil targets expect an equal share of every observed sample
+func AnalyzePooling(summary *IndexSummary, targets []*PoolTarget) *PoolingReport {
+	ret := new(PoolingReport)
+	for _, ls := range summary.Lanes {
+		laneTargets := targets
+		if laneTargets == nil {
+			for _, s := range ls.Samples {
+				laneTargets = append(laneTargets, &PoolTarget{LaneNum: ls.LaneNum, SampleId: s.SampleName})
+			}
+		}
+		expected := expectedFractions(ls.LaneNum, laneTargets)
+		pl := &PoolingLane{LaneNum: ls.LaneNum, IdentifiedClusters: ls.IdentifiedClusters}
+		seen := map[string]bool{}
+		for _, s := range ls.Samples {
+			ps := &PoolingSample{
+				LaneNum:   ls.LaneNum,
+				SampleId:  s.SampleName,
+				IndexName: s.IndexName,
+				Clusters:  s.Clusters,
+			}
+			seen[s.SampleName] = true
+			pl.Samples = append(pl.Samples, ps)
+			if _, ok := expected[s.SampleName]; ok {
+				pl.ExpectedClusters += s.Clusters
+			} else {
+				pl.UnexpectedClusters += s.Clusters
+			}
+		}
+		if pl.IdentifiedClusters > 0 {
+			pl.UnexpectedFraction = float64(pl.UnexpectedClusters) / float64(pl.IdentifiedClusters)
+		}
+		//unexpected samples would skew the shares of the expected ones, so those are taken over expected samples only
+		for _, ps := range pl.Samples {
+			if _, ok := expected[ps.SampleId]; !ok {
+				if pl.IdentifiedClusters > 0 {
+					ps.Observed = float64(ps.Clusters) / float64(pl.IdentifiedClusters)
+				}
+				continue
+			}
+			if pl.ExpectedClusters > 0 {
+				ps.Observed = float64(ps.Clusters) / float64(pl.ExpectedClusters)
+			}
+		}
+		//a sample with several indexes is judged on the sum of its indexes
+		merged := map[string]float64{}
+		for _, ps := range pl.Samples {
+			merged[ps.SampleId] += ps.Observed
+		}
+		for _, ps := range pl.Samples {
+			if e, ok := expected[ps.SampleId]; ok {
+				ps.Expected = e
+				ps.Ratio = merged[ps.SampleId] / e
+			}
+		}
+		for id, e := range expected {
+			if !seen[id] {
+				pl.Samples = append(pl.Samples, &PoolingSample{LaneNum: ls.LaneNum, SampleId: id, Expected: e})
+			}
+		}
+		sort.SliceStable(pl.Samples, func(i, j int) bool { return pl.Samples[i].SampleId < pl.Samples[j].SampleId })
+
+		ratios := []float64{}
+		counted := map[string]bool{}
+		for _, ps := range pl.Samples {
+			classifyPooling(ps)
+			switch ps.Status {
+			case POOLING_OVER:
+				pl.Over++
+			case POOLING_UNDER:
+				pl.Under++
+			case POOLING_MISSING:
+				pl.Missing++
+			}
+			if ps.Status != POOLING_UNEXPECTED && !counted[ps.SampleId] {
+				counted[ps.SampleId] = true
+				ratios = append(ratios, ps.Ratio)
+			}
+		}
+		if mean, variance := SampleStat(ratios); mean > 0 {
+			pl.CV = math.Sqrt(variance) / mean
+		}
+		ret.Lanes = append(ret.Lanes, pl)
+	}
+	return ret
+}
+
+func classifyPooling(ps *PoolingSample) {
+	switch {
+	case ps.Expected == 0:
+		ps.Status = POOLING_UNEXPECTED
+		return
+	case ps.Ratio == 0:
+		ps.Status = POOLING_MISSING
+		return
+	case ps.Ratio > 1+POOLING_TOLERANCE:
+		ps.Status = POOLING_OVER
+	case ps.Ratio < 1-POOLING_TOLERANCE:
+		ps.Status = POOLING_UNDER
+	default:
+		ps.Status = POOLING_OK
+	}
+	ps.VolumeFactor = math.Max(1/POOLING_MAX_VOLUME, math.Min(POOLING_MAX_VOLUME, 1/ps.Ratio))
+}
+
+func formatPoolingFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 6, 64)
+}
+
+//WriteCSV one row per lane and sample, POOLING_CSV_HEADER columns
+func (self *PoolingReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(POOLING_CSV_HEADER); err != nil {
+		return err
+	}
+	for _, pl := range self.Lanes {
+		for _, ps := range pl.Samples {
+			volume := ""
+			if ps.VolumeFactor > 0 {
+				volume = formatPoolingFloat(ps.VolumeFactor)
+			}
+			row := []string{
+				strconv.Itoa(int(ps.LaneNum)),
+				ps.SampleId,
+				ps.IndexName,
+				strconv.FormatUint(ps.Clusters, 10),
+				formatPoolingFloat(ps.Observed),
+				formatPoolingFloat(ps.Expected),
+				formatPoolingFloat(ps.Ratio),
+				ps.Status,
+				volume,
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
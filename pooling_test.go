@@ -0,0 +1,79 @@
+package interop
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"testing"
+)
+
+const testPoolingSampleSheet = `[Header]
+IEMFileVersion,4
+
+[Data]
+Lane,Sample_ID,index,Target_Fraction
+1,S1,AAAA,50
+1,S2,CCCC,25
+1,S3,GGGG,25
+1,S4,TTTT,
+`
+
+func TestAnalyzePooling(t *testing.T) {
+	ss, err := NewPoolingReader(POOLING_TARGET_COLUMN).Read(testPoolingSampleSheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets, err := PoolTargetsFromSampleSheet(ss, POOLING_TARGET_COLUMN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 4 {
+		t.Fatalf("expect 4 targets, got %d", len(targets))
+	}
+	indexInfo := &IndexInfo{Metrics: []*IndexMetrics{
+		{LaneNum: 1, TileNum: 1101, Read: 2, IndexName: "AAAA", SampleName: "S1", Clusters_PF: 400},
+		{LaneNum: 1, TileNum: 1101, Read: 2, IndexName: "CCCC", SampleName: "S2", Clusters_PF: 100},
+		{LaneNum: 1, TileNum: 1101, Read: 2, IndexName: "GGGG", SampleName: "S3", Clusters_PF: 100},
+		{LaneNum: 1, TileNum: 1101, Read: 2, IndexName: "NNNN", SampleName: "X", Clusters_PF: 200},
+	}}
+	report := AnalyzePooling(BuildIndexSummary(indexInfo, nil), targets)
+	if len(report.Lanes) != 1 {
+		t.Fatalf("expect one lane, got %d", len(report.Lanes))
+	}
+	status := map[string]*PoolingSample{}
+	for _, ps := range report.Lanes[0].Samples {
+		status[ps.SampleId] = ps
+	}
+	//S4 blank target takes the mean of the given ones: 50,25,25,33.3 normalized
+	if math.Abs(status["S1"].Expected-0.375) > 1e-9 {
+		t.Fatalf("S1 expected 0.375, got %f", status["S1"].Expected)
+	}
+	//X is left out of the expected samples' shares: S2 is 100 of 600, not of 800
+	lane := report.Lanes[0]
+	if lane.ExpectedClusters != 600 || lane.UnexpectedClusters != 200 || math.Abs(lane.UnexpectedFraction-0.25) > 1e-9 {
+		t.Fatalf("expect 600 expected and 200 unexpected clusters, got %+v", lane)
+	}
+	if math.Abs(status["S2"].Observed-1./6) > 1e-9 || math.Abs(status["X"].Observed-0.25) > 1e-9 {
+		t.Fatalf("expect S2 1/6 and X 1/4 observed, got %f and %f", status["S2"].Observed, status["X"].Observed)
+	}
+	for id, want := range map[string]string{"S1": POOLING_OVER, "S2": POOLING_OK, "S4": POOLING_MISSING, "X": POOLING_UNEXPECTED} {
+		if status[id].Status != want {
+			t.Fatalf("%s: expect %s, got %s", id, want, status[id].Status)
+		}
+	}
+	if status["S1"].VolumeFactor >= 1 {
+		t.Fatalf("over represented S1 needs less volume, got %f", status["S1"].VolumeFactor)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 6 {
+		t.Fatalf("expect header and 5 rows, got %d", len(rows))
+	}
+}
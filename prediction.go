@@ -0,0 +1,544 @@
+package interop
+
+//prediction.go projects end-of-run %>=Q30 and PF yield from a partially sequenced run
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ws6/interop/fcinfo"
+)
+
+//Q30 Q score cutoff of %>=Q30
+const Q30 = 30
+
+var (
+	PREDICTION_Z_SCORE        = 1.96 //two-sided 95% interval
+	PREDICTION_MIN_FIT_CYCLES = 5    //a read needs this many observed cycles to fit its own trend
+	PREDICTION_BORROW_PENALTY = 2.0  //variance inflation when a read borrows the trend of another read
+)
+
+//Interval point estimate with symmetric normal confidence bounds
+type Interval struct {
+	Estimate float64
+	Stdev    float64
+	Lower    float64
+	Upper    float64
+}
+
+func newInterval(estimate, variance float64) Interval {
+	ret := Interval{Estimate: estimate}
+	if variance > 0 {
+		ret.Stdev = math.Sqrt(variance)
+	}
+	ret.Lower = estimate - PREDICTION_Z_SCORE*ret.Stdev
+	ret.Upper = estimate + PREDICTION_Z_SCORE*ret.Stdev
+	return ret
+}
+
+func (self Interval) clamp(lower, upper float64) Interval {
+	clip := func(v float64) float64 {
+		return math.Max(lower, math.Min(upper, v))
+	}
+	self.Estimate = clip(self.Estimate)
+	self.Lower = clip(self.Lower)
+	self.Upper = clip(self.Upper)
+	return self
+}
+
+func (self Interval) Contains(v float64) bool {
+	return v >= self.Lower && v <= self.Upper
+}
+
+type ReadPrediction struct {
+	ReadNum        int
+	IsIndexedRead  bool
+	FirstCycle     int
+	LastCycle      int
+	ObservedCycles int
+	Borrowed       bool //not enough own cycles; trend shape taken from an earlier read
+	PctQ30         *Interval
+	ErrorRate      *Interval //nil when no error metrics for the read, e.g. index reads
+}
+
+type YieldPrediction struct {
+	ExpectedTiles int
+	ReportedTiles int
+	PFClusters    Interval
+	YieldBases    Interval //PF clusters x planned cycles
+	YieldGb       Interval
+}
+
+type RunPrediction struct {
+	CurrentCycle  int
+	PlannedCycles int
+	Reads         []*ReadPrediction
+	PctQ30        *Interval //cluster weighted over all reads, as RunOutcome.PctQ30
+	Yield         *YieldPrediction
+}
+
+//cycleQ30Counts clusters >=Q30 and all clusters of each cycle across all lanes and tiles
+func cycleQ30Counts(qInfo *QMetricsInfo) (above, total map[uint16]uint64) {
+	above = make(map[uint16]uint64)
+	total = make(map[uint16]uint64)
+	qInfo.EachRecord(func(laneNum uint16, tileNum uint32, cycle uint16, numClusters *[50]uint32) {
+		if laneNum == 0 || cycle == 0 {
+			return
+		}
+		a, t := QscoreAbove(numClusters, Q30)
+		above[cycle] += a
+		total[cycle] += t
+	})
+	return
+}
+
+//CycleQ30Series percent >=Q30 of each cycle across all lanes and tiles
+func CycleQ30Series(qInfo *QMetricsInfo) map[uint16]float64 {
+	above, total := cycleQ30Counts(qInfo)
+	ret := make(map[uint16]float64)
+	for cycle, t := range total {
+		if t == 0 {
+			continue
+		}
+		ret[cycle] = 100. * float64(above[cycle]) / float64(t)
+	}
+	return ret
+}
+
+//CycleErrorSeries mean error rate of each cycle across all lanes and tiles
+func CycleErrorSeries(errInfo *ErrorInfo) map[uint16]float64 {
+	sum := make(map[uint16]float64)
+	cnt := make(map[uint16]int)
+	errInfo.EachRecord(func(laneNum uint16, tileNum uint32, cycle uint16, errorRate float32) {
+		if laneNum == 0 || cycle == 0 {
+			return
+		}
+		sum[cycle] += float64(errorRate)
+		cnt[cycle]++
+	})
+	ret := make(map[uint16]float64)
+	for cycle, n := range cnt {
+		ret[cycle] = sum[cycle] / float64(n)
+	}
+	return ret
+}
+
+//linearTrend least square fit of y = Intercept + Slope * x
+type linearTrend struct {
+	N         int
+	MeanX     float64
+	Sxx       float64
+	Intercept float64
+	Slope     float64
+	ResVar    float64 //residual variance
+}
+
+func fitLinearTrend(x, y []float64) *linearTrend {
+	ret := &linearTrend{N: len(x)}
+	if ret.N == 0 {
+		return ret
+	}
+	meanY := float64(0)
+	for i := range x {
+		ret.MeanX += x[i]
+		meanY += y[i]
+	}
+	ret.MeanX /= float64(ret.N)
+	meanY /= float64(ret.N)
+	sxy := float64(0)
+	for i := range x {
+		dx := x[i] - ret.MeanX
+		ret.Sxx += dx * dx
+		sxy += dx * (y[i] - meanY)
+	}
+	if ret.Sxx > 0 {
+		ret.Slope = sxy / ret.Sxx
+	}
+	ret.Intercept = meanY - ret.Slope*ret.MeanX
+	if ret.N > 2 {
+		rss := float64(0)
+		for i := range x {
+			r := y[i] - ret.At(x[i])
+			rss += r * r
+		}
+		ret.ResVar = rss / float64(ret.N-2)
+	}
+	return ret
+}
+
+func (self *linearTrend) At(x float64) float64 {
+	return self.Intercept + self.Slope*x
+}
+
+//meanVariance variance of the fitted line at x
+func (self *linearTrend) meanVariance(x float64) float64 {
+	if self.N == 0 {
+		return 0
+	}
+	v := 1. / float64(self.N)
+	if self.Sxx > 0 {
+		d := x - self.MeanX
+		v += d * d / self.Sxx
+	}
+	return self.ResVar * v
+}
+
+//projectRead mean of a per-cycle series over a read once it completes.
+//x is the 0-based cycle offset inside the read.
+func projectRead(series map[uint16]float64, firstCycle, lastCycle int, ref *linearTrend) (ret *Interval, own *linearTrend, observed int, borrowed bool) {
+	obsX, obsY, futureX := []float64{}, []float64{}, []float64{}
+	sumObs := float64(0)
+	for c := firstCycle; c <= lastCycle; c++ {
+		x := float64(c - firstCycle)
+		if v, ok := series[uint16(c)]; ok {
+			obsX = append(obsX, x)
+			obsY = append(obsY, v)
+			sumObs += v
+			continue
+		}
+		futureX = append(futureX, x)
+	}
+	observed = len(obsX)
+	m := len(futureX)
+	if observed > 0 && m == 0 {
+		//read is complete, nothing to predict
+		own = fitLinearTrend(obsX, obsY)
+		interval := newInterval(sumObs/float64(observed), 0)
+		return &interval, own, observed, false
+	}
+
+	trend := ref
+	if observed >= PREDICTION_MIN_FIT_CYCLES {
+		own = fitLinearTrend(obsX, obsY)
+		trend = own
+	}
+	if trend == nil {
+		if observed == 0 {
+			return nil, nil, 0, false
+		}
+		//flat model around what is seen so far
+		trend = fitLinearTrend(obsX, obsY)
+		trend.Slope = 0
+		trend.Intercept = sumObs / float64(observed)
+		trend.Sxx = 0
+		if observed > 1 {
+			rss := float64(0)
+			for _, y := range obsY {
+				rss += (y - trend.Intercept) * (y - trend.Intercept)
+			}
+			trend.ResVar = rss / float64(observed-1)
+		}
+	}
+	borrowed = trend == ref && ref != nil
+	if borrowed && observed > 0 {
+		//keep the borrowed slope, anchor the level on own cycles
+		shifted := *ref
+		meanX := float64(0)
+		for _, x := range obsX {
+			meanX += x
+		}
+		meanX /= float64(observed)
+		shifted.Intercept = sumObs/float64(observed) - shifted.Slope*meanX
+		trend = &shifted
+	}
+
+	meanFutureX := float64(0)
+	for _, x := range futureX {
+		meanFutureX += x
+	}
+	meanFutureX /= float64(m)
+
+	predicted := trend.At(meanFutureX)
+	variance := trend.meanVariance(meanFutureX) + trend.ResVar/float64(m)
+	if borrowed {
+		variance *= PREDICTION_BORROW_PENALTY
+	}
+	w := float64(m) / float64(observed+m)
+	interval := newInterval((sumObs+float64(m)*predicted)/float64(observed+m), w*w*variance)
+	return &interval, own, observed, borrowed
+}
+
+func expectedTilesPerLane(runInfo *fcinfo.RunInfo) int {
+	layout := runInfo.Run.FlowcellLayout
+	return layout.SurfaceCount * layout.SwathCount * layout.TileCount
+}
+
+//PredictYield project PF clusters and bases from reported tiles; unreported tiles and lanes are extrapolated by the tile mean
+func PredictYield(runInfo *fcinfo.RunInfo, tileInfo *TileInfo) *YieldPrediction {
+	ret := new(YieldPrediction)
+	_, pf := tileInfo.ClustersByTile()
+	perLane := expectedTilesPerLane(runInfo)
+	lanes := runInfo.Run.FlowcellLayout.LaneCount
+
+	estimate, variance := float64(0), float64(0)
+	all := []float64{}
+	for _, tiles := range pf {
+		values := []float64{}
+		for _, v := range tiles {
+			values = append(values, v)
+		}
+		all = append(all, values...)
+		n := len(values)
+		expected := perLane
+		if expected < n {
+			expected = n
+		}
+		mean, sd := MeanStat(&values)
+		estimate += mean * float64(expected)
+		if n > 1 {
+			//finite population correction; zero when every tile reported
+			fpc := 1. - float64(n)/float64(expected)
+			variance += sd * sd * float64(n) / float64(n-1) / float64(n) * float64(expected*expected) * fpc
+		}
+		ret.ReportedTiles += n
+		ret.ExpectedTiles += expected
+	}
+	if missing := lanes - len(pf); missing > 0 && len(all) > 0 && perLane > 0 {
+		mean, sd := MeanStat(&all)
+		tiles := float64(missing * perLane)
+		estimate += mean * tiles
+		variance += sd * sd * tiles
+		ret.ExpectedTiles += missing * perLane
+	}
+	ret.PFClusters = newInterval(estimate, variance).clamp(0, math.Inf(1))
+	cycles := float64(runInfo.GetNumCycles())
+	ret.YieldBases = newInterval(estimate*cycles, variance*cycles*cycles).clamp(0, math.Inf(1))
+	ret.YieldGb = newInterval(estimate*cycles/1e9, variance*cycles*cycles/1e18).clamp(0, math.Inf(1))
+	return ret
+}
+
+func maxCycleOf(series map[uint16]float64) int {
+	ret := 0
+	for c := range series {
+		if int(c) > ret {
+			ret = int(c)
+		}
+	}
+	return ret
+}
+
+//PredictRun project final %>=Q30 per read and PF yield. errInfo and tileInfo are optional.
+func PredictRun(runInfo *fcinfo.RunInfo, qInfo *QMetricsInfo, errInfo *ErrorInfo, tileInfo *TileInfo) (*RunPrediction, error) {
+	if runInfo == nil {
+		return nil, fmt.Errorf("RunInfo is required")
+	}
+	if qInfo == nil {
+		return nil, fmt.Errorf("QMetrics is required")
+	}
+	q30 := CycleQ30Series(qInfo)
+	if len(q30) == 0 {
+		return nil, fmt.Errorf("no QMetrics cycles reported yet")
+	}
+	//clusters a future cycle is expected to call, for weighting reads as the actual outcome does
+	_, totals := cycleQ30Counts(qInfo)
+	meanTotal := float64(0)
+	for _, t := range totals {
+		meanTotal += float64(t)
+	}
+	meanTotal /= float64(len(totals))
+	errSeries := map[uint16]float64{}
+	if errInfo != nil {
+		errSeries = CycleErrorSeries(errInfo)
+	}
+
+	ret := new(RunPrediction)
+	ret.CurrentCycle = maxCycleOf(q30)
+	ret.PlannedCycles = runInfo.GetNumCycles()
+
+	//most recent fitted trend per read kind, borrowed by reads without enough cycles
+	refQ := map[bool]*linearTrend{}
+	refErr := map[bool]*linearTrend{}
+	pick := func(refs map[bool]*linearTrend, indexed bool) *linearTrend {
+		if t, ok := refs[indexed]; ok {
+			return t
+		}
+		return refs[!indexed]
+	}
+
+	sum, variance, weights := float64(0), float64(0), float64(0)
+	for i, r := range runInfo.Run.Reads {
+		firstLast := runInfo.GetFirstLastCyclesByRead(i + 1)
+		rp := &ReadPrediction{
+			ReadNum:       i + 1,
+			IsIndexedRead: r.IsIndexedRead == "Y",
+			FirstCycle:    int(firstLast[0]),
+			LastCycle:     int(firstLast[1]),
+		}
+		ret.Reads = append(ret.Reads, rp)
+
+		pct, own, observed, borrowed := projectRead(q30, rp.FirstCycle, rp.LastCycle, pick(refQ, rp.IsIndexedRead))
+		rp.ObservedCycles = observed
+		rp.Borrowed = borrowed
+		if own != nil && observed >= PREDICTION_MIN_FIT_CYCLES {
+			refQ[rp.IsIndexedRead] = own
+		}
+		if pct != nil {
+			clamped := pct.clamp(0, 100)
+			rp.PctQ30 = &clamped
+			w := float64(rp.LastCycle-rp.FirstCycle+1-observed) * meanTotal
+			for c := rp.FirstCycle; c <= rp.LastCycle; c++ {
+				w += float64(totals[uint16(c)])
+			}
+			sum += w * pct.Estimate
+			variance += w * w * pct.Stdev * pct.Stdev
+			weights += w
+		}
+
+		if rp.IsIndexedRead {
+			continue
+		}
+		er, ownErr, errObserved, _ := projectRead(errSeries, rp.FirstCycle, rp.LastCycle, pick(refErr, false))
+		if ownErr != nil && errObserved >= PREDICTION_MIN_FIT_CYCLES {
+			refErr[false] = ownErr
+		}
+		if er != nil {
+			clamped := er.clamp(0, 100)
+			rp.ErrorRate = &clamped
+		}
+	}
+	if weights > 0 {
+		overall := newInterval(sum/weights, variance/(weights*weights)).clamp(0, 100)
+		ret.PctQ30 = &overall
+	}
+	if tileInfo != nil {
+		ret.Yield = PredictYield(runInfo, tileInfo)
+	}
+	return ret, nil
+}
+
+type ReadOutcome struct {
+	ReadNum   int
+	PctQ30    float64
+	ErrorRate float64
+}
+
+type RunOutcome struct {
+	LastCycle  int
+	Reads      []*ReadOutcome
+	PctQ30     float64
+	PFClusters float64
+	YieldBases float64
+}
+
+//ActualOutcome %>=Q30 and yield measured from what has been sequenced
+func ActualOutcome(runInfo *fcinfo.RunInfo, qInfo *QMetricsInfo, errInfo *ErrorInfo, tileInfo *TileInfo) *RunOutcome {
+	ret := new(RunOutcome)
+	above, total := cycleQ30Counts(qInfo)
+	for cycle := range total {
+		if int(cycle) > ret.LastCycle {
+			ret.LastCycle = int(cycle)
+		}
+	}
+	errSeries := map[uint16]float64{}
+	if errInfo != nil {
+		errSeries = CycleErrorSeries(errInfo)
+	}
+	allAbove, allTotal := uint64(0), uint64(0)
+	for i := range runInfo.Run.Reads {
+		firstLast := runInfo.GetFirstLastCyclesByRead(i + 1)
+		ro := &ReadOutcome{ReadNum: i + 1}
+		a, t := uint64(0), uint64(0)
+		errSum, errCnt := float64(0), 0
+		for c := firstLast[0]; c <= firstLast[1] && c != 0; c++ {
+			a += above[c]
+			t += total[c]
+			if v, ok := errSeries[c]; ok {
+				errSum += v
+				errCnt++
+			}
+		}
+		if t > 0 {
+			ro.PctQ30 = 100. * float64(a) / float64(t)
+		}
+		if errCnt > 0 {
+			ro.ErrorRate = errSum / float64(errCnt)
+		}
+		allAbove += a
+		allTotal += t
+		ret.Reads = append(ret.Reads, ro)
+	}
+	if allTotal > 0 {
+		ret.PctQ30 = 100. * float64(allAbove) / float64(allTotal)
+	}
+	if tileInfo != nil {
+		_, pf := tileInfo.ClustersByTile()
+		for _, tiles := range pf {
+			for _, v := range tiles {
+				ret.PFClusters += v
+			}
+		}
+		ret.YieldBases = ret.PFClusters * float64(runInfo.GetNumCycles())
+	}
+	return ret
+}
+
+type BacktestValue struct {
+	Predicted *Interval
+	Actual    float64
+	Delta     float64 //Predicted - Actual
+	Covered   bool    //Actual within interval
+}
+
+func newBacktestValue(predicted *Interval, actual float64) *BacktestValue {
+	if predicted == nil {
+		return nil
+	}
+	return &BacktestValue{
+		Predicted: predicted,
+		Actual:    actual,
+		Delta:     predicted.Estimate - actual,
+		Covered:   predicted.Contains(actual),
+	}
+}
+
+type BacktestRead struct {
+	ReadNum   int
+	PctQ30    *BacktestValue
+	ErrorRate *BacktestValue
+}
+
+type BacktestResult struct {
+	TruncatedAt int
+	Prediction  *RunPrediction
+	Actual      *RunOutcome
+	Reads       []*BacktestRead
+	PctQ30      *BacktestValue
+}
+
+//Backtest cut a completed run at cycle, predict from the remains and score %>=Q30 and error rates against the full run.
+//Yield is not backtested: tile metrics carry no cycle and PF counts are final once a tile reports them, so
+//Prediction.Yield is what the full tile metrics give.
+func Backtest(runInfo *fcinfo.RunInfo, qInfo *QMetricsInfo, errInfo *ErrorInfo, tileInfo *TileInfo, cycle int) (*BacktestResult, error) {
+	if runInfo == nil {
+		return nil, fmt.Errorf("RunInfo is required")
+	}
+	if qInfo == nil {
+		return nil, fmt.Errorf("QMetrics is required")
+	}
+	if cycle <= 0 {
+		return nil, fmt.Errorf("truncate cycle must be positive, got %d", cycle)
+	}
+	var truncatedErr *ErrorInfo
+	if errInfo != nil {
+		truncatedErr = errInfo.TruncateAtCycle(uint16(cycle))
+	}
+	prediction, err := PredictRun(runInfo, qInfo.TruncateAtCycle(uint16(cycle)), truncatedErr, tileInfo)
+	if err != nil {
+		return nil, err
+	}
+	ret := &BacktestResult{
+		TruncatedAt: cycle,
+		Prediction:  prediction,
+		Actual:      ActualOutcome(runInfo, qInfo, errInfo, tileInfo),
+	}
+	for i, rp := range prediction.Reads {
+		ro := ret.Actual.Reads[i]
+		ret.Reads = append(ret.Reads, &BacktestRead{
+			ReadNum:   rp.ReadNum,
+			PctQ30:    newBacktestValue(rp.PctQ30, ro.PctQ30),
+			ErrorRate: newBacktestValue(rp.ErrorRate, ro.ErrorRate),
+		})
+	}
+	ret.PctQ30 = newBacktestValue(prediction.PctQ30, ret.Actual.PctQ30)
+	return ret, nil
+}
@@ -0,0 +1,101 @@
+package interop
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ws6/interop/fcinfo"
+)
+
+const testRunInfo = `<?xml version="1.0"?>
+<RunInfo>
+  <Run Id="131220_SN1_0001_AH7TESTXX" Number="1">
+    <Flowcell>H7TESTXX</Flowcell>
+    <Instrument>SN1</Instrument>
+    <Date>131220</Date>
+    <Reads>
+      <Read Number="1" NumCycles="60" IsIndexedRead="N" />
+      <Read Number="2" NumCycles="6" IsIndexedRead="Y" />
+      <Read Number="3" NumCycles="60" IsIndexedRead="N" />
+    </Reads>
+    <FlowcellLayout LaneCount="8" SurfaceCount="2" SwathCount="3" TileCount="16" />
+  </Run>
+</RunInfo>`
+
+//makeDecayQMetrics Q30 fraction drops linearly inside each read with a small deterministic wobble
+func makeDecayQMetrics(runInfo *fcinfo.RunInfo) *QMetricsInfo {
+	ret := &QMetricsInfo{Version: 4}
+	for i := range runInfo.Run.Reads {
+		firstLast := runInfo.GetFirstLastCyclesByRead(i + 1)
+		start := 0.95 - 0.03*float64(i)
+		for c := firstLast[0]; c <= firstLast[1]; c++ {
+			x := float64(c - firstLast[0])
+			frac := start - 0.002*x + 0.005*math.Sin(float64(c))
+			m := new(QMetrics)
+			m.LaneNum, m.TileNum, m.Cycle = 1, 1101, c
+			m.NumClusters[35] = uint32(frac * 100000)
+			m.NumClusters[15] = uint32((1 - frac) * 100000)
+			ret.Metrics = append(ret.Metrics, m)
+		}
+	}
+	return ret
+}
+
+func TestBacktestPrediction(t *testing.T) {
+	runInfo, err := fcinfo.ParseRunInfoXML(testRunInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tileInfo := &TileInfo{Filename: `test_data/InterOp/TileMetricsOut.bin`}
+	if err := tileInfo.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	qInfo := makeDecayQMetrics(runInfo)
+
+	result, err := Backtest(runInfo, qInfo, nil, tileInfo, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Prediction.CurrentCycle != 30 {
+		t.Fatalf("expect current cycle 30, got %d", result.Prediction.CurrentCycle)
+	}
+	read1 := result.Reads[0].PctQ30
+	if read1 == nil || !read1.Covered {
+		t.Fatalf("read1 %%Q30 not covered: %+v", read1)
+	}
+	read3 := result.Prediction.Reads[2]
+	if !read3.Borrowed || read3.ObservedCycles != 0 {
+		t.Fatalf("read3 shall borrow read1 trend: %+v", read3)
+	}
+	//actual %>=Q30 counted straight from the records, every Q35 cluster is >=Q30
+	above, total := float64(0), float64(0)
+	for _, m := range qInfo.Metrics {
+		above += float64(m.NumClusters[35])
+		total += float64(m.NumClusters[35] + m.NumClusters[15])
+	}
+	if want := 100 * above / total; math.Abs(result.Actual.PctQ30-want) > 1e-9 {
+		t.Fatalf("expect actual %%Q30 %f, got %f", want, result.Actual.PctQ30)
+	}
+	if math.Abs(result.PctQ30.Delta) > 5 {
+		t.Errorf("overall %%Q30 off by %f: %+v", result.PctQ30.Delta, result.PctQ30.Predicted)
+	}
+
+	//every tile reported, so predicted yield is the PF sum over the 126 planned cycles
+	pf := float64(0)
+	for _, m := range tileInfo.Metrics {
+		if m.MetricCode == NUMBER_CLUSTER_PF {
+			pf += float64(m.MetricValue)
+		}
+	}
+	yield := result.Prediction.Yield
+	if yield.ReportedTiles != 768 || yield.ExpectedTiles != 768 {
+		t.Fatalf("expect 768 of 768 tiles, got %d of %d", yield.ReportedTiles, yield.ExpectedTiles)
+	}
+	if want := pf * 126; math.Abs(yield.YieldBases.Estimate-want) > 1e-6*want || yield.YieldBases.Stdev != 0 {
+		t.Errorf("expect yield %.0f bases, got %+v", want, yield.YieldBases)
+	}
+
+	if _, err := Backtest(runInfo, nil, nil, tileInfo, 30); err == nil {
+		t.Errorf("expect an error without QMetrics")
+	}
+}
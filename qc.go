@@ -0,0 +1,118 @@
+package interop
+
+//qc.go pass/warn/fail verdicts of summary values against run spec rules
+
+import (
+	"fmt"
+	"math"
+)
+
+var (
+	QC_PASS    = "pass"
+	QC_WARN    = "warn"
+	QC_FAIL    = "fail"
+	QC_NO_DATA = "nodata" //the value is NaN; worse than a pass, better than a warning
+
+	//DEFAULT_QC_RULES loose spec that fits most 2x150 runs; teams bring their own per instrument and kit
+	DEFAULT_QC_RULES = []*QcRule{
+		{Metric: SUMMARY_PCT_Q30, Min: QcBound(75), Severity: QC_FAIL},
+		{Metric: SUMMARY_PCT_Q30, Min: QcBound(80), Severity: QC_WARN},
+		{Metric: SUMMARY_PCT_PF, Min: QcBound(50), Severity: QC_FAIL},
+		{Metric: SUMMARY_PCT_PF, Min: QcBound(65), Severity: QC_WARN},
+		{Metric: SUMMARY_ERROR_RATE, Max: QcBound(3), Severity: QC_FAIL},
+		{Metric: SUMMARY_ERROR_RATE, Max: QcBound(1.5), Severity: QC_WARN},
+	}
+)
+
+//QcRule bounds of one summary metric; a nil Min or Max is no bound
+type QcRule struct {
+	Metric   string
+	Min      *float64
+	Max      *float64
+	Severity string //QC_WARN or QC_FAIL when out of bounds
+}
+
+//QcBound a rule bound, zero included
+func QcBound(v float64) *float64 {
+	return &v
+}
+
+//Check QC_PASS, QC_NO_DATA for NaN, or the rule severity
+func (self *QcRule) Check(v float64) string {
+	if math.IsNaN(v) {
+		return QC_NO_DATA
+	}
+	if (self.Min != nil && v < *self.Min) || (self.Max != nil && v > *self.Max) {
+		return self.Severity
+	}
+	return QC_PASS
+}
+
+func (self *QcRule) String() string {
+	switch {
+	case self.Min != nil && self.Max != nil:
+		return fmt.Sprintf("%g <= %s <= %g", *self.Min, self.Metric, *self.Max)
+	case self.Max != nil:
+		return fmt.Sprintf("%s <= %g", self.Metric, *self.Max)
+	case self.Min != nil:
+		return fmt.Sprintf("%s >= %g", self.Metric, *self.Min)
+	}
+	return self.Metric
+}
+
+type QcVerdict struct {
+	ReadNum int
+	LaneNum uint16
+	Metric  string
+	Value   float64
+	Rule    string
+	Status  string
+}
+
+type QcReport struct {
+	Status   string //worst status of Verdicts
+	Verdicts []*QcVerdict
+}
+
+func qcWorse(a, b string) string {
+	rank := map[string]int{QC_PASS: 0, QC_NO_DATA: 1, QC_WARN: 2, QC_FAIL: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+//Check lanes of every non indexed read against rules; metrics without tile values are skipped.
+//Of several rules on the same lane metric only the worst outcome is kept.
+func (self *RunSummary) Check(rules []*QcRule) *QcReport {
+	ret := &QcReport{Status: QC_PASS}
+	for _, rs := range self.Reads {
+		if rs.IsIndexedRead {
+			continue
+		}
+		for _, ls := range rs.Lanes {
+			byMetric := map[string]*QcVerdict{}
+			for _, rule := range rules {
+				if len(ls.TileValues[rule.Metric]) == 0 {
+					continue
+				}
+				v := ls.Value(rule.Metric)
+				status := rule.Check(v)
+				if status == QC_NO_DATA {
+					v = 0 //NaN does not marshal to JSON
+				}
+				verdict, ok := byMetric[rule.Metric]
+				if !ok {
+					verdict = &QcVerdict{ReadNum: rs.ReadNum, LaneNum: ls.LaneNum, Metric: rule.Metric, Value: v, Rule: rule.String(), Status: status}
+					byMetric[rule.Metric] = verdict
+					ret.Verdicts = append(ret.Verdicts, verdict)
+				}
+				if qcWorse(verdict.Status, status) != verdict.Status {
+					verdict.Status, verdict.Rule = status, rule.String()
+				}
+				ret.Status = qcWorse(ret.Status, status)
+			}
+		}
+	}
+	return ret
+}
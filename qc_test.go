@@ -0,0 +1,59 @@
+package interop
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQcCheck(t *testing.T) {
+	lane := func(laneNum uint16, q30, errorRate float64) *LaneSummary {
+		return &LaneSummary{
+			LaneNum:    laneNum,
+			PctQ30:     q30,
+			ErrorRate:  errorRate,
+			TileValues: map[string][]float64{SUMMARY_PCT_Q30: {q30}, SUMMARY_ERROR_RATE: {errorRate}},
+		}
+	}
+	summary := &RunSummary{Reads: []*ReadSummary{
+		{ReadNum: 1, Lanes: []*LaneSummary{lane(1, 90, 0.5), lane(2, 78, 0.5)}},
+		{ReadNum: 2, IsIndexedRead: true, Lanes: []*LaneSummary{lane(1, 10, 9)}},
+		{ReadNum: 3, Lanes: []*LaneSummary{lane(1, 85, 4)}},
+	}}
+	report := summary.Check(DEFAULT_QC_RULES)
+	if report.Status != QC_FAIL {
+		t.Errorf("expect fail, got %s", report.Status)
+	}
+	//two metrics with data for each of the three non indexed lanes, pct_pf has none
+	if len(report.Verdicts) != 6 {
+		t.Fatalf("expect 6 verdicts, got %d", len(report.Verdicts))
+	}
+	expect := map[[2]int]string{}
+	for _, v := range report.Verdicts {
+		if v.ReadNum == 2 {
+			t.Error("indexed reads are not checked")
+		}
+		if v.Metric == SUMMARY_PCT_Q30 {
+			expect[[2]int{v.ReadNum, int(v.LaneNum)}] = v.Status
+		}
+		if v.Metric == SUMMARY_ERROR_RATE && v.ReadNum == 3 && v.Status != QC_FAIL {
+			t.Errorf("error rate 4 should fail, got %s", v.Status)
+		}
+	}
+	if expect[[2]int{1, 1}] != QC_PASS || expect[[2]int{1, 2}] != QC_WARN || expect[[2]int{3, 1}] != QC_PASS {
+		t.Errorf("unexpected Q30 verdicts %v", expect)
+	}
+
+	//a metric without data is neither a pass nor a failure
+	if status := DEFAULT_QC_RULES[0].Check(math.NaN()); status != QC_NO_DATA {
+		t.Errorf("expect %s for NaN, got %s", QC_NO_DATA, status)
+	}
+	nan := &RunSummary{Reads: []*ReadSummary{{ReadNum: 1, Lanes: []*LaneSummary{lane(1, math.NaN(), 0.5)}}}}
+	if report := nan.Check(DEFAULT_QC_RULES); report.Status != QC_NO_DATA || report.Verdicts[0].Value != 0 {
+		t.Errorf("expect %s for a NaN lane, got %s %+v", QC_NO_DATA, report.Status, report.Verdicts[0])
+	}
+	//0 is a bound like any other
+	zero := &QcRule{Metric: SUMMARY_ERROR_RATE, Max: QcBound(0), Severity: QC_FAIL}
+	if zero.Check(0.1) != QC_FAIL || zero.Check(0) != QC_PASS || zero.String() != "error_rate <= 0" {
+		t.Errorf("expect error rate above a 0 max to fail, got %s %s", zero.Check(0.1), zero)
+	}
+}
@@ -0,0 +1,151 @@
+package interop
+
+//qhist.go Q score histogram, SAV's QScore Distribution chart
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/ws6/interop/fcinfo"
+)
+
+type QHistogramBin struct {
+	Q        int
+	Clusters uint64 //base calls at this Q score, summed over tiles and cycles
+}
+
+type QHistogram struct {
+	ReadNum int //0 for every read
+	Bins    []*QHistogramBin
+	Total   uint64
+	PctQ30  float64
+	MeanQ   float64
+}
+
+//Histogram sum Q score counts over cycles; nil cycles takes every cycle. Empty bins are left out.
+func (self *QMetricsInfo) Histogram(cycles fcinfo.CycleSet) *QHistogram {
+	sums := [50]uint64{}
+	self.EachRecord(func(laneNum uint16, tileNum uint32, cycle uint16, numClusters *[50]uint32) {
+		if laneNum == 0 || !cycles.Admits(cycle) {
+			return
+		}
+		for qval, n := range numClusters {
+			sums[qval] += uint64(n)
+		}
+	})
+	ret := new(QHistogram)
+	weighted, above := 0., uint64(0)
+	for qval, n := range sums {
+		q := qval + 1
+		ret.Total += n
+		weighted += float64(q) * float64(n)
+		if q >= Q30 {
+			above += n
+		}
+		if n > 0 {
+			ret.Bins = append(ret.Bins, &QHistogramBin{Q: q, Clusters: n})
+		}
+	}
+	if ret.Total > 0 {
+		ret.PctQ30 = 100. * float64(above) / float64(ret.Total)
+		ret.MeanQ = weighted / float64(ret.Total)
+	}
+	return ret
+}
+
+//QHistogram Q score histogram of one read, readNum 0 for the whole run
+func (self *Run) QHistogram(readNum int) (*QHistogram, error) {
+	if self.Q == nil {
+		return nil, fmt.Errorf("run %s has no QMetricsOut.bin", self.Name())
+	}
+	var cycles fcinfo.CycleSet
+	if readNum != 0 {
+		if self.RunInfo == nil {
+			return nil, fmt.Errorf("run %s has no RunInfo.xml to find read %d in", self.Name(), readNum)
+		}
+		if readNum < 0 || readNum > len(self.RunInfo.Run.Reads) {
+			return nil, fmt.Errorf("run %s has no read %d", self.Name(), readNum)
+		}
+		cycles = self.RunInfo.GetCyclesMapByRead(readNum)
+	}
+	ret := self.Q.Histogram(cycles)
+	ret.ReadNum = readNum
+	return ret, nil
+}
+
+func (self *QHistogram) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"Q", "Clusters"}); err != nil {
+		return err
+	}
+	for _, b := range self.Bins {
+		if err := cw.Write([]string{strconv.Itoa(b.Q), strconv.FormatUint(b.Clusters, 10)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+//QHeatmap share of base calls per cycle and Q score, SAV's QScore Heatmap chart
+type QHeatmap struct {
+	Cycles []uint16
+	MaxQ   int
+	Values [][]float64 //Values[i][q-1] % of cycle Cycles[i] calls at Q score q
+	Max    float64
+}
+
+//QHeatmap every cycle's Q score distribution, in percent of the cycle's calls
+func (self *QMetricsInfo) QHeatmap() *QHeatmap {
+	sums := map[uint16]*[50]uint64{}
+	self.EachRecord(func(laneNum uint16, tileNum uint32, cycle uint16, numClusters *[50]uint32) {
+		if laneNum == 0 {
+			return
+		}
+		s, ok := sums[cycle]
+		if !ok {
+			s = new([50]uint64)
+			sums[cycle] = s
+		}
+		for qval, n := range numClusters {
+			s[qval] += uint64(n)
+		}
+	})
+	ret := new(QHeatmap)
+	for c := range sums {
+		ret.Cycles = append(ret.Cycles, c)
+	}
+	sortUint16s(ret.Cycles)
+	for _, c := range ret.Cycles {
+		total := uint64(0)
+		for qval, n := range sums[c] {
+			total += n
+			if n > 0 && qval+1 > ret.MaxQ {
+				ret.MaxQ = qval + 1
+			}
+		}
+		row := make([]float64, 50)
+		for qval, n := range sums[c] {
+			if total > 0 {
+				row[qval] = 100. * float64(n) / float64(total)
+			}
+			if row[qval] > ret.Max {
+				ret.Max = row[qval]
+			}
+		}
+		ret.Values = append(ret.Values, row)
+	}
+	for i := range ret.Values {
+		ret.Values[i] = ret.Values[i][:ret.MaxQ]
+	}
+	return ret
+}
+
+func (self *Run) QHeatmap() (*QHeatmap, error) {
+	if self.Q == nil {
+		return nil, fmt.Errorf("run %s has no QMetricsOut.bin", self.Name())
+	}
+	return self.Q.QHeatmap(), nil
+}
@@ -0,0 +1,20 @@
+package interop
+
+import (
+	"testing"
+)
+
+func TestQHistogramNoRunInfo(t *testing.T) {
+	run := &Run{Q: synthQ(1, 2, 3)}
+	h, err := run.QHistogram(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Total == 0 {
+		t.Fatal("whole run histogram is empty")
+	}
+	//without RunInfo.xml there are no reads to pick from
+	if _, err := run.QHistogram(1); err == nil {
+		t.Fatal("expect an error for read 1 without RunInfo")
+	}
+}
@@ -6,7 +6,8 @@ import (
 	"fmt"
 	"io"
 	"math"
-	"os"
+
+	"github.com/ws6/interop/fcinfo"
 )
 
 //common struct Lane,Tile and Cycle
@@ -44,6 +45,7 @@ type QMetricsInfo struct {
 	Metrics    []*QMetrics
 	Metrics7   []*QMetrics7
 	err        error
+	fsys       fcinfo.RunFS //nil reads Filename from disk
 }
 
 func (self *QMetricsInfo) Error() string {
@@ -283,7 +285,7 @@ func (self *QMetricsInfo) Parse() error {
 	if self.err != nil {
 		return self.err
 	}
-	file, err := os.Open(self.Filename)
+	file, err := openMetricFile(self.fsys, self.Filename)
 	if err != nil {
 		self.err = err
 		return self.err
@@ -413,15 +415,12 @@ func (self *QMetricsInfo) GetLaneMaxCycle() map[uint16]uint16 {
 //	return
 //}
 
-//GetLaneSum return either filtered or unfiltered by cycleMap
-func (self *QMetricsInfo) GetLaneSum(cycleMap *map[uint16]bool) map[uint16][]uint64 {
+//GetLaneSum return either filtered or unfiltered by cycles, nil cycles takes every cycle
+func (self *QMetricsInfo) GetLaneSum(cycles fcinfo.CycleSet) map[uint16][]uint64 {
 	ret := make(map[uint16][]uint64)
 	for _, v := range self.Metrics {
-		if cycleMap != nil {
-			cm := *cycleMap
-			if used, ok := cm[v.Cycle]; !ok || !used {
-				continue
-			}
+		if !cycles.Admits(v.Cycle) {
+			continue
 		}
 		if _, ok := ret[v.LaneNum]; !ok {
 			ret[v.LaneNum] = make([]uint64, len(v.NumClusters))
@@ -435,13 +434,14 @@ func (self *QMetricsInfo) GetLaneSum(cycleMap *map[uint16]bool) map[uint16][]uin
 	return ret
 }
 
-func (self *QMetricsInfo) QscoreInCycle(qvalCutoff int, laneNum uint16, cycleMap map[uint16]bool) uint64 {
+//QscoreInCycle base calls of a lane at or above qvalCutoff within cycles, nil cycles takes every cycle
+func (self *QMetricsInfo) QscoreInCycle(qvalCutoff int, laneNum uint16, cycles fcinfo.CycleSet) uint64 {
 	count := uint64(0)
 	for _, v := range self.Metrics {
 		if v.LaneNum != laneNum {
 			continue
 		}
-		if used, ok := cycleMap[v.Cycle]; !ok || !used {
+		if !cycles.Admits(v.Cycle) {
 			continue
 		}
 		for qval, qscore := range v.NumClusters {
@@ -526,3 +526,50 @@ func QscoreLaneStat(laneSum map[uint16][]uint64, laneNum uint16) (mean float64,
 	}
 	return
 }
+
+//EachRecord visit every record regardless of file version; tile number is widened to uint32
+func (self *QMetricsInfo) EachRecord(fn func(laneNum uint16, tileNum uint32, cycle uint16, numClusters *[50]uint32)) {
+	for _, v := range self.Metrics {
+		fn(v.LaneNum, uint32(v.TileNum), v.Cycle, &v.NumClusters)
+	}
+	for _, v := range self.Metrics7 {
+		fn(v.LaneNum, v.TileNum, v.Cycle, &v.NumClusters)
+	}
+}
+
+//TruncateAtCycle return a copy only keeps records up to maxCycle(inclusive), as if the run stopped there
+func (self *QMetricsInfo) TruncateAtCycle(maxCycle uint16) *QMetricsInfo {
+	ret := &QMetricsInfo{
+		Filename:   self.Filename,
+		Version:    self.Version,
+		SSize:      self.SSize,
+		EnableQbin: self.EnableQbin,
+		NumQscores: self.NumQscores,
+		QbinConfig: self.QbinConfig,
+	}
+	for _, v := range self.Metrics {
+		if v.Cycle > maxCycle {
+			continue
+		}
+		//!!! only use ref
+		ret.Metrics = append(ret.Metrics, v)
+	}
+	for _, v := range self.Metrics7 {
+		if v.Cycle > maxCycle {
+			continue
+		}
+		ret.Metrics7 = append(ret.Metrics7, v)
+	}
+	return ret
+}
+
+//QscoreAbove number of clusters at or above qvalCutoff in a histogram, same binning rule as QscoreSumByLane
+func QscoreAbove(numClusters *[50]uint32, qvalCutoff int) (above uint64, total uint64) {
+	for qval, n := range numClusters {
+		total += uint64(n)
+		if (qval + 1) >= qvalCutoff {
+			above += uint64(n)
+		}
+	}
+	return
+}
@@ -6,7 +6,8 @@ import (
 	"encoding/binary"
 	"fmt"
 	//	"math"
-	"os"
+
+	"github.com/ws6/interop/fcinfo"
 )
 
 type SubtileOffsetRegion struct {
@@ -41,6 +42,7 @@ type RegistrationMetricsInfo struct {
 	NumberOfSubRegions uint8
 	Metrics            []*RegistrationSubTileMetrics
 	err                error
+	fsys               fcinfo.RunFS //nil reads Filename from disk
 }
 
 func NewMetrics(NumOfChannels, NumberOfSubRegions int) *RegistrationSubTileMetrics {
@@ -56,7 +58,7 @@ func (self *RegistrationMetricsInfo) Parse() error {
 	if self.err != nil {
 		return self.err
 	}
-	file, err := os.Open(self.Filename)
+	file, err := openMetricFile(self.fsys, self.Filename)
 	if err != nil {
 		self.err = err
 		return self.err
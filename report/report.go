@@ -0,0 +1,202 @@
+package report
+
+//report.go one self-contained HTML file per run: tables, QC verdicts and inline SVG charts
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ws6/interop"
+	"github.com/ws6/interop/fcinfo"
+	"github.com/ws6/interop/plot"
+)
+
+var (
+	DEFAULT_HEATMAP_METRICS = []string{interop.SUMMARY_DENSITY_PF, interop.HEATMAP_PCT_Q30, interop.HEATMAP_INTENSITY, interop.HEATMAP_ERROR_RATE}
+	DEFAULT_BYCYCLE_METRICS = []string{interop.SERIES_INTENSITY, interop.SERIES_PCT_Q30, interop.SERIES_ERROR_RATE}
+
+	//METRIC_LABELS chart titles and axis labels of metric names
+	METRIC_LABELS = map[string]string{
+		interop.SUMMARY_DENSITY:     "Density (k/mm2)",
+		interop.SUMMARY_DENSITY_PF:  "Density PF (k/mm2)",
+		interop.SUMMARY_CLUSTERS:    "Clusters",
+		interop.SUMMARY_CLUSTERS_PF: "Clusters PF",
+		interop.SUMMARY_PCT_PF:      "% PF",
+		interop.SUMMARY_ERROR_RATE:  "Error Rate (%)",
+		interop.SUMMARY_PCT_Q30:     "% >= Q30",
+		interop.SUMMARY_PCT_ALIGNED: "% Aligned",
+		interop.SUMMARY_YIELD_GB:    "Yield (Gb)",
+		interop.SERIES_INTENSITY:    "Intensity",
+		interop.BYCYCLE_FWHM:        "FWHM",
+		interop.BYCYCLE_MEAN_QSCORE: "Mean Q Score",
+	}
+)
+
+func label(metric string) string {
+	if l, ok := METRIC_LABELS[metric]; ok {
+		return l
+	}
+	return metric
+}
+
+type Chart struct {
+	Title string
+	SVG   template.HTML
+}
+
+//Data everything a report template sees; nil fields mean the run has no data for them
+type Data struct {
+	Title        string
+	Generated    time.Time
+	RunId        string
+	RunFolder    string
+	Flowcell     *fcinfo.Flowcell
+	Summary      *interop.RunSummary
+	IndexSummary *interop.IndexSummary
+	Qc           *interop.QcReport
+	Heatmaps     []*Chart
+	ByCycle      []*Chart
+	QHistogram   *Chart
+	QHeatmap     *Chart
+	Subtile      []*Chart
+	Notes        []string //charts or files left out and why
+}
+
+//Funcs helpers available to every template, default or overridden
+var Funcs = template.FuncMap{
+	"f": func(digits int, v float64) string {
+		return fmt.Sprintf("%.*f", digits, v)
+	},
+	"millions": func(v float64) string {
+		return fmt.Sprintf("%.2f", v/1e6)
+	},
+	"count": func(v uint64) string {
+		return fmt.Sprintf("%d", v)
+	},
+	"label": label,
+	"upper": strings.ToUpper,
+	"date": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04:05 MST")
+	},
+}
+
+type Generator struct {
+	Template       *template.Template
+	QcRules        []*interop.QcRule
+	HeatmapMetrics []string
+	ByCycleMetrics []string
+	Now            func() time.Time
+}
+
+//New generator with the default template, rules and charts
+func New() *Generator {
+	return &Generator{
+		Template:       template.Must(template.New("report").Funcs(Funcs).Parse(DEFAULT_TEMPLATE)),
+		QcRules:        interop.DEFAULT_QC_RULES,
+		HeatmapMetrics: DEFAULT_HEATMAP_METRICS,
+		ByCycleMetrics: DEFAULT_BYCYCLE_METRICS,
+		Now:            time.Now,
+	}
+}
+
+//ParseTemplate replace the whole template; it is executed with a *Data and can use Funcs
+func (self *Generator) ParseTemplate(text string) error {
+	t, err := template.New("report").Funcs(Funcs).Parse(text)
+	if err != nil {
+		return err
+	}
+	self.Template = t
+	return nil
+}
+
+//Override redefine blocks of the default template, e.g. {{define "style"}} or {{define "header"}} for branding
+func (self *Generator) Override(text string) error {
+	t, err := template.Must(template.New("report").Funcs(Funcs).Parse(DEFAULT_TEMPLATE)).Parse(text)
+	if err != nil {
+		return err
+	}
+	self.Template = t
+	return nil
+}
+
+//Build collect report data; a missing file only drops its section and adds a note
+func (self *Generator) Build(run *interop.Run) *Data {
+	ret := &Data{
+		Title:     "Run Report " + run.Name(),
+		Generated: self.Now(),
+		RunId:     run.Name(),
+		RunFolder: run.RunFolder,
+		Flowcell:  run.Flowcell,
+	}
+	note := func(err error) {
+		ret.Notes = append(ret.Notes, err.Error())
+	}
+	if run.Tile != nil || run.Q != nil || run.Error != nil || run.Extraction != nil {
+		ret.Summary = run.Summary()
+		ret.Qc = ret.Summary.Check(self.QcRules)
+	}
+	if run.Index != nil {
+		ret.IndexSummary = run.IndexSummary()
+	}
+	for _, metric := range self.HeatmapMetrics {
+		h, err := run.Heatmap(metric, 0)
+		if err != nil {
+			note(err)
+			continue
+		}
+		title := label(metric)
+		ret.Heatmaps = append(ret.Heatmaps, &Chart{Title: title, SVG: template.HTML(plot.FlowcellHeatmap(title, h))})
+	}
+	reads := plot.ReadSpans(run.RunInfo)
+	for _, metric := range self.ByCycleMetrics {
+		s, err := run.ByCycle(metric)
+		if err != nil {
+			note(err)
+			continue
+		}
+		title := label(metric) + " by Cycle"
+		c := &plot.LineChart{Title: title, XLabel: "Cycle", YLabel: label(metric), Series: plot.CycleSeriesLines(s), Reads: reads}
+		ret.ByCycle = append(ret.ByCycle, &Chart{Title: title, SVG: template.HTML(c.SVG())})
+	}
+	if h, err := run.QHistogram(0); err == nil {
+		ret.QHistogram = &Chart{Title: "Q Score Distribution", SVG: template.HTML(plot.QHistogram("Q Score Distribution", h))}
+	} else {
+		note(err)
+	}
+	if h, err := run.QHeatmap(); err == nil {
+		ret.QHeatmap = &Chart{Title: "Q Score Heatmap", SVG: template.HTML(plot.QHeatmap("Q Score Heatmap", h, reads))}
+	}
+	if subtile, err := run.Subtile(); err == nil {
+		stat := subtile.SubtileLaneStat.ToJson()
+		ret.Subtile = append(ret.Subtile, subtileCharts("% PF", stat.PF)...)
+		ret.Subtile = append(ret.Subtile, subtileCharts("FWHM", stat.FWHM_Channel_All)...)
+	} else {
+		note(err)
+	}
+	return ret
+}
+
+//subtileCharts one box whisker chart per lane over the X bins
+func subtileCharts(name string, stat *interop.BinStatJson) []*Chart {
+	ret := []*Chart{}
+	if stat == nil {
+		return ret
+	}
+	for _, lane := range stat.XBinStat {
+		title := fmt.Sprintf("Lane %d %s by Subtile X Bin", lane.LaneNum, name)
+		c := &plot.BoxChart{Title: title, XLabel: "X Bin", YLabel: name}
+		for _, bin := range lane.BinBoxStat {
+			c.Boxes = append(c.Boxes, &plot.Box{Label: fmt.Sprintf("%d", bin.BinValue), Stat: bin.BoxWhiskerStat})
+		}
+		ret = append(ret, &Chart{Title: title, SVG: template.HTML(c.SVG())})
+	}
+	return ret
+}
+
+//Write the HTML report of run
+func (self *Generator) Write(w io.Writer, run *interop.Run) error {
+	return self.Template.Execute(w, self.Build(run))
+}
@@ -0,0 +1,112 @@
+package report
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ws6/interop"
+)
+
+const testRunInfo = `<?xml version="1.0"?>
+<RunInfo>
+  <Run Id="131220_SN1_0001_AH7TESTXX" Number="1">
+    <Flowcell>H7TESTXX</Flowcell>
+    <Instrument>SN1</Instrument>
+    <Date>131220</Date>
+    <Reads>
+      <Read Number="1" NumCycles="60" IsIndexedRead="N" />
+      <Read Number="2" NumCycles="6" IsIndexedRead="Y" />
+      <Read Number="3" NumCycles="60" IsIndexedRead="N" />
+    </Reads>
+    <FlowcellLayout LaneCount="8" SurfaceCount="2" SwathCount="3" TileCount="16" />
+  </Run>
+</RunInfo>`
+
+func loadTestRun(t *testing.T) (*interop.Run, string) {
+	dir, err := ioutil.TempDir("", "interop-report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "InterOp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "RunInfo.xml"), []byte(testRunInfo), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join("..", "test_data", "InterOp")
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		b, err := ioutil.ReadFile(filepath.Join(src, f.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "InterOp", f.Name()), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run, err := interop.LoadRun(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return run, dir
+}
+
+func TestReport(t *testing.T) {
+	run, dir := loadTestRun(t)
+	defer os.RemoveAll(dir)
+
+	g := New()
+	g.Now = func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC) }
+	var buf bytes.Buffer
+	if err := g.Write(&buf, run); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, expect := range []string{
+		"Run Report 131220_SN1_0001_AH7TESTXX",
+		"2020-01-02 03:04:05 UTC",
+		"<h2>Summary</h2>",
+		"<h2>Index Summary</h2>",
+		"<h2>QC <span",
+		"<svg xmlns",
+		"Error Rate (%) by Cycle",
+		"has no QMetricsOut.bin",
+	} {
+		if !strings.Contains(out, expect) {
+			t.Errorf("expect %q in the report", expect)
+		}
+	}
+	if strings.Contains(out, "<script") || strings.Contains(out, "http://") && !strings.Contains(out, "http://www.w3.org/2000/svg") {
+		t.Error("report should not reference external resources")
+	}
+
+	if err := g.Override(`{{define "header"}}<h1 class="brand">Core Lab</h1>{{end}}`); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := g.Write(&buf, run); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<h1 class="brand">Core Lab</h1>`) || !strings.Contains(buf.String(), "<h2>Summary</h2>") {
+		t.Error("expect the overridden header and the rest of the default template")
+	}
+
+	if err := g.ParseTemplate(`{{.RunId}} {{len .Heatmaps}}`); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := g.Write(&buf, run); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "131220_SN1_0001_AH7TESTXX 3" {
+		t.Errorf("unexpected custom template output %q", buf.String())
+	}
+}
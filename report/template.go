@@ -0,0 +1,111 @@
+package report
+
+//DEFAULT_TEMPLATE no external assets so the file can be mailed or attached as is.
+//Blocks style, header and footer are meant to be redefined through Generator.Override.
+var DEFAULT_TEMPLATE = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+{{block "style" .}}
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.6em; }
+h2 { border-bottom: 1px solid #ccc; padding-bottom: 0.2em; margin-top: 2em; }
+table { border-collapse: collapse; margin: 0.5em 0 1em 0; font-size: 0.9em; }
+th, td { border: 1px solid #ccc; padding: 0.25em 0.6em; text-align: right; }
+th { background: #f0f0f0; }
+td.text, th.text { text-align: left; }
+.pass { background: #dff0d8; }
+.warn { background: #fcf8e3; }
+.fail { background: #f2dede; }
+.nodata { background: #eeeeee; }
+.chart { display: inline-block; margin: 0.5em; vertical-align: top; }
+.notes { color: #777; font-size: 0.85em; }
+{{end}}
+</style>
+</head>
+<body>
+{{block "header" .}}<h1>{{.Title}}</h1>{{end}}
+<p>Generated {{date .Generated}} from {{.RunFolder}}</p>
+
+{{with .Flowcell}}
+<h2>Flowcell</h2>
+<table>
+<tr><th class="text">Run Id</th><td class="text">{{.RunId}}</td></tr>
+<tr><th class="text">Flowcell</th><td class="text">{{.FlowcellBarcode}}</td></tr>
+<tr><th class="text">Instrument</th><td class="text">{{.MachineName}} {{.InstrumentType}}</td></tr>
+<tr><th class="text">Position</th><td class="text">{{.FCPosition}}</td></tr>
+<tr><th class="text">Run Start</th><td class="text">{{.RunStartDate}}</td></tr>
+<tr><th class="text">Read Length</th><td class="text">{{.ReadLength}}</td></tr>
+<tr><th class="text">Cycles</th><td class="text">{{.Cycles}}</td></tr>
+<tr><th class="text">Chemistry</th><td class="text">{{.Chemistry}}</td></tr>
+<tr><th class="text">Application</th><td class="text">{{.ApplicationName}} {{.ApplicationVersion}}</td></tr>
+<tr><th class="text">RTA</th><td class="text">{{.RtaVersion}}</td></tr>
+</table>
+{{end}}
+
+{{with .Qc}}
+<h2>QC <span class="{{.Status}}">{{upper .Status}}</span></h2>
+<table>
+<tr><th>Read</th><th>Lane</th><th class="text">Metric</th><th>Value</th><th class="text">Rule</th><th class="text">Verdict</th></tr>
+{{range .Verdicts}}<tr class="{{.Status}}"><td>{{.ReadNum}}</td><td>{{.LaneNum}}</td><td class="text">{{label .Metric}}</td><td>{{f 2 .Value}}</td><td class="text">{{.Rule}}</td><td class="text">{{.Status}}</td></tr>
+{{end}}</table>
+{{end}}
+
+{{with .Summary}}
+<h2>Summary</h2>
+<p>Cycle {{.CurrentCycle}} of {{.PlannedCycles}}</p>
+<table>
+<tr><th class="text">Level</th><th>Yield (Gb)</th><th>% &gt;= Q30</th><th>% Aligned</th><th>Error Rate</th><th>Intensity C1</th></tr>
+{{range .Reads}}<tr><td class="text">Read {{.ReadNum}}{{if .IsIndexedRead}} (I){{end}}</td><td>{{f 2 .Yield}}</td><td>{{f 2 .PctQ30}}</td><td>{{f 2 .PctAligned}}</td><td>{{f 2 .ErrorRate}}</td><td>{{f 0 .IntensityC1}}</td></tr>
+{{end}}{{with .NonIndexTotal}}<tr><td class="text">Non-indexed</td><td>{{f 2 .Yield}}</td><td>{{f 2 .PctQ30}}</td><td>{{f 2 .PctAligned}}</td><td>{{f 2 .ErrorRate}}</td><td>{{f 0 .IntensityC1}}</td></tr>
+{{end}}{{with .Total}}<tr><th class="text">Total</th><td>{{f 2 .Yield}}</td><td>{{f 2 .PctQ30}}</td><td>{{f 2 .PctAligned}}</td><td>{{f 2 .ErrorRate}}</td><td>{{f 0 .IntensityC1}}</td></tr>
+{{end}}</table>
+{{range .Reads}}
+<h3>Read {{.ReadNum}}{{if .IsIndexedRead}} (I){{end}}</h3>
+<table>
+<tr><th>Lane</th><th>Tiles</th><th>Density (k/mm2)</th><th>Cluster PF (%)</th><th>Phas/Prephas (%)</th><th>Reads (M)</th><th>Reads PF (M)</th><th>% &gt;= Q30</th><th>Yield (Gb)</th><th>Aligned (%)</th><th>Error Rate (%)</th><th>Intensity C1</th></tr>
+{{range .Lanes}}<tr><td>{{.LaneNum}}</td><td>{{.TileCount}}</td><td>{{f 0 .Density}} &plusmn; {{f 0 .DensityStdev}}</td><td>{{f 2 .PctPF}} &plusmn; {{f 2 .PctPFStdev}}</td><td>{{f 3 .Phasing}} / {{f 3 .Prephasing}}</td><td>{{millions .Clusters}}</td><td>{{millions .ClustersPF}}</td><td>{{f 2 .PctQ30}}</td><td>{{f 2 .Yield}}</td><td>{{f 2 .PctAligned}} &plusmn; {{f 2 .PctAlignedStdev}}</td><td>{{f 2 .ErrorRate}} &plusmn; {{f 2 .ErrorRateStdev}}</td><td>{{f 0 .IntensityC1}} &plusmn; {{f 0 .IntensityC1Stdev}}</td></tr>
+{{end}}</table>
+{{end}}
+{{end}}
+
+{{with .IndexSummary}}
+<h2>Index Summary</h2>
+{{range .Lanes}}
+<h3>Lane {{.LaneNum}}</h3>
+<p>PF clusters {{millions .TotalPFClusters}} M, identified {{f 2 .PctIdentified}}%, CV {{f 4 .CV}}, min {{f 4 .Min}}%, max {{f 4 .Max}}%</p>
+<table>
+<tr><th class="text">Sample</th><th class="text">Project</th><th class="text">Index</th><th>Clusters</th><th>% of Lane PF</th><th>% Identified</th></tr>
+{{range .Samples}}<tr><td class="text">{{.SampleName}}</td><td class="text">{{.ProjectName}}</td><td class="text">{{.IndexName}}</td><td>{{count .Clusters}}</td><td>{{f 4 .PctOfLanePF}}</td><td>{{f 4 .PctOfIdentified}}</td></tr>
+{{end}}</table>
+{{end}}
+{{end}}
+
+{{if .Heatmaps}}<h2>Flowcell</h2>
+{{range .Heatmaps}}<div class="chart">{{.SVG}}</div>
+{{end}}{{end}}
+
+{{if .ByCycle}}<h2>By Cycle</h2>
+{{range .ByCycle}}<div class="chart">{{.SVG}}</div>
+{{end}}{{end}}
+
+{{if or .QHistogram .QHeatmap}}<h2>Q Score</h2>
+{{with .QHistogram}}<div class="chart">{{.SVG}}</div>
+{{end}}{{with .QHeatmap}}<div class="chart">{{.SVG}}</div>
+{{end}}{{end}}
+
+{{if .Subtile}}<h2>Subtile</h2>
+{{range .Subtile}}<div class="chart">{{.SVG}}</div>
+{{end}}{{end}}
+
+{{if .Notes}}<h2>Notes</h2>
+<ul class="notes">
+{{range .Notes}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}
+{{block "footer" .}}{{end}}
+</body>
+</html>
+`
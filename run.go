@@ -0,0 +1,346 @@
+package interop
+
+//run.go loads every known InterOp file of a run folder in one go
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/ws6/interop/fcinfo"
+)
+
+var (
+	INTEROP_DIR = "InterOp"
+)
+
+//Run parsed run folder; a nil metrics field means the file is absent or failed to parse
+type Run struct {
+	RunFolder        string
+	RunInfo          *fcinfo.RunInfo
+	Flowcell         *fcinfo.Flowcell
+	Tile             *TileInfo
+	Q                *QMetricsInfo
+	Error            *ErrorInfo
+	Extraction       *ExtractionInfo
+	CorrectedInt     *CorrectIntInfo
+	Index            *IndexInfo
+	Control          *ControlInfo
+	Image            *ImageInfo
+	EmpiricalPhasing *EmpericalPhasingInfo
+	Extended         *ExtendMetricsInfo
+	ParseErrors      map[string]error //file name, or "Flowcell" for run folder metadata -> error
+
+	fsys   fcinfo.RunFS //nil when RunFolder is a folder on disk
+	closer io.Closer    //archive fsys reads from, nil when there is none
+}
+
+//InterOpFile Load's filename is a path of run's RunFS when the run has one
+type InterOpFile struct {
+	Name  string //file name under InterOp/
+	Field string //Run field Load sets, for the parse cache
+	Load  func(run *Run, filename string) error
+}
+
+//InterOpFiles files picked up by LoadRun. Grid and registration metrics are left out on purpose, they can be gigabytes.
+var InterOpFiles = []*InterOpFile{
+	{
+		Name:  "TileMetricsOut.bin",
+		Field: "Tile",
+		Load: func(run *Run, filename string) error {
+			info := &TileInfo{Filename: filename, fsys: run.fsys}
+			if err := info.ParseFast(); err != nil {
+				return err
+			}
+			run.Tile = info
+			return nil
+		},
+	},
+	{
+		Name:  "QMetricsOut.bin",
+		Field: "Q",
+		Load: func(run *Run, filename string) error {
+			info := &QMetricsInfo{Filename: filename, fsys: run.fsys}
+			if err := info.ParseFast(); err != nil {
+				return err
+			}
+			run.Q = info
+			return nil
+		},
+	},
+	{
+		Name:  "ErrorMetricsOut.bin",
+		Field: "Error",
+		Load: func(run *Run, filename string) error {
+			info := &ErrorInfo{Filename: filename, fsys: run.fsys}
+			if err := info.ParseFast(); err != nil {
+				return err
+			}
+			run.Error = info
+			return nil
+		},
+	},
+	{
+		Name:  "ExtractionMetricsOut.bin",
+		Field: "Extraction",
+		Load: func(run *Run, filename string) error {
+			info := &ExtractionInfo{Filename: filename, fsys: run.fsys}
+			if err := info.ParseFast(); err != nil {
+				return err
+			}
+			run.Extraction = info
+			return nil
+		},
+	},
+	{
+		Name:  "CorrectedIntMetricsOut.bin",
+		Field: "CorrectedInt",
+		Load: func(run *Run, filename string) error {
+			info := &CorrectIntInfo{Filename: filename, fsys: run.fsys}
+			if err := info.Parse(); err != nil {
+				return err
+			}
+			run.CorrectedInt = info
+			return nil
+		},
+	},
+	{
+		Name:  "IndexMetricsOut.bin",
+		Field: "Index",
+		Load: func(run *Run, filename string) error {
+			info := &IndexInfo{Filename: filename, fsys: run.fsys}
+			if err := info.Parse(); err != nil {
+				return err
+			}
+			run.Index = info
+			return nil
+		},
+	},
+	{
+		Name:  "ControlMetricsOut.bin",
+		Field: "Control",
+		Load: func(run *Run, filename string) error {
+			info := &ControlInfo{Filename: filename, fsys: run.fsys}
+			if err := info.Parse(); err != nil {
+				return err
+			}
+			run.Control = info
+			return nil
+		},
+	},
+	{
+		Name:  "ImageMetricsOut.bin",
+		Field: "Image",
+		Load: func(run *Run, filename string) error {
+			info := &ImageInfo{Filename: filename, fsys: run.fsys}
+			if err := info.Parse(); err != nil {
+				return err
+			}
+			run.Image = info
+			return nil
+		},
+	},
+	{
+		Name:  "EmpiricalPhasingMetricsOut.bin",
+		Field: "EmpiricalPhasing",
+		Load: func(run *Run, filename string) error {
+			info := &EmpericalPhasingInfo{Filename: filename, fsys: run.fsys}
+			if err := info.Parse(); err != nil {
+				return err
+			}
+			run.EmpiricalPhasing = info
+			return nil
+		},
+	},
+	{
+		Name:  "ExtendedTileMetricsOut.bin",
+		Field: "Extended",
+		Load: func(run *Run, filename string) error {
+			info := &ExtendMetricsInfo{Filename: filename, fsys: run.fsys}
+			if err := info.Parse(); err != nil {
+				return err
+			}
+			run.Extended = info
+			return nil
+		},
+	},
+}
+
+//OnDemandFiles files LoadRun leaves out as too big, read only when asked for, as by Subtile; Load parses without keeping
+//the metrics. Validate parses them too, so a corrupt grid file is found before a heatmap needs it
+var OnDemandFiles = []*InterOpFile{
+	{
+		Name: PF_GRID_FILE,
+		Load: func(run *Run, filename string) error {
+			return (&PFMetricsInfo{Filename: filename, fsys: run.fsys}).ParseFast()
+		},
+	},
+	{
+		Name: FWHM_GRID_FILE,
+		Load: func(run *Run, filename string) error {
+			return (&FwhmMetricsInfo{Filename: filename, fsys: run.fsys}).Parse()
+		},
+	},
+	{
+		Name: REGISTRATION_FILE,
+		Load: func(run *Run, filename string) error {
+			return (&RegistrationMetricsInfo{Filename: filename, fsys: run.fsys}).Parse()
+		},
+	},
+}
+
+//LoadRun parse RunInfo.xml, RunParameters.xml when present and every InterOp file found.
+//Only a missing RunInfo.xml or InterOp folder fails; per-file errors are kept in ParseErrors.
+func LoadRun(runFolder string) (*Run, error) {
+	return loadRun(runFolder, nil)
+}
+
+//loadRun InterOp files found in cache are not parsed; cache may be nil
+func loadRun(runFolder string, cache *ParseCache) (*Run, error) {
+	dir, err := filepath.Abs(runFolder)
+	if err != nil {
+		return nil, err
+	}
+	return loadRunFrom(&Run{RunFolder: dir}, cache)
+}
+
+//LoadRunFS same as LoadRun for a run folder in fsys, such as an archive; name is kept as RunFolder
+func LoadRunFS(fsys fcinfo.RunFS, name string) (*Run, error) {
+	return loadRunFrom(&Run{RunFolder: name, fsys: fsys}, nil)
+}
+
+//LoadRunArchive LoadRun of a .zip, .tar, .tar.gz or .tgz archive of a run folder, without extracting it.
+//Files not loaded up front, as grid metrics, are read from the archive later on, so Close the run once done.
+func LoadRunArchive(filename string) (*Run, error) {
+	fsys, closer, err := fcinfo.OpenArchive(filename)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := loadRunFrom(&Run{RunFolder: filename, fsys: fsys, closer: closer}, nil)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	return ret, nil
+}
+
+//Close the archive of a run from LoadRunArchive; a no-op for other runs
+func (self *Run) Close() error {
+	if self.closer == nil {
+		return nil
+	}
+	err := self.closer.Close()
+	self.closer = nil
+	return err
+}
+
+//path name of a run folder file, as its RunFS or the disk takes it
+func (self *Run) path(name ...string) string {
+	if self.fsys == nil {
+		return filepath.Join(append([]string{self.RunFolder}, name...)...)
+	}
+	return path.Join(name...)
+}
+
+func (self *Run) stat(name ...string) (os.FileInfo, error) {
+	return statMetricFile(self.fsys, self.path(name...))
+}
+
+func loadRunFrom(ret *Run, cache *ParseCache) (*Run, error) {
+	ret.ParseErrors = make(map[string]error)
+	runInfoByte, err := ret.readFile("RunInfo.xml")
+	if err != nil {
+		return nil, fmt.Errorf("read RunInfo.xml err:%s", err.Error())
+	}
+	if ret.RunInfo, err = fcinfo.ParseRunInfoXML(string(runInfoByte)); err != nil {
+		return nil, fmt.Errorf("parse RunInfo.xml err:%s", err.Error())
+	}
+	if _, err := ret.stat(INTEROP_DIR); err != nil {
+		return nil, fmt.Errorf("read InterOp/ folder err:%s", err.Error())
+	}
+
+	var fc *fcinfo.Flowcell
+	if ret.fsys == nil {
+		fc, err = fcinfo.ParseFlowcellRunFolder(ret.RunFolder, false)
+	} else {
+		fc, err = fcinfo.ParseFlowcellFS(ret.fsys, ret.RunFolder, false)
+	}
+	if err == nil {
+		ret.Flowcell = fc
+	} else {
+		ret.ParseErrors["Flowcell"] = err
+	}
+
+	for _, f := range InterOpFiles {
+		filename := ret.path(INTEROP_DIR, f.Name)
+		if _, err := ret.stat(INTEROP_DIR, f.Name); err != nil {
+			continue
+		}
+		if cache != nil && cache.restore(f, ret, filename) {
+			continue
+		}
+		if err := f.Load(ret, filename); err != nil {
+			ret.ParseErrors[f.Name] = err
+			continue
+		}
+		if cache != nil {
+			cache.store(f, ret, filename)
+		}
+	}
+	return ret, nil
+}
+
+func (self *Run) readFile(name string) ([]byte, error) {
+	if self.fsys == nil {
+		return ioutil.ReadFile(self.path(name))
+	}
+	return fcinfo.ReadFileFS(self.fsys, name)
+}
+
+//Name run id if known, otherwise folder name
+func (self *Run) Name() string {
+	if self.RunInfo != nil && self.RunInfo.Run.RunId != "" {
+		return self.RunInfo.Run.RunId
+	}
+	return filepath.Base(self.RunFolder)
+}
+
+type FileStatus struct {
+	Name    string
+	Present bool
+	Err     error
+}
+
+//Validate status of every file in InterOpFiles and OnDemandFiles plus run folder metadata; ok is false when any present file failed to parse.
+//InterOpFiles were parsed by LoadRun, OnDemandFiles present are parsed here
+func (self *Run) Validate() (statuses []*FileStatus, ok bool) {
+	ok = true
+	for _, f := range InterOpFiles {
+		st := &FileStatus{Name: f.Name, Err: self.ParseErrors[f.Name]}
+		if _, err := self.stat(INTEROP_DIR, f.Name); err == nil {
+			st.Present = true
+		}
+		if st.Err != nil {
+			ok = false
+		}
+		statuses = append(statuses, st)
+	}
+	for _, f := range OnDemandFiles {
+		st := &FileStatus{Name: f.Name}
+		if _, err := self.stat(INTEROP_DIR, f.Name); err == nil {
+			st.Present = true
+			st.Err = f.Load(self, self.path(INTEROP_DIR, f.Name))
+		}
+		if st.Err != nil {
+			ok = false
+		}
+		statuses = append(statuses, st)
+	}
+	if err, found := self.ParseErrors["Flowcell"]; found {
+		statuses = append(statuses, &FileStatus{Name: "Flowcell", Present: true, Err: err})
+	}
+	return
+}
@@ -0,0 +1,98 @@
+package interop
+
+//run_cache.go in memory LoadRun results, dropped when a file of the run folder changes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	//RUN_SIGNATURE_FILES run folder files besides InterOp/ whose change drops a cached run
+	RUN_SIGNATURE_FILES = []string{"RunInfo.xml", "RunParameters.xml", "runParameters.xml"}
+)
+
+//RunSignature newest mtime, total size and count of the files a run is loaded from
+type RunSignature struct {
+	ModTime time.Time
+	Size    int64
+	Files   int
+}
+
+func GetRunSignature(runFolder string) RunSignature {
+	ret := RunSignature{}
+	add := func(fi os.FileInfo) {
+		if fi.ModTime().After(ret.ModTime) {
+			ret.ModTime = fi.ModTime()
+		}
+		ret.Size += fi.Size()
+		ret.Files++
+	}
+	for _, f := range RUN_SIGNATURE_FILES {
+		if fi, err := os.Stat(filepath.Join(runFolder, f)); err == nil {
+			add(fi)
+		}
+	}
+	if files, err := ioutil.ReadDir(filepath.Join(runFolder, INTEROP_DIR)); err == nil {
+		for _, fi := range files {
+			if !fi.IsDir() {
+				add(fi)
+			}
+		}
+	}
+	return ret
+}
+
+type runCacheEntry struct {
+	sig RunSignature
+	run *Run
+}
+
+//RunCache safe for concurrent use; a run is parsed again only when its signature changed
+type RunCache struct {
+	Disk *ParseCache //optional, runs dropped from memory or reloaded after a restart skip unchanged files
+
+	mu      sync.Mutex
+	entries map[string]*runCacheEntry
+}
+
+func NewRunCache() *RunCache {
+	return &RunCache{entries: make(map[string]*runCacheEntry)}
+}
+
+//Load cached run of runFolder, reloaded when any of its files changed
+func (self *RunCache) Load(runFolder string) (*Run, error) {
+	dir, err := filepath.Abs(runFolder)
+	if err != nil {
+		return nil, err
+	}
+	sig := GetRunSignature(dir)
+	self.mu.Lock()
+	entry, ok := self.entries[dir]
+	self.mu.Unlock()
+	if ok && entry.sig == sig {
+		return entry.run, nil
+	}
+	run, err := loadRun(dir, self.Disk)
+	if err != nil {
+		return nil, err
+	}
+	self.mu.Lock()
+	self.entries[dir] = &runCacheEntry{sig: sig, run: run}
+	self.mu.Unlock()
+	return run, nil
+}
+
+//Forget drop cached runs whose folder is not in keep, for run folders moved away or deleted
+func (self *RunCache) Forget(keep map[string]bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for dir := range self.entries {
+		if !keep[dir] {
+			delete(self.entries, dir)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package interop
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadRunArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "interop-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	runFolder := filepath.Join(dir, "run")
+	if err := os.MkdirAll(filepath.Join(runFolder, INTEROP_DIR), 0755); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(dir, "run.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	files := map[string][]byte{"RunInfo.xml": []byte(testRunInfo)}
+	for _, name := range []string{"TileMetricsOut.bin", "ErrorMetricsOut.bin", "ExtractionMetricsOut.bin", "IndexMetricsOut.bin"} {
+		b, err := ioutil.ReadFile(filepath.Join("test_data", INTEROP_DIR, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[INTEROP_DIR+"/"+name] = b
+	}
+	//grid metrics are read from the archive after loading, by Subtile
+	interOpDir := filepath.Join(runFolder, INTEROP_DIR)
+	grids := map[string]string{
+		PF_GRID_FILE: writeBin(t, interOpDir, PF_GRID_FILE, uint8(1), uint16(36), uint16(2), uint16(2), float32(0.25),
+			uint16(1), uint16(1101), [4]uint32{100, 200, 300, 400}, [4]uint32{80, 160, 240, 320}),
+		FWHM_GRID_FILE: writeBin(t, interOpDir, FWHM_GRID_FILE, uint8(1), uint8(2), uint8(2), uint8(4), uint16(70),
+			LTC{1, 1101, 1}, [16]float32{2.5, 2.6, 2.7, 2.8, 2.5, 2.6, 2.7, 2.8, 2.5, 2.6, 2.7, 2.8, 2.5, 2.6, 2.7, 2.8}),
+	}
+	for name, filename := range grids {
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[INTEROP_DIR+"/"+name] = b
+	}
+	for name, b := range files {
+		if err := ioutil.WriteFile(filepath.Join(runFolder, filepath.FromSlash(name)), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		w, err := zw.Create("run/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	want, err := LoadRun(runFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadRunArchive(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer got.Close()
+	//the folder has no Data/ so only the archive, which does not need one, parses its Flowcell
+	if got.RunFolder != archive || got.Name() != want.Name() || len(got.ParseErrors) != 0 || got.Flowcell == nil {
+		t.Fatalf("archive run %s %s %v", got.RunFolder, got.Name(), got.ParseErrors)
+	}
+	if !reflect.DeepEqual(got.Tile.Metrics, want.Tile.Metrics) || !reflect.DeepEqual(got.Error.Metrics, want.Error.Metrics) ||
+		!reflect.DeepEqual(got.Extraction.Metrics, want.Extraction.Metrics) || !reflect.DeepEqual(got.Index.Metrics, want.Index.Metrics) {
+		t.Fatal("archive metrics differ from the run folder's")
+	}
+	if got.Tile.Filename != "InterOp/TileMetricsOut.bin" {
+		t.Fatalf("tile file %s", got.Tile.Filename)
+	}
+	statuses, ok := got.Validate()
+	if !ok || !statuses[0].Present {
+		t.Fatalf("validate %+v", statuses[0])
+	}
+	checked := 0
+	for _, st := range statuses {
+		if grids[st.Name] != "" && st.Present {
+			checked++
+		}
+	}
+	if checked != len(grids) {
+		t.Fatalf("validate checked %d grid files", checked)
+	}
+	//a truncated grid file fails validation, though LoadRun never reads it
+	if err := ioutil.WriteFile(grids[FWHM_GRID_FILE], []byte{1, 2, 2}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if statuses, ok := want.Validate(); ok {
+		t.Fatalf("validate passed a truncated %s %+v", FWHM_GRID_FILE, statuses)
+	}
+	subtile, err := got.Subtile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subtile.PFInfo.Metrics) != 1 || subtile.PFInfo.Metrics[0].PFCluster[3] != 320 || len(subtile.FwhmInfo.Metrics) != 1 {
+		t.Fatalf("subtile from the archive %+v %+v", subtile.PFInfo.Metrics, subtile.FwhmInfo.Metrics)
+	}
+	if err := got.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := got.Subtile(); err == nil {
+		t.Fatal("read a closed archive")
+	}
+}
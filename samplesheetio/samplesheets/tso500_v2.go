@@ -1,6 +1,7 @@
 package samplesheets
 
 import (
+	"github.com/ws6/interop/fcinfo"
 	"github.com/ws6/interop/samplesheetio"
 )
 
@@ -9,6 +10,18 @@ const (
 	VERSION_TSO500_2 = `v2.0`
 )
 
+var (
+	tso500Insert = &fcinfo.ReadSpec{Segments: []fcinfo.ReadSegment{
+		{Type: fcinfo.SEGMENT_UMI, Cycles: 7},
+		{Type: fcinfo.SEGMENT_SKIP, Cycles: 1},
+		{Type: fcinfo.SEGMENT_TEMPLATE, Cycles: 93},
+	}}
+	tso500Index = &fcinfo.ReadSpec{Segments: []fcinfo.ReadSegment{{Type: fcinfo.SEGMENT_INDEX, Cycles: 8}}}
+
+	//TSO500_READ_STRUCTURE 7 cycle UMI, one skipped cycle and 93 template cycles on both reads, 8 cycle dual indexes
+	TSO500_READ_STRUCTURE = &fcinfo.ReadStructure{Reads: []*fcinfo.ReadSpec{tso500Insert, tso500Index, tso500Index, tso500Insert}}
+)
+
 func init() {
 	Register(&TSO500IO{
 		Name:    TSO500,
@@ -34,7 +47,7 @@ func updateTSO500SettingsSection(sec *samplesheetio.Section) *samplesheetio.Sect
 		[]string{`AdapterBehavior`, `trim`},
 		[]string{`MinimumTrimmedReadLength`, `35`},
 		[]string{`MaskShortReads`, `35`},
-		[]string{`OverrideCycles`, `U7N1Y93;I8;I8;U7N1Y93`},
+		[]string{`OverrideCycles`, TSO500_READ_STRUCTURE.String()},
 		[]string{``},
 	}
 
@@ -0,0 +1,306 @@
+package server
+
+//server.go JSON API over a root folder of run folders, for dashboards
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ws6/interop"
+	"github.com/ws6/interop/fcinfo"
+)
+
+type RunEntry struct {
+	Name     string
+	Flowcell *fcinfo.Flowcell `json:",omitempty"`
+	Error    string           `json:",omitempty"`
+}
+
+type errorResponse struct {
+	Error string
+}
+
+//cachedSubtile stats of one parse of the run; stale once the run cache hands out another *Run
+type cachedSubtile struct {
+	run  *interop.Run
+	stat *interop.SubtileLaneStatJson
+}
+
+//Handler serves every run folder directly under Root
+type Handler struct {
+	Root string
+
+	runs    *interop.RunCache
+	mu      sync.Mutex
+	subtile map[string]*cachedSubtile
+}
+
+//NewHandler routes:
+//	GET /runs
+//	GET /runs/{run}/summary
+//	GET /runs/{run}/index-summary
+//	GET /runs/{run}/heatmap/{metric}?cycle=N
+//	GET /runs/{run}/bycycle/{metric}
+//	GET /runs/{run}/qhist?read=N
+//	GET /runs/{run}/subtile
+func NewHandler(root string) *Handler {
+	return &Handler{Root: root, runs: interop.NewRunCache(), subtile: make(map[string]*cachedSubtile)}
+}
+
+//SetParseCache keep parsed InterOp files in cache, so runs survive a restart without being parsed again
+func (self *Handler) SetParseCache(cache *interop.ParseCache) {
+	self.runs.Disk = cache
+}
+
+func (self *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "runs" {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no route %s", r.URL.Path))
+		return
+	}
+	if len(parts) == 1 {
+		self.serveRuns(w)
+		return
+	}
+	if len(parts) < 3 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no route %s", r.URL.Path))
+		return
+	}
+	name, endpoint, rest := parts[1], parts[2], parts[3:]
+	arity := map[string]int{
+		"summary":       0,
+		"index-summary": 0,
+		"heatmap":       1,
+		"bycycle":       1,
+		"qhist":         0,
+		"subtile":       0,
+	}
+	n, ok := arity[endpoint]
+	if !ok || len(rest) != n {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no route %s", r.URL.Path))
+		return
+	}
+	if endpoint == "subtile" {
+		ret, status, err := self.Subtile(name)
+		if err != nil {
+			writeError(w, status, err)
+			return
+		}
+		writeJson(w, ret)
+		return
+	}
+	run, status, err := self.Run(name)
+	if err != nil {
+		writeError(w, status, err)
+		return
+	}
+	query := r.URL.Query()
+	switch endpoint {
+	case "summary":
+		writeJson(w, run.Summary())
+	case "index-summary":
+		if run.Index == nil {
+			writeError(w, http.StatusNotFound, fmt.Errorf("run %s has no IndexMetricsOut.bin", name))
+			return
+		}
+		writeJson(w, run.IndexSummary())
+	case "heatmap":
+		if !contains(interop.HEATMAP_METRICS, rest[0]) {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("unknown heatmap metric %s, expect one of %s", rest[0], strings.Join(interop.HEATMAP_METRICS, ",")))
+			return
+		}
+		cycle, err := intParam(query.Get("cycle"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("cycle err:%s", err.Error()))
+			return
+		}
+		heatmap, err := run.Heatmap(rest[0], cycle)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJson(w, heatmap)
+	case "bycycle":
+		if !contains(interop.BYCYCLE_METRICS, rest[0]) {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("unknown by cycle metric %s, expect one of %s", rest[0], strings.Join(interop.BYCYCLE_METRICS, ",")))
+			return
+		}
+		series, err := run.ByCycle(rest[0])
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJson(w, series)
+	case "qhist":
+		readNum, err := intParam(query.Get("read"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("read err:%s", err.Error()))
+			return
+		}
+		if run.Q == nil {
+			writeError(w, http.StatusNotFound, fmt.Errorf("run %s has no QMetricsOut.bin", name))
+			return
+		}
+		hist, err := run.QHistogram(readNum)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJson(w, hist)
+	}
+}
+
+//Runs every folder under Root with a RunInfo.xml, sorted by name
+func (self *Handler) Runs() ([]*RunEntry, error) {
+	files, err := ioutil.ReadDir(self.Root)
+	if err != nil {
+		return nil, err
+	}
+	ret := []*RunEntry{}
+	keep, names := map[string]bool{}, map[string]bool{}
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+		dir := filepath.Join(self.Root, f.Name())
+		if _, err := os.Stat(filepath.Join(dir, "RunInfo.xml")); err != nil {
+			continue
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			keep[abs] = true
+		}
+		names[f.Name()] = true
+		entry := &RunEntry{Name: f.Name()}
+		fc, err := fcinfo.ParseFlowcellRunFolder(dir, false)
+		entry.Flowcell = fc
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		ret = append(ret, entry)
+	}
+	self.forget(keep, names)
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+//forget cached runs and subtile stats of run folders no longer under Root, so both caches stay as big as Root is
+func (self *Handler) forget(keep, names map[string]bool) {
+	self.runs.Forget(keep)
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for name := range self.subtile {
+		if !names[name] {
+			delete(self.subtile, name)
+		}
+	}
+}
+
+func (self *Handler) serveRuns(w http.ResponseWriter) {
+	runs, err := self.Runs()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJson(w, runs)
+}
+
+//runFolder path of a run name, refusing anything that is not a plain folder name under Root
+func (self *Handler) runFolder(name string) (string, int, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", http.StatusBadRequest, fmt.Errorf("bad run name %q", name)
+	}
+	dir := filepath.Join(self.Root, name)
+	if _, err := os.Stat(filepath.Join(dir, "RunInfo.xml")); err != nil {
+		return "", http.StatusNotFound, fmt.Errorf("run %s not found", name)
+	}
+	return dir, http.StatusOK, nil
+}
+
+//Run parsed run folder, reloaded when any of its files changed since it was cached
+func (self *Handler) Run(name string) (*interop.Run, int, error) {
+	dir, status, err := self.runFolder(name)
+	if err != nil {
+		return nil, status, err
+	}
+	run, err := self.runs.Load(dir)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return run, http.StatusOK, nil
+}
+
+//Subtile box whisker stats of PFGridMetricsOut.bin and FWHMGridMetricsOut.bin, cached along with the run
+func (self *Handler) Subtile(name string) (*interop.SubtileLaneStatJson, int, error) {
+	run, status, err := self.Run(name)
+	if err != nil {
+		return nil, status, err
+	}
+	self.mu.Lock()
+	cached, ok := self.subtile[name]
+	self.mu.Unlock()
+	if ok && cached.run == run {
+		return cached.stat, http.StatusOK, nil
+	}
+
+	for _, f := range []string{interop.PF_GRID_FILE, interop.FWHM_GRID_FILE} {
+		if _, err := os.Stat(filepath.Join(run.RunFolder, interop.INTEROP_DIR, f)); err != nil {
+			return nil, http.StatusNotFound, fmt.Errorf("run %s has no %s", name, f)
+		}
+	}
+	info, err := run.Subtile()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	ret := info.SubtileLaneStat.ToJson()
+	self.mu.Lock()
+	self.subtile[name] = &cachedSubtile{run: run, stat: ret}
+	self.mu.Unlock()
+	return ret, http.StatusOK, nil
+}
+
+//intParam empty is 0
+func intParam(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJson(w http.ResponseWriter, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	b, _ := json.Marshal(&errorResponse{Error: err.Error()})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
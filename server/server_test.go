@@ -0,0 +1,155 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testRunInfo = `<?xml version="1.0"?>
+<RunInfo>
+  <Run Id="131220_SN1_0001_AH7TESTXX" Number="1">
+    <Flowcell>H7TESTXX</Flowcell>
+    <Instrument>SN1</Instrument>
+    <Date>131220</Date>
+    <Reads>
+      <Read Number="1" NumCycles="60" IsIndexedRead="N" />
+      <Read Number="2" NumCycles="6" IsIndexedRead="Y" />
+      <Read Number="3" NumCycles="60" IsIndexedRead="N" />
+    </Reads>
+    <FlowcellLayout LaneCount="8" SurfaceCount="2" SwathCount="3" TileCount="16" />
+  </Run>
+</RunInfo>`
+
+//makeRoot a root folder holding one run folder built from test_data
+func makeRoot(t *testing.T, runName string) string {
+	root, err := ioutil.TempDir("", "interop-server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(root, runName)
+	for _, sub := range []string{"InterOp", "Data"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "RunInfo.xml"), []byte(testRunInfo), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join("..", "test_data", "InterOp")
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		b, err := ioutil.ReadFile(filepath.Join(src, f.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "InterOp", f.Name()), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestHandler(t *testing.T) {
+	runName := "131220_SN1_0001_AH7TESTXX"
+	root := makeRoot(t, runName)
+	defer os.RemoveAll(root)
+
+	h := NewHandler(root)
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		path   string
+		status int
+	}{
+		{"/runs", http.StatusOK},
+		{"/runs/" + runName + "/summary", http.StatusOK},
+		{"/runs/" + runName + "/index-summary", http.StatusOK},
+		{"/runs/" + runName + "/heatmap/density", http.StatusOK},
+		{"/runs/" + runName + "/heatmap/error_rate?cycle=1", http.StatusOK},
+		{"/runs/" + runName + "/heatmap/nope", http.StatusBadRequest},
+		{"/runs/" + runName + "/heatmap/error_rate?cycle=x", http.StatusBadRequest},
+		{"/runs/" + runName + "/bycycle/intensity", http.StatusOK},
+		{"/runs/" + runName + "/bycycle/pct_q30", http.StatusNotFound},
+		{"/runs/" + runName + "/qhist", http.StatusNotFound},
+		{"/runs/" + runName + "/subtile", http.StatusNotFound},
+		{"/runs/missing/summary", http.StatusNotFound},
+		{"/runs/../summary", http.StatusBadRequest},
+		{"/runs/%2e%2e/summary", http.StatusBadRequest},
+		{"/other", http.StatusNotFound},
+	} {
+		resp, err := http.Get(ts.URL + tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s status %d, expect %d: %s", tc.path, resp.StatusCode, tc.status, body)
+		}
+		if !json.Valid(body) {
+			t.Errorf("%s returned invalid json %s", tc.path, body)
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/runs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs := []*RunEntry{}
+	err = json.NewDecoder(resp.Body).Decode(&runs)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Name != runName || runs[0].Flowcell == nil || runs[0].Flowcell.RunId != runName {
+		b, _ := json.Marshal(runs)
+		t.Fatalf("unexpected run list %s", b)
+	}
+
+	//cached until a file of the run changes
+	first, _, err := h.Run(runName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _, _ := h.Run(runName)
+	if again != first {
+		t.Error("expect the cached run")
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(root, runName, "InterOp", "TileMetricsOut.bin"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, _, _ := h.Run(runName)
+	if reloaded == first {
+		t.Error("expect a reload after the mtime change")
+	}
+
+	//listing runs forgets folders gone from root, along with their subtile stats
+	h.subtile[runName] = &cachedSubtile{run: reloaded}
+	moved := filepath.Join(root, "moved")
+	if err := os.Rename(filepath.Join(root, runName), moved); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Runs(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := h.subtile[runName]; ok {
+		t.Error("subtile stats of a moved run kept")
+	}
+	if err := os.Rename(moved, filepath.Join(root, runName)); err != nil {
+		t.Fatal(err)
+	}
+	if back, _, _ := h.Run(runName); back == reloaded {
+		t.Error("expect the moved run dropped from the run cache")
+	}
+}
@@ -0,0 +1,150 @@
+package interop
+
+//stats.go small statistics helpers shared by analyses
+
+import (
+	"math"
+)
+
+var (
+	SIGNIFICANCE_ALPHA = 0.05
+)
+
+type TTest struct {
+	T      float64
+	DF     float64 //Welch-Satterthwaite degrees of freedom
+	PValue float64 //two sided
+}
+
+//dropNaN values that have data
+func dropNaN(arr []float64) []float64 {
+	ret := make([]float64, 0, len(arr))
+	for _, v := range arr {
+		if !math.IsNaN(v) {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+//SampleStat mean and sample(n-1) variance; NaN values are skipped
+func SampleStat(arr []float64) (mean, variance float64) {
+	arr = dropNaN(arr)
+	n := len(arr)
+	if n == 0 {
+		return
+	}
+	for _, v := range arr {
+		mean += v
+	}
+	mean /= float64(n)
+	if n < 2 {
+		return
+	}
+	for _, v := range arr {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(n - 1)
+	return
+}
+
+//WelchTTest compare means of two samples without assuming equal variance; nil when either side has fewer than two values besides NaN
+func WelchTTest(a, b []float64) *TTest {
+	a, b = dropNaN(a), dropNaN(b)
+	if len(a) < 2 || len(b) < 2 {
+		return nil
+	}
+	meanA, varA := SampleStat(a)
+	meanB, varB := SampleStat(b)
+	na, nb := float64(len(a)), float64(len(b))
+	sa, sb := varA/na, varB/nb
+	se := sa + sb
+	ret := new(TTest)
+	if se == 0 {
+		ret.DF = na + nb - 2
+		ret.PValue = 1
+		if meanA != meanB {
+			ret.T = math.Inf(1)
+			ret.PValue = 0
+		}
+		return ret
+	}
+	ret.T = (meanB - meanA) / math.Sqrt(se)
+	ret.DF = se * se / (sa*sa/(na-1) + sb*sb/(nb-1))
+	ret.PValue = StudentTwoSidedP(ret.T, ret.DF)
+	return ret
+}
+
+//StudentTwoSidedP P(|T| >= |t|) for Student's t with df degrees of freedom
+func StudentTwoSidedP(t, df float64) float64 {
+	if math.IsInf(t, 0) {
+		return 0
+	}
+	return RegIncBeta(df/(df+t*t), df/2, 0.5)
+}
+
+//RegIncBeta regularized incomplete beta function I_x(a, b)
+func RegIncBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	//continued fraction converges fast on this side, use symmetry otherwise
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(x, a, b) / a
+	}
+	return 1 - front*betaContinuedFraction(1-x, b, a)/b
+}
+
+//betaContinuedFraction modified Lentz's method
+func betaContinuedFraction(x, a, b float64) float64 {
+	const (
+		maxIter = 300
+		eps     = 1e-14
+		tiny    = 1e-300
+	)
+	qab, qap, qam := a+b, a+1, a-1
+	c, d := 1., 1-qab*x/qap
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= maxIter; m++ {
+		fm := float64(m)
+		m2 := 2 * fm
+		aa := fm * (b - fm) * x / ((qam + m2) * (a + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+		aa = -(a + fm) * (qab + fm) * x / ((a + m2) * (qap + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < eps {
+			break
+		}
+	}
+	return h
+}
@@ -549,3 +549,31 @@ func (self *BinStatMap) ToJson() *BinStatJson {
 	}
 	return ret
 }
+
+var (
+	PF_GRID_FILE      = "PFGridMetricsOut.bin"
+	FWHM_GRID_FILE    = "FWHMGridMetricsOut.bin"
+	REGISTRATION_FILE = "RegistrationMetricsOut.bin"
+)
+
+//Subtile parse the grid metrics of the run and build every box whisker stat; the files are big so this is not part of LoadRun
+func (self *Run) Subtile() (*SubtileInfo, error) {
+	for _, f := range []string{PF_GRID_FILE, FWHM_GRID_FILE} {
+		if _, err := self.stat(INTEROP_DIR, f); err != nil {
+			return nil, fmt.Errorf("run %s has no %s", self.Name(), f)
+		}
+	}
+	ret := new(SubtileInfo)
+	ret.PFInfo = &PFMetricsInfo{Filename: self.path(INTEROP_DIR, PF_GRID_FILE), fsys: self.fsys}
+	ret.FwhmInfo = &FwhmMetricsInfo{Filename: self.path(INTEROP_DIR, FWHM_GRID_FILE), fsys: self.fsys}
+	if err := ret.PFInfo.ParseFast(); err != nil {
+		return nil, fmt.Errorf("parse %s err:%s", PF_GRID_FILE, err.Error())
+	}
+	if err := ret.FwhmInfo.Parse(); err != nil {
+		return nil, fmt.Errorf("parse %s err:%s", FWHM_GRID_FILE, err.Error())
+	}
+	if err := ret.MakeBoxStat(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,604 @@
+package interop
+
+//summary.go per read and lane summary as shown by SAV's Summary tab
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+)
+
+var (
+	SUMMARY_DENSITY      = "density"      //k/mm2
+	SUMMARY_DENSITY_PF   = "density_pf"   //k/mm2
+	SUMMARY_CLUSTERS     = "clusters"     //raw clusters
+	SUMMARY_CLUSTERS_PF  = "clusters_pf"  //PF clusters
+	SUMMARY_PCT_PF       = "pct_pf"       //PF clusters / raw clusters %
+	SUMMARY_PHASING      = "phasing"      //%
+	SUMMARY_PREPHASING   = "prephasing"   //%
+	SUMMARY_PCT_ALIGNED  = "pct_aligned"  //% aligned to PhiX
+	SUMMARY_ERROR_RATE   = "error_rate"   //%
+	SUMMARY_PCT_Q30      = "pct_q30"      //% >=Q30
+	SUMMARY_YIELD_GB     = "yield_gb"     //Gb
+	SUMMARY_INTENSITY_C1 = "intensity_c1" //brightest channel at the first cycle of a read
+
+	//SummaryMetricNames fixed output order
+	SummaryMetricNames = []string{
+		SUMMARY_DENSITY,
+		SUMMARY_DENSITY_PF,
+		SUMMARY_CLUSTERS,
+		SUMMARY_CLUSTERS_PF,
+		SUMMARY_PCT_PF,
+		SUMMARY_PHASING,
+		SUMMARY_PREPHASING,
+		SUMMARY_PCT_ALIGNED,
+		SUMMARY_ERROR_RATE,
+		SUMMARY_PCT_Q30,
+		SUMMARY_YIELD_GB,
+		SUMMARY_INTENSITY_C1,
+	}
+)
+
+type MetricValue struct {
+	Name  string
+	Value float64
+}
+
+//LaneSummary values of one lane in one read; means are over tiles unless noted.
+//Values no tile has data for stay zero and are left out of JSON.
+type LaneSummary struct {
+	LaneNum          uint16
+	TileCount        int
+	Density          float64
+	DensityStdev     float64
+	DensityPF        float64
+	DensityPFStdev   float64
+	Clusters         float64 //sum over tiles
+	ClustersPF       float64 //sum over tiles
+	PctPF            float64
+	PctPFStdev       float64
+	Phasing          float64 `json:",omitempty"`
+	Prephasing       float64 `json:",omitempty"`
+	PctAligned       float64 `json:",omitempty"`
+	PctAlignedStdev  float64 `json:",omitempty"`
+	ErrorRate        float64 `json:",omitempty"`
+	ErrorRateStdev   float64 `json:",omitempty"`
+	PctQ30           float64 `json:",omitempty"` //cluster weighted
+	Yield            float64 //Gb, sum over tiles
+	IntensityC1      float64 `json:",omitempty"`
+	IntensityC1Stdev float64 `json:",omitempty"`
+
+	TileValues map[string][]float64 `json:"-"` //metric name -> per tile values, for tile level statistics
+	q30Above   uint64
+	q30Total   uint64
+}
+
+type SummaryTotal struct {
+	Yield       float64
+	PctQ30      float64 `json:",omitempty"`
+	PctAligned  float64 `json:",omitempty"`
+	ErrorRate   float64 `json:",omitempty"`
+	IntensityC1 float64 `json:",omitempty"`
+	q30Above    uint64
+	q30Total    uint64
+}
+
+type ReadSummary struct {
+	ReadNum       int
+	IsIndexedRead bool
+	FirstCycle    int
+	LastCycle     int
+	Lanes         []*LaneSummary
+	SummaryTotal
+}
+
+type RunSummary struct {
+	RunId         string
+	CurrentCycle  int
+	PlannedCycles int
+	Reads         []*ReadSummary
+	NonIndexTotal *SummaryTotal
+	Total         *SummaryTotal
+}
+
+//Value look up a summary value by metric name
+func (self *LaneSummary) Value(name string) float64 {
+	switch name {
+	case SUMMARY_DENSITY:
+		return self.Density
+	case SUMMARY_DENSITY_PF:
+		return self.DensityPF
+	case SUMMARY_CLUSTERS:
+		return self.Clusters
+	case SUMMARY_CLUSTERS_PF:
+		return self.ClustersPF
+	case SUMMARY_PCT_PF:
+		return self.PctPF
+	case SUMMARY_PHASING:
+		return self.Phasing
+	case SUMMARY_PREPHASING:
+		return self.Prephasing
+	case SUMMARY_PCT_ALIGNED:
+		return self.PctAligned
+	case SUMMARY_ERROR_RATE:
+		return self.ErrorRate
+	case SUMMARY_PCT_Q30:
+		return self.PctQ30
+	case SUMMARY_YIELD_GB:
+		return self.Yield
+	case SUMMARY_INTENSITY_C1:
+		return self.IntensityC1
+	}
+	return 0
+}
+
+//Metrics values that have data, in SummaryMetricNames order
+func (self *LaneSummary) Metrics() []MetricValue {
+	ret := []MetricValue{}
+	for _, name := range SummaryMetricNames {
+		if len(self.TileValues[name]) == 0 {
+			continue
+		}
+		ret = append(ret, MetricValue{Name: name, Value: self.Value(name)})
+	}
+	return ret
+}
+
+func (self *ReadSummary) GetLane(laneNum uint16) *LaneSummary {
+	for _, ls := range self.Lanes {
+		if ls.LaneNum == laneNum {
+			return ls
+		}
+	}
+	return nil
+}
+
+//tileKey lane and 32 bit tile number, wide enough for every file version
+type tileKey struct {
+	LaneNum uint16
+	TileNum uint32
+}
+
+type tileValues map[tileKey]float64
+
+//put v of a tile; NaN, as RTA writes for % aligned and phasing of tiles without PhiX, is no data
+func (self tileValues) put(laneNum uint16, tileNum uint32, v float64) {
+	if math.IsNaN(v) {
+		return
+	}
+	self[tileKey{laneNum, tileNum}] = v
+}
+
+//sortedKeys by lane then tile, keeps float sums reproducible
+func (self tileValues) sortedKeys() []tileKey {
+	ret := make([]tileKey, 0, len(self))
+	for k := range self {
+		ret = append(ret, k)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].LaneNum != ret[j].LaneNum {
+			return ret[i].LaneNum < ret[j].LaneNum
+		}
+		return ret[i].TileNum < ret[j].TileNum
+	})
+	return ret
+}
+
+type tileCounter struct {
+	sum map[tileKey]float64
+	cnt map[tileKey]float64
+}
+
+func newTileCounter() *tileCounter {
+	return &tileCounter{sum: make(map[tileKey]float64), cnt: make(map[tileKey]float64)}
+}
+
+func (self *tileCounter) add(laneNum uint16, tileNum uint32, v, w float64) {
+	if math.IsNaN(v) {
+		return
+	}
+	k := tileKey{laneNum, tileNum}
+	self.sum[k] += v
+	self.cnt[k] += w
+}
+
+func (self *tileCounter) values(scale float64) tileValues {
+	ret := make(tileValues)
+	for k, v := range self.sum {
+		if self.cnt[k] == 0 {
+			continue
+		}
+		ret[k] = scale * v / self.cnt[k]
+	}
+	return ret
+}
+
+//readTileMetrics every per tile value of a read
+type readTileMetrics struct {
+	values   map[string]tileValues
+	q30Above map[tileKey]uint64
+	q30Total map[tileKey]uint64
+}
+
+func inCycles(cycle uint16, firstLast []uint16) bool {
+	return cycle >= firstLast[0] && cycle <= firstLast[1]
+}
+
+//CurrentCycle last cycle with Q metrics, or extraction metrics when Q metrics are missing
+func (self *Run) CurrentCycle() int {
+	ret := 0
+	if self.Q != nil {
+		self.Q.EachRecord(func(laneNum uint16, tileNum uint32, cycle uint16, numClusters *[50]uint32) {
+			if int(cycle) > ret {
+				ret = int(cycle)
+			}
+		})
+	}
+	if ret == 0 && self.Extraction != nil {
+		self.Extraction.EachIntensity(func(laneNum uint16, tileNum uint32, cycle uint16, intensity []uint16) {
+			if int(cycle) > ret {
+				ret = int(cycle)
+			}
+		})
+	}
+	return ret
+}
+
+//clusterTileValues per tile, read independent density and cluster counts
+func (self *Run) clusterTileValues() map[string]tileValues {
+	common := map[string]tileValues{}
+	for _, name := range []string{SUMMARY_DENSITY, SUMMARY_DENSITY_PF, SUMMARY_CLUSTERS, SUMMARY_CLUSTERS_PF, SUMMARY_PCT_PF} {
+		common[name] = make(tileValues)
+	}
+	if self.Tile != nil {
+		raw, pf := self.Tile.ClustersByTile()
+		for ln, tiles := range raw {
+			for tn, v := range tiles {
+				common[SUMMARY_CLUSTERS].put(ln, tn, v)
+				if p, ok := pf[ln][tn]; ok && v > 0 {
+					common[SUMMARY_PCT_PF].put(ln, tn, 100.*p/v)
+				}
+			}
+		}
+		for ln, tiles := range pf {
+			for tn, v := range tiles {
+				common[SUMMARY_CLUSTERS_PF].put(ln, tn, v)
+			}
+		}
+		for _, cv := range self.Tile.Metrics {
+			switch cv.MetricCode {
+			case CLUSTER_DENSITY:
+				common[SUMMARY_DENSITY].put(cv.LaneNum, uint32(cv.TileNum), float64(cv.MetricValue)/1000.)
+			case CLUSTER_DENSITY_PF:
+				common[SUMMARY_DENSITY_PF].put(cv.LaneNum, uint32(cv.TileNum), float64(cv.MetricValue)/1000.)
+			}
+		}
+		if self.Tile.AreaSize > 0 {
+			for ln, tiles := range raw {
+				for tn, v := range tiles {
+					common[SUMMARY_DENSITY].put(ln, tn, v/float64(self.Tile.AreaSize)/1000.)
+				}
+			}
+			for ln, tiles := range pf {
+				for tn, v := range tiles {
+					common[SUMMARY_DENSITY_PF].put(ln, tn, v/float64(self.Tile.AreaSize)/1000.)
+				}
+			}
+		}
+	}
+	return common
+}
+
+//Summary compute read and lane summary from whatever metrics the run has; empty without RunInfo, as read from a dump text
+func (self *Run) Summary() *RunSummary {
+	if self.RunInfo == nil {
+		return &RunSummary{RunId: self.Name(), NonIndexTotal: new(SummaryTotal), Total: new(SummaryTotal)}
+	}
+	ret := &RunSummary{
+		RunId:         self.Name(),
+		PlannedCycles: self.RunInfo.GetNumCycles(),
+	}
+
+	common := self.clusterTileValues()
+	ret.CurrentCycle = self.CurrentCycle()
+
+	nonIndex, total := new(SummaryTotal), new(SummaryTotal)
+	nonIndexTiles, allTiles := map[string][]float64{}, map[string][]float64{}
+	for i, r := range self.RunInfo.Run.Reads {
+		readNum := i + 1
+		firstLast := self.RunInfo.GetFirstLastCyclesByRead(readNum)
+		rs := &ReadSummary{
+			ReadNum:       readNum,
+			IsIndexedRead: r.IsIndexedRead == "Y",
+			FirstCycle:    int(firstLast[0]),
+			LastCycle:     int(firstLast[1]),
+		}
+		ret.Reads = append(ret.Reads, rs)
+
+		//cycles of this read already sequenced
+		cyclesDone := 0
+		if ret.CurrentCycle >= rs.FirstCycle {
+			cyclesDone = int(math.Min(float64(ret.CurrentCycle), float64(rs.LastCycle))) - rs.FirstCycle + 1
+		}
+		rtm := self.readTileMetrics(readNum, firstLast, common, cyclesDone)
+		rs.Lanes = summarizeLanes(rtm)
+
+		readTiles := map[string][]float64{}
+		for _, ls := range rs.Lanes {
+			rs.Yield += ls.Yield
+			rs.q30Above += ls.q30Above
+			rs.q30Total += ls.q30Total
+			for name, arr := range ls.TileValues {
+				readTiles[name] = append(readTiles[name], arr...)
+			}
+		}
+		rs.SummaryTotal.finish(readTiles)
+		for name, arr := range readTiles {
+			allTiles[name] = append(allTiles[name], arr...)
+			if !rs.IsIndexedRead {
+				nonIndexTiles[name] = append(nonIndexTiles[name], arr...)
+			}
+		}
+		total.add(&rs.SummaryTotal)
+		if !rs.IsIndexedRead {
+			nonIndex.add(&rs.SummaryTotal)
+		}
+	}
+	nonIndex.finish(nonIndexTiles)
+	total.finish(allTiles)
+	ret.NonIndexTotal = nonIndex
+	ret.Total = total
+	return ret
+}
+
+func (self *SummaryTotal) add(other *SummaryTotal) {
+	self.Yield += other.Yield
+	self.q30Above += other.q30Above
+	self.q30Total += other.q30Total
+}
+
+//finish fill the means of a read or run total from the pooled tile values
+func (self *SummaryTotal) finish(tiles map[string][]float64) {
+	if self.q30Total > 0 {
+		self.PctQ30 = 100. * float64(self.q30Above) / float64(self.q30Total)
+	}
+	mean := func(name string) float64 {
+		arr := tiles[name]
+		m, _ := MeanStat(&arr)
+		return m
+	}
+	self.PctAligned = mean(SUMMARY_PCT_ALIGNED)
+	self.ErrorRate = mean(SUMMARY_ERROR_RATE)
+	self.IntensityC1 = mean(SUMMARY_INTENSITY_C1)
+}
+
+func (self *Run) readTileMetrics(readNum int, firstLast []uint16, common map[string]tileValues, cyclesDone int) *readTileMetrics {
+	ret := &readTileMetrics{
+		values:   map[string]tileValues{},
+		q30Above: map[tileKey]uint64{},
+		q30Total: map[tileKey]uint64{},
+	}
+	for name, tv := range common {
+		ret.values[name] = tv
+	}
+	for _, name := range []string{SUMMARY_PHASING, SUMMARY_PREPHASING, SUMMARY_PCT_ALIGNED, SUMMARY_YIELD_GB} {
+		ret.values[name] = make(tileValues)
+	}
+
+	if self.Tile != nil {
+		codes := ReadNumToCode(uint16(readNum))
+		for _, cv := range self.Tile.Metrics {
+			switch cv.MetricCode {
+			case codes.Phasing:
+				ret.values[SUMMARY_PHASING].put(cv.LaneNum, uint32(cv.TileNum), 100.*float64(cv.MetricValue))
+			case codes.PrePhasing:
+				ret.values[SUMMARY_PREPHASING].put(cv.LaneNum, uint32(cv.TileNum), 100.*float64(cv.MetricValue))
+			case codes.PercentAligned:
+				ret.values[SUMMARY_PCT_ALIGNED].put(cv.LaneNum, uint32(cv.TileNum), float64(cv.MetricValue))
+			}
+		}
+		for _, cv := range self.Tile.Metrics3 {
+			if cv.MetricCode == 'r' && int(cv.NumberRead) == readNum {
+				ret.values[SUMMARY_PCT_ALIGNED].put(cv.LaneNum, cv.TileNum, float64(cv.PctAligned))
+			}
+		}
+		if cyclesDone > 0 {
+			for k, v := range common[SUMMARY_CLUSTERS_PF] {
+				ret.values[SUMMARY_YIELD_GB][k] = v * float64(cyclesDone) / 1e9
+			}
+		}
+	}
+
+	if self.Error != nil {
+		errs := newTileCounter()
+		self.Error.EachRecord(func(laneNum uint16, tileNum uint32, cycle uint16, errorRate float32) {
+			if laneNum == 0 || !inCycles(cycle, firstLast) {
+				return
+			}
+			errs.add(laneNum, tileNum, float64(errorRate), 1)
+		})
+		ret.values[SUMMARY_ERROR_RATE] = errs.values(1)
+	}
+
+	if self.Q != nil {
+		self.Q.EachRecord(func(laneNum uint16, tileNum uint32, cycle uint16, numClusters *[50]uint32) {
+			if laneNum == 0 || !inCycles(cycle, firstLast) {
+				return
+			}
+			above, total := QscoreAbove(numClusters, Q30)
+			k := tileKey{laneNum, tileNum}
+			ret.q30Above[k] += above
+			ret.q30Total[k] += total
+		})
+		q30 := make(tileValues)
+		for k, t := range ret.q30Total {
+			if t > 0 {
+				q30[k] = 100. * float64(ret.q30Above[k]) / float64(t)
+			}
+		}
+		ret.values[SUMMARY_PCT_Q30] = q30
+	}
+
+	if self.Extraction != nil {
+		c1 := make(tileValues)
+		self.Extraction.EachIntensity(func(laneNum uint16, tileNum uint32, cycle uint16, intensity []uint16) {
+			if laneNum == 0 || cycle != firstLast[0] {
+				return
+			}
+			c1.put(laneNum, tileNum, float64(maxUint16(intensity)))
+		})
+		ret.values[SUMMARY_INTENSITY_C1] = c1
+	}
+	return ret
+}
+
+func summarizeLanes(rtm *readTileMetrics) []*LaneSummary {
+	lanes := map[uint16]*LaneSummary{}
+	get := func(laneNum uint16) *LaneSummary {
+		if ls, ok := lanes[laneNum]; ok {
+			return ls
+		}
+		ls := &LaneSummary{LaneNum: laneNum, TileValues: map[string][]float64{}}
+		lanes[laneNum] = ls
+		return ls
+	}
+	tiles := map[uint16]map[uint32]bool{}
+	for _, name := range SummaryMetricNames {
+		for _, k := range rtm.values[name].sortedKeys() {
+			v := rtm.values[name][k]
+			if k.LaneNum == 0 {
+				continue
+			}
+			ls := get(k.LaneNum)
+			ls.TileValues[name] = append(ls.TileValues[name], v)
+			if _, ok := tiles[k.LaneNum]; !ok {
+				tiles[k.LaneNum] = map[uint32]bool{}
+			}
+			tiles[k.LaneNum][k.TileNum] = true
+		}
+	}
+	for k, t := range rtm.q30Total {
+		if k.LaneNum == 0 {
+			continue
+		}
+		ls := get(k.LaneNum)
+		ls.q30Above += rtm.q30Above[k]
+		ls.q30Total += t
+	}
+
+	ret := []*LaneSummary{}
+	for _, ln := range sortedLaneKeys(lanes) {
+		ls := lanes[ln]
+		ls.TileCount = len(tiles[ln])
+		stat := func(name string) (float64, float64) {
+			arr := ls.TileValues[name]
+			return MeanStat(&arr)
+		}
+		sum := func(name string) float64 {
+			s := float64(0)
+			for _, v := range ls.TileValues[name] {
+				s += v
+			}
+			return s
+		}
+		ls.Density, ls.DensityStdev = stat(SUMMARY_DENSITY)
+		ls.DensityPF, ls.DensityPFStdev = stat(SUMMARY_DENSITY_PF)
+		ls.Clusters = sum(SUMMARY_CLUSTERS)
+		ls.ClustersPF = sum(SUMMARY_CLUSTERS_PF)
+		ls.PctPF, ls.PctPFStdev = stat(SUMMARY_PCT_PF)
+		ls.Phasing, _ = stat(SUMMARY_PHASING)
+		ls.Prephasing, _ = stat(SUMMARY_PREPHASING)
+		ls.PctAligned, ls.PctAlignedStdev = stat(SUMMARY_PCT_ALIGNED)
+		ls.ErrorRate, ls.ErrorRateStdev = stat(SUMMARY_ERROR_RATE)
+		ls.Yield = sum(SUMMARY_YIELD_GB)
+		ls.IntensityC1, ls.IntensityC1Stdev = stat(SUMMARY_INTENSITY_C1)
+		if ls.q30Total > 0 {
+			ls.PctQ30 = 100. * float64(ls.q30Above) / float64(ls.q30Total)
+		}
+		ret = append(ret, ls)
+	}
+	return ret
+}
+
+func sortedLaneKeys(m map[uint16]*LaneSummary) []uint16 {
+	ret := []uint16{}
+	for ln := range m {
+		ret = append(ret, ln)
+	}
+	sortUint16s(ret)
+	return ret
+}
+
+func sortUint16s(arr []uint16) {
+	sort.Slice(arr, func(i, j int) bool { return arr[i] < arr[j] })
+}
+
+//Metrics totals that have data
+func (self *SummaryTotal) Metrics() []MetricValue {
+	ret := []MetricValue{{Name: SUMMARY_YIELD_GB, Value: self.Yield}}
+	if self.q30Total > 0 {
+		ret = append(ret, MetricValue{Name: SUMMARY_PCT_Q30, Value: self.PctQ30})
+	}
+	if self.PctAligned > 0 {
+		ret = append(ret, MetricValue{Name: SUMMARY_PCT_ALIGNED, Value: self.PctAligned})
+	}
+	if self.ErrorRate > 0 {
+		ret = append(ret, MetricValue{Name: SUMMARY_ERROR_RATE, Value: self.ErrorRate})
+	}
+	if self.IntensityC1 > 0 {
+		ret = append(ret, MetricValue{Name: SUMMARY_INTENSITY_C1, Value: self.IntensityC1})
+	}
+	return ret
+}
+
+//TileValues pooled tile values of every lane of the read
+func (self *ReadSummary) TileValues(name string) []float64 {
+	ret := []float64{}
+	for _, ls := range self.Lanes {
+		ret = append(ret, ls.TileValues[name]...)
+	}
+	return ret
+}
+
+//WriteTable SAV like text: run totals, then one block of lanes per read
+func (self *RunSummary) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Run: %s\tCycle %d of %d\t\n\n", self.RunId, self.CurrentCycle, self.PlannedCycles)
+	fmt.Fprintln(tw, "Level\tYield(Gb)\t%>=Q30\t%Aligned\tError Rate\tIntensity C1\t")
+	for _, rs := range self.Reads {
+		name := fmt.Sprintf("Read %d", rs.ReadNum)
+		if rs.IsIndexedRead {
+			name += " (I)"
+		}
+		writeSummaryTotal(tw, name, &rs.SummaryTotal)
+	}
+	writeSummaryTotal(tw, "Non-indexed", self.NonIndexTotal)
+	writeSummaryTotal(tw, "Total", self.Total)
+
+	for _, rs := range self.Reads {
+		fmt.Fprintf(tw, "\nRead %d\t\n", rs.ReadNum)
+		fmt.Fprintln(tw, "Lane\tTiles\tDensity(k/mm2)\tCluster PF(%)\tPhas/Prephas(%)\tReads(M)\tReads PF(M)\t%>=Q30\tYield(Gb)\tAligned(%)\tError Rate(%)\tIntensity C1\t")
+		for _, ls := range rs.Lanes {
+			fmt.Fprintf(tw, "%d\t%d\t%.0f +/- %.0f\t%.2f +/- %.2f\t%.3f / %.3f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f +/- %.2f\t%.2f +/- %.2f\t%.0f +/- %.0f\t\n",
+				ls.LaneNum, ls.TileCount,
+				ls.Density, ls.DensityStdev,
+				ls.PctPF, ls.PctPFStdev,
+				ls.Phasing, ls.Prephasing,
+				ls.Clusters/1e6, ls.ClustersPF/1e6,
+				ls.PctQ30, ls.Yield,
+				ls.PctAligned, ls.PctAlignedStdev,
+				ls.ErrorRate, ls.ErrorRateStdev,
+				ls.IntensityC1, ls.IntensityC1Stdev)
+		}
+	}
+	return tw.Flush()
+}
+
+func writeSummaryTotal(w io.Writer, name string, t *SummaryTotal) {
+	if t == nil {
+		return
+	}
+	fmt.Fprintf(w, "%s\t%.2f\t%.2f\t%.2f\t%.2f\t%.0f\t\n", name, t.Yield, t.PctQ30, t.PctAligned, t.ErrorRate, t.IntensityC1)
+}
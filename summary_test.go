@@ -0,0 +1,67 @@
+package interop
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ws6/interop/fcinfo"
+)
+
+//TestSummaryNaNTile RTA writes NaN % aligned and phasing for tiles without PhiX; those are no data, not NaN means
+func TestSummaryNaNTile(t *testing.T) {
+	runInfo, err := fcinfo.ParseRunInfoXML(testRunInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nan := float32(math.NaN())
+	tile := &TileInfo{Version: 2}
+	for _, m := range []struct {
+		tileNum uint16
+		code    uint16
+		value   float32
+	}{
+		{1101, CLUSTER_DENSITY, 200000}, {1101, NUMBER_CLUSTER, 1e6}, {1101, NUMBER_CLUSTER_PF, 8e5},
+		{1101, 200, 0.001}, {1101, 300, 1.5}, {1101, 302, nan},
+		{1102, CLUSTER_DENSITY, 220000}, {1102, NUMBER_CLUSTER, 1.1e6}, {1102, NUMBER_CLUSTER_PF, 9e5},
+		{1102, 200, nan}, {1102, 300, nan}, {1102, 302, nan},
+	} {
+		tile.Metrics = append(tile.Metrics, &TileMetrics{LaneNum: 1, TileNum: m.tileNum, MetricCode: m.code, MetricValue: m.value})
+	}
+	run := &Run{RunFolder: "nan", RunInfo: runInfo, Tile: tile, Error: &ErrorInfo{Version: 4, Metrics4: []*ErrorMetrics4{
+		{LaneNum: 1, TileNum: 1101, Cycle: 1, ErrorRate: 0.5},
+		{LaneNum: 1, TileNum: 1102, Cycle: 1, ErrorRate: nan},
+	}}}
+
+	summary := run.Summary()
+	lane := summary.Reads[0].GetLane(1)
+	if lane.PctAligned != 1.5 || math.Abs(lane.Phasing-0.1) > 1e-6 || lane.ErrorRate != 0.5 || lane.TileCount != 2 {
+		t.Fatalf("expect tile 1101 values only, got %+v", lane)
+	}
+	if len(summary.Reads[2].GetLane(1).TileValues[SUMMARY_PCT_ALIGNED]) != 0 {
+		t.Fatalf("all NaN aligned of read 3 shall have no tile values")
+	}
+	b, err := json.Marshal(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := new(RunSummary)
+	if err := json.Unmarshal(b, back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Reads[0].Lanes[0].PctAligned != 1.5 || back.Total.ErrorRate != 0.5 {
+		t.Fatalf("values lost in JSON: %s", b)
+	}
+	var raw struct {
+		Reads []struct {
+			Lanes []map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := raw.Reads[2].Lanes[0]["PctAligned"]; ok || strings.Contains(string(b), "NaN") {
+		t.Fatalf("read 3 has no aligned data, expect it left out: %v", raw.Reads[2].Lanes[0])
+	}
+}
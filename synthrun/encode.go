@@ -0,0 +1,254 @@
+package synthrun
+
+//encode.go InterOp binaries of the drawn tiles, in the layouts the root package's parsers read
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/ws6/interop/fcinfo"
+)
+
+var (
+	FWHM = float32(2.7)
+
+	WINDOWS_TICK        = uint64(1e7)
+	SEC_SINCE_WIN_EPOCH = uint64(62135596800) //0001, Jan,1st
+	CYCLE_DURATION      = 5 * time.Minute     //extraction time between cycles
+)
+
+var le = binary.LittleEndian
+
+//put little endian values, a bytes.Buffer does not fail
+func put(buf *bytes.Buffer, values ...interface{}) {
+	for _, v := range values {
+		binary.Write(buf, le, v)
+	}
+}
+
+//winTicks time as extraction metrics store it
+func winTicks(t time.Time) uint64 {
+	return (uint64(t.Unix()) + SEC_SINCE_WIN_EPOCH) * WINDOWS_TICK
+}
+
+//interOp file content by name under InterOp/
+func (self *generator) interOp() map[string][]byte {
+	ret := map[string][]byte{
+		"TileMetricsOut.bin":       self.tileMetrics(),
+		"QMetricsOut.bin":          self.qMetrics(),
+		"ErrorMetricsOut.bin":      self.errorMetrics(),
+		"ExtractionMetricsOut.bin": self.extractionMetrics(),
+	}
+	if len(self.indexCycles()) > 0 && len(self.samples) > 0 {
+		ret["IndexMetricsOut.bin"] = self.indexMetrics()
+	}
+	return ret
+}
+
+//nonIndexCycles cycles of reads that are not index reads
+func (self *generator) nonIndexCycles() fcinfo.CycleSet {
+	ret := fcinfo.CycleSet{}
+	for i, r := range self.rs.Reads {
+		if !r.IsIndex() {
+			ret = ret.Union(self.rs.ReadCycles(i + 1))
+		}
+	}
+	return ret
+}
+
+func (self *generator) tileMetrics() []byte {
+	var buf bytes.Buffer
+	area := self.spec.Instrument.TileArea
+	if self.spec.Instrument.TileVersion == 3 {
+		put(&buf, uint8(3), uint8(15), area)
+		for _, t := range self.tiles {
+			put(&buf, uint16(t.laneNum), uint32(t.tileNum), uint8('t'), float32(t.clusters), float32(t.clustersPF))
+			for i, r := range self.rs.Reads {
+				if !r.IsIndex() {
+					put(&buf, uint16(t.laneNum), uint32(t.tileNum), uint8('r'), uint32(i+1), float32(t.pctAligned[i]))
+				}
+			}
+		}
+		return buf.Bytes()
+	}
+	put(&buf, uint8(2), uint8(10))
+	record := func(t *tileDraw, code int, value float64) {
+		put(&buf, uint16(t.laneNum), uint16(t.tileNum), uint16(code), float32(value))
+	}
+	for _, t := range self.tiles {
+		record(t, 100, t.clusters/float64(area))
+		record(t, 101, t.clustersPF/float64(area))
+		record(t, 102, t.clusters)
+		record(t, 103, t.clustersPF)
+		for i, r := range self.rs.Reads {
+			record(t, 200+2*i, t.phasing[i])
+			record(t, 201+2*i, t.prephasing[i])
+			if !r.IsIndex() {
+				record(t, 300+i, t.pctAligned[i])
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+//qCounts clusters of t at Q_HIGH and Q_LOW
+func qCounts(t *tileDraw) (high, low uint32) {
+	high = uint32(math.Round(t.clustersPF * t.pctQ30 / 100))
+	return high, uint32(t.clustersPF) - high
+}
+
+//qBinOf bin index of q, -1 when no bin holds it
+func qBinOf(bins []QBin, q int) int {
+	for i, b := range bins {
+		if int(b.Lower) <= q && q <= int(b.Upper) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (self *generator) qMetrics() []byte {
+	var buf bytes.Buffer
+	inst := self.spec.Instrument
+	version := inst.QVersion
+	bins := inst.QBins
+	ltc := 6
+	if version == 7 {
+		ltc = 8
+	}
+	numBins := 50
+	if bins != nil {
+		numBins = len(bins)
+	}
+	put(&buf, version, uint8(ltc+4*numBins))
+	if version >= 5 {
+		if bins == nil {
+			put(&buf, uint8(0))
+		} else {
+			put(&buf, uint8(1), uint8(len(bins)))
+			if version == 7 {
+				for _, b := range bins {
+					put(&buf, b.Lower, b.Upper, b.Remap)
+				}
+			} else {
+				for _, b := range bins {
+					put(&buf, b.Lower)
+				}
+				for _, b := range bins {
+					put(&buf, b.Upper)
+				}
+				for _, b := range bins {
+					put(&buf, b.Remap)
+				}
+			}
+		}
+	}
+	highBin, lowBin := Q_HIGH-1, Q_LOW-1
+	if bins != nil {
+		highBin, lowBin = qBinOf(bins, Q_HIGH), qBinOf(bins, Q_LOW)
+	}
+	for _, t := range self.tiles {
+		high, low := qCounts(t)
+		counts := make([]uint32, numBins)
+		counts[highBin] += high
+		counts[lowBin] += low
+		for cycle := 1; cycle <= t.lastCycle; cycle++ {
+			if version == 7 {
+				put(&buf, uint16(t.laneNum), uint32(t.tileNum), uint16(cycle))
+			} else {
+				put(&buf, uint16(t.laneNum), uint16(t.tileNum), uint16(cycle))
+			}
+			put(&buf, counts)
+		}
+	}
+	return buf.Bytes()
+}
+
+//errorMetrics error rates of non index cycles, in %
+func (self *generator) errorMetrics() []byte {
+	var buf bytes.Buffer
+	version := self.spec.Instrument.ErrorVersion
+	if version == 4 {
+		put(&buf, uint8(4), uint8(12))
+	} else {
+		put(&buf, uint8(3), uint8(30))
+	}
+	aligned := self.nonIndexCycles()
+	for _, t := range self.tiles {
+		for cycle := 1; cycle <= t.lastCycle; cycle++ {
+			if !aligned.Has(uint16(cycle)) {
+				continue
+			}
+			rate := float32(self.cycleErrorRate(t, cycle))
+			if version == 4 {
+				put(&buf, uint16(t.laneNum), uint32(t.tileNum), uint16(cycle), rate)
+				continue
+			}
+			put(&buf, uint16(t.laneNum), uint16(t.tileNum), uint16(cycle), rate, [5]uint32{})
+		}
+	}
+	return buf.Bytes()
+}
+
+//extractionMetrics channel i is 10% dimmer than channel i-1, the first the brightest
+func (self *generator) extractionMetrics() []byte {
+	var buf bytes.Buffer
+	channels := self.spec.Instrument.NumChannels()
+	version := self.spec.Instrument.ExtractionVersion
+	if version == 3 {
+		put(&buf, uint8(3), uint8(8+6*channels), uint8(channels))
+	} else {
+		put(&buf, uint8(2), uint8(38))
+	}
+	for _, t := range self.tiles {
+		for cycle := 1; cycle <= t.lastCycle; cycle++ {
+			brightest := self.cycleIntensity(t, cycle)
+			fwhm := make([]float32, channels)
+			intensity := make([]uint16, channels)
+			for i := range intensity {
+				fwhm[i] = FWHM
+				intensity[i] = uint16(math.Round(brightest * (1 - 0.1*float64(i))))
+			}
+			if version == 3 {
+				put(&buf, uint16(t.laneNum), uint32(t.tileNum), uint16(cycle), fwhm, intensity)
+				continue
+			}
+			put(&buf, uint16(t.laneNum), uint16(t.tileNum), uint16(cycle), fwhm, intensity,
+				winTicks(self.spec.Date.Add(time.Duration(cycle)*CYCLE_DURATION)))
+		}
+	}
+	return buf.Bytes()
+}
+
+//indexMetrics version 1, PF clusters of each sample per tile, reported on the first index read
+func (self *generator) indexMetrics() []byte {
+	var buf bytes.Buffer
+	put(&buf, uint8(1))
+	readNum := 0
+	for i, r := range self.rs.Reads {
+		if r.IsIndex() {
+			readNum = i + 1
+			break
+		}
+	}
+	str := func(s string) {
+		put(&buf, uint16(len(s)), []byte(s))
+	}
+	for _, t := range self.tiles {
+		counts := self.indexCounts(t)
+		for i, s := range self.samples {
+			indexName := s.Index
+			if s.Index2 != "" {
+				indexName += "-" + s.Index2
+			}
+			put(&buf, uint16(t.laneNum), uint16(t.tileNum), uint16(readNum))
+			str(indexName)
+			put(&buf, counts[i])
+			str(s.Id)
+			str(s.Project)
+		}
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,167 @@
+package synthrun
+
+//instrument.go what each instrument family writes: RunParameters.xml layout, RunInfo.xml details and InterOp versions
+
+import (
+	"fmt"
+)
+
+//QBin Q scores Lower to Upper are reported as Remap
+type QBin struct {
+	Lower uint8
+	Upper uint8
+	Remap uint8
+}
+
+type Instrument struct {
+	Name           string //Instrument Type in the sample sheet
+	IdPrefix       string //serial prefix, as M for MiSeq
+	Side           string //flowcell position prefixed to the barcode in run ids, empty on one flowcell instruments
+	BarcodePrefix  string
+	BarcodeSuffix  string
+	RunInfoVersion string
+	DateLayout     string //RunInfo.xml Date
+	RTAVersion     string
+	Channels       []string //RunInfo.xml ImageChannels, nil for instruments that do not list them
+	TileArea       float32  //mm2
+	Density        float64  //typical k/mm2
+
+	TileVersion       uint8
+	QVersion          uint8
+	QBins             []QBin //nil for Q scores not binned
+	ErrorVersion      uint8
+	ExtractionVersion uint8
+
+	RunParameters func(spec *Spec, params *RunParams) string
+}
+
+//BarcodeOf flowcell barcode shaped as the instrument's, around id
+func (self *Instrument) BarcodeOf(id string) string {
+	return self.BarcodePrefix + id + self.BarcodeSuffix
+}
+
+//NumChannels ImageChannels, else the four bases
+func (self *Instrument) NumChannels() int {
+	if len(self.Channels) > 0 {
+		return len(self.Channels)
+	}
+	return 4
+}
+
+var (
+	QBINS_RTA2 = []QBin{{1, 9, 2}, {10, 19, 12}, {20, 29, 23}, {30, 50, 37}}
+
+	//MISEQ RTA 1.18: tile v2, unbinned Q v5, error v3 and extraction v2
+	MISEQ = &Instrument{
+		Name:              "MiSeq",
+		IdPrefix:          "M",
+		BarcodePrefix:     "000000000-",
+		RunInfoVersion:    "2",
+		DateLayout:        "060102",
+		RTAVersion:        "1.18.54",
+		TileArea:          0.7,
+		Density:           900,
+		TileVersion:       2,
+		QVersion:          5,
+		ErrorVersion:      3,
+		ExtractionVersion: 2,
+		RunParameters: func(spec *Spec, params *RunParams) string {
+			return fmt.Sprintf(`<?xml version="1.0"?>
+<RunParameters>
+  <RunID>%s</RunID>
+  <Setup>
+    <ApplicationName>MiSeq Control Software</ApplicationName>
+    <ApplicationVersion>2.6.2.1</ApplicationVersion>
+  </Setup>
+  <RunStartDate>%s</RunStartDate>
+  <OutputFolder>%s</OutputFolder>
+  <FPGAVersion>9.5.12</FPGAVersion>
+  <RTAVersion>%s</RTAVersion>
+  <Chemistry>Amplicon</Chemistry>
+</RunParameters>
+`, params.RunId, spec.Date.Format("060102"), params.OutputFolder, spec.Instrument.RTAVersion)
+		},
+	}
+
+	//HISEQ_4000 RTA 2.7: tile v2, binned Q v6, error v3 and extraction v2
+	HISEQ_4000 = &Instrument{
+		Name:              "HiSeq4000",
+		IdPrefix:          "K",
+		Side:              "A",
+		BarcodePrefix:     "H",
+		BarcodeSuffix:     "BBXX",
+		RunInfoVersion:    "3",
+		DateLayout:        "060102",
+		RTAVersion:        "2.7.7",
+		TileArea:          1.6,
+		Density:           1200,
+		TileVersion:       2,
+		QVersion:          6,
+		QBins:             QBINS_RTA2,
+		ErrorVersion:      3,
+		ExtractionVersion: 2,
+		RunParameters: func(spec *Spec, params *RunParams) string {
+			return fmt.Sprintf(`<?xml version="1.0"?>
+<RunParameters>
+  <Setup>
+    <ApplicationName>HiSeq Control Software</ApplicationName>
+    <ApplicationVersion>3.4.0.38</ApplicationVersion>
+    <FCPosition>%s</FCPosition>
+    <OutputFolder>%s</OutputFolder>
+    <FPGAVersion>7.5.3</FPGAVersion>
+    <RTAVersion>%s</RTAVersion>
+    <ChemistryVersion>HiSeq 3000/4000 SBS</ChemistryVersion>
+    <RunID>%s</RunID>
+    <Read1>%d</Read1>
+    <IndexRead1>%d</IndexRead1>
+    <IndexRead2>%d</IndexRead2>
+    <Read2>%d</Read2>
+  </Setup>
+</RunParameters>
+`, spec.Instrument.Side, params.OutputFolder, spec.Instrument.RTAVersion, params.RunId, params.Reads[0], params.Reads[1], params.Reads[2], params.Reads[3])
+		},
+	}
+
+	//NOVASEQ RTA 3: tile v3, binned Q v7, error v4 and two channel extraction v3
+	NOVASEQ = &Instrument{
+		Name:              "NovaSeq",
+		IdPrefix:          "A",
+		Side:              "A",
+		BarcodePrefix:     "H",
+		BarcodeSuffix:     "DSXX",
+		RunInfoVersion:    "5",
+		DateLayout:        "1/2/2006 3:04:05 PM",
+		RTAVersion:        "v3.4.4",
+		Channels:          []string{"Red", "Green"},
+		TileArea:          2.7,
+		Density:           2800,
+		TileVersion:       3,
+		QVersion:          7,
+		QBins:             QBINS_RTA2,
+		ErrorVersion:      4,
+		ExtractionVersion: 3,
+		RunParameters: func(spec *Spec, params *RunParams) string {
+			return fmt.Sprintf(`<?xml version="1.0"?>
+<RunParameters>
+  <Application>NovaSeq Control Software</Application>
+  <ApplicationVersion>1.7.5</ApplicationVersion>
+  <RunId>%s</RunId>
+  <RunStartDate>%s</RunStartDate>
+  <OutputFolder>%s</OutputFolder>
+  <Side>%s</Side>
+  <RtaVersion>%s</RtaVersion>
+  <RecipeFilePath>C:\Recipes\NovaSeqS2.xml</RecipeFilePath>
+  <RfidsInfo>
+    <FlowCellMode>S2</FlowCellMode>
+  </RfidsInfo>
+  <Read1NumberOfCycles>%d</Read1NumberOfCycles>
+  <IndexRead1NumberOfCycles>%d</IndexRead1NumberOfCycles>
+  <IndexRead2NumberOfCycles>%d</IndexRead2NumberOfCycles>
+  <Read2NumberOfCycles>%d</Read2NumberOfCycles>
+</RunParameters>
+`, params.RunId, spec.Date.Format("060102"), params.OutputFolder, spec.Instrument.Side, spec.Instrument.RTAVersion, params.Reads[0], params.Reads[1], params.Reads[2], params.Reads[3])
+		},
+	}
+
+	Instruments = []*Instrument{MISEQ, HISEQ_4000, NOVASEQ}
+)
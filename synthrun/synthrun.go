@@ -0,0 +1,506 @@
+package synthrun
+
+//synthrun.go synthetic run folders for hermetic tests. A Spec tells the instrument, read structure, tile layout
+//and metric distributions; Files renders RunInfo.xml, RunParameters.xml, a sample sheet and the InterOp files
+//at the versions the instrument writes, with the Spec's anomalies injected into the metrics.
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ws6/interop/fcinfo"
+)
+
+var (
+	ANOMALY_BUBBLE         = "bubble"         //error rate spike at Cycle, Severity times the tile's rate
+	ANOMALY_BAD_TILE       = "bad tile"       //density, PF, Q30 and intensity scaled by Severity, error rate divided by it
+	ANOMALY_INTENSITY_DROP = "intensity drop" //intensity scaled by Severity from Cycle on
+	ANOMALY_MISSING_TILE   = "missing tile"   //tile left out of every InterOp file
+	ANOMALY_TILE_DROPOUT   = "tile dropout"   //tile not reported by per cycle files from Cycle on
+
+	//ANOMALY_SEVERITY used when an Anomaly leaves Severity 0
+	ANOMALY_SEVERITY = map[string]float64{
+		ANOMALY_BUBBLE:         10,
+		ANOMALY_BAD_TILE:       0.5,
+		ANOMALY_INTENSITY_DROP: 0.5,
+	}
+
+	Q_HIGH = 37 //Q score clusters above Q30 are put at
+	Q_LOW  = 23 //Q score the other clusters are put at
+
+	INDEX_BASES = "ACGT"
+)
+
+//Distribution normal, values drawn below 0 are taken as 0
+type Distribution struct {
+	Mean  float64
+	Stdev float64
+}
+
+func (self Distribution) draw(rng *rand.Rand) float64 {
+	return math.Max(0, self.Mean+self.Stdev*rng.NormFloat64())
+}
+
+//Anomaly LaneNum 0 is every lane, TileNum 0 every tile of the lane
+type Anomaly struct {
+	Kind     string
+	LaneNum  int
+	TileNum  int
+	Cycle    int     //cycle of a bubble, first cycle of an intensity drop or tile dropout
+	Severity float64 //0 takes ANOMALY_SEVERITY
+}
+
+func (self *Anomaly) hits(laneNum, tileNum int) bool {
+	return (self.LaneNum == 0 || self.LaneNum == laneNum) && (self.TileNum == 0 || self.TileNum == tileNum)
+}
+
+func (self *Anomaly) severity() float64 {
+	if self.Severity != 0 {
+		return self.Severity
+	}
+	return ANOMALY_SEVERITY[self.Kind]
+}
+
+//Sample Index and Index2 are drawn at random when left empty; Share 0 splits clusters evenly
+type Sample struct {
+	Id      string
+	Project string
+	Index   string
+	Index2  string
+	Share   float64
+}
+
+type Spec struct {
+	Instrument      *Instrument
+	InstrumentId    string //serial in the run id, as M00123
+	RunNumber       int
+	Date            time.Time
+	FlowcellBarcode string
+	ReadStructure   string //OverrideCycles value, as "Y151;I8;I8;Y151"
+
+	Lanes         int
+	Surfaces      int
+	Swaths        int
+	TilesPerSwath int //up to 99, tiles are numbered surface, swath and two tile digits
+
+	Samples      []*Sample
+	Undetermined float64 //fraction of PF clusters no sample gets
+
+	Density        Distribution //k/mm2, per tile
+	PctPF          Distribution //per tile
+	Phasing        Distribution //%, per tile and read
+	Prephasing     Distribution //%, per tile and read
+	PctAligned     Distribution //per tile and non index read
+	ErrorRate      Distribution //%, per tile, then per cycle with CycleNoise
+	PctQ30         Distribution //per tile
+	Intensity      Distribution //first cycle, per tile
+	IntensityDecay float64      //fraction of intensity lost each cycle
+	CycleNoise     float64      //relative stdev of per cycle error rates and intensities
+
+	Anomalies []*Anomaly
+	Seed      int64
+}
+
+//DefaultSpec a clean two lane paired end run of instrument, with four samples
+func DefaultSpec(instrument *Instrument) *Spec {
+	ret := &Spec{
+		Instrument:      instrument,
+		InstrumentId:    instrument.IdPrefix + "00123",
+		RunNumber:       1,
+		Date:            time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC),
+		FlowcellBarcode: instrument.BarcodeOf("SYNTH"),
+		ReadStructure:   "Y51;I8;I8;Y51",
+		Lanes:           2,
+		Surfaces:        2,
+		Swaths:          2,
+		TilesPerSwath:   4,
+		Undetermined:    0.05,
+		Density:         Distribution{instrument.Density, instrument.Density / 20},
+		PctPF:           Distribution{85, 2},
+		Phasing:         Distribution{0.1, 0.01},
+		Prephasing:      Distribution{0.05, 0.005},
+		PctAligned:      Distribution{1, 0.1},
+		ErrorRate:       Distribution{0.3, 0.03},
+		PctQ30:          Distribution{92, 1},
+		Intensity:       Distribution{1000, 50},
+		IntensityDecay:  0.002,
+		CycleNoise:      0.05,
+		Seed:            1,
+	}
+	for i := 1; i <= 4; i++ {
+		ret.Samples = append(ret.Samples, &Sample{Id: fmt.Sprintf("Sample%d", i), Project: "Synthetic"})
+	}
+	return ret
+}
+
+//RunId yymmdd_instrument_run_flowcell, the flowcell prefixed by the side on instruments with two
+func (self *Spec) RunId() string {
+	return fmt.Sprintf("%s_%s_%04d_%s%s", self.Date.Format("060102"), self.InstrumentId, self.RunNumber, self.Instrument.Side, self.FlowcellBarcode)
+}
+
+//Tiles tile numbers of a lane in RunInfo.xml order
+func (self *Spec) Tiles() []int {
+	ret := []int{}
+	for surface := 1; surface <= self.Surfaces; surface++ {
+		for swath := 1; swath <= self.Swaths; swath++ {
+			for t := 1; t <= self.TilesPerSwath; t++ {
+				ret = append(ret, surface*1000+swath*100+t)
+			}
+		}
+	}
+	return ret
+}
+
+func (self *Spec) Validate() error {
+	if self.Instrument == nil {
+		return fmt.Errorf("no instrument")
+	}
+	if self.Lanes < 1 || self.Surfaces < 1 || self.Swaths < 1 || self.TilesPerSwath < 1 {
+		return fmt.Errorf("empty tile layout %d lanes %d surfaces %d swaths %d tiles", self.Lanes, self.Surfaces, self.Swaths, self.TilesPerSwath)
+	}
+	if self.Surfaces > 2 || self.Swaths > 9 || self.TilesPerSwath > 99 {
+		return fmt.Errorf("tile layout %d surfaces %d swaths %d tiles does not fit four digit tile numbers", self.Surfaces, self.Swaths, self.TilesPerSwath)
+	}
+	rs, err := fcinfo.ParseOverrideCycles(self.ReadStructure)
+	if err != nil {
+		return err
+	}
+	if len(rs.Reads) > 4 {
+		return fmt.Errorf("%s has %d reads, tile metrics codes take 4", self.ReadStructure, len(rs.Reads))
+	}
+	if self.Undetermined < 0 || self.Undetermined > 1 {
+		return fmt.Errorf("undetermined fraction %f out of [0,1]", self.Undetermined)
+	}
+	for _, a := range self.Anomalies {
+		switch a.Kind {
+		case ANOMALY_BUBBLE, ANOMALY_BAD_TILE, ANOMALY_INTENSITY_DROP, ANOMALY_MISSING_TILE, ANOMALY_TILE_DROPOUT:
+		default:
+			return fmt.Errorf("unknown anomaly %q", a.Kind)
+		}
+		if a.LaneNum < 0 || a.LaneNum > self.Lanes {
+			return fmt.Errorf("%s in lane %d of %d", a.Kind, a.LaneNum, self.Lanes)
+		}
+	}
+	return nil
+}
+
+//Files run folder content by slash separated name, ready for fcinfo.MapFS
+func (self *Spec) Files() (map[string][]byte, error) {
+	if err := self.Validate(); err != nil {
+		return nil, err
+	}
+	rs, _ := fcinfo.ParseOverrideCycles(self.ReadStructure)
+	g := &generator{spec: self, rs: rs, rng: rand.New(rand.NewSource(self.Seed))}
+	g.fillSamples()
+
+	ret := map[string][]byte{}
+	runInfo, err := fcinfo.MarshalRunInfoXML(g.runInfo())
+	if err != nil {
+		return nil, fmt.Errorf("marshal RunInfo.xml err:%s", err.Error())
+	}
+	ret["RunInfo.xml"] = []byte(runInfo)
+	ret["RunParameters.xml"] = []byte(self.Instrument.RunParameters(self, g.runParams()))
+	ret["SampleSheet.csv"] = []byte(g.sampleSheet())
+	ret[fcinfo.RTA_COMPLETE_FILE] = []byte(fmt.Sprintf("RTA %s completed on %s\r\n", self.Instrument.RTAVersion, self.Date.Add(24*time.Hour).Format("1/2/2006 3:04:05 PM")))
+
+	g.draw()
+	for name, b := range g.interOp() {
+		ret["InterOp/"+name] = b
+	}
+	return ret, nil
+}
+
+//Generate write the run folder under dir, named by its run id; Data/ gets an empty lane folder per lane
+func Generate(dir string, spec *Spec) (string, error) {
+	files, err := spec.Files()
+	if err != nil {
+		return "", err
+	}
+	runFolder := filepath.Join(dir, spec.RunId())
+	for lane := 1; lane <= spec.Lanes; lane++ {
+		if err := os.MkdirAll(filepath.Join(runFolder, "Data", "Intensities", "BaseCalls", fmt.Sprintf("L%03d", lane)), 0755); err != nil {
+			return "", err
+		}
+	}
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		filename := filepath.Join(runFolder, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return "", err
+		}
+		if err := ioutil.WriteFile(filename, files[name], 0644); err != nil {
+			return "", fmt.Errorf("write %s err:%s", name, err.Error())
+		}
+	}
+	return runFolder, nil
+}
+
+//tileDraw per tile values drawn from the Spec, anomalies applied
+type tileDraw struct {
+	laneNum    int
+	tileNum    int
+	clusters   float64
+	clustersPF float64
+	pctQ30     float64
+	errorRate  float64
+	intensity  float64
+	phasing    []float64 //per read, fraction
+	prephasing []float64
+	pctAligned []float64 //per read, 0 for index reads
+	lastCycle  int       //last cycle per cycle files report
+}
+
+type generator struct {
+	spec    *Spec
+	rs      *fcinfo.ReadStructure
+	rng     *rand.Rand
+	samples []*Sample //the Spec's, indexes filled in
+	tiles   []*tileDraw
+}
+
+func (self *generator) anomalies(kind string, laneNum, tileNum int) []*Anomaly {
+	ret := []*Anomaly{}
+	for _, a := range self.spec.Anomalies {
+		if a.Kind == kind && a.hits(laneNum, tileNum) {
+			ret = append(ret, a)
+		}
+	}
+	return ret
+}
+
+func (self *generator) runInfo() *fcinfo.RunInfo {
+	spec := self.spec
+	ret := &fcinfo.RunInfo{Version: spec.Instrument.RunInfoVersion}
+	ret.Run.RunId = spec.RunId()
+	ret.Run.RunNumber = fmt.Sprint(spec.RunNumber)
+	ret.Run.FlowcellBarcode = spec.FlowcellBarcode
+	ret.Run.Instrument = spec.InstrumentId
+	ret.Run.Date = spec.Date.Format(spec.Instrument.DateLayout)
+	ret.Run.Reads = self.rs.RunInfoReads()
+	tileSet := &fcinfo.RunInfoTileSet{TileNamingConvention: fcinfo.TILE_NAMING_FOUR_DIGIT}
+	for lane := 1; lane <= spec.Lanes; lane++ {
+		for _, t := range spec.Tiles() {
+			tileSet.Tiles = append(tileSet.Tiles, fmt.Sprintf("%d_%d", lane, t))
+		}
+	}
+	ret.Run.FlowcellLayout = fcinfo.RunInfoFlowcellLayout{
+		LaneCount:    spec.Lanes,
+		SurfaceCount: spec.Surfaces,
+		SwathCount:   spec.Swaths,
+		TileCount:    spec.TilesPerSwath,
+		TileSet:      tileSet,
+	}
+	for lane := 1; lane <= spec.Lanes; lane++ {
+		ret.Run.AlignToPhiX = append(ret.Run.AlignToPhiX, lane)
+	}
+	ret.Run.ImageChannels = spec.Instrument.Channels
+	return ret
+}
+
+//RunParams what RunParameters.xml templates fill in
+type RunParams struct {
+	RunId        string
+	Date         string
+	OutputFolder string
+	Reads        []int //planned cycles of Read1, Index1, Index2 and Read2; 0 when the run has no such read
+}
+
+func (self *generator) runParams() *RunParams {
+	ret := &RunParams{
+		RunId:        self.spec.RunId(),
+		Date:         self.spec.Date.Format(time.RFC3339),
+		OutputFolder: `D:\Runs\` + self.spec.RunId(),
+		Reads:        make([]int, 4),
+	}
+	nonIndex, index := 0, 0
+	for _, r := range self.rs.Reads {
+		switch {
+		case r.IsIndex() && index < 2:
+			ret.Reads[1+index] = r.NumCycles()
+			index++
+		case !r.IsIndex() && nonIndex < 2:
+			ret.Reads[3*nonIndex] = r.NumCycles()
+			nonIndex++
+		}
+	}
+	return ret
+}
+
+//indexCycles cycles of the first two index reads
+func (self *generator) indexCycles() []int {
+	ret := []int{}
+	for _, r := range self.rs.Reads {
+		if r.IsIndex() && len(ret) < 2 {
+			ret = append(ret, r.NumCycles())
+		}
+	}
+	return ret
+}
+
+//fillSamples copy the Spec's samples, missing indexes drawn distinct from the others
+func (self *generator) fillSamples() {
+	cycles := self.indexCycles()
+	seen := map[string]bool{}
+	for _, s := range self.spec.Samples {
+		c := *s
+		self.samples = append(self.samples, &c)
+		seen[s.Index+"-"+s.Index2] = true
+	}
+	random := func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = INDEX_BASES[self.rng.Intn(len(INDEX_BASES))]
+		}
+		return string(b)
+	}
+	for _, s := range self.samples {
+		if s.Index != "" || len(cycles) == 0 {
+			continue
+		}
+		for tries := 0; tries < 100; tries++ {
+			s.Index = random(cycles[0])
+			if len(cycles) > 1 && s.Index2 == "" {
+				s.Index2 = random(cycles[1])
+			}
+			if !seen[s.Index+"-"+s.Index2] {
+				break
+			}
+		}
+		seen[s.Index+"-"+s.Index2] = true
+	}
+}
+
+func (self *generator) sampleSheet() string {
+	spec := self.spec
+	lines := []string{
+		"[Header]",
+		"IEMFileVersion,4",
+		"Experiment Name," + spec.RunId(),
+		"Date," + spec.Date.Format("1/2/2006"),
+		"Workflow,GenerateFASTQ",
+		"Instrument Type," + spec.Instrument.Name,
+		"",
+		"[Reads]",
+	}
+	for _, r := range self.rs.Reads {
+		if !r.IsIndex() {
+			lines = append(lines, fmt.Sprint(r.NumCycles()))
+		}
+	}
+	lines = append(lines, "", "[Settings]", "OverrideCycles,"+self.rs.String(), "", "[Data]")
+	header := "Sample_ID,Sample_Name,Sample_Project,index"
+	dual := len(self.indexCycles()) > 1
+	if dual {
+		header += ",index2"
+	}
+	lines = append(lines, header)
+	for _, s := range self.samples {
+		row := strings.Join([]string{s.Id, s.Id, s.Project, s.Index}, ",")
+		if dual {
+			row += "," + s.Index2
+		}
+		lines = append(lines, row)
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+//draw per tile values, lane by lane and tile by tile so a Seed always gives the same run
+func (self *generator) draw() {
+	spec := self.spec
+	area := float64(spec.Instrument.TileArea)
+	cycles := self.rs.NumCycles()
+	for lane := 1; lane <= spec.Lanes; lane++ {
+		for _, tileNum := range spec.Tiles() {
+			t := &tileDraw{laneNum: lane, tileNum: tileNum, lastCycle: cycles}
+			t.clusters = math.Round(spec.Density.draw(self.rng) * 1000 * area)
+			pctPF := math.Min(100, spec.PctPF.draw(self.rng))
+			t.pctQ30 = math.Min(100, spec.PctQ30.draw(self.rng))
+			t.errorRate = spec.ErrorRate.draw(self.rng)
+			t.intensity = spec.Intensity.draw(self.rng)
+			for _, r := range self.rs.Reads {
+				t.phasing = append(t.phasing, spec.Phasing.draw(self.rng)/100)
+				t.prephasing = append(t.prephasing, spec.Prephasing.draw(self.rng)/100)
+				aligned := 0.
+				if !r.IsIndex() {
+					aligned = math.Min(100, spec.PctAligned.draw(self.rng))
+				}
+				t.pctAligned = append(t.pctAligned, aligned)
+			}
+			for _, a := range self.anomalies(ANOMALY_BAD_TILE, lane, tileNum) {
+				s := a.severity()
+				t.clusters = math.Round(t.clusters * s)
+				pctPF *= s
+				t.pctQ30 *= s
+				t.intensity *= s
+				if s > 0 {
+					t.errorRate /= s
+				}
+			}
+			t.clustersPF = math.Round(t.clusters * pctPF / 100)
+			for _, a := range self.anomalies(ANOMALY_TILE_DROPOUT, lane, tileNum) {
+				if a.Cycle > 0 && a.Cycle-1 < t.lastCycle {
+					t.lastCycle = a.Cycle - 1
+				}
+			}
+			if len(self.anomalies(ANOMALY_MISSING_TILE, lane, tileNum)) > 0 {
+				continue
+			}
+			self.tiles = append(self.tiles, t)
+		}
+	}
+}
+
+//noise relative per cycle noise around 1
+func (self *generator) noise() float64 {
+	return math.Max(0, 1+self.spec.CycleNoise*self.rng.NormFloat64())
+}
+
+//cycleErrorRate error rate of t at cycle, bubbles included
+func (self *generator) cycleErrorRate(t *tileDraw, cycle int) float64 {
+	ret := t.errorRate * self.noise()
+	for _, a := range self.anomalies(ANOMALY_BUBBLE, t.laneNum, t.tileNum) {
+		if a.Cycle == cycle {
+			ret *= a.severity()
+		}
+	}
+	return ret
+}
+
+//cycleIntensity brightest channel intensity of t at cycle, decayed and dropped
+func (self *generator) cycleIntensity(t *tileDraw, cycle int) float64 {
+	ret := t.intensity * math.Pow(1-self.spec.IntensityDecay, float64(cycle-1)) * self.noise()
+	for _, a := range self.anomalies(ANOMALY_INTENSITY_DROP, t.laneNum, t.tileNum) {
+		if cycle >= a.Cycle {
+			ret *= a.severity()
+		}
+	}
+	return math.Min(math.MaxUint16, ret)
+}
+
+//indexCounts PF clusters of each sample in t
+func (self *generator) indexCounts(t *tileDraw) []uint32 {
+	total := 0.
+	for _, s := range self.samples {
+		total += s.Share
+	}
+	ret := make([]uint32, len(self.samples))
+	for i, s := range self.samples {
+		share := 1 / float64(len(self.samples))
+		if total > 0 {
+			share = s.Share / total
+		}
+		ret[i] = uint32(math.Round(t.clustersPF * (1 - self.spec.Undetermined) * share))
+	}
+	return ret
+}
@@ -0,0 +1,131 @@
+package synthrun
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/ws6/interop"
+	"github.com/ws6/interop/fcinfo"
+)
+
+func TestGenerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "synthrun")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wantType := map[*Instrument]string{MISEQ: "MiSeq", HISEQ_4000: "HiSeq", NOVASEQ: fcinfo.VOYAGER}
+	for _, inst := range Instruments {
+		spec := DefaultSpec(inst)
+		spec.Anomalies = []*Anomaly{
+			{Kind: ANOMALY_BUBBLE, LaneNum: 1, TileNum: 1101, Cycle: 20},
+			{Kind: ANOMALY_MISSING_TILE, LaneNum: 2, TileNum: 2204},
+			{Kind: ANOMALY_TILE_DROPOUT, LaneNum: 2, TileNum: 1102, Cycle: 70},
+			{Kind: ANOMALY_INTENSITY_DROP, LaneNum: 1, Cycle: 60},
+		}
+		runFolder, err := Generate(dir, spec)
+		if err != nil {
+			t.Fatalf("%s: %s", inst.Name, err)
+		}
+		run, err := interop.LoadRun(runFolder)
+		if err != nil {
+			t.Fatalf("%s: %s", inst.Name, err)
+		}
+		if len(run.ParseErrors) != 0 || run.Tile == nil || run.Q == nil || run.Error == nil || run.Extraction == nil || run.Index == nil {
+			t.Fatalf("%s: parse errors %v", inst.Name, run.ParseErrors)
+		}
+		if run.Tile.Version != inst.TileVersion || run.Q.Version != inst.QVersion || run.Error.Version != inst.ErrorVersion || run.Extraction.Version != inst.ExtractionVersion {
+			t.Fatalf("%s: versions tile %d q %d error %d extraction %d", inst.Name, run.Tile.Version, run.Q.Version, run.Error.Version, run.Extraction.Version)
+		}
+		if run.Flowcell.InstrumentType != wantType[inst] || run.Flowcell.FlowcellBarcode != spec.FlowcellBarcode || run.Name() != spec.RunId() {
+			t.Fatalf("%s: flowcell %+v", inst.Name, run.Flowcell)
+		}
+
+		summary := run.Summary()
+		if summary.CurrentCycle != 118 || len(summary.Reads) != 4 || !summary.Reads[1].IsIndexedRead {
+			t.Fatalf("%s: summary %d cycles %d reads", inst.Name, summary.CurrentCycle, len(summary.Reads))
+		}
+		r1 := summary.Reads[0]
+		lane := r1.GetLane(1)
+		if math.Abs(lane.Density-inst.Density) > inst.Density/10 || math.Abs(lane.PctPF-85) > 2 || math.Abs(r1.PctQ30-92) > 1 ||
+			math.Abs(r1.ErrorRate-0.3) > 0.05 || math.Abs(r1.PctAligned-1) > 0.2 || math.Abs(r1.IntensityC1-1000) > 50 {
+			t.Fatalf("%s: read 1 %+v lane 1 %+v", inst.Name, r1.SummaryTotal, lane)
+		}
+		if r2 := summary.Reads[3].GetLane(2); r2.TileCount != 15 {
+			t.Fatalf("%s: lane 2 read 2 has %d tiles", inst.Name, r2.TileCount)
+		}
+
+		coverage := run.TileCoverage()
+		if coverage.Source != interop.TILE_SOURCE_TILESET || coverage.Expected != 32 || len(coverage.Missing) != 1 || coverage.Missing[0].TileNum != 2204 {
+			t.Fatalf("%s: coverage %+v", inst.Name, coverage)
+		}
+		for _, d := range coverage.Dropouts {
+			if d.LaneNum != 2 || d.TileNum != 1102 || d.LastCycle != 69 {
+				t.Fatalf("%s: dropout %+v", inst.Name, d)
+			}
+		}
+		if len(coverage.Dropouts) != 2 {
+			t.Fatalf("%s: %d dropouts", inst.Name, len(coverage.Dropouts))
+		}
+
+		bubbles := run.Error.BubbleCounter(nil)
+		bubbled := []uint32{}
+		for _, lr := range bubbles.Lanes {
+			for _, surface := range lr.Surfaces {
+				for _, swath := range surface {
+					for _, tile := range swath {
+						if tile.MeanErrorRate > 0 {
+							bubbled = append(bubbled, tile.TileNum)
+						}
+					}
+				}
+			}
+		}
+		if !reflect.DeepEqual(bubbled, []uint32{1101}) {
+			t.Fatalf("%s: bubbled tiles %v", inst.Name, bubbled)
+		}
+
+		//lane 1 loses half its intensity at cycle 60, lane 2 keeps it
+		intensity := map[uint16]map[uint16]float64{1: {}, 2: {}}
+		run.Extraction.EachIntensity(func(laneNum uint16, tileNum uint32, cycle uint16, values []uint16) {
+			if tileNum == 1101 && (cycle == 59 || cycle == 60) {
+				intensity[laneNum][cycle] = float64(values[0])
+			}
+		})
+		if ratio := intensity[1][60] / intensity[1][59]; ratio > 0.7 {
+			t.Fatalf("%s: lane 1 intensity %v", inst.Name, intensity[1])
+		}
+		if ratio := intensity[2][60] / intensity[2][59]; ratio < 0.7 {
+			t.Fatalf("%s: lane 2 intensity %v", inst.Name, intensity[2])
+		}
+
+		index := run.IndexSummary().GetLane(1)
+		if index == nil || len(index.Samples) != 4 || math.Abs(index.PctIdentified-95) > 0.1 || index.CV > 0.01 {
+			t.Fatalf("%s: index %+v", inst.Name, index)
+		}
+	}
+
+	//the same spec renders the same files, in memory as on disk
+	spec := DefaultSpec(NOVASEQ)
+	a, err := spec.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := spec.Files()
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("same spec, different files")
+	}
+	run, err := interop.LoadRunFS(fcinfo.MapFS(a), "synthetic")
+	if err != nil || len(run.ParseErrors) != 0 || len(run.Tile.Metrics3) != 2*16*3 {
+		t.Fatalf("in memory run %v %v", err, run)
+	}
+
+	spec.Anomalies = []*Anomaly{{Kind: "meteor"}}
+	if _, err := spec.Files(); err == nil {
+		t.Fatal("unknown anomaly accepted")
+	}
+}
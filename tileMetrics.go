@@ -3,8 +3,9 @@ package interop
 import (
 	"encoding/binary"
 	"math"
-	"os"
 	"sort"
+
+	"github.com/ws6/interop/fcinfo"
 )
 
 var (
@@ -100,13 +101,14 @@ type TileInfo struct {
 	Metrics  []*TileMetrics
 	Metrics3 []*TileMetrics3
 	err      error
+	fsys     fcinfo.RunFS //nil reads Filename from disk
 }
 
 func (self *TileInfo) Parse() error {
 	if self.err != nil {
 		return self.err
 	}
-	file, err := os.Open(self.Filename)
+	file, err := openMetricFile(self.fsys, self.Filename)
 	if err != nil {
 		self.err = err
 		return self.err
@@ -322,3 +324,37 @@ func (self *TileInfo) GetAlignedStatByLanes(reads, lanes []uint16) (float64, flo
 	}
 	return GetTileStat(&er)
 }
+
+//ClustersByTile return lane->tile->(raw, pf) cluster counts from either code 102/103 or RTA3 't' records
+func (self *TileInfo) ClustersByTile() (raw, pf map[uint16]map[uint32]float64) {
+	raw = make(map[uint16]map[uint32]float64)
+	pf = make(map[uint16]map[uint32]float64)
+	if self == nil {
+		return
+	}
+	put := func(m map[uint16]map[uint32]float64, laneNum uint16, tileNum uint32, v float64) {
+		if _, ok := m[laneNum]; !ok {
+			m[laneNum] = make(map[uint32]float64)
+		}
+		m[laneNum][tileNum] = v
+	}
+	for _, cv := range self.Metrics {
+		if cv.LaneNum == 0 {
+			continue
+		}
+		switch cv.MetricCode {
+		case NUMBER_CLUSTER:
+			put(raw, cv.LaneNum, uint32(cv.TileNum), float64(cv.MetricValue))
+		case NUMBER_CLUSTER_PF:
+			put(pf, cv.LaneNum, uint32(cv.TileNum), float64(cv.MetricValue))
+		}
+	}
+	for _, cv := range self.Metrics3 {
+		if cv.LaneNum == 0 || cv.MetricCode != 't' {
+			continue
+		}
+		put(raw, cv.LaneNum, cv.TileNum, float64(cv.ClusterCount))
+		put(pf, cv.LaneNum, cv.TileNum, float64(cv.PFClusterCount))
+	}
+	return
+}
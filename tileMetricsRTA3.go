@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 
-	"os"
 )
 
 //Format for version 3:
@@ -57,7 +56,7 @@ func (self *TileInfo) ParseRTA3() error {
 	if self.err != nil {
 		return self.err
 	}
-	file, err := os.Open(self.Filename)
+	file, err := openMetricFile(self.fsys, self.Filename)
 	if err != nil {
 		self.err = err
 		return self.err
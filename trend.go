@@ -0,0 +1,419 @@
+package interop
+
+//trend.go per instrument history of run summaries in a JSONL file, with control charts to catch drift
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ws6/interop/fcinfo"
+)
+
+var (
+	//TREND_BASELINE_RUNS first N runs of an instrument set the chart's mean and sigma; 0 uses every run
+	TREND_BASELINE_RUNS = 20
+	//TREND_MIN_RUNS fewer runs than this give no limits
+	TREND_MIN_RUNS = 3
+
+	//moving range chart constants for n=2
+	MR_D2 = 1.128
+	MR_D4 = 3.267
+
+	WESTGARD_1_2S  = "1_2s"  //one point beyond 2 sigma, warning only
+	WESTGARD_1_3S  = "1_3s"  //one point beyond 3 sigma
+	WESTGARD_2_2S  = "2_2s"  //two consecutive points beyond 2 sigma on the same side
+	WESTGARD_R_4S  = "R_4s"  //two consecutive points more than 4 sigma apart
+	WESTGARD_4_1S  = "4_1s"  //four consecutive points beyond 1 sigma on the same side
+	WESTGARD_10_X  = "10_x"  //ten consecutive points on the same side of the mean
+	WESTGARD_MR_UL = "mr_ul" //moving range above its upper limit
+)
+
+//TrendPoint one run of an instrument
+type TrendPoint struct {
+	RunId          string
+	Instrument     string
+	InstrumentType string `json:",omitempty"`
+	RunStartDate   time.Time
+	Metrics        map[string]float64
+}
+
+//NewTrendPoint non-index totals plus lane means of the first read's cluster metrics
+func NewTrendPoint(run *Run) (*TrendPoint, error) {
+	if run.RunInfo == nil {
+		return nil, fmt.Errorf("run %s has no RunInfo", run.Name())
+	}
+	ret := &TrendPoint{
+		RunId:      run.RunInfo.Run.RunId,
+		Instrument: run.RunInfo.Run.Instrument,
+		Metrics:    map[string]float64{},
+	}
+	//Flowcell.RunStartDate is cut to 10 characters, "2/3/2014 10:11:12 AM" ends up "2/3/2014 1"; it only backs up RunInfo.xml's
+	dates := []string{run.RunInfo.Run.Date}
+	if fc := run.Flowcell; fc != nil {
+		if fc.MachineName != "" {
+			ret.Instrument = fc.MachineName
+		}
+		ret.InstrumentType = fc.InstrumentType
+		dates = append(dates, fc.RunStartDate)
+	}
+	if ret.Instrument == "" {
+		return nil, fmt.Errorf("run %s has no instrument name", run.Name())
+	}
+	var err error
+	for _, date := range dates {
+		if ret.RunStartDate, err = fcinfo.ParseRunDate(date); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("run %s err:%s", run.Name(), err.Error())
+	}
+
+	summary := run.Summary()
+	if summary.NonIndexTotal != nil {
+		for _, mv := range summary.NonIndexTotal.Metrics() {
+			ret.Metrics[mv.Name] = mv.Value
+		}
+	}
+	if len(summary.Reads) > 0 {
+		lanes := summary.Reads[0].Lanes
+		for _, name := range []string{SUMMARY_DENSITY, SUMMARY_DENSITY_PF, SUMMARY_PCT_PF, SUMMARY_CLUSTERS_PF} {
+			arr := []float64{}
+			for _, ls := range lanes {
+				if len(ls.TileValues[name]) > 0 {
+					arr = append(arr, ls.Value(name))
+				}
+			}
+			if len(arr) > 0 {
+				mean, _ := SampleStat(arr)
+				ret.Metrics[name] = mean
+			}
+		}
+	}
+	return ret, nil
+}
+
+//TrendStore append only JSONL file; a later line with the same instrument and run id replaces the earlier one
+type TrendStore struct {
+	Filename string
+	points   []*TrendPoint
+}
+
+//OpenTrendStore load the file; a missing file is an empty store
+func OpenTrendStore(filename string) (*TrendStore, error) {
+	ret := &TrendStore{Filename: filename}
+	fh, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return ret, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		p := new(TrendPoint)
+		if err := json.Unmarshal([]byte(line), p); err != nil {
+			return nil, fmt.Errorf("%s line %d err:%s", filename, lineNum, err.Error())
+		}
+		ret.put(p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (self *TrendStore) put(p *TrendPoint) {
+	for i, old := range self.points {
+		if old.Instrument == p.Instrument && old.RunId == p.RunId {
+			self.points[i] = p
+			return
+		}
+	}
+	self.points = append(self.points, p)
+}
+
+//Append write one line and keep it in memory
+func (self *TrendStore) Append(p *TrendPoint) error {
+	if p.Instrument == "" || p.RunId == "" {
+		return fmt.Errorf("trend point needs Instrument and RunId")
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	fh, err := os.OpenFile(self.Filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fh.Write(append(b, '\n')); err != nil {
+		fh.Close()
+		return err
+	}
+	if err := fh.Close(); err != nil {
+		return err
+	}
+	self.put(p)
+	return nil
+}
+
+//AppendRun convenience for NewTrendPoint then Append
+func (self *TrendStore) AppendRun(run *Run) (*TrendPoint, error) {
+	p, err := NewTrendPoint(run)
+	if err != nil {
+		return nil, err
+	}
+	return p, self.Append(p)
+}
+
+func (self *TrendStore) Instruments() []string {
+	seen := map[string]bool{}
+	ret := []string{}
+	for _, p := range self.points {
+		if !seen[p.Instrument] {
+			seen[p.Instrument] = true
+			ret = append(ret, p.Instrument)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+//Points runs of an instrument by start date
+func (self *TrendStore) Points(instrument string) []*TrendPoint {
+	ret := []*TrendPoint{}
+	for _, p := range self.points {
+		if p.Instrument == instrument {
+			ret = append(ret, p)
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].RunStartDate.Before(ret[j].RunStartDate) })
+	return ret
+}
+
+//MetricNames every metric recorded for an instrument
+func (self *TrendStore) MetricNames(instrument string) []string {
+	seen := map[string]bool{}
+	for _, p := range self.Points(instrument) {
+		for name := range p.Metrics {
+			seen[name] = true
+		}
+	}
+	ret := []string{}
+	for _, name := range SummaryMetricNames {
+		if seen[name] {
+			ret = append(ret, name)
+			delete(seen, name)
+		}
+	}
+	rest := []string{}
+	for name := range seen {
+		rest = append(rest, name)
+	}
+	sort.Strings(rest)
+	return append(ret, rest...)
+}
+
+type ControlPoint struct {
+	RunId        string
+	RunStartDate time.Time
+	Value        float64
+	MovingRange  float64 //|value - previous value|; zero for the first point
+	Z            float64 //(value - mean) / sigma
+}
+
+type RuleViolation struct {
+	Rule    string
+	Index   int //into ControlChart.Points, the last point of the pattern
+	RunId   string
+	Warning bool //1_2s only warns
+}
+
+//ControlChart individuals and moving range chart of one metric of one instrument
+type ControlChart struct {
+	Instrument    string
+	Metric        string
+	BaselineRuns  int
+	Mean          float64
+	Sigma         float64 //sample stdev of the baseline
+	UCL           float64 //mean + 3 sigma
+	LCL           float64
+	UWL           float64 //mean + 2 sigma
+	LWL           float64
+	MovingRange   float64 //mean moving range of the baseline
+	MRUCL         float64 //D4 * mean moving range
+	MRSigma       float64 //mean moving range / d2, the short term sigma estimate
+	Points        []*ControlPoint
+	Violations    []*RuleViolation
+	InsufficientN bool `json:",omitempty"` //too few runs for limits
+}
+
+//ControlChart build a chart from the store; runs missing the metric are skipped
+func (self *TrendStore) ControlChart(instrument, metric string) (*ControlChart, error) {
+	points := self.Points(instrument)
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no runs for instrument %s", instrument)
+	}
+	ret := &ControlChart{Instrument: instrument, Metric: metric}
+	for _, p := range points {
+		v, ok := p.Metrics[metric]
+		if !ok || math.IsNaN(v) {
+			continue
+		}
+		cp := &ControlPoint{RunId: p.RunId, RunStartDate: p.RunStartDate, Value: v}
+		if n := len(ret.Points); n > 0 {
+			cp.MovingRange = math.Abs(v - ret.Points[n-1].Value)
+		}
+		ret.Points = append(ret.Points, cp)
+	}
+	if len(ret.Points) == 0 {
+		return nil, fmt.Errorf("instrument %s has no %s values", instrument, metric)
+	}
+	ret.compute()
+	return ret, nil
+}
+
+//ControlCharts one chart per recorded metric
+func (self *TrendStore) ControlCharts(instrument string) ([]*ControlChart, error) {
+	ret := []*ControlChart{}
+	for _, name := range self.MetricNames(instrument) {
+		cc, err := self.ControlChart(instrument, name)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, cc)
+	}
+	return ret, nil
+}
+
+func (self *ControlChart) compute() {
+	n := len(self.Points)
+	self.BaselineRuns = n
+	if TREND_BASELINE_RUNS > 0 && TREND_BASELINE_RUNS < n {
+		self.BaselineRuns = TREND_BASELINE_RUNS
+	}
+	if n < TREND_MIN_RUNS {
+		self.InsufficientN = true
+		return
+	}
+	baseline := []float64{}
+	mrSum := 0.
+	for i, p := range self.Points[:self.BaselineRuns] {
+		baseline = append(baseline, p.Value)
+		if i > 0 {
+			mrSum += p.MovingRange
+		}
+	}
+	mean, variance := SampleStat(baseline)
+	self.Mean = mean
+	self.Sigma = math.Sqrt(variance)
+	self.UCL, self.LCL = mean+3*self.Sigma, mean-3*self.Sigma
+	self.UWL, self.LWL = mean+2*self.Sigma, mean-2*self.Sigma
+	if self.BaselineRuns > 1 {
+		self.MovingRange = mrSum / float64(self.BaselineRuns-1)
+		self.MRUCL = MR_D4 * self.MovingRange
+		self.MRSigma = self.MovingRange / MR_D2
+	}
+	for _, p := range self.Points {
+		if self.Sigma > 0 {
+			p.Z = (p.Value - mean) / self.Sigma
+		}
+	}
+	self.Violations = WestgardRules(self.Points, self.MRUCL)
+}
+
+func signOf(z float64) int {
+	if z > 0 {
+		return 1
+	}
+	if z < 0 {
+		return -1
+	}
+	return 0
+}
+
+//sameSideBeyond the last n points up to i all beyond limit sigma on one side
+func sameSideBeyond(points []*ControlPoint, i, n int, limit float64) bool {
+	if i+1 < n {
+		return false
+	}
+	side := signOf(points[i].Z)
+	if side == 0 {
+		return false
+	}
+	for j := i - n + 1; j <= i; j++ {
+		if signOf(points[j].Z) != side || math.Abs(points[j].Z) <= limit {
+			return false
+		}
+	}
+	return true
+}
+
+//WestgardRules evaluate rules on z-scored points; each point reports the strictest rejection it completes plus 1_2s warnings.
+//mrUCL <= 0 skips the moving range check.
+func WestgardRules(points []*ControlPoint, mrUCL float64) []*RuleViolation {
+	ret := []*RuleViolation{}
+	add := func(rule string, i int, warning bool) {
+		ret = append(ret, &RuleViolation{Rule: rule, Index: i, RunId: points[i].RunId, Warning: warning})
+	}
+	for i, p := range points {
+		z := math.Abs(p.Z)
+		rejected := true
+		switch {
+		case z > 3:
+			add(WESTGARD_1_3S, i, false)
+		case sameSideBeyond(points, i, 2, 2):
+			add(WESTGARD_2_2S, i, false)
+		case i > 0 && math.Abs(p.Z-points[i-1].Z) > 4 && signOf(p.Z) != signOf(points[i-1].Z):
+			add(WESTGARD_R_4S, i, false)
+		case sameSideBeyond(points, i, 4, 1):
+			add(WESTGARD_4_1S, i, false)
+		case sameSideBeyond(points, i, 10, 0):
+			add(WESTGARD_10_X, i, false)
+		default:
+			rejected = false
+		}
+		if !rejected && z > 2 {
+			add(WESTGARD_1_2S, i, true)
+		}
+		if mrUCL > 0 && i > 0 && p.MovingRange > mrUCL {
+			add(WESTGARD_MR_UL, i, false)
+		}
+	}
+	return ret
+}
+
+//Rejections violations that are not warnings
+func (self *ControlChart) Rejections() []*RuleViolation {
+	ret := []*RuleViolation{}
+	for _, v := range self.Violations {
+		if !v.Warning {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+//Drifting the latest run broke a rejection rule
+func (self *ControlChart) Drifting() bool {
+	for _, v := range self.Rejections() {
+		if v.Index == len(self.Points)-1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package interop
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ws6/interop/fcinfo"
+)
+
+func TestTrendStoreControlChart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "trend.jsonl")
+
+	store, err := OpenTrendStore(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 24; i++ {
+		q30 := 92 + 0.5*math.Sin(float64(i))
+		if i >= 20 {
+			q30 = 89 //drifted well below the baseline
+		}
+		p := &TrendPoint{
+			RunId:        fmt.Sprintf("run%02d", i),
+			Instrument:   "SN1",
+			RunStartDate: start.AddDate(0, 0, 7*i),
+			Metrics:      map[string]float64{SUMMARY_PCT_Q30: q30},
+		}
+		if err := store.Append(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err = OpenTrendStore(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(store.Points("SN1")); got != 24 {
+		t.Fatalf("expect 24 runs after reopen, got %d", got)
+	}
+	cc, err := store.ControlChart("SN1", SUMMARY_PCT_Q30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cc.BaselineRuns != 20 || math.Abs(cc.Mean-92) > 0.5 {
+		t.Fatalf("unexpected baseline n=%d mean=%f", cc.BaselineRuns, cc.Mean)
+	}
+	for _, v := range cc.Rejections() {
+		if v.Index < 20 {
+			t.Fatalf("baseline run %s rejected by %s", v.RunId, v.Rule)
+		}
+	}
+	if !cc.Drifting() {
+		t.Fatalf("expect drift, violations %d", len(cc.Violations))
+	}
+}
+
+func TestNewTrendPointRunDate(t *testing.T) {
+	runInfo, err := fcinfo.ParseRunInfoXML(testRunInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runInfo.Run.Date = "2/3/2014 10:11:12 AM"
+	//as ParseFlowcellRunFolder leaves it
+	run := &Run{RunFolder: "run", RunInfo: runInfo, Flowcell: &fcinfo.Flowcell{MachineName: "SN1", RunStartDate: "2/3/2014 1"}}
+	p, err := NewTrendPoint(run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2014, 2, 3, 10, 11, 12, 0, time.UTC); !p.RunStartDate.Equal(want) || p.Instrument != "SN1" {
+		t.Fatalf("trend point %s %v, expect %v", p.Instrument, p.RunStartDate, want)
+	}
+}
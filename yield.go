@@ -0,0 +1,228 @@
+package interop
+
+//yield.go bases per lane, read and sample: PF clusters x cycles, actual so far and projected to the end of the run
+
+import (
+	"fmt"
+	"sort"
+)
+
+var (
+	BASES_PER_GB = 1e9
+)
+
+//YieldTotal bases sequenced so far and expected at the end of the run
+type YieldTotal struct {
+	ActualBases    float64
+	ProjectedBases float64
+}
+
+func (self *YieldTotal) add(other YieldTotal) {
+	self.ActualBases += other.ActualBases
+	self.ProjectedBases += other.ProjectedBases
+}
+
+func (self YieldTotal) ActualGb() float64 {
+	return self.ActualBases / BASES_PER_GB
+}
+
+func (self YieldTotal) ProjectedGb() float64 {
+	return self.ProjectedBases / BASES_PER_GB
+}
+
+type ReadYield struct {
+	ReadNum         int
+	IsIndexedRead   bool
+	PlannedCycles   int
+	CompletedCycles int
+	YieldTotal
+}
+
+type LaneYield struct {
+	LaneNum    uint16
+	ClustersPF float64
+	Reads      []*ReadYield
+	NonIndex   YieldTotal
+	Index      YieldTotal
+}
+
+//SampleYield non-index bases of a sample in one lane, split by its share of the lane's PF clusters
+type SampleYield struct {
+	RunId       string
+	Flowcell    string
+	LaneNum     uint16
+	SampleId    string //SampleName of IndexMetrics, which RTA fills from the sample sheet's Sample_ID
+	IndexName   string
+	ProjectName string
+	Clusters    uint64  //PF clusters assigned to the sample
+	Fraction    float64 //of the lane's PF clusters; of identified clusters when TileMetrics is missing
+	NonIndex    YieldTotal
+}
+
+type RunYield struct {
+	RunId         string
+	Flowcell      string
+	CurrentCycle  int
+	PlannedCycles int
+	Lanes         []*LaneYield
+	Samples       []*SampleYield
+	NonIndex      YieldTotal
+	Index         YieldTotal
+}
+
+func (self *RunYield) GetLane(laneNum uint16) *LaneYield {
+	for _, l := range self.Lanes {
+		if l.LaneNum == laneNum {
+			return l
+		}
+	}
+	return nil
+}
+
+//Total index and non-index bases
+func (self *RunYield) Total() YieldTotal {
+	ret := self.NonIndex
+	ret.add(self.Index)
+	return ret
+}
+
+//Yield PF clusters from TileMetrics times the cycles of every read in RunInfo
+func (self *Run) Yield() (*RunYield, error) {
+	if self.RunInfo == nil {
+		return nil, fmt.Errorf("run %s has no RunInfo", self.Name())
+	}
+	if self.Tile == nil {
+		return nil, fmt.Errorf("run %s has no TileMetrics", self.Name())
+	}
+	ret := &RunYield{
+		RunId:         self.Name(),
+		Flowcell:      self.RunInfo.Run.FlowcellBarcode,
+		CurrentCycle:  self.CurrentCycle(),
+		PlannedCycles: self.RunInfo.GetNumCycles(),
+	}
+	_, pf := self.Tile.ClustersByTile()
+	laneNums := []uint16{}
+	for ln := range pf {
+		if ln > 0 {
+			laneNums = append(laneNums, ln)
+		}
+	}
+	sortUint16s(laneNums)
+	for _, ln := range laneNums {
+		ly := &LaneYield{LaneNum: ln}
+		for _, v := range pf[ln] {
+			ly.ClustersPF += v
+		}
+		for i, r := range self.RunInfo.Run.Reads {
+			firstLast := self.RunInfo.GetFirstLastCyclesByRead(i + 1)
+			ry := &ReadYield{
+				ReadNum:       i + 1,
+				IsIndexedRead: r.IsIndexedRead == "Y",
+				PlannedCycles: int(firstLast[1]) - int(firstLast[0]) + 1,
+			}
+			if ret.CurrentCycle >= int(firstLast[0]) {
+				ry.CompletedCycles = ret.CurrentCycle - int(firstLast[0]) + 1
+				if ry.CompletedCycles > ry.PlannedCycles {
+					ry.CompletedCycles = ry.PlannedCycles
+				}
+			}
+			ry.ActualBases = ly.ClustersPF * float64(ry.CompletedCycles)
+			ry.ProjectedBases = ly.ClustersPF * float64(ry.PlannedCycles)
+			ly.Reads = append(ly.Reads, ry)
+			if ry.IsIndexedRead {
+				ly.Index.add(ry.YieldTotal)
+			} else {
+				ly.NonIndex.add(ry.YieldTotal)
+			}
+		}
+		ret.NonIndex.add(ly.NonIndex)
+		ret.Index.add(ly.Index)
+		ret.Lanes = append(ret.Lanes, ly)
+	}
+
+	for _, ls := range self.IndexSummary().Lanes {
+		ly := ret.GetLane(ls.LaneNum)
+		if ly == nil {
+			continue
+		}
+		for _, s := range ls.Samples {
+			sy := &SampleYield{
+				RunId:       ret.RunId,
+				Flowcell:    ret.Flowcell,
+				LaneNum:     ls.LaneNum,
+				SampleId:    s.SampleName,
+				IndexName:   s.IndexName,
+				ProjectName: s.ProjectName,
+				Clusters:    s.Clusters,
+				Fraction:    s.PctOfLanePF / 100.,
+			}
+			if ls.TotalPFClusters == 0 {
+				sy.Fraction = s.PctOfIdentified / 100.
+			}
+			sy.NonIndex.ActualBases = sy.Fraction * ly.NonIndex.ActualBases
+			sy.NonIndex.ProjectedBases = sy.Fraction * ly.NonIndex.ProjectedBases
+			ret.Samples = append(ret.Samples, sy)
+		}
+	}
+	return ret, nil
+}
+
+//SampleRollup one Sample_ID over every lane and flowcell it was sequenced on
+type SampleRollup struct {
+	SampleId    string
+	ProjectName string
+	Flowcells   []string
+	Lanes       int
+	Clusters    uint64
+	NonIndex    YieldTotal
+}
+
+//Shortfall bases still missing to reach targetBases once projected yield lands; zero when the target is met
+func (self *SampleRollup) Shortfall(targetBases float64) float64 {
+	if self.NonIndex.ProjectedBases >= targetBases {
+		return 0
+	}
+	return targetBases - self.NonIndex.ProjectedBases
+}
+
+//RollupSampleYield sum per sample yields of several runs by Sample_ID
+func RollupSampleYield(runs []*RunYield) []*SampleRollup {
+	index := map[string]*SampleRollup{}
+	ret := []*SampleRollup{}
+	for _, ry := range runs {
+		for _, sy := range ry.Samples {
+			sr, ok := index[sy.SampleId]
+			if !ok {
+				sr = &SampleRollup{SampleId: sy.SampleId, ProjectName: sy.ProjectName}
+				index[sy.SampleId] = sr
+				ret = append(ret, sr)
+			}
+			found := false
+			for _, fc := range sr.Flowcells {
+				if fc == sy.Flowcell {
+					found = true
+				}
+			}
+			if !found {
+				sr.Flowcells = append(sr.Flowcells, sy.Flowcell)
+			}
+			sr.Lanes++
+			sr.Clusters += sy.Clusters
+			sr.NonIndex.add(sy.NonIndex)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].SampleId < ret[j].SampleId })
+	return ret
+}
+
+//TopUpList samples projected to fall short of targetBases, largest shortfall first
+func TopUpList(rollups []*SampleRollup, targetBases float64) []*SampleRollup {
+	ret := []*SampleRollup{}
+	for _, sr := range rollups {
+		if sr.Shortfall(targetBases) > 0 {
+			ret = append(ret, sr)
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Shortfall(targetBases) > ret[j].Shortfall(targetBases) })
+	return ret
+}
@@ -0,0 +1,69 @@
+package interop
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ws6/interop/fcinfo"
+)
+
+func TestRunYield(t *testing.T) {
+	runInfo, err := fcinfo.ParseRunInfoXML(testRunInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tileInfo := &TileInfo{Filename: `test_data/InterOp/TileMetricsOut.bin`}
+	if err := tileInfo.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	indexInfo := &IndexInfo{Filename: `test_data/InterOp/IndexMetricsOut.bin`}
+	if err := indexInfo.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	run := &Run{RunFolder: "A", RunInfo: runInfo, Tile: tileInfo, Index: indexInfo, Q: makeDecayQMetrics(runInfo).TruncateAtCycle(30)}
+
+	ry, err := run.Yield()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ry.CurrentCycle != 30 || len(ry.Lanes) != 8 {
+		t.Fatalf("expect 8 lanes at cycle 30, got %d lanes at %d", len(ry.Lanes), ry.CurrentCycle)
+	}
+	for _, ly := range ry.Lanes {
+		if ly.NonIndex.ActualBases != 30*ly.ClustersPF || ly.NonIndex.ProjectedBases != 120*ly.ClustersPF {
+			t.Fatalf("lane %d: unexpected non-index yield %+v for %f clusters", ly.LaneNum, ly.NonIndex, ly.ClustersPF)
+		}
+		if ly.Index.ActualBases != 0 || ly.Index.ProjectedBases != 6*ly.ClustersPF {
+			t.Fatalf("lane %d: unexpected index yield %+v", ly.LaneNum, ly.Index)
+		}
+	}
+	if len(ry.Samples) == 0 {
+		t.Fatal("expect per sample yields")
+	}
+
+	ry2 := *ry
+	ry2.Flowcell = "OTHER"
+	ry2.Samples = nil
+	for _, sy := range ry.Samples {
+		copied := *sy
+		copied.Flowcell = ry2.Flowcell
+		ry2.Samples = append(ry2.Samples, &copied)
+	}
+	rollups := RollupSampleYield([]*RunYield{ry, &ry2})
+	for _, sr := range rollups {
+		if len(sr.Flowcells) != 2 {
+			t.Fatalf("sample %s: expect 2 flowcells, got %v", sr.SampleId, sr.Flowcells)
+		}
+	}
+	sum := 0.
+	for _, sr := range rollups {
+		sum += sr.NonIndex.ProjectedBases
+	}
+	expect := 0.
+	for _, sy := range ry.Samples {
+		expect += 2 * sy.NonIndex.ProjectedBases
+	}
+	if math.Abs(sum-expect) > 1e-6*expect {
+		t.Fatalf("rollup projected %f, expect %f", sum, expect)
+	}
+}